@@ -0,0 +1,150 @@
+// Package main is a stand-in token issuer for development and tests.
+//
+// It mints JWTs the server accepts without a real identity provider:
+//
+//	# HS256, verified with AUTH_HS256_SECRET
+//	go run ./cmd/devtoken -secret dev-secret -sub alice
+//
+//	# RS256, verified with AUTH_JWKS_FILE (key and JWKS are created if missing)
+//	go run ./cmd/devtoken -key dev-key.pem -jwks dev-jwks.json -sub alice
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+func main() {
+	secret := flag.String("secret", os.Getenv("AUTH_HS256_SECRET"), "HS256 secret")
+	keyFile := flag.String("key", "", "RSA private key (PEM) for RS256; created if missing")
+	jwksFile := flag.String("jwks", "", "write the key's JWKS here (with -key)")
+	kid := flag.String("kid", "dev", "key ID (RS256)")
+	subject := flag.String("sub", "dev-user", "subject (user ID)")
+	issuer := flag.String("iss", os.Getenv("AUTH_ISSUER"), "issuer")
+	audience := flag.String("aud", os.Getenv("AUTH_AUDIENCE"), "audience")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime")
+	flag.Parse()
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"sub": *subject,
+		"iat": now.Unix(),
+		"exp": now.Add(*ttl).Unix(),
+	}
+	if *issuer != "" {
+		claims["iss"] = *issuer
+	}
+	if *audience != "" {
+		claims["aud"] = *audience
+	}
+
+	var token string
+	var err error
+	switch {
+	case *keyFile != "":
+		var key *rsa.PrivateKey
+		key, err = loadOrCreateKey(*keyFile)
+		if err == nil && *jwksFile != "" {
+			err = writeJWKS(*jwksFile, *kid, &key.PublicKey)
+		}
+		if err == nil {
+			token, err = signRS256(key, *kid, claims)
+		}
+	case *secret != "":
+		token, err = signHS256([]byte(*secret), claims)
+	default:
+		err = errors.New("set -secret (HS256) or -key (RS256)")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "devtoken:", err)
+		os.Exit(1)
+	}
+	fmt.Println(token)
+}
+
+func signHS256(secret []byte, claims map[string]interface{}) (string, error) {
+	signing, err := signingInput(map[string]string{"alg": "HS256", "typ": "JWT"}, claims)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signing))
+	return signing + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims map[string]interface{}) (string, error) {
+	signing, err := signingInput(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}, claims)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(signing))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func signingInput(header map[string]string, claims map[string]interface{}) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c), nil
+}
+
+func loadOrCreateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		return key, os.WriteFile(path, pem.EncodeToMemory(block), 0o600)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func writeJWKS(path, kid string, key *rsa.PublicKey) error {
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	data, err := json.MarshalIndent(jwks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
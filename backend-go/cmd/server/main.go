@@ -15,11 +15,14 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/swiss-railway/backend-go/internal/auth"
+	"github.com/swiss-railway/backend-go/internal/broker"
 	"github.com/swiss-railway/backend-go/internal/config"
 	"github.com/swiss-railway/backend-go/internal/handlers"
 	"github.com/swiss-railway/backend-go/internal/middleware"
 	"github.com/swiss-railway/backend-go/internal/models"
 	"github.com/swiss-railway/backend-go/internal/services"
+	"github.com/swiss-railway/backend-go/internal/store"
 	"github.com/swiss-railway/backend-go/internal/websocket"
 )
 
@@ -34,44 +37,189 @@ func main() {
 
 	// Initialize services
 	gtfsService := services.NewGTFSService(cfg.GTFSDataPath)
-	swissService := services.NewSwissTransportService(cfg.SwissTransportAPIURL)
+	transport, err := services.NewTransportProvider(
+		cfg.TransportProvider,
+		cfg.TransportFallback,
+		cfg.SwissTransportAPIURL,
+		cfg.SearchChAPIURL,
+	)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid transport provider configuration")
+	}
+	log.Info().Str("provider", transport.Name()).Msg("Upstream transport provider configured")
 
 	// Load GTFS data
 	if err := gtfsService.LoadData(); err != nil {
 		log.Fatal().Err(err).Msg("Failed to load GTFS data")
 	}
 
+	// Background stationboard poller (feeds handlers and the hub from cache).
+	// Upstream journeys are matched to GTFS trips for stable train IDs.
+	resolver := services.NewTrainIdentityResolver(gtfsService)
+	poller := services.NewStationBoardPoller(transport, resolver, cfg.StationWatchList, cfg.PollerDailyBudget, cfg.PollerBoardLimit)
+
 	// Initialize handlers
 	healthHandler := handlers.NewHealthHandler(gtfsService)
-	stationsHandler := handlers.NewStationsHandler(gtfsService, swissService, cfg.EnableSwissAPI)
-	trainsHandler := handlers.NewTrainsHandler(gtfsService, swissService, cfg.EnableSwissAPI)
-	favoritesHandler := handlers.NewFavoritesHandler(gtfsService)
+	stationsHandler := handlers.NewStationsHandler(gtfsService, transport, poller, cfg.EnableSwissAPI)
+	trainsHandler := handlers.NewTrainsHandler(gtfsService, transport, poller, cfg.EnableSwissAPI)
+
+	// Callers are identified by JWT or device ID; favorites are scoped to them
+	authenticator, err := auth.New(auth.Config{
+		HS256Secret: cfg.AuthHS256Secret,
+		JWKSFile:    cfg.AuthJWKSFile,
+		Issuer:      cfg.AuthIssuer,
+		Audience:    cfg.AuthAudience,
+		Anonymous:   cfg.AuthAnonymous,
+		Admins:      cfg.AuthAdmins,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid authentication configuration")
+	}
+	if !authenticator.Tokens() && !authenticator.Anonymous() {
+		log.Warn().Msg("No token verification configured and device IDs disabled: favorites are unavailable")
+	}
+	log.Info().Bool("tokens", authenticator.Tokens()).Bool("anonymous", authenticator.Anonymous()).Msg("Authentication configured")
+
+	// Favorites are kept in memory or in an embedded database file
+	favoritesRepo, err := store.New(store.Config{
+		Type: cfg.FavoritesStore,
+		Path: cfg.FavoritesDBPath,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open favorites store")
+	}
+	defer favoritesRepo.Close()
+	log.Info().Str("store", cfg.FavoritesStore).Msg("Favorites store opened")
+	favoritesHandler := handlers.NewFavoritesHandler(gtfsService, transport, favoritesRepo, cfg.EnableSwissAPI)
+	favoritesHandler.SetReplayTTL(time.Duration(cfg.IdempotencyTTL) * time.Second)
+	favoritesHandler.SetAuditRetention(time.Duration(cfg.AuditRetention) * 24 * time.Hour)
+
+	// Train events detected from live snapshots, served over REST, WebSocket and SSE
+	eventLog := services.NewEventLog(cfg.EventLogSize)
+	eventsHandler := handlers.NewEventsHandler(eventLog)
+
+	// Live updates are computed by the leader and fanned out to every
+	// instance through the broker
+	liveBroker, err := broker.New(broker.Config{
+		Type:  cfg.BrokerType,
+		Role:  cfg.BrokerRole,
+		Addr:  cfg.BrokerAddr,
+		Token: cfg.BrokerToken,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid broker configuration")
+	}
+	defer liveBroker.Close()
+	log.Info().Str("broker", cfg.BrokerType).Bool("leader", liveBroker.Leader()).Msg("Live update broker configured")
 
 	// Initialize WebSocket hub
-	wsHub := websocket.NewHub(gtfsService, cfg.WSUpdateInterval)
+	wsHub := websocket.NewHub(gtfsService, cfg.WSUpdateInterval, cfg.WSResumeWindow, cfg.WSReplayBuffer)
+	wsHub.SetBroker(liveBroker)
+	wsHub.SetCompression(cfg.WSCompression)
+	wsHub.SetSimulationLimits(cfg.WSMaxSimulations, float64(cfg.WSSimMaxSpeed))
+	wsHub.SetEventLog(eventLog)
+	wsHub.SetFavoriteTrains(favoritesHandler.FavoriteTrains, cfg.WSArrivingMinutes)
+	wsHub.AttachStationBoards(poller)
+
+	// Lookups and favorites over the socket use the same handlers as REST
+	for name, method := range handlers.RPCMethods(stationsHandler, trainsHandler, favoritesHandler) {
+		wsHub.RegisterMethod(name, method)
+	}
 	go wsHub.Run()
 	defer wsHub.Stop()
 
+	// Replicas receive stationboards from the leader instead of polling
+	if cfg.EnableSwissAPI && liveBroker.Leader() {
+		poller.SetPriorityFunc(func() []string {
+			return append(favoritesHandler.FavoriteStationIDs(), wsHub.FavoriteTrainStations()...)
+		})
+		go poller.Run()
+		defer poller.Stop()
+	}
+
+	// Alert rules on favorites are evaluated by the leader and delivered
+	// to webhooks
+	var webhooks *services.WebhookDispatcher
+	if cfg.AlertsEnabled {
+		allowedNetworks, err := services.ParseNetworks(cfg.WebhookAllowedNets)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid WEBHOOK_ALLOWED_NETWORKS")
+		}
+		webhooks = services.NewWebhookDispatcher(services.WebhookConfig{
+			MaxAttempts:     cfg.WebhookMaxAttempts,
+			RetryBase:       time.Duration(cfg.WebhookRetryBase) * time.Second,
+			Timeout:         time.Duration(cfg.WebhookTimeout) * time.Second,
+			LogSize:         cfg.WebhookLogSize,
+			DeadLetterSize:  cfg.WebhookDeadLetters,
+			AllowedNetworks: allowedNetworks,
+		})
+		webhooks.Run()
+		defer webhooks.Stop()
+		favoritesHandler.SetAlerts(webhooks, eventLog)
+
+		if liveBroker.Leader() {
+			monitor := services.NewAlertMonitor(favoritesHandler.EvaluateAlerts, webhooks,
+				time.Duration(cfg.AlertInterval)*time.Second)
+			go monitor.Run()
+			defer monitor.Stop()
+		}
+	}
+
+	// Token-bucket rate limits per client, route and API key
+	var rateLimiter *middleware.RateLimiter
+	if cfg.RateLimitEnabled {
+		rateLimiter, err = middleware.NewRateLimiter(middleware.RateLimitConfig{
+			Default:        middleware.RatePolicy{Rate: cfg.RateLimitRate, Burst: cfg.RateLimitBurst},
+			Routes:         cfg.RateLimitRoutes,
+			APIKeys:        cfg.RateLimitAPIKeys,
+			TrustedProxies: cfg.RateLimitTrustedProxies,
+			MaxBuckets:     cfg.RateLimitMaxBuckets,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid rate limit configuration")
+		}
+	}
+
+	// Runtime metrics
+	metricsHandler := handlers.NewMetricsHandler()
+	metricsHandler.Register("websocket", func() interface{} { return wsHub.Metrics() })
+	metricsHandler.Register("poller", func() interface{} { return poller.GetStatus() })
+	metricsHandler.Register("broker", func() interface{} { return liveBroker.Stats() })
+	metricsHandler.Register("events", func() interface{} { return eventLog.Stats() })
+	if webhooks != nil {
+		metricsHandler.Register("webhooks", func() interface{} { return webhooks.Stats() })
+	}
+	if rateLimiter != nil {
+		metricsHandler.Register("ratelimit", func() interface{} { return rateLimiter.Stats() })
+	}
+
 	// Create router
-	router := setupRouter(cfg, healthHandler, stationsHandler, trainsHandler, favoritesHandler, wsHub)
+	router := setupRouter(cfg, healthHandler, stationsHandler, trainsHandler, favoritesHandler, eventsHandler, metricsHandler, wsHub)
 
 	// Setup CORS
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{cfg.FrontendURL, "http://localhost:3000", "http://localhost:3001"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Requested-With"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Requested-With", middleware.DeviceIDHeader, "If-Match", "If-None-Match", handlers.IdempotencyKeyHeader, middleware.RequestIDHeader, middleware.APIKeyHeader},
+		ExposedHeaders:   []string{"ETag", "Idempotent-Replayed", middleware.RequestIDHeader, "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300, // 5 minutes
 	})
 
-	// Apply middleware chain
-	handler := middleware.Chain(
-		router,
+	// Apply middleware chain; requests over their rate limit are turned away
+	// before authentication
+	middlewares := []func(http.Handler) http.Handler{
+		middleware.RequestID,
 		middleware.Recovery,
 		middleware.Logging,
 		middleware.SecurityHeaders,
 		middleware.ContentType,
-	)
+	}
+	if rateLimiter != nil {
+		middlewares = append(middlewares, rateLimiter.Limit)
+	}
+	middlewares = append(middlewares, middleware.Authenticate(authenticator))
+	handler := middleware.Chain(router, middlewares...)
 
 	handler = corsHandler.Handler(handler)
 
@@ -166,6 +314,8 @@ func setupRouter(
 	stationsHandler *handlers.StationsHandler,
 	trainsHandler *handlers.TrainsHandler,
 	favoritesHandler *handlers.FavoritesHandler,
+	eventsHandler *handlers.EventsHandler,
+	metricsHandler *handlers.MetricsHandler,
 	wsHub *websocket.Hub,
 ) *mux.Router {
 	router := mux.NewRouter()
@@ -192,6 +342,7 @@ func setupRouter(
 	router.HandleFunc("/health", healthHandler.Health).Methods("GET")
 	router.HandleFunc("/health/ready", healthHandler.Ready).Methods("GET")
 	router.HandleFunc("/health/live", healthHandler.Live).Methods("GET")
+	router.HandleFunc("/metrics", metricsHandler.Metrics).Methods("GET")
 
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
@@ -202,6 +353,7 @@ func setupRouter(
 	api.HandleFunc("/stations/nearby/{lat}/{lng}", stationsHandler.GetNearbyStations).Methods("GET")
 	api.HandleFunc("/stations/{id}", stationsHandler.GetStation).Methods("GET")
 	api.HandleFunc("/stations/{id}/departures", stationsHandler.GetStationDepartures).Methods("GET")
+	api.HandleFunc("/stations/{id}/live", stationsHandler.GetStationLiveBoard).Methods("GET")
 
 	// Trains routes - Note: specific routes before parametric routes
 	api.HandleFunc("/trains", trainsHandler.GetTrains).Methods("GET")
@@ -209,45 +361,142 @@ func setupRouter(
 	api.HandleFunc("/trains/stats/summary", trainsHandler.GetTrainStats).Methods("GET")
 	api.HandleFunc("/trains/{id}", trainsHandler.GetTrain).Methods("GET")
 
+	// Trips routes
+	api.HandleFunc("/trips/{id}", trainsHandler.GetTrip).Methods("GET")
+
+	// Train events (arrivals, departures, delays, ...)
+	api.HandleFunc("/events", eventsHandler.GetEvents).Methods("GET")
+
 	// ========================================================================
 	// FAVORITES ROUTES - Learning HTTP POST/PUT/DELETE methods
 	// ========================================================================
+	// Lists take ?collection=, ?tag=, ?q=, ?sort= and ?order=. Favorites
+	// carry an ETag (their version) checked against If-Match on PUT, PATCH
+	// and DELETE; POSTs that create take an Idempotency-Key
+	//
 	// STATIONS:
-	// GET    /api/favorites/stations      - List all favorite stations
-	// POST   /api/favorites/stations      - Add station to favorites
-	// GET    /api/favorites/stations/{id} - Get a favorite station
-	// PUT    /api/favorites/stations/{id} - Update favorite station
-	// DELETE /api/favorites/stations/{id} - Remove favorite station
+	// GET    /api/favorites/stations         - List all favorite stations
+	// POST   /api/favorites/stations         - Add station to favorites
+	// POST   /api/favorites/stations/reorder - Reorder favorite stations
+	// GET    /api/favorites/stations/{id}    - Get a favorite station
+	// PUT    /api/favorites/stations/{id}    - Update favorite station
+	// PATCH  /api/favorites/stations/{id}    - Update some fields of a favorite station
+	// DELETE /api/favorites/stations/{id}    - Remove favorite station
 	//
 	// TRAINS (with auto-follow):
-	// GET    /api/favorites/trains        - List all favorite trains
-	// POST   /api/favorites/trains        - Add train to favorites
-	// GET    /api/favorites/trains/{id}   - Get a favorite train
-	// PUT    /api/favorites/trains/{id}   - Update favorite train
-	// DELETE /api/favorites/trains/{id}   - Remove favorite train
+	// GET    /api/favorites/trains           - List all favorite trains
+	// POST   /api/favorites/trains           - Add train to favorites
+	// POST   /api/favorites/trains/reorder   - Reorder favorite trains
+	// GET    /api/favorites/trains/{id}      - Get a favorite train
+	// PUT    /api/favorites/trains/{id}      - Update favorite train
+	// PATCH  /api/favorites/trains/{id}      - Update some fields of a favorite train
+	// DELETE /api/favorites/trains/{id}      - Remove favorite train
+	//
+	// JOURNEYS (origin, destination and preferred time windows):
+	// GET    /api/favorites/journeys           - List all favorite journeys
+	// POST   /api/favorites/journeys           - Add journey to favorites
+	// POST   /api/favorites/journeys/reorder   - Reorder favorite journeys
+	// GET    /api/favorites/journeys/{id}      - Get a favorite journey
+	// PUT    /api/favorites/journeys/{id}      - Update favorite journey
+	// PATCH  /api/favorites/journeys/{id}      - Update some fields of a favorite journey
+	// DELETE /api/favorites/journeys/{id}      - Remove favorite journey
+	// GET    /api/favorites/journeys/{id}/next - Next connections with live delays
+	//
+	// COLLECTIONS (named groups such as "Commute"):
+	// GET    /api/favorites/collections         - List collections
+	// POST   /api/favorites/collections         - Add a collection
+	// POST   /api/favorites/collections/reorder - Reorder collections
+	// GET    /api/favorites/collections/{id}    - Get a collection
+	// PUT    /api/favorites/collections/{id}    - Rename a collection
+	// DELETE /api/favorites/collections/{id}    - Remove a collection (favorites are kept)
+	//
+	// ALERTS (rules on favorite trains and journeys, delivered to webhooks):
+	// GET    /api/favorites/alerts                          - List alert rules
+	// POST   /api/favorites/alerts                          - Add an alert rule
+	// GET    /api/favorites/alerts/deliveries               - Webhook delivery log
+	// GET    /api/favorites/alerts/dead-letters             - Deliveries that gave up
+	// POST   /api/favorites/alerts/dead-letters/{id}/retry  - Redeliver a dead delivery
+	// GET    /api/favorites/alerts/{id}                     - Get an alert rule
+	// PUT    /api/favorites/alerts/{id}                     - Update an alert rule
+	// DELETE /api/favorites/alerts/{id}                     - Remove an alert rule
+	// POST   /api/favorites/alerts/{id}/test                - Send a test delivery
+	//
+	// GET    /api/favorites/dashboard     - Next departures and live state of all favorites
+	// POST   /api/favorites/import        - Import favorites (export file, localStorage or CSV)
+	// GET    /api/favorites/export        - Download all favorites (?format=json|csv)
+	// GET    /api/favorites/{id}/history  - Changes to a favorite, also after it was deleted
+	//
+	// GET    /api/admin/audit             - Audit log of everyone's favorites (administrators)
 	// ========================================================================
 
-	// Legacy routes (backwards compatibility)
-	api.HandleFunc("/favorites", favoritesHandler.GetFavorites).Methods("GET")
-	api.HandleFunc("/favorites", favoritesHandler.CreateFavorite).Methods("POST")
-	api.HandleFunc("/favorites/{id}", favoritesHandler.GetFavorite).Methods("GET")
-	api.HandleFunc("/favorites/{id}", favoritesHandler.UpdateFavorite).Methods("PUT")
-	api.HandleFunc("/favorites/{id}", favoritesHandler.DeleteFavorite).Methods("DELETE")
-
 	// Station favorites (explicit path)
 	api.HandleFunc("/favorites/stations", favoritesHandler.GetFavorites).Methods("GET")
-	api.HandleFunc("/favorites/stations", favoritesHandler.CreateFavorite).Methods("POST")
+	api.HandleFunc("/favorites/stations", favoritesHandler.Idempotent(favoritesHandler.CreateFavorite)).Methods("POST")
+	api.HandleFunc("/favorites/stations/reorder", favoritesHandler.PostReorderFavorites).Methods("POST")
 	api.HandleFunc("/favorites/stations/{id}", favoritesHandler.GetFavorite).Methods("GET")
 	api.HandleFunc("/favorites/stations/{id}", favoritesHandler.UpdateFavorite).Methods("PUT")
+	api.HandleFunc("/favorites/stations/{id}", favoritesHandler.PatchFavorite).Methods("PATCH")
 	api.HandleFunc("/favorites/stations/{id}", favoritesHandler.DeleteFavorite).Methods("DELETE")
 
 	// Train favorites (with auto-follow feature)
 	api.HandleFunc("/favorites/trains", favoritesHandler.GetFavoriteTrains).Methods("GET")
-	api.HandleFunc("/favorites/trains", favoritesHandler.CreateFavoriteTrain).Methods("POST")
+	api.HandleFunc("/favorites/trains", favoritesHandler.Idempotent(favoritesHandler.CreateFavoriteTrain)).Methods("POST")
+	api.HandleFunc("/favorites/trains/reorder", favoritesHandler.PostReorderFavoriteTrains).Methods("POST")
 	api.HandleFunc("/favorites/trains/{id}", favoritesHandler.GetFavoriteTrain).Methods("GET")
 	api.HandleFunc("/favorites/trains/{id}", favoritesHandler.UpdateFavoriteTrain).Methods("PUT")
+	api.HandleFunc("/favorites/trains/{id}", favoritesHandler.PatchFavoriteTrain).Methods("PATCH")
 	api.HandleFunc("/favorites/trains/{id}", favoritesHandler.DeleteFavoriteTrain).Methods("DELETE")
 
+	// Journey favorites (commutes with their next connections)
+	api.HandleFunc("/favorites/journeys", favoritesHandler.GetFavoriteJourneys).Methods("GET")
+	api.HandleFunc("/favorites/journeys", favoritesHandler.Idempotent(favoritesHandler.CreateFavoriteJourney)).Methods("POST")
+	api.HandleFunc("/favorites/journeys/reorder", favoritesHandler.PostReorderFavoriteJourneys).Methods("POST")
+	api.HandleFunc("/favorites/journeys/{id}", favoritesHandler.GetFavoriteJourney).Methods("GET")
+	api.HandleFunc("/favorites/journeys/{id}", favoritesHandler.UpdateFavoriteJourney).Methods("PUT")
+	api.HandleFunc("/favorites/journeys/{id}", favoritesHandler.PatchFavoriteJourney).Methods("PATCH")
+	api.HandleFunc("/favorites/journeys/{id}", favoritesHandler.DeleteFavoriteJourney).Methods("DELETE")
+	api.HandleFunc("/favorites/journeys/{id}/next", favoritesHandler.GetNextConnections).Methods("GET")
+
+	// Collections of favorites (reorder before {id})
+	api.HandleFunc("/favorites/collections", favoritesHandler.GetCollections).Methods("GET")
+	api.HandleFunc("/favorites/collections", favoritesHandler.Idempotent(favoritesHandler.CreateCollection)).Methods("POST")
+	api.HandleFunc("/favorites/collections/reorder", favoritesHandler.PostReorderCollections).Methods("POST")
+	api.HandleFunc("/favorites/collections/{id}", favoritesHandler.GetCollection).Methods("GET")
+	api.HandleFunc("/favorites/collections/{id}", favoritesHandler.UpdateCollection).Methods("PUT", "PATCH")
+	api.HandleFunc("/favorites/collections/{id}", favoritesHandler.DeleteCollection).Methods("DELETE")
+
+	// Alert rules and webhook deliveries (fixed paths before {id})
+	api.HandleFunc("/favorites/alerts", favoritesHandler.GetAlertRules).Methods("GET")
+	api.HandleFunc("/favorites/alerts", favoritesHandler.CreateAlertRule).Methods("POST")
+	api.HandleFunc("/favorites/alerts/deliveries", favoritesHandler.GetDeliveries).Methods("GET")
+	api.HandleFunc("/favorites/alerts/dead-letters", favoritesHandler.GetDeadLetters).Methods("GET")
+	api.HandleFunc("/favorites/alerts/dead-letters/{id}/retry", favoritesHandler.PostDeadLetterRetry).Methods("POST")
+	api.HandleFunc("/favorites/alerts/{id}", favoritesHandler.GetAlertRule).Methods("GET")
+	api.HandleFunc("/favorites/alerts/{id}", favoritesHandler.UpdateAlertRule).Methods("PUT")
+	api.HandleFunc("/favorites/alerts/{id}", favoritesHandler.DeleteAlertRule).Methods("DELETE")
+	api.HandleFunc("/favorites/alerts/{id}/test", favoritesHandler.PostAlertRuleTest).Methods("POST")
+
+	api.HandleFunc("/favorites/dashboard", favoritesHandler.GetDashboard).Methods("GET")
+	api.HandleFunc("/favorites/import", favoritesHandler.PostImport).Methods("POST")
+	api.HandleFunc("/favorites/export", favoritesHandler.GetExport).Methods("GET")
+	api.HandleFunc("/favorites/{id}/history", favoritesHandler.GetFavoriteHistory).Methods("GET")
+	api.HandleFunc("/admin/audit", favoritesHandler.GetAuditLog).Methods("GET")
+
+	// Legacy routes (backwards compatibility). Registered last: mux matches
+	// in order, and /favorites/{id} would match /favorites/trains
+	api.HandleFunc("/favorites", favoritesHandler.GetFavorites).Methods("GET")
+	api.HandleFunc("/favorites", favoritesHandler.Idempotent(favoritesHandler.CreateFavorite)).Methods("POST")
+	api.HandleFunc("/favorites/reorder", favoritesHandler.PostReorderFavorites).Methods("POST")
+	api.HandleFunc("/favorites/{id}", favoritesHandler.GetFavorite).Methods("GET")
+	api.HandleFunc("/favorites/{id}", favoritesHandler.UpdateFavorite).Methods("PUT")
+	api.HandleFunc("/favorites/{id}", favoritesHandler.PatchFavorite).Methods("PATCH")
+	api.HandleFunc("/favorites/{id}", favoritesHandler.DeleteFavorite).Methods("DELETE")
+
+	// Server-Sent Events streams (fed by the WebSocket hub)
+	api.HandleFunc("/stream/trains", wsHub.HandleTrainStream).Methods("GET")
+	api.HandleFunc("/stream/stations/{id}/departures", wsHub.HandleDepartureStream).Methods("GET")
+	api.HandleFunc("/stream/events", wsHub.HandleEventStream).Methods("GET")
+
 	// WebSocket endpoint
 	router.HandleFunc("/ws", wsHub.HandleWebSocket)
 
@@ -0,0 +1,98 @@
+// Package main is a local webhook receiver for trying out alert rules.
+//
+// It verifies the signature of each delivery and prints it. Failing the
+// first deliveries exercises the server's retries and dead letters:
+//
+//	go run ./cmd/webhookrecv -addr :9090 -secret <rule secret>
+//
+//	# answer 503 to the first 3 deliveries, then 200
+//	go run ./cmd/webhookrecv -secret <rule secret> -fail 3 -status 503
+//
+// Point a rule at it with "webhookUrl": "http://localhost:9090/hook".
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	secret := flag.String("secret", "", "rule secret; signatures aren't checked without one")
+	fail := flag.Int("fail", 0, "fail this many deliveries before accepting")
+	status := flag.Int("status", http.StatusInternalServerError, "status of failed deliveries")
+	tolerance := flag.Duration("tolerance", 5*time.Minute, "accepted clock skew of the timestamp")
+	flag.Parse()
+
+	var mu sync.Mutex
+	received := 0
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id := r.Header.Get(services.WebhookIDHeader)
+		event := r.Header.Get(services.WebhookEventHeader)
+		if *secret != "" {
+			if reason := verify(r, body, *secret, *tolerance); reason != "" {
+				log.Printf("REJECTED %s (%s): %s", id, event, reason)
+				http.Error(w, reason, http.StatusUnauthorized)
+				return
+			}
+		}
+
+		mu.Lock()
+		received++
+		n := received
+		mu.Unlock()
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Write(body)
+		}
+		if n <= *fail {
+			log.Printf("FAILING #%d %s (%s) with %d", n, id, event, *status)
+			http.Error(w, "failing on purpose", *status)
+			return
+		}
+		log.Printf("RECEIVED #%d %s (%s)\n%s", n, id, event, pretty.String())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// verify checks a delivery's timestamp and signature and returns why it is
+// rejected, or "".
+func verify(r *http.Request, body []byte, secret string, tolerance time.Duration) string {
+	timestamp, err := strconv.ParseInt(r.Header.Get(services.WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return "missing or invalid timestamp"
+	}
+	if skew := time.Since(time.Unix(timestamp, 0)); skew > tolerance || skew < -tolerance {
+		return "timestamp outside tolerance"
+	}
+	expected := services.SignWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(services.WebhookSignatureHeader))) {
+		return "signature mismatch"
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+// Package auth identifies API callers.
+//
+// Signed-in users present a JWT from the identity provider, verified with
+// an HS256 secret or the public keys of a JWKS file, so a local stand-in
+// issuer can replace the provider in development and tests. Clients
+// without an account may identify themselves with a device ID instead;
+// their data is then scoped to that device.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Subject   string // JWT subject, or the device ID
+	Anonymous bool   // identified by a device ID rather than a token
+	Admin     bool   // a user listed as an administrator
+}
+
+// Owner is the key the caller's data is stored under. Users and devices
+// are namespaced, so a device ID can never claim a user's data.
+func (i Identity) Owner() string {
+	if i.Anonymous {
+		return "device:" + i.Subject
+	}
+	return "user:" + i.Subject
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the identity.
+func NewContext(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity stored in ctx, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok
+}
+
+// ErrInvalidToken is returned (wrapped) for tokens that fail verification.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrInvalidDevice is returned for malformed device IDs, or when device
+// IDs are not accepted.
+var ErrInvalidDevice = errors.New("invalid device ID")
+
+// deviceIDPattern accepts UUIDs and similar client-generated IDs.
+var deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,64}$`)
+
+// clockSkew is how far token times may be off from ours.
+const clockSkew = time.Minute
+
+// Config configures an Authenticator. Without a secret or JWKS file no
+// token verifies; without Anonymous no device ID is accepted.
+type Config struct {
+	HS256Secret string   // shared secret for HS256 tokens
+	JWKSFile    string   // JSON Web Key Set with the issuer's public keys (RS256, ES256)
+	Issuer      string   // required "iss" claim (optional)
+	Audience    string   // required "aud" claim (optional)
+	Anonymous   bool     // accept device IDs from clients without a token
+	Admins      []string // subjects of the users allowed to administer (e.g. read the audit log)
+}
+
+// Authenticator verifies tokens and device IDs.
+type Authenticator struct {
+	secret    []byte
+	keys      []jsonWebKey
+	issuer    string
+	audience  string
+	anonymous bool
+	admins    map[string]bool
+	now       func() time.Time
+}
+
+// New creates an authenticator, loading the JWKS file if configured.
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{
+		secret:    []byte(cfg.HS256Secret),
+		issuer:    cfg.Issuer,
+		audience:  cfg.Audience,
+		anonymous: cfg.Anonymous,
+		admins:    make(map[string]bool, len(cfg.Admins)),
+		now:       time.Now,
+	}
+	for _, subject := range cfg.Admins {
+		a.admins[subject] = true
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("load JWKS %s: %w", cfg.JWKSFile, err)
+		}
+		a.keys = keys
+	}
+	return a, nil
+}
+
+// Tokens reports whether any token can verify.
+func (a *Authenticator) Tokens() bool {
+	return len(a.secret) > 0 || len(a.keys) > 0
+}
+
+// Anonymous reports whether device IDs are accepted.
+func (a *Authenticator) Anonymous() bool {
+	return a.anonymous
+}
+
+// Authenticate verifies a bearer token and returns the user it identifies.
+func (a *Authenticator) Authenticate(token string) (Identity, error) {
+	claims, err := a.verify(token)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Subject: claims.Subject, Admin: a.admins[claims.Subject]}, nil
+}
+
+// Device returns the anonymous identity of a device ID.
+func (a *Authenticator) Device(id string) (Identity, error) {
+	if !a.anonymous {
+		return Identity{}, fmt.Errorf("%w: device IDs are not accepted, sign in instead", ErrInvalidDevice)
+	}
+	if !deviceIDPattern.MatchString(id) {
+		return Identity{}, fmt.Errorf("%w: expected 16 to 64 letters, digits, '-' or '_'", ErrInvalidDevice)
+	}
+	return Identity{Subject: id, Anonymous: true}, nil
+}
+
+// key returns the JWKS key for a token header.
+func (a *Authenticator) key(alg, kid string) (crypto.PublicKey, error) {
+	var match crypto.PublicKey
+	for _, key := range a.keys {
+		if key.alg != alg || (kid != "" && key.kid != kid) {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("%w: several keys match; the token needs a kid", ErrInvalidToken)
+		}
+		match = key.public
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: no %s key with kid %q", ErrInvalidToken, alg, kid)
+	}
+	return match, nil
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// jsonWebKey is a public signing key from a JWKS file.
+type jsonWebKey struct {
+	kid    string
+	alg    string // "RS256" or "ES256"
+	public crypto.PublicKey
+}
+
+// jwksFile is the JSON Web Key Set format (RFC 7517).
+type jwksFile struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		// RSA
+		N string `json:"n"`
+		E string `json:"e"`
+		// EC
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	} `json:"keys"`
+}
+
+// loadJWKS reads the RSA and P-256 signing keys of a JWKS file. Keys for
+// other uses or curves are skipped.
+func loadJWKS(path string) ([]jsonWebKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set jwksFile
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []jsonWebKey
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := decodeBigInt(k.N)
+			e, errE := decodeBigInt(k.E)
+			if errN != nil || errE != nil || !e.IsInt64() {
+				return nil, fmt.Errorf("key %d (%s): bad RSA modulus or exponent", i, k.Kid)
+			}
+			keys = append(keys, jsonWebKey{
+				kid:    k.Kid,
+				alg:    "RS256",
+				public: &rsa.PublicKey{N: n, E: int(e.Int64())},
+			})
+
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := decodeBigInt(k.X)
+			y, errY := decodeBigInt(k.Y)
+			if errX != nil || errY != nil || !elliptic.P256().IsOnCurve(x, y) {
+				return nil, fmt.Errorf("key %d (%s): bad P-256 point", i, k.Kid)
+			}
+			keys = append(keys, jsonWebKey{
+				kid:    k.Kid,
+				alg:    "ES256",
+				public: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y},
+			})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA or P-256 signing keys")
+	}
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
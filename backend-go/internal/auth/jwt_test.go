@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// now is the fixed time tokens are verified at.
+var now = time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+// issuer is a local stand-in for the identity provider: it signs tokens
+// with an HS256 secret and its RSA and P-256 keys.
+type issuer struct {
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &issuer{rsaKey: rsaKey, ecKey: ecKey}
+}
+
+// jwks writes the issuer's public keys, with extra keys of the same kinds
+// if given, and returns the file's path.
+func (iss *issuer) jwks(t *testing.T, extra ...map[string]string) string {
+	t.Helper()
+	b64 := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	keys := []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(iss.rsaKey.N), "e": b64(big.NewInt(int64(iss.rsaKey.E)))},
+		{"kty": "EC", "kid": "ec-1", "use": "sig", "crv": "P-256", "x": b64(iss.ecKey.X), "y": b64(iss.ecKey.Y)},
+	}
+	keys = append(keys, extra...)
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign returns a compact JWT of the claims, signed with alg ("none" leaves
+// the signature empty).
+func (iss *issuer) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signing := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signing))
+
+	var signature []byte
+	switch alg {
+	case "none":
+	case "HS256":
+		mac := hmac.New(sha256.New, []byte(testSecret))
+		mac.Write([]byte(signing))
+		signature = mac.Sum(nil)
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, iss.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		t.Fatalf("unknown alg %s", alg)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims are claims every test authenticator accepts.
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "alice",
+		"iss": "https://id.example.com",
+		"aud": "railway-api",
+		"exp": now.Add(time.Hour).Unix(),
+		"nbf": now.Add(-time.Minute).Unix(),
+	}
+}
+
+func newAuthenticator(t *testing.T, cfg Config) *Authenticator {
+	t.Helper()
+	cfg.Issuer = "https://id.example.com"
+	cfg.Audience = "railway-api"
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+	return a
+}
+
+func TestAuthenticateAcceptsSignedTokens(t *testing.T) {
+	iss := newIssuer(t)
+	a := newAuthenticator(t, Config{HS256Secret: testSecret, JWKSFile: iss.jwks(t), Admins: []string{"alice"}})
+
+	for _, tc := range []struct{ alg, kid string }{
+		{"HS256", ""},
+		{"RS256", "rsa-1"},
+		{"RS256", ""}, // the only RSA key
+		{"ES256", "ec-1"},
+	} {
+		identity, err := a.Authenticate(iss.sign(t, tc.alg, tc.kid, validClaims()))
+		if err != nil {
+			t.Errorf("%s (kid %q): %v", tc.alg, tc.kid, err)
+			continue
+		}
+		if identity.Owner() != "user:alice" || identity.Anonymous || !identity.Admin {
+			t.Errorf("%s: identity = %+v, want admin user:alice", tc.alg, identity)
+		}
+	}
+
+	// An audience array holding ours is fine
+	claims := validClaims()
+	claims["aud"] = []string{"other-api", "railway-api"}
+	if _, err := a.Authenticate(iss.sign(t, "HS256", "", claims)); err != nil {
+		t.Errorf("audience array: %v", err)
+	}
+}
+
+func TestAuthenticateRejectsTokens(t *testing.T) {
+	iss := newIssuer(t)
+	both := newAuthenticator(t, Config{HS256Secret: testSecret, JWKSFile: iss.jwks(t)})
+	jwksOnly := newAuthenticator(t, Config{JWKSFile: iss.jwks(t)})
+
+	// A second RSA key, so RS256 tokens must say which one signed them
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	twoRSA := newAuthenticator(t, Config{JWKSFile: iss.jwks(t, map[string]string{
+		"kty": "RSA", "kid": "rsa-2", "n": b64(other.N), "e": b64(big.NewInt(int64(other.E))),
+	})})
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	hs256 := iss.sign(t, "HS256", "", validClaims())
+
+	tests := []struct {
+		name  string
+		a     *Authenticator
+		token string
+	}{
+		{"alg none", both, iss.sign(t, "none", "", validClaims())},
+		{"alg none with HS256 signature", both, strings.Replace(hs256,
+			strings.Split(hs256, ".")[0], base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)), 1)},
+		{"HS256 with only a JWKS", jwksOnly, hs256},
+		{"tampered claims", both, func() string {
+			parts := strings.Split(hs256, ".")
+			claims, _ := json.Marshal(with("sub", "mallory"))
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(claims) + "." + parts[2]
+		}()},
+		{"expired beyond clock skew", both, iss.sign(t, "HS256", "", with("exp", now.Add(-clockSkew-time.Second).Unix()))},
+		{"not valid beyond clock skew", both, iss.sign(t, "HS256", "", with("nbf", now.Add(clockSkew+time.Second).Unix()))},
+		{"missing exp", both, iss.sign(t, "HS256", "", with("exp", nil))},
+		{"missing sub", both, iss.sign(t, "HS256", "", with("sub", nil))},
+		{"wrong issuer", both, iss.sign(t, "HS256", "", with("iss", "https://evil.example.com"))},
+		{"wrong audience", both, iss.sign(t, "RS256", "rsa-1", with("aud", "other-api"))},
+		{"missing audience", both, iss.sign(t, "ES256", "ec-1", with("aud", nil))},
+		{"unknown kid", both, iss.sign(t, "RS256", "rsa-9", validClaims())},
+		{"missing kid with several keys", twoRSA, iss.sign(t, "RS256", "", validClaims())},
+		{"RS256 signed by another key", twoRSA, iss.sign(t, "RS256", "rsa-2", validClaims())},
+		{"malformed", both, "not-a-token"},
+	}
+	for _, tt := range tests {
+		if identity, err := tt.a.Authenticate(tt.token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %+v, %v; want ErrInvalidToken", tt.name, identity, err)
+		}
+	}
+}
+
+func TestAuthenticateClockSkew(t *testing.T) {
+	iss := newIssuer(t)
+	a := newAuthenticator(t, Config{HS256Secret: testSecret})
+
+	// Just inside the skew either way still verifies
+	claims := validClaims()
+	claims["exp"] = now.Add(-clockSkew + time.Second).Unix()
+	claims["nbf"] = now.Add(clockSkew - time.Second).Unix()
+	if _, err := a.Authenticate(iss.sign(t, "HS256", "", claims)); err != nil {
+		t.Errorf("token within clock skew: %v", err)
+	}
+}
+
+func TestDevice(t *testing.T) {
+	a := newAuthenticator(t, Config{Anonymous: true})
+
+	identity, err := a.Device("3f2c9a1e-7b4d-4e8a-9c6f-0d1e2f3a4b5c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !identity.Anonymous || identity.Owner() != "device:3f2c9a1e-7b4d-4e8a-9c6f-0d1e2f3a4b5c" {
+		t.Errorf("identity = %+v", identity)
+	}
+
+	for _, id := range []string{
+		"",
+		"short",
+		"user:alice-0123456789",   // can't spell another namespace
+		"device:0123456789abcdef", // nor its own
+		"0123456789abcdef/../x",
+		"0123456789abcdef 0123",
+		strings.Repeat("a", 65),
+	} {
+		if _, err := a.Device(id); !errors.Is(err, ErrInvalidDevice) {
+			t.Errorf("Device(%q) = %v, want ErrInvalidDevice", id, err)
+		}
+	}
+
+	noDevices := newAuthenticator(t, Config{HS256Secret: testSecret})
+	if _, err := noDevices.Device("3f2c9a1e-7b4d-4e8a-9c6f-0d1e2f3a4b5c"); !errors.Is(err, ErrInvalidDevice) {
+		t.Errorf("device accepted without Anonymous: %v", err)
+	}
+}
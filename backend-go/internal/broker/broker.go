@@ -0,0 +1,178 @@
+// Package broker provides publish/subscribe fan-out between backend instances.
+//
+// One instance (the leader) computes live state and publishes it; every
+// instance, the leader included, subscribes and pushes what it receives to
+// its own WebSocket clients. The in-memory broker serves a single instance;
+// the TCP broker links a leader with any number of replicas.
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Message is a published payload on a topic. Messages published with
+// PublishValue carry the value itself to local subscribers and its JSON
+// encoding to linked instances; Value is nil otherwise.
+type Message struct {
+	Topic string
+	Data  []byte
+	Value interface{}
+}
+
+// Broker publishes messages to all subscribers of a topic, on this instance
+// and on linked instances.
+type Broker interface {
+	// Publish sends data to the topic's subscribers without blocking.
+	Publish(topic string, data []byte) error
+
+	// PublishValue sends value to the topic's local subscribers as is and,
+	// JSON-encoded, to linked instances; it is only encoded when some are
+	// linked.
+	PublishValue(topic string, value interface{}) error
+
+	// Subscribe returns a subscription receiving messages on the topics.
+	Subscribe(topics ...string) *Subscription
+
+	// Leader reports whether this instance computes and publishes state.
+	Leader() bool
+
+	// Stats returns delivery counters.
+	Stats() Stats
+
+	// Close stops the broker and closes all subscriptions.
+	Close() error
+}
+
+// Stats describes a broker's traffic.
+type Stats struct {
+	Type      string `json:"type"`
+	Leader    bool   `json:"leader"`
+	Published uint64 `json:"published"`
+	Delivered uint64 `json:"delivered"`
+	// Messages dropped because a subscriber or peer fell behind
+	Dropped uint64 `json:"dropped"`
+	// Connected replicas (leader) or 1 while connected to the leader (replica)
+	Peers int `json:"peers"`
+}
+
+// subscriptionBuffer is how many messages a subscriber may have pending.
+const subscriptionBuffer = 64
+
+// Subscription receives messages on its topics from C. Messages are dropped
+// while the buffer is full, so a slow subscriber never blocks publishers.
+type Subscription struct {
+	C <-chan Message
+
+	ch     chan Message
+	topics map[string]bool
+	owner  *fanout
+	once   sync.Once
+}
+
+// Unsubscribe stops delivery and closes C.
+func (s *Subscription) Unsubscribe() {
+	s.owner.remove(s)
+}
+
+// fanout delivers messages to local subscribers.
+type fanout struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]bool
+
+	published uint64
+	delivered uint64
+	dropped   uint64
+}
+
+func newFanout() *fanout {
+	return &fanout{subs: make(map[*Subscription]bool)}
+}
+
+func (f *fanout) subscribe(topics []string) *Subscription {
+	ch := make(chan Message, subscriptionBuffer)
+	sub := &Subscription{C: ch, ch: ch, topics: make(map[string]bool), owner: f}
+	for _, topic := range topics {
+		sub.topics[topic] = true
+	}
+
+	f.mu.Lock()
+	f.subs[sub] = true
+	f.mu.Unlock()
+	return sub
+}
+
+func (f *fanout) remove(sub *Subscription) {
+	f.mu.Lock()
+	delete(f.subs, sub)
+	f.mu.Unlock()
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+// deliver hands a message to every local subscriber of its topic.
+func (f *fanout) deliver(msg Message) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for sub := range f.subs {
+		if !sub.topics[msg.Topic] {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+			atomic.AddUint64(&f.delivered, 1)
+		default:
+			atomic.AddUint64(&f.dropped, 1)
+		}
+	}
+}
+
+// closeAll closes every subscription.
+func (f *fanout) closeAll() {
+	f.mu.Lock()
+	subs := f.subs
+	f.subs = make(map[*Subscription]bool)
+	f.mu.Unlock()
+
+	for sub := range subs {
+		sub.once.Do(func() { close(sub.ch) })
+	}
+}
+
+func (f *fanout) stats() Stats {
+	return Stats{
+		Published: atomic.LoadUint64(&f.published),
+		Delivered: atomic.LoadUint64(&f.delivered),
+		Dropped:   atomic.LoadUint64(&f.dropped),
+	}
+}
+
+// Config selects and configures a broker.
+type Config struct {
+	Type  string // "memory" or "tcp"
+	Role  string // "leader" or "replica" (tcp only)
+	Addr  string // listen address (leader) or leader address (replica)
+	Token string // shared secret replicas present to the leader (tcp only, required)
+}
+
+// New creates the configured broker.
+func New(cfg Config) (Broker, error) {
+	switch cfg.Type {
+	case "", "memory":
+		return NewMemory(), nil
+
+	case "tcp":
+		switch cfg.Role {
+		case "", "leader":
+			return ListenTCP(cfg.Addr, cfg.Token)
+		case "replica":
+			return DialTCP(cfg.Addr, cfg.Token)
+		default:
+			return nil, fmt.Errorf("unknown broker role %q (expected leader or replica)", cfg.Role)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown broker type %q (expected memory or tcp)", cfg.Type)
+	}
+}
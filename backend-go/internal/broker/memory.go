@@ -0,0 +1,80 @@
+// Package broker - In-Memory Broker
+// This file contains the broker for a single instance.
+package broker
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned when publishing on a closed broker.
+var ErrClosed = errors.New("broker closed")
+
+// Memory is an in-process broker. The instance is always the leader.
+type Memory struct {
+	*fanout
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewMemory creates an in-memory broker.
+func NewMemory() *Memory {
+	return &Memory{fanout: newFanout()}
+}
+
+// Publish delivers data to local subscribers.
+func (m *Memory) Publish(topic string, data []byte) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return ErrClosed
+	}
+
+	atomic.AddUint64(&m.published, 1)
+	m.deliver(Message{Topic: topic, Data: data})
+	return nil
+}
+
+// PublishValue delivers value to local subscribers without encoding it.
+func (m *Memory) PublishValue(topic string, value interface{}) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return ErrClosed
+	}
+
+	atomic.AddUint64(&m.published, 1)
+	m.deliver(Message{Topic: topic, Value: value})
+	return nil
+}
+
+// Subscribe returns a subscription to the topics.
+func (m *Memory) Subscribe(topics ...string) *Subscription {
+	return m.subscribe(topics)
+}
+
+// Leader always returns true.
+func (m *Memory) Leader() bool {
+	return true
+}
+
+// Stats returns delivery counters.
+func (m *Memory) Stats() Stats {
+	stats := m.stats()
+	stats.Type = "memory"
+	stats.Leader = true
+	return stats
+}
+
+// Close closes all subscriptions.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		m.closeAll()
+	}
+	return nil
+}
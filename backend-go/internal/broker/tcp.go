@@ -0,0 +1,459 @@
+// Package broker - TCP Broker
+// This file contains a small TCP pub/sub linking a leader with replicas.
+//
+// Frames are length-prefixed: topic length (uint16), data length (uint32),
+// topic, data. Only the leader publishes: a replica presents the shared
+// token in its first frame, then receives the leader's frames and
+// reconnects with backoff when the link drops. The leader drops anything
+// else a replica sends, so a peer can't inject state into other instances.
+package broker
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxFrameData bounds a frame's payload.
+	maxFrameData = 16 << 20
+
+	// peerBuffer is how many frames may wait for a slow peer.
+	peerBuffer = 256
+
+	// helloTopic carries a replica's token as its first frame.
+	helloTopic = "_hello"
+
+	peerWriteTimeout = 10 * time.Second
+	helloTimeout     = 10 * time.Second
+	minDialBackoff   = time.Second
+	maxDialBackoff   = 30 * time.Second
+)
+
+// ErrNoToken is returned when a TCP broker is created without a token.
+var ErrNoToken = errors.New("broker token is required")
+
+// TCP is a networked broker: a leader listening for replicas, or a replica
+// connected to a leader.
+type TCP struct {
+	*fanout
+
+	leader bool
+	addr   string
+	token  string
+
+	listener net.Listener
+
+	mu     sync.Mutex
+	peers  map[*peer]bool
+	closed bool
+	done   chan struct{}
+}
+
+// ListenTCP starts a leader accepting replicas on addr. Replicas must
+// present token before receiving anything.
+func ListenTCP(addr, token string) (*TCP, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("broker listen on %s: %w", addr, err)
+	}
+
+	t := newTCP(true, addr, token)
+	t.listener = listener
+	go t.acceptLoop()
+
+	log.Info().Str("addr", listener.Addr().String()).Msg("📡 Broker leader listening for replicas")
+	return t, nil
+}
+
+// DialTCP starts a replica that connects (and reconnects) to the leader at
+// addr and presents token.
+func DialTCP(addr, token string) (*TCP, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+	t := newTCP(false, addr, token)
+	go t.dialLoop()
+	return t, nil
+}
+
+func newTCP(leader bool, addr, token string) *TCP {
+	return &TCP{
+		fanout: newFanout(),
+		leader: leader,
+		addr:   addr,
+		token:  token,
+		peers:  make(map[*peer]bool),
+		done:   make(chan struct{}),
+	}
+}
+
+// Addr returns the address the leader listens on, or the leader's address
+// on a replica.
+func (t *TCP) Addr() string {
+	if t.listener != nil {
+		return t.listener.Addr().String()
+	}
+	return t.addr
+}
+
+// Publish delivers data to local subscribers and, on the leader, to the
+// replicas.
+func (t *TCP) Publish(topic string, data []byte) error {
+	if err := checkFrame(topic, data); err != nil {
+		return err
+	}
+	if t.isClosed() {
+		return ErrClosed
+	}
+
+	msg := Message{Topic: topic, Data: data}
+	atomic.AddUint64(&t.published, 1)
+	t.deliver(msg)
+	t.forward(msg)
+	return nil
+}
+
+// PublishValue delivers value to local subscribers as is and, on the
+// leader, JSON-encoded to the replicas. Without replicas nothing is encoded.
+func (t *TCP) PublishValue(topic string, value interface{}) error {
+	if t.isClosed() {
+		return ErrClosed
+	}
+
+	atomic.AddUint64(&t.published, 1)
+	t.deliver(Message{Topic: topic, Value: value})
+
+	if !t.leader || t.peerCount() == 0 {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("broker message on %q: %w", topic, err)
+	}
+	if err := checkFrame(topic, data); err != nil {
+		return err
+	}
+	t.forward(Message{Topic: topic, Data: data})
+	return nil
+}
+
+// checkFrame reports a message too large for a frame.
+func checkFrame(topic string, data []byte) error {
+	if len(topic) > 0xffff || len(data) > maxFrameData {
+		return fmt.Errorf("broker message on %q too large (%d bytes)", topic, len(data))
+	}
+	return nil
+}
+
+func (t *TCP) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+func (t *TCP) peerCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.peers)
+}
+
+// Subscribe returns a subscription to the topics.
+func (t *TCP) Subscribe(topics ...string) *Subscription {
+	return t.subscribe(topics)
+}
+
+// Leader reports whether this instance is the leader.
+func (t *TCP) Leader() bool {
+	return t.leader
+}
+
+// Stats returns delivery counters and the number of linked peers.
+func (t *TCP) Stats() Stats {
+	stats := t.stats()
+	stats.Type = "tcp"
+	stats.Leader = t.leader
+
+	t.mu.Lock()
+	stats.Peers = len(t.peers)
+	for p := range t.peers {
+		stats.Dropped += atomic.LoadUint64(&p.dropped)
+	}
+	t.mu.Unlock()
+	return stats
+}
+
+// Close stops listening or dialing, drops all peers and closes subscriptions.
+func (t *TCP) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	peers := t.peers
+	t.peers = make(map[*peer]bool)
+	t.mu.Unlock()
+
+	if t.listener != nil {
+		t.listener.Close()
+	}
+	for p := range peers {
+		p.close()
+	}
+	t.closeAll()
+	return nil
+}
+
+// forward queues a message for every replica (leader only).
+func (t *TCP) forward(msg Message) {
+	if !t.leader {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for p := range t.peers {
+		p.send(msg)
+	}
+}
+
+func (t *TCP) addPeer(p *peer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.peers[p] = true
+	return true
+}
+
+func (t *TCP) removePeer(p *peer) {
+	t.mu.Lock()
+	delete(t.peers, p)
+	t.mu.Unlock()
+	p.close()
+}
+
+// acceptLoop accepts replicas until the listener is closed.
+func (t *TCP) acceptLoop() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			select {
+			case <-t.done:
+				return
+			default:
+			}
+			log.Warn().Err(err).Msg("Broker accept failed")
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go t.serveReplica(conn)
+	}
+}
+
+// serveReplica authenticates a replica and sends it every published frame.
+func (t *TCP) serveReplica(conn net.Conn) {
+	remote := conn.RemoteAddr().String()
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	hello, err := readFrame(reader)
+	if err != nil || hello.Topic != helloTopic ||
+		subtle.ConstantTimeCompare(hello.Data, []byte(t.token)) != 1 {
+		log.Warn().Str("replica", remote).Msg("Broker replica rejected (bad token)")
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	p := newPeer(conn)
+	if !t.addPeer(p) {
+		p.close()
+		return
+	}
+	log.Info().Str("replica", remote).Msg("Broker replica connected")
+
+	t.readFrames(reader)
+
+	t.removePeer(p)
+	log.Info().Str("replica", remote).Msg("Broker replica disconnected")
+}
+
+// dialLoop keeps a replica connected to the leader.
+func (t *TCP) dialLoop() {
+	backoff := minDialBackoff
+
+	for {
+		conn, err := net.DialTimeout("tcp", t.addr, 5*time.Second)
+		if err != nil {
+			log.Warn().Err(err).Str("leader", t.addr).Dur("retry", backoff).Msg("Broker leader unreachable")
+			select {
+			case <-t.done:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxDialBackoff {
+				backoff = maxDialBackoff
+			}
+			continue
+		}
+		backoff = minDialBackoff
+
+		p := newPeer(conn)
+		p.send(Message{Topic: helloTopic, Data: []byte(t.token)})
+		if !t.addPeer(p) {
+			p.close()
+			return
+		}
+		log.Info().Str("leader", t.addr).Msg("📡 Broker replica connected to leader")
+
+		t.readFrames(bufio.NewReader(conn))
+
+		t.removePeer(p)
+		log.Warn().Str("leader", t.addr).Msg("Broker link to leader lost")
+
+		select {
+		case <-t.done:
+			return
+		default:
+		}
+	}
+}
+
+// readFrames delivers the leader's frames locally until the connection
+// fails. The leader reads its replicas only to notice a closed link and
+// drops what they send.
+func (t *TCP) readFrames(reader *bufio.Reader) {
+	for {
+		msg, err := readFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Debug().Err(err).Msg("Broker read failed")
+			}
+			return
+		}
+		if t.leader || msg.Topic == helloTopic {
+			continue
+		}
+		t.deliver(msg)
+	}
+}
+
+// peer is a linked instance with its own writer, so a slow peer never
+// blocks publishers.
+type peer struct {
+	conn    net.Conn
+	out     chan Message
+	done    chan struct{}
+	once    sync.Once
+	dropped uint64
+}
+
+func newPeer(conn net.Conn) *peer {
+	p := &peer{
+		conn: conn,
+		out:  make(chan Message, peerBuffer),
+		done: make(chan struct{}),
+	}
+	go p.writeLoop()
+	return p
+}
+
+// send queues a frame; it is dropped if the peer is too far behind.
+func (p *peer) send(msg Message) bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+
+	select {
+	case p.out <- msg:
+		return true
+	default:
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+}
+
+func (p *peer) writeLoop() {
+	writer := bufio.NewWriter(p.conn)
+	for {
+		select {
+		case <-p.done:
+			return
+		case msg := <-p.out:
+			p.conn.SetWriteDeadline(time.Now().Add(peerWriteTimeout))
+			if err := writeFrame(writer, msg); err != nil {
+				p.close()
+				return
+			}
+			// Batch frames that are already waiting into one write
+			if len(p.out) == 0 {
+				if err := writer.Flush(); err != nil {
+					p.close()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *peer) close() {
+	p.once.Do(func() {
+		close(p.done)
+		p.conn.Close()
+	})
+}
+
+// writeFrame writes one length-prefixed frame.
+func writeFrame(w io.Writer, msg Message) error {
+	var header [6]byte
+	binary.BigEndian.PutUint16(header[0:2], uint16(len(msg.Topic)))
+	binary.BigEndian.PutUint32(header[2:6], uint32(len(msg.Data)))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, msg.Topic); err != nil {
+		return err
+	}
+	_, err := w.Write(msg.Data)
+	return err
+}
+
+// readFrame reads one length-prefixed frame.
+func readFrame(r io.Reader) (Message, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Message{}, err
+	}
+
+	topicLen := binary.BigEndian.Uint16(header[0:2])
+	dataLen := binary.BigEndian.Uint32(header[2:6])
+	if dataLen > maxFrameData {
+		return Message{}, fmt.Errorf("broker frame too large (%d bytes)", dataLen)
+	}
+
+	buf := make([]byte, int(topicLen)+int(dataLen))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return Message{}, err
+	}
+	return Message{Topic: string(buf[:topicLen]), Data: buf[topicLen:]}, nil
+}
@@ -0,0 +1,130 @@
+package broker
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPRequiresToken(t *testing.T) {
+	if _, err := ListenTCP("127.0.0.1:0", ""); !errors.Is(err, ErrNoToken) {
+		t.Errorf("ListenTCP without a token = %v, want ErrNoToken", err)
+	}
+	if _, err := DialTCP("127.0.0.1:7400", ""); !errors.Is(err, ErrNoToken) {
+		t.Errorf("DialTCP without a token = %v, want ErrNoToken", err)
+	}
+}
+
+// waitForPeers waits until b has n peers.
+func waitForPeers(t *testing.T, b *TCP, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Stats().Peers != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("peers = %d, want %d", b.Stats().Peers, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// receive returns the next message on sub, or false after a short wait.
+func receive(sub *Subscription) (Message, bool) {
+	select {
+	case msg := <-sub.C:
+		return msg, true
+	case <-time.After(200 * time.Millisecond):
+		return Message{}, false
+	}
+}
+
+func TestTCPLeaderToReplica(t *testing.T) {
+	leader, err := ListenTCP("127.0.0.1:0", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leader.Close()
+	addr := leader.listener.Addr().String()
+
+	replica, err := DialTCP(addr, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+	waitForPeers(t, leader, 1)
+
+	leaderSub := leader.Subscribe("live.tick")
+	replicaSub := replica.Subscribe("live.tick")
+
+	// The leader's values reach local subscribers as is and replicas encoded
+	value := map[string]int{"trains": 3}
+	if err := leader.PublishValue("live.tick", value); err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := receive(leaderSub); !ok || msg.Value == nil || msg.Data != nil {
+		t.Errorf("leader got %+v, want the value", msg)
+	}
+	if msg, ok := receive(replicaSub); !ok || string(msg.Data) != `{"trains":3}` {
+		t.Errorf("replica got %+v, want the encoded value", msg)
+	}
+
+	// Nothing a replica publishes reaches the leader or other replicas
+	other, err := DialTCP(addr, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	waitForPeers(t, leader, 2)
+	otherSub := other.Subscribe("live.tick")
+
+	if err := replica.Publish("live.tick", []byte(`{"trains":0}`)); err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := receive(replicaSub); !ok || string(msg.Data) != `{"trains":0}` {
+		t.Errorf("replica's own subscriber got %+v", msg)
+	}
+	if msg, ok := receive(leaderSub); ok {
+		t.Errorf("leader delivered a replica's frame: %+v", msg)
+	}
+	if msg, ok := receive(otherSub); ok {
+		t.Errorf("other replica received a replica's frame: %+v", msg)
+	}
+}
+
+func TestTCPRejectsUnauthenticatedPeers(t *testing.T) {
+	leader, err := ListenTCP("127.0.0.1:0", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leader.Close()
+	addr := leader.listener.Addr().String()
+	sub := leader.Subscribe("live.tick")
+
+	for name, hello := range map[string]Message{
+		"wrong token": {Topic: helloTopic, Data: []byte("guess")},
+		"no hello":    {Topic: "live.tick", Data: []byte(`{"trains":0}`)},
+	} {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer := bufio.NewWriter(conn)
+		writeFrame(writer, hello)
+		writeFrame(writer, Message{Topic: "live.tick", Data: []byte(`{"trains":0}`)})
+		writer.Flush()
+
+		// The leader hangs up without delivering anything
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Read(make([]byte, 1)); err == nil {
+			t.Errorf("%s: connection still open", name)
+		}
+		conn.Close()
+		if msg, ok := receive(sub); ok {
+			t.Errorf("%s: leader delivered %+v", name, msg)
+		}
+	}
+	if peers := leader.Stats().Peers; peers != 0 {
+		t.Errorf("peers = %d, want 0", peers)
+	}
+}
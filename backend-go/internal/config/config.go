@@ -4,6 +4,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -17,8 +18,62 @@ type Config struct {
 	LogLevel             string
 	LogFormat            string // "json" or "console" (pretty)
 	SwissTransportAPIURL string
+	SearchChAPIURL       string
+	TransportProvider    string // "opendata" or "searchch"
+	TransportFallback    string // secondary provider, "" or "none" to disable
 	EnableSwissAPI       bool
 	WSUpdateInterval     int // seconds
+	WSResumeWindow       int // seconds a dropped session stays resumable
+	WSReplayBuffer       int // messages kept per session for resume
+	WSCompression        bool
+	WSMaxSimulations     int // concurrent per-client simulations (0 disables)
+	WSSimMaxSpeed        int // fastest simulation speed (x real time)
+	WSArrivingMinutes    int // favorite trains: "arriving" event this many minutes before arrival
+	EventLogSize         int // train events kept for /api/events
+
+	// Fan-out between instances
+	BrokerType  string // "memory" (single instance) or "tcp"
+	BrokerRole  string // "leader" or "replica" (tcp only)
+	BrokerAddr  string // leader listen address, or the leader's address on replicas
+	BrokerToken string // shared secret replicas present to the leader
+
+	// Favorites persistence
+	FavoritesStore  string // "memory" (lost on restart) or "bolt"
+	FavoritesDBPath string // database file (bolt only)
+	IdempotencyTTL  int    // seconds responses to creates with an Idempotency-Key are replayed (0 disables)
+	AuditRetention  int    // days audit entries are kept (0 keeps them forever)
+
+	// Authentication
+	AuthHS256Secret string   // verifies HS256 tokens
+	AuthJWKSFile    string   // public keys verifying RS256/ES256 tokens
+	AuthIssuer      string   // required token issuer (optional)
+	AuthAudience    string   // required token audience (optional)
+	AuthAnonymous   bool     // accept device IDs from clients without a token
+	AuthAdmins      []string // token subjects allowed to administer, e.g. query the audit log
+
+	// Rate limiting
+	RateLimitEnabled        bool
+	RateLimitRate           int      // requests per minute per client
+	RateLimitBurst          int      // requests a client may send at once
+	RateLimitRoutes         []string // "[METHOD ]/path/prefix=rate[:burst]" policies
+	RateLimitAPIKeys        []string // "key=rate[:burst]" policies of X-API-Key clients
+	RateLimitTrustedProxies []string // IPs or CIDRs whose X-Forwarded-For is believed
+	RateLimitMaxBuckets     int      // buckets kept in memory
+
+	// Alerts on favorites
+	AlertsEnabled      bool     // evaluate alert rules and deliver webhooks
+	AlertInterval      int      // seconds between evaluations
+	WebhookMaxAttempts int      // attempts before a delivery is dead
+	WebhookRetryBase   int      // seconds before the first retry, doubled after each
+	WebhookTimeout     int      // seconds per attempt
+	WebhookLogSize     int      // deliveries kept in the delivery log
+	WebhookDeadLetters int      // dead deliveries kept for redelivery
+	WebhookAllowedNets []string // internal IPs or CIDRs webhooks may reach anyway
+
+	// Stationboard poller
+	StationWatchList  []string // station IDs polled in the background
+	PollerDailyBudget int      // upstream calls per 24h
+	PollerBoardLimit  int      // departures fetched per stationboard
 }
 
 // Load loads configuration from environment variables.
@@ -34,8 +89,57 @@ func Load() *Config {
 		LogLevel:             getEnv("LOG_LEVEL", "info"),
 		LogFormat:            getEnv("LOG_FORMAT", "json"), // Default to JSON format for structured logging
 		SwissTransportAPIURL: getEnv("SWISS_TRANSPORT_API_URL", "https://transport.opendata.ch/v1"),
+		SearchChAPIURL:       getEnv("SEARCH_CH_API_URL", "https://search.ch/timetable/api"),
+		TransportProvider:    getEnv("TRANSPORT_PROVIDER", "opendata"),
+		TransportFallback:    getEnv("TRANSPORT_FALLBACK", "searchch"),
 		EnableSwissAPI:       getEnvBool("ENABLE_SWISS_API", true),
 		WSUpdateInterval:     getEnvInt("WS_UPDATE_INTERVAL", 5),
+		WSResumeWindow:       getEnvInt("WS_RESUME_WINDOW", 30),
+		WSReplayBuffer:       getEnvInt("WS_REPLAY_BUFFER", 128),
+		WSCompression:        getEnvBool("WS_COMPRESSION", true),
+		WSMaxSimulations:     getEnvInt("WS_MAX_SIMULATIONS", 20),
+		WSSimMaxSpeed:        getEnvInt("WS_SIM_MAX_SPEED", 120),
+		WSArrivingMinutes:    getEnvInt("WS_ARRIVING_MINUTES", 5),
+		EventLogSize:         getEnvInt("EVENT_LOG_SIZE", 5000),
+
+		BrokerType:  getEnv("BROKER", "memory"),
+		BrokerRole:  getEnv("BROKER_ROLE", "leader"),
+		BrokerAddr:  getEnv("BROKER_ADDR", ":7400"),
+		BrokerToken: getEnv("BROKER_TOKEN", ""),
+
+		FavoritesStore:  getEnv("FAVORITES_STORE", "memory"),
+		FavoritesDBPath: getEnv("FAVORITES_DB_PATH", "./storage/favorites.db"),
+		IdempotencyTTL:  getEnvInt("IDEMPOTENCY_TTL", 86400),
+		AuditRetention:  getEnvInt("AUDIT_RETENTION_DAYS", 365),
+
+		AuthHS256Secret: getEnv("AUTH_HS256_SECRET", ""),
+		AuthJWKSFile:    getEnv("AUTH_JWKS_FILE", ""),
+		AuthIssuer:      getEnv("AUTH_ISSUER", ""),
+		AuthAudience:    getEnv("AUTH_AUDIENCE", ""),
+		AuthAnonymous:   getEnvBool("AUTH_ANONYMOUS", true),
+		AuthAdmins:      getEnvList("AUTH_ADMINS", nil),
+
+		RateLimitEnabled:        getEnvBool("RATE_LIMIT_ENABLED", true),
+		RateLimitRate:           getEnvInt("RATE_LIMIT_RATE", 300),
+		RateLimitBurst:          getEnvInt("RATE_LIMIT_BURST", 100),
+		RateLimitRoutes:         getEnvList("RATE_LIMIT_ROUTES", []string{"/health=0", "POST /api/favorites/import=10:5"}),
+		RateLimitAPIKeys:        getEnvList("RATE_LIMIT_API_KEYS", nil),
+		RateLimitTrustedProxies: getEnvList("RATE_LIMIT_TRUSTED_PROXIES", nil),
+		RateLimitMaxBuckets:     getEnvInt("RATE_LIMIT_MAX_BUCKETS", 10000),
+
+		AlertsEnabled:      getEnvBool("ALERTS_ENABLED", true),
+		AlertInterval:      getEnvInt("ALERT_INTERVAL", 30),
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 6),
+		WebhookRetryBase:   getEnvInt("WEBHOOK_RETRY_BASE", 5),
+		WebhookTimeout:     getEnvInt("WEBHOOK_TIMEOUT", 10),
+		WebhookLogSize:     getEnvInt("WEBHOOK_LOG_SIZE", 1000),
+		WebhookDeadLetters: getEnvInt("WEBHOOK_DEAD_LETTERS", 500),
+		WebhookAllowedNets: getEnvList("WEBHOOK_ALLOWED_NETWORKS", nil),
+
+		// Zürich HB, Bern, Basel SBB, Genève, Lausanne
+		StationWatchList:  getEnvList("STATION_WATCHLIST", []string{"8503000", "8507000", "8500010", "8501008", "8501120"}),
+		PollerDailyBudget: getEnvInt("POLLER_DAILY_BUDGET", 600),
+		PollerBoardLimit:  getEnvInt("POLLER_BOARD_LIMIT", 10),
 	}
 }
 
@@ -71,6 +175,22 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvList retrieves a comma-separated list environment variable.
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 // IsDevelopment returns true if running in development mode.
 func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
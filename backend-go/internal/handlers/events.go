@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// EventsHandler serves recent train events detected from live snapshots.
+type EventsHandler struct {
+	events *services.EventLog
+}
+
+// NewEventsHandler creates a new events handler.
+func NewEventsHandler(events *services.EventLog) *EventsHandler {
+	return &EventsHandler{events: events}
+}
+
+// GetEvents returns recent train events, oldest first.
+//
+//	GET /api/events?since=1697631234000123      events after an event ID
+//	GET /api/events?since=2025-03-14T07:30:00Z  events at or after a time
+//	GET /api/events?train=1001&station=8503000&type=arrival,departure&limit=50
+//
+// Poll with the last ID received as since to get each event once.
+func (h *EventsHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := services.EventQuery{
+		TrainID:   query.Get("train"),
+		StationID: query.Get("station"),
+		Limit:     defaultEventLimit,
+	}
+
+	if since := query.Get("since"); since != "" {
+		if id, err := strconv.ParseUint(since, 10, 64); err == nil {
+			q.AfterID = id
+		} else if at, err := time.Parse(time.RFC3339, since); err == nil {
+			q.Since = at
+		} else {
+			sendError(w, http.StatusBadRequest, "Invalid Parameter", "since must be an event ID or an RFC3339 time")
+			return
+		}
+	}
+
+	if types := query.Get("type"); types != "" {
+		q.Types = make(map[string]bool)
+		for _, eventType := range strings.Split(types, ",") {
+			eventType = strings.TrimSpace(eventType)
+			if !validEventTypes[eventType] {
+				sendError(w, http.StatusBadRequest, "Invalid Parameter",
+					"Unknown event type "+eventType+" (expected arrival, departure, pass_through, delay_change, platform_change or cancellation)")
+				return
+			}
+			q.Types[eventType] = true
+		}
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxEventLimit {
+			sendError(w, http.StatusBadRequest, "Invalid Parameter", "limit must be between 1 and "+strconv.Itoa(maxEventLimit))
+			return
+		}
+		q.Limit = limit
+	}
+
+	events, total := h.events.Query(q)
+
+	response := models.APIResponse{
+		Data: events,
+		Meta: &models.APIMeta{
+			Total:     total,
+			Count:     len(events),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "live_event_detector",
+			Filters: map[string]interface{}{
+				"since":   query.Get("since"),
+				"train":   q.TrainID,
+				"station": q.StationID,
+				"type":    query.Get("type"),
+				"limit":   q.Limit,
+			},
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+var validEventTypes = map[string]bool{
+	models.EventArrival:        true,
+	models.EventDeparture:      true,
+	models.EventPassThrough:    true,
+	models.EventDelayChange:    true,
+	models.EventPlatformChange: true,
+	models.EventCancellation:   true,
+}
@@ -0,0 +1,896 @@
+// Package handlers provides HTTP handlers for the Swiss Railway API.
+// This file implements alert rules on favorite trains and journeys ("delay
+// of 5 min or more", "cancelled", "platform changed", "departing in 10
+// min"), their evaluation against the live state and the webhook delivery
+// log.
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+const (
+	// maxAlertRules limits the alert rules of one owner.
+	maxAlertRules = 50
+
+	// maxAlertMinutes bounds the minutes of delay and departing_soon rules.
+	maxAlertMinutes = 180
+
+	// minSecretLength and maxSecretLength bound webhook secrets.
+	minSecretLength = 16
+	maxSecretLength = 256
+
+	// defaultDeliveries and maxDeliveries bound ?limit= of the delivery log.
+	defaultDeliveries = 100
+	maxDeliveries     = 500
+
+	// alertConnections is how many connections a journey rule looks at to
+	// find the usual one.
+	alertConnections = 3
+)
+
+// alertConditions lists the conditions each favorite type supports.
+var alertConditions = map[string][]string{
+	models.AlertFavoriteTrain: {
+		models.AlertDelay, models.AlertCancelled, models.AlertPlatformChanged, models.AlertDepartingSoon,
+	},
+	models.AlertFavoriteJourney: {
+		models.AlertDelay, models.AlertCancelled, models.AlertPlatformChanged, models.AlertDepartingSoon, models.AlertAtRisk,
+	},
+}
+
+// errAlertsDisabled is returned for alert requests while alerts are off.
+var errAlertsDisabled = newRequestError(http.StatusServiceUnavailable, "Service Unavailable",
+	"Alerts are disabled on this server")
+
+// SetAlerts enables alert rules: webhooks delivers the alerts and events
+// provides the platform changes.
+func (h *FavoritesHandler) SetAlerts(webhooks *services.WebhookDispatcher, events *services.EventLog) {
+	h.webhooks = webhooks
+	h.events = events
+}
+
+// alertsOwner returns the caller's owner key if alerts are enabled.
+func (h *FavoritesHandler) alertsOwner(ctx context.Context) (string, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return "", reqErr
+	}
+	if h.webhooks == nil {
+		return "", errAlertsDisabled
+	}
+	return owner, nil
+}
+
+// redactRule returns a rule without its secret, which is only shown when
+// the rule is created.
+func redactRule(rule models.AlertRule) models.AlertRule {
+	rule.Secret = ""
+	return rule
+}
+
+// validateWebhookURL checks that a webhook is an absolute http(s) URL whose
+// host resolves to addresses the server may reach. Deliveries check the
+// addresses again when they connect.
+func (h *FavoritesHandler) validateWebhookURL(ctx context.Context, raw string) (string, *models.RequestError) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if raw == "" || len(raw) > 2048 || err != nil ||
+		(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return "", newRequestError(http.StatusBadRequest, "Validation Error",
+			"webhookUrl must be an absolute http or https URL without credentials")
+	}
+	if err := h.webhooks.CheckURL(ctx, raw); err != nil {
+		if errors.Is(err, services.ErrWebhookAddress) {
+			return "", newRequestError(http.StatusBadRequest, "Validation Error",
+				"webhookUrl must not point to a loopback, private, link-local or multicast address")
+		}
+		return "", newRequestError(http.StatusBadRequest, "Validation Error",
+			"webhookUrl host "+u.Hostname()+" could not be resolved")
+	}
+	return raw, nil
+}
+
+// validateSecret checks a webhook secret, generating one if it is empty.
+func validateSecret(secret string) (string, *models.RequestError) {
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", newRequestError(http.StatusInternalServerError, "Internal Error", "Secret could not be generated")
+		}
+		return hex.EncodeToString(buf), nil
+	}
+	if len(secret) < minSecretLength || len(secret) > maxSecretLength {
+		return "", newRequestError(http.StatusBadRequest, "Validation Error",
+			"secret must be "+strconv.Itoa(minSecretLength)+" to "+strconv.Itoa(maxSecretLength)+" characters")
+	}
+	return secret, nil
+}
+
+// validateMinutes checks the minutes of a rule: required for delay and
+// departing_soon, not allowed otherwise.
+func validateMinutes(condition string, minutes int) *models.RequestError {
+	switch condition {
+	case models.AlertDelay, models.AlertDepartingSoon:
+		if minutes < 1 || minutes > maxAlertMinutes {
+			return newRequestError(http.StatusBadRequest, "Validation Error",
+				"minutes must be between 1 and "+strconv.Itoa(maxAlertMinutes)+" for "+condition)
+		}
+	default:
+		if minutes != 0 {
+			return newRequestError(http.StatusBadRequest, "Validation Error", "minutes is only used by delay and departing_soon")
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// ALERT RULES - Store operations (shared by REST and WebSocket RPC)
+// ============================================================================
+
+// ListAlertRules returns the caller's alert rules, oldest first.
+func (h *FavoritesHandler) ListAlertRules(ctx context.Context) ([]models.AlertRule, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	rules, err := h.repo.ListRules(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	for i := range rules {
+		rules[i] = redactRule(rules[i])
+	}
+	return rules, nil
+}
+
+// FindAlertRule returns one of the caller's alert rules by ID.
+func (h *FavoritesHandler) FindAlertRule(ctx context.Context, id string) (*models.AlertRule, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	rule, err := h.repo.GetRule(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Alert rule with ID "+id+" does not exist", "")
+	}
+	redacted := redactRule(*rule)
+	return &redacted, nil
+}
+
+// AddAlertRule validates and stores a new alert rule on one of the
+// caller's favorites. The rule is returned with its secret.
+func (h *FavoritesHandler) AddAlertRule(ctx context.Context, req models.CreateAlertRuleRequest) (*models.AlertRule, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	conditions, ok := alertConditions[req.FavoriteType]
+	if !ok {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error", "favoriteType must be train or journey")
+	}
+	if !containsString(conditions, req.Condition) {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"condition of a "+req.FavoriteType+" must be one of "+strings.Join(conditions, ", "))
+	}
+	if reqErr := validateMinutes(req.Condition, req.Minutes); reqErr != nil {
+		return nil, reqErr
+	}
+	webhookURL, reqErr := h.validateWebhookURL(ctx, req.WebhookURL)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	secret, reqErr := validateSecret(req.Secret)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	var err error
+	if req.FavoriteType == models.AlertFavoriteTrain {
+		_, err = h.repo.GetTrain(owner, req.FavoriteID)
+	} else {
+		_, err = h.repo.GetJourney(owner, req.FavoriteID)
+	}
+	if err != nil {
+		if reqErr := storeError(err, "", ""); reqErr.Status != http.StatusNotFound {
+			return nil, reqErr
+		}
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"Favorite "+req.FavoriteType+" with ID "+req.FavoriteID+" does not exist")
+	}
+
+	rules, err := h.repo.ListRules(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	if len(rules) >= maxAlertRules {
+		return nil, newRequestError(http.StatusConflict, "Conflict",
+			"At most "+strconv.Itoa(maxAlertRules)+" alert rules are allowed")
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	rule := models.AlertRule{
+		ID:           uuid.New().String(),
+		Owner:        owner,
+		FavoriteType: req.FavoriteType,
+		FavoriteID:   req.FavoriteID,
+		Condition:    req.Condition,
+		Minutes:      req.Minutes,
+		WebhookURL:   webhookURL,
+		Secret:       secret,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := h.repo.CreateRule(rule); err != nil {
+		return nil, storeError(err, "", "The favorite already has a "+rule.Condition+" rule")
+	}
+
+	log.Info().
+		Str("id", rule.ID).
+		Str("favoriteId", rule.FavoriteID).
+		Str("condition", rule.Condition).
+		Msg("Created alert rule")
+
+	return &rule, nil
+}
+
+// EditAlertRule updates the minutes, webhook, secret or state of an alert
+// rule. Omitted fields are kept.
+func (h *FavoritesHandler) EditAlertRule(ctx context.Context, id string, req models.UpdateAlertRuleRequest) (*models.AlertRule, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	var webhookURL, secret string
+	if req.WebhookURL != "" {
+		if webhookURL, reqErr = h.validateWebhookURL(ctx, req.WebhookURL); reqErr != nil {
+			return nil, reqErr
+		}
+	}
+	if req.Secret != "" {
+		if secret, reqErr = validateSecret(req.Secret); reqErr != nil {
+			return nil, reqErr
+		}
+	}
+
+	// The minutes are checked against the stored condition
+	current, err := h.repo.GetRule(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Alert rule with ID "+id+" does not exist", "")
+	}
+	if req.Minutes != nil {
+		if reqErr := validateMinutes(current.Condition, *req.Minutes); reqErr != nil {
+			return nil, reqErr
+		}
+	}
+
+	rule, err := h.repo.UpdateRule(owner, id, func(rule *models.AlertRule) {
+		if req.Minutes != nil {
+			rule.Minutes = *req.Minutes
+		}
+		if webhookURL != "" {
+			rule.WebhookURL = webhookURL
+		}
+		if secret != "" {
+			rule.Secret = secret
+		}
+		if req.Enabled != nil {
+			rule.Enabled = *req.Enabled
+		}
+		rule.UpdatedAt = time.Now().Format(time.RFC3339)
+	})
+	if err != nil {
+		return nil, storeError(err, "Alert rule with ID "+id+" does not exist", "")
+	}
+
+	log.Info().
+		Str("id", id).
+		Bool("enabled", rule.Enabled).
+		Msg("Updated alert rule")
+
+	redacted := redactRule(*rule)
+	return &redacted, nil
+}
+
+// RemoveAlertRule deletes an alert rule and returns it.
+func (h *FavoritesHandler) RemoveAlertRule(ctx context.Context, id string) (*models.AlertRule, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	rule, err := h.repo.DeleteRule(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Alert rule with ID "+id+" does not exist", "")
+	}
+
+	log.Info().
+		Str("id", id).
+		Str("favoriteId", rule.FavoriteID).
+		Msg("Deleted alert rule")
+
+	redacted := redactRule(*rule)
+	return &redacted, nil
+}
+
+// removeRules deletes the rules of a removed favorite. Failures are only
+// logged: rules of missing favorites never fire.
+func (h *FavoritesHandler) removeRules(owner, favoriteID string) {
+	rules, err := h.repo.ListRules(owner)
+	if err != nil {
+		log.Error().Err(err).Str("favoriteId", favoriteID).Msg("Failed to list alert rules")
+		return
+	}
+	for _, rule := range rules {
+		if rule.FavoriteID != favoriteID {
+			continue
+		}
+		if _, err := h.repo.DeleteRule(owner, rule.ID); err != nil {
+			log.Error().Err(err).Str("id", rule.ID).Msg("Failed to delete alert rule")
+		}
+	}
+}
+
+// TestAlertRule sends a test alert to a rule's webhook, enabled or not.
+func (h *FavoritesHandler) TestAlertRule(ctx context.Context, id string) (*models.WebhookDelivery, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	rule, err := h.repo.GetRule(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Alert rule with ID "+id+" does not exist", "")
+	}
+
+	delivery := h.webhooks.Dispatch(*rule, models.Alert{
+		ID:           uuid.New().String(),
+		RuleID:       rule.ID,
+		FavoriteType: rule.FavoriteType,
+		FavoriteID:   rule.FavoriteID,
+		Condition:    rule.Condition,
+		Message:      "Test alert for your " + rule.Condition + " rule",
+		Test:         true,
+		Timestamp:    time.Now().Format(time.RFC3339),
+	})
+	return &delivery, nil
+}
+
+// ListDeliveries returns the caller's logged webhook deliveries, newest
+// first, and how many matched before the limit.
+func (h *FavoritesHandler) ListDeliveries(ctx context.Context, ruleID, status string, limit int) ([]models.WebhookDelivery, int, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, 0, reqErr
+	}
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return nil, 0, newRequestError(http.StatusBadRequest, "Invalid Parameter",
+			"status must be pending, delivered or dead")
+	}
+	deliveries, total := h.webhooks.Deliveries(services.WebhookQuery{
+		Owner:  owner,
+		RuleID: ruleID,
+		Status: status,
+		Limit:  limit,
+	})
+	return deliveries, total, nil
+}
+
+// ListDeadLetters returns the caller's deliveries that gave up, newest
+// first.
+func (h *FavoritesHandler) ListDeadLetters(ctx context.Context) ([]models.WebhookDelivery, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	return h.webhooks.DeadLetters(owner), nil
+}
+
+// RetryDeadLetter redelivers a dead delivery to its rule's current webhook.
+func (h *FavoritesHandler) RetryDeadLetter(ctx context.Context, id string) (*models.WebhookDelivery, *models.RequestError) {
+	owner, reqErr := h.alertsOwner(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	dead, ok := h.webhooks.DeadLetter(owner, id)
+	if !ok {
+		return nil, newRequestError(http.StatusNotFound, "Not Found", "Dead letter with ID "+id+" does not exist")
+	}
+	rule, err := h.repo.GetRule(owner, dead.RuleID)
+	if err != nil {
+		return nil, storeError(err, "The alert rule of the delivery was deleted", "")
+	}
+	delivery, ok := h.webhooks.Redeliver(owner, id, *rule)
+	if !ok {
+		return nil, newRequestError(http.StatusNotFound, "Not Found", "Dead letter with ID "+id+" does not exist")
+	}
+	return &delivery, nil
+}
+
+// ============================================================================
+// ALERT EVALUATION
+// ============================================================================
+
+// EvaluateAlerts returns the alerts of everyone's enabled rules at now, for
+// the alert monitor. Platform changes are the events detected after since.
+// Journeys use the local planner only, so alerts spend no upstream calls.
+func (h *FavoritesHandler) EvaluateAlerts(since, now time.Time) []services.AlertMatch {
+	if !h.gtfsService.IsDataLoaded() {
+		return nil
+	}
+	rules, err := h.repo.AllRules()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list alert rules")
+		return nil
+	}
+
+	now = swissTime(now)
+	var matches []services.AlertMatch
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		var nickname string
+		var alerts []models.Alert
+		switch rule.FavoriteType {
+		case models.AlertFavoriteTrain:
+			favorite, err := h.repo.GetTrain(rule.Owner, rule.FavoriteID)
+			if err != nil {
+				continue
+			}
+			nickname = favorite.Nickname
+			alerts = h.trainAlerts(rule, *favorite, since, now)
+		case models.AlertFavoriteJourney:
+			favorite, err := h.repo.GetJourney(rule.Owner, rule.FavoriteID)
+			if err != nil {
+				continue
+			}
+			nickname = favorite.Nickname
+			alerts = h.journeyAlerts(rule, favorite, since, now)
+		}
+
+		for _, alert := range alerts {
+			alert.Key = rule.ID + "|" + alert.Key
+			alert.RuleID = rule.ID
+			alert.Owner = rule.Owner
+			alert.FavoriteType = rule.FavoriteType
+			alert.FavoriteID = rule.FavoriteID
+			alert.Nickname = nickname
+			alert.Condition = rule.Condition
+			matches = append(matches, services.AlertMatch{Rule: rule, Alert: alert})
+		}
+	}
+	return matches
+}
+
+// trainAlerts checks a rule of a favorite train against today's run.
+func (h *FavoritesHandler) trainAlerts(rule models.AlertRule, favorite models.FavoriteTrain, since, now time.Time) []models.Alert {
+	pattern := h.patternOf(favorite)
+	if pattern == nil {
+		return nil
+	}
+	label := pattern.Name + " " + pattern.DepartureTime
+	if pattern.OriginName != "" {
+		label += " from " + pattern.OriginName
+	}
+
+	if rule.Condition == models.AlertDepartingSoon {
+		tripID, _, departure, ok := h.gtfsService.NextPatternRun(*pattern, now, 1)
+		if !ok {
+			return nil
+		}
+		delay := 0
+		if train := h.gtfsService.GetLiveTrain(tripID); train != nil {
+			delay = train.Delay
+		}
+		until := departure.Add(time.Duration(delay) * time.Minute).Sub(now)
+		if until <= 0 || until > time.Duration(rule.Minutes)*time.Minute {
+			return nil
+		}
+		return []models.Alert{{
+			Key:       tripID + "|" + departure.Format(time.RFC3339),
+			TripID:    tripID,
+			Name:      pattern.Name,
+			Station:   h.gtfsService.GetStationByID(pattern.OriginID),
+			Departure: departure.Format(time.RFC3339),
+			Delay:     delay,
+			Message:   label + " departs in " + strconv.Itoa(int(until.Minutes()+0.5)) + " min",
+		}}
+	}
+
+	tripID, ok := h.gtfsService.ResolvePattern(*pattern, now)
+	if !ok {
+		return nil
+	}
+	train := h.gtfsService.GetLiveTrain(tripID)
+	if train == nil {
+		return nil
+	}
+	base := models.Alert{
+		Key:     tripID,
+		TripID:  tripID,
+		Name:    train.Name,
+		Station: train.CurrentStation,
+		Delay:   train.Delay,
+	}
+
+	switch rule.Condition {
+	case models.AlertDelay:
+		if train.Delay >= rule.Minutes {
+			base.Message = label + " is " + strconv.Itoa(train.Delay) + " min late"
+			return []models.Alert{base}
+		}
+	case models.AlertCancelled:
+		if train.Cancelled {
+			base.Message = label + " is cancelled"
+			return []models.Alert{base}
+		}
+	case models.AlertPlatformChanged:
+		return h.platformAlerts(base, label, tripID, "", since)
+	}
+	return nil
+}
+
+// journeyAlerts checks a rule of a favorite journey against its usual (or
+// else next) connection.
+func (h *FavoritesHandler) journeyAlerts(rule models.AlertRule, favorite *models.FavoriteJourney, since, now time.Time) []models.Alert {
+	result, reqErr := h.journeyConnections(favorite, sourceGTFS, alertConnections, now)
+	if reqErr != nil || len(result.Connections) == 0 {
+		return nil
+	}
+	conn := result.Usual
+	if conn == nil {
+		conn = &result.Connections[0]
+	}
+	departure, err := time.Parse(time.RFC3339, conn.Departure)
+	if err != nil {
+		return nil
+	}
+
+	label := conn.From.Name + " → " + conn.To.Name + " at " + departure.Format("15:04")
+	base := models.Alert{
+		Key:       conn.Departure,
+		Name:      strings.Join(conn.Products, ", "),
+		Station:   conn.From,
+		Departure: conn.Departure,
+		Delay:     conn.DepartureDelay,
+	}
+	if len(conn.Legs) > 0 {
+		base.TripID = conn.Legs[0].TripID
+		base.Key += "|" + base.TripID
+	}
+
+	switch rule.Condition {
+	case models.AlertDelay:
+		delay := conn.DepartureDelay
+		if conn.ArrivalDelay > delay {
+			delay = conn.ArrivalDelay
+		}
+		if delay >= rule.Minutes {
+			base.Delay = delay
+			base.Message = label + " is " + strconv.Itoa(delay) + " min late"
+			return []models.Alert{base}
+		}
+	case models.AlertCancelled:
+		if conn.Cancelled {
+			base.Message = label + " is cancelled"
+			return []models.Alert{base}
+		}
+	case models.AlertAtRisk:
+		if conn.AtRisk {
+			base.Risks = conn.Risks
+			base.Message = label + " is at risk: " + strings.Join(conn.Risks, "; ")
+			return []models.Alert{base}
+		}
+	case models.AlertDepartingSoon:
+		until := departure.Add(time.Duration(conn.DepartureDelay) * time.Minute).Sub(now)
+		if until > 0 && until <= time.Duration(rule.Minutes)*time.Minute {
+			base.Message = label + " departs in " + strconv.Itoa(int(until.Minutes()+0.5)) + " min"
+			return []models.Alert{base}
+		}
+	case models.AlertPlatformChanged:
+		var alerts []models.Alert
+		for _, leg := range conn.Legs {
+			if leg.TripID == "" || leg.From == nil {
+				continue
+			}
+			alerts = append(alerts, h.platformAlerts(base, label, leg.TripID, leg.From.ID, since)...)
+		}
+		return alerts
+	}
+	return nil
+}
+
+// platformAlerts returns an alert per platform change of a trip detected
+// after since, at stationID only unless it is empty.
+func (h *FavoritesHandler) platformAlerts(base models.Alert, label, tripID, stationID string, since time.Time) []models.Alert {
+	if h.events == nil {
+		return nil
+	}
+	events, _ := h.events.Query(services.EventQuery{
+		Since:     since,
+		TrainID:   tripID,
+		StationID: stationID,
+		Types:     map[string]bool{models.EventPlatformChange: true},
+	})
+
+	alerts := make([]models.Alert, 0, len(events))
+	for _, event := range events {
+		alert := base
+		alert.Key = tripID + "|" + strconv.FormatUint(event.ID, 10)
+		alert.TripID = tripID
+		alert.Station = event.Station
+		alert.Platform = event.Platform
+		alert.PreviousPlatform = event.PreviousPlatform
+		station := "a stop"
+		if event.Station != nil {
+			station = event.Station.Name
+		}
+		alert.Message = label + ": platform " + event.Platform + " at " + station +
+			" (was " + event.PreviousPlatform + ")"
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// ============================================================================
+// /api/favorites/alerts - REST endpoints
+// ============================================================================
+
+// GetAlertRules returns all alert rules.
+func (h *FavoritesHandler) GetAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, reqErr := h.ListAlertRules(r.Context())
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: rules,
+		Meta: &models.APIMeta{
+			Total:     len(rules),
+			Count:     len(rules),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "alert_rules_store",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAlertRule returns a specific alert rule by ID.
+func (h *FavoritesHandler) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	rule, reqErr := h.FindAlertRule(r.Context(), id)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: rule,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "alert_rules_store",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateAlertRule adds an alert rule to a favorite.
+func (h *FavoritesHandler) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
+		return
+	}
+
+	var req models.CreateAlertRuleRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	rule, reqErr := h.AddAlertRule(r.Context(), req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	response := models.APIResponse{
+		Data: rule,
+		Meta: &models.APIMeta{
+			Timestamp: rule.CreatedAt,
+			Source:    "alert_rules_store",
+			Note:      "Alert rule created; keep the secret to verify webhook signatures",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateAlertRule modifies an existing alert rule.
+func (h *FavoritesHandler) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+
+	var req models.UpdateAlertRuleRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	rule, reqErr := h.EditAlertRule(r.Context(), id, req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: rule,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "alert_rules_store",
+			Note:      "Alert rule updated successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteAlertRule removes an alert rule.
+func (h *FavoritesHandler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, reqErr := h.RemoveAlertRule(r.Context(), id); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: deletedResult(id),
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "alert_rules_store",
+			Note:      "Alert rule deleted successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PostAlertRuleTest queues a test delivery to a rule's webhook.
+func (h *FavoritesHandler) PostAlertRuleTest(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	delivery, reqErr := h.TestAlertRule(r.Context(), id)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	response := models.APIResponse{
+		Data: delivery,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "webhook_dispatcher",
+			Note:      "Test delivery queued; see /api/favorites/alerts/deliveries",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDeliveries returns the delivery log, newest first.
+// ?ruleId=, ?status=pending|delivered|dead and ?limit=N (1-500, default 100).
+func (h *FavoritesHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultDeliveries
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxDeliveries {
+			sendError(w, http.StatusBadRequest, "Invalid Parameter",
+				"limit must be between 1 and "+strconv.Itoa(maxDeliveries))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, total, reqErr := h.ListDeliveries(r.Context(), query.Get("ruleId"), query.Get("status"), limit)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	filters := map[string]interface{}{"limit": limit}
+	if ruleID := query.Get("ruleId"); ruleID != "" {
+		filters["ruleId"] = ruleID
+	}
+	if status := query.Get("status"); status != "" {
+		filters["status"] = status
+	}
+	response := models.APIResponse{
+		Data: deliveries,
+		Meta: &models.APIMeta{
+			Total:     total,
+			Count:     len(deliveries),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "webhook_dispatcher",
+			Filters:   filters,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetDeadLetters returns the deliveries that gave up.
+func (h *FavoritesHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deliveries, reqErr := h.ListDeadLetters(r.Context())
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: deliveries,
+		Meta: &models.APIMeta{
+			Total:     len(deliveries),
+			Count:     len(deliveries),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "webhook_dispatcher",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PostDeadLetterRetry redelivers a dead delivery.
+func (h *FavoritesHandler) PostDeadLetterRetry(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	delivery, reqErr := h.RetryDeadLetter(r.Context(), id)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	response := models.APIResponse{
+		Data: delivery,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "webhook_dispatcher",
+			Note:      "Delivery queued again",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,362 @@
+// Package handlers provides HTTP handlers for the Swiss Railway API.
+// This file implements the audit log of favorites: who created, changed,
+// deleted or imported each favorite, in which request, with its values
+// before and after. Owners read the history of their favorites;
+// administrators query everyone's.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/auth"
+	"github.com/swiss-railway/backend-go/internal/middleware"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/store"
+)
+
+const (
+	// defaultAuditLimit and maxAuditLimit bound the entries of a query.
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+
+	// auditPurgeInterval is how often entries past the retention period
+	// are removed.
+	auditPurgeInterval = time.Hour
+)
+
+// errNotAdmin is returned for audit queries by anyone but administrators.
+var errNotAdmin = newRequestError(http.StatusForbidden, "Forbidden",
+	"The audit log is only available to administrators")
+
+// auditActionKey is the context key of the action creates are audited as.
+type auditActionKey struct{}
+
+// withAuditAction returns a context whose creates are audited as action,
+// e.g. models.AuditImport.
+func withAuditAction(ctx context.Context, action string) context.Context {
+	return context.WithValue(ctx, auditActionKey{}, action)
+}
+
+// createAction returns the action creates in ctx are audited as.
+func createAction(ctx context.Context) string {
+	if action, ok := ctx.Value(auditActionKey{}).(string); ok {
+		return action
+	}
+	return models.AuditCreate
+}
+
+// SetAuditRetention sets how long audit entries are kept; 0 keeps them
+// forever.
+func (h *FavoritesHandler) SetAuditRetention(retention time.Duration) {
+	h.auditRetention = retention
+}
+
+// audit appends an entry for a change to one of owner's favorites. before
+// and after are the favorite before and after the change, or nil. The
+// change is already made, so a failure is logged rather than returned.
+func (h *FavoritesHandler) audit(ctx context.Context, owner, action, favoriteType, favoriteID string, before, after interface{}) {
+	entry := models.AuditEntry{
+		ID:              uuid.New().String(),
+		Owner:           owner,
+		Actor:           owner,
+		RequestID:       middleware.RequestIDFromContext(ctx),
+		ClientRequestID: middleware.ClientRequestIDFromContext(ctx),
+		Action:          action,
+		FavoriteType:    favoriteType,
+		FavoriteID:      favoriteID,
+		Timestamp:       time.Now().Format(time.RFC3339),
+	}
+	if identity, ok := auth.FromContext(ctx); ok {
+		entry.Actor = identity.Owner()
+	}
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			log.Error().Err(err).Str("favoriteId", favoriteID).Msg("Failed to encode audited favorite")
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			log.Error().Err(err).Str("favoriteId", favoriteID).Msg("Failed to encode audited favorite")
+		}
+	}
+
+	if err := h.repo.AppendAudit(entry); err != nil {
+		log.Error().
+			Err(err).
+			Str("action", action).
+			Str("favoriteId", favoriteID).
+			Str("requestId", entry.RequestID).
+			Msg("Failed to record audit entry")
+	}
+	h.purgeAudit(time.Now())
+}
+
+// purgeAudit removes the entries past the retention period, at most once
+// an auditPurgeInterval.
+func (h *FavoritesHandler) purgeAudit(now time.Time) {
+	if h.auditRetention <= 0 {
+		return
+	}
+	h.auditMu.Lock()
+	if now.Sub(h.lastAuditPurge) < auditPurgeInterval {
+		h.auditMu.Unlock()
+		return
+	}
+	h.lastAuditPurge = now
+	h.auditMu.Unlock()
+
+	purged, err := h.repo.PurgeAudit(now.Add(-h.auditRetention))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to purge audit entries")
+		return
+	}
+	if purged > 0 {
+		log.Info().Int("purged", purged).Msg("Purged audit entries past retention")
+	}
+}
+
+// versionedFavorite is a favorite as seen before or after an operation
+// that may rewrite several of them.
+type versionedFavorite struct {
+	kind    string // one of the AuditFavorite* types
+	version int
+	value   interface{}
+}
+
+// favoriteVersions returns the owner's favorites of the given kinds by ID.
+func (h *FavoritesHandler) favoriteVersions(owner string, kinds ...string) (map[string]versionedFavorite, error) {
+	favorites := make(map[string]versionedFavorite)
+	for _, kind := range kinds {
+		switch kind {
+		case models.AuditFavoriteStation:
+			stations, err := h.repo.ListStations(owner)
+			if err != nil {
+				return nil, err
+			}
+			for _, favorite := range stations {
+				favorites[favorite.ID] = versionedFavorite{kind, favorite.Version, favorite}
+			}
+		case models.AuditFavoriteTrain:
+			trains, err := h.repo.ListTrains(owner)
+			if err != nil {
+				return nil, err
+			}
+			for _, favorite := range trains {
+				favorites[favorite.ID] = versionedFavorite{kind, favorite.Version, favorite}
+			}
+		case models.AuditFavoriteJourney:
+			journeys, err := h.repo.ListJourneys(owner)
+			if err != nil {
+				return nil, err
+			}
+			for _, favorite := range journeys {
+				favorites[favorite.ID] = versionedFavorite{kind, favorite.Version, favorite}
+			}
+		}
+	}
+	return favorites, nil
+}
+
+// auditRewrites appends an update entry for each favorite an operation
+// rewrote on the side, as reorders and collection deletes do: those of
+// before whose version changed since.
+func (h *FavoritesHandler) auditRewrites(ctx context.Context, owner string, before map[string]versionedFavorite, kinds ...string) {
+	after, err := h.favoriteVersions(owner, kinds...)
+	if err != nil {
+		log.Error().Err(err).Str("owner", owner).Msg("Failed to read favorites for the audit log")
+		return
+	}
+	ids := make([]string, 0, len(after))
+	for id, favorite := range after {
+		if old, ok := before[id]; ok && old.version != favorite.version {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		h.audit(ctx, owner, models.AuditUpdate, after[id].kind, id, before[id].value, after[id].value)
+	}
+}
+
+// auditQuery reads the time range and limit of an audit query: ?from= and
+// ?to= (RFC 3339 times or dates; to is exclusive) and ?limit=.
+func auditQuery(values url.Values) (store.AuditQuery, *models.RequestError) {
+	query := store.AuditQuery{Limit: defaultAuditLimit}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		value := values.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", value); err != nil {
+				return query, newRequestError(http.StatusBadRequest, "Invalid Parameter",
+					bound.name+" must be an RFC 3339 time (2024-05-01T08:00:00Z) or a date (2024-05-01)")
+			}
+		}
+		*bound.t = t
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return query, newRequestError(http.StatusBadRequest, "Invalid Parameter", "from must be before to")
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return query, newRequestError(http.StatusBadRequest, "Invalid Parameter",
+				"limit must be between 1 and "+strconv.Itoa(maxAuditLimit))
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+// auditFilters describes an audit query for the response's meta.
+func auditFilters(query store.AuditQuery) map[string]interface{} {
+	filters := map[string]interface{}{"limit": query.Limit}
+	if !query.From.IsZero() {
+		filters["from"] = query.From.Format(time.RFC3339)
+	}
+	if !query.To.IsZero() {
+		filters["to"] = query.To.Format(time.RFC3339)
+	}
+	for name, value := range map[string]string{
+		"owner": query.Owner, "actor": query.Actor, "action": query.Action, "favoriteId": query.FavoriteID,
+	} {
+		if value != "" {
+			filters[name] = value
+		}
+	}
+	return filters
+}
+
+// FavoriteHistory returns the audit entries of one of the caller's
+// favorites in the query's range, newest first. Deleted favorites keep
+// their history; a favorite the caller never had is 404.
+func (h *FavoritesHandler) FavoriteHistory(ctx context.Context, id string, query store.AuditQuery) ([]models.AuditEntry, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	query.Owner = owner
+	query.FavoriteID = id
+	query.Actor, query.Action = "", ""
+
+	entries, err := h.repo.ListAudit(query)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	if len(entries) == 0 {
+		any, err := h.repo.ListAudit(store.AuditQuery{Owner: owner, FavoriteID: id, Limit: 1})
+		if err != nil {
+			return nil, storeError(err, "", "")
+		}
+		if len(any) == 0 {
+			return nil, newRequestError(http.StatusNotFound, "Not Found", "Favorite with ID "+id+" has no history")
+		}
+	}
+	return entries, nil
+}
+
+// AuditLog returns the audit entries of every owner the query selects,
+// newest first. Only administrators may read it.
+func (h *FavoritesHandler) AuditLog(ctx context.Context, query store.AuditQuery) ([]models.AuditEntry, *models.RequestError) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+	if !identity.Admin {
+		return nil, errNotAdmin
+	}
+
+	switch query.Action {
+	case "", models.AuditCreate, models.AuditUpdate, models.AuditDelete, models.AuditImport:
+	default:
+		return nil, newRequestError(http.StatusBadRequest, "Invalid Parameter",
+			"action must be create, update, delete or import")
+	}
+
+	entries, err := h.repo.ListAudit(query)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	return entries, nil
+}
+
+// ============================================================================
+// REST handlers
+// ============================================================================
+
+// GetFavoriteHistory returns the changes to a favorite station, train or
+// journey (?from=, ?to=, ?limit=).
+func (h *FavoritesHandler) GetFavoriteHistory(w http.ResponseWriter, r *http.Request) {
+	query, reqErr := auditQuery(r.URL.Query())
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+	entries, reqErr := h.FavoriteHistory(r.Context(), mux.Vars(r)["id"], query)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: entries,
+		Meta: &models.APIMeta{
+			Count:     len(entries),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_audit",
+			Filters:   auditFilters(query),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAuditLog returns the audit log of every owner's favorites for
+// administrators (?from=, ?to=, ?owner=, ?actor=, ?action=, ?favoriteId=,
+// ?limit=).
+func (h *FavoritesHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	query, reqErr := auditQuery(values)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+	query.Owner = values.Get("owner")
+	query.Actor = values.Get("actor")
+	query.Action = values.Get("action")
+	query.FavoriteID = values.Get("favoriteId")
+
+	entries, reqErr := h.AuditLog(r.Context(), query)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: entries,
+		Meta: &models.APIMeta{
+			Count:     len(entries),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_audit",
+			Filters:   auditFilters(query),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/middleware"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/store"
+)
+
+func TestAuditTrail(t *testing.T) {
+	h := newTestFavorites(t)
+	ctx := middleware.WithRequestID(asUser("alice"), "req-1")
+
+	zurich, reqErr := h.AddFavorite(ctx, models.CreateFavoriteRequest{StationID: "8503000"})
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	olten, reqErr := h.AddFavorite(ctx, models.CreateFavoriteRequest{StationID: "8500218"})
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	bern, reqErr := h.AddFavorite(ctx, models.CreateFavoriteRequest{StationID: "8507000"})
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	if _, reqErr := h.EditFavorite(ctx, zurich.ID, models.UpdateFavoriteRequest{Nickname: "home"}); reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	// Bern and Zürich HB swap places; Olten stays
+	if _, reqErr := h.ReorderFavorites(ctx, models.ReorderRequest{IDs: []string{bern.ID, olten.ID, zurich.ID}}); reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	if _, reqErr := h.RemoveFavorite(ctx, olten.ID); reqErr != nil {
+		t.Fatal(reqErr)
+	}
+
+	entries, err := h.repo.ListAudit(store.AuditQuery{Owner: "user:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		action, id    string
+		before, after bool
+	}{
+		{models.AuditDelete, olten.ID, true, false},
+		{models.AuditUpdate, "", true, true},        // moved (by ID order)
+		{models.AuditUpdate, "", true, true},        // moved
+		{models.AuditUpdate, zurich.ID, true, true}, // renamed
+		{models.AuditCreate, bern.ID, false, true},
+		{models.AuditCreate, olten.ID, false, true},
+		{models.AuditCreate, zurich.ID, false, true},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("%d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		entry := entries[i]
+		if entry.Action != w.action || (w.id != "" && entry.FavoriteID != w.id) || entry.FavoriteType != models.AuditFavoriteStation ||
+			(entry.Before != nil) != w.before || (entry.After != nil) != w.after ||
+			entry.Actor != "user:alice" || entry.RequestID != "req-1" {
+			t.Errorf("entry %d = %+v, want %s of %s", i, entry, w.action, w.id)
+		}
+	}
+
+	// The reorder's entries hold the favorites before and after it
+	moves := make(map[string][2]int) // stationId -> positions before and after
+	for _, entry := range entries[1:3] {
+		var before, after models.FavoriteStation
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(entry.After, &after); err != nil {
+			t.Fatal(err)
+		}
+		if after.Version != before.Version+1 || after.Nickname != before.Nickname {
+			t.Errorf("reorder: %+v -> %+v", before, after)
+		}
+		moves[after.StationID] = [2]int{before.Position, after.Position}
+	}
+	if moves["8503000"] != [2]int{0, 2} || moves["8507000"] != [2]int{2, 0} {
+		t.Errorf("moves = %v, want Zürich HB and Bern swapped", moves)
+	}
+
+	// Deleted favorites keep their history; other users don't see it
+	history, reqErr := h.FavoriteHistory(ctx, olten.ID, store.AuditQuery{})
+	if reqErr != nil || len(history) != 2 || history[0].Action != models.AuditDelete {
+		t.Errorf("history = %+v (%v)", history, reqErr)
+	}
+	if _, reqErr := h.FavoriteHistory(asUser("bob"), olten.ID, store.AuditQuery{}); reqErr == nil || reqErr.Status != http.StatusNotFound {
+		t.Errorf("bob's history: %+v", reqErr)
+	}
+	if _, reqErr := h.AuditLog(ctx, store.AuditQuery{}); reqErr == nil || reqErr.Status != http.StatusForbidden {
+		t.Errorf("audit log of a user: %+v", reqErr)
+	}
+}
+
+func TestAuditRetention(t *testing.T) {
+	h := newTestFavorites(t)
+	now := time.Now()
+	appendAt := func(id string, at time.Time) {
+		t.Helper()
+		if err := h.repo.AppendAudit(models.AuditEntry{ID: id, Owner: "user:alice", Action: models.AuditCreate, Timestamp: at.Format(time.RFC3339)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func() []string {
+		t.Helper()
+		entries, err := h.repo.ListAudit(store.AuditQuery{Owner: "user:alice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		return ids
+	}
+
+	// Kept forever without a retention period
+	appendAt("ancient", now.AddDate(-3, 0, 0))
+	h.purgeAudit(now)
+	if got := ids(); len(got) != 1 {
+		t.Fatalf("entries = %v, want the ancient one kept", got)
+	}
+
+	// A change purges the entries past AUDIT_RETENTION_DAYS
+	h.SetAuditRetention(30 * 24 * time.Hour)
+	appendAt("old", now.AddDate(0, 0, -31))
+	appendAt("recent", now.AddDate(0, 0, -29))
+	if _, reqErr := h.AddFavorite(asUser("alice"), models.CreateFavoriteRequest{StationID: "8503000"}); reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	got := ids()
+	if len(got) != 2 || got[1] != "recent" {
+		t.Fatalf("entries = %v, want the create and recent", got)
+	}
+
+	// At most once an hour
+	appendAt("expired", now.AddDate(0, 0, -40))
+	h.purgeAudit(now.Add(30 * time.Minute))
+	if got := ids(); len(got) != 3 {
+		t.Errorf("entries = %v, want no purge within the hour", got)
+	}
+	h.purgeAudit(now.Add(auditPurgeInterval + time.Minute))
+	if got := ids(); len(got) != 2 || got[0] == "expired" {
+		t.Errorf("entries = %v, want expired purged", got)
+	}
+}
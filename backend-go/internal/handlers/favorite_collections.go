@@ -0,0 +1,729 @@
+// Package handlers provides HTTP handlers for the Swiss Railway API.
+// This file implements organizing favorites: named collections (such as
+// "Commute"), tags, the owner's order of favorites with drag-and-drop
+// reordering, and filtering and sorting the favorites lists.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+const (
+	// maxCollections limits the collections of an owner.
+	maxCollections = 50
+
+	// maxCollectionName limits the length of a collection name.
+	maxCollectionName = 50
+
+	// maxTags and maxTagLength limit the tags of a favorite.
+	maxTags      = 10
+	maxTagLength = 30
+
+	// maxReorderIDs limits the IDs of a reorder request.
+	maxReorderIDs = 1000
+)
+
+// noCollection filters favorites that aren't in a collection.
+const noCollection = "none"
+
+// Sort orders of the favorites lists.
+const (
+	sortPosition = "position"
+	sortName     = "name"
+	sortCreated  = "created"
+	sortUpdated  = "updated"
+)
+
+// validateTags sanitizes tags, lowercases them and drops repeated ones.
+func validateTags(tags []string) ([]string, *models.RequestError) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(sanitizeString(tag))
+		if tag == "" {
+			return nil, newRequestError(http.StatusBadRequest, "Validation Error", "Tags must not be empty")
+		}
+		if len(tag) > maxTagLength {
+			return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+				"Tags must be "+strconv.Itoa(maxTagLength)+" characters or less")
+		}
+		if !containsString(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTags {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"At most "+strconv.Itoa(maxTags)+" tags are allowed")
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// validateCollection checks that the owner has the collection a favorite is
+// filed in ("" for none).
+func (h *FavoritesHandler) validateCollection(owner, id string) *models.RequestError {
+	if id == "" {
+		return nil
+	}
+	if _, err := h.repo.GetCollection(owner, id); err != nil {
+		reqErr := storeError(err, "", "")
+		if reqErr.Status == http.StatusNotFound {
+			return newRequestError(http.StatusBadRequest, "Validation Error",
+				"Collection with ID "+id+" does not exist")
+		}
+		return reqErr
+	}
+	return nil
+}
+
+// validateCollectionName sanitizes and checks a collection name.
+func validateCollectionName(name string) (string, *models.RequestError) {
+	name = sanitizeString(name)
+	if name == "" {
+		return "", newRequestError(http.StatusBadRequest, "Validation Error", "name is required")
+	}
+	if len(name) > maxCollectionName {
+		return "", newRequestError(http.StatusBadRequest, "Validation Error",
+			"Name must be "+strconv.Itoa(maxCollectionName)+" characters or less")
+	}
+	return name, nil
+}
+
+// validateReorder checks the IDs of a reorder request.
+func validateReorder(req models.ReorderRequest) *models.RequestError {
+	if len(req.IDs) == 0 {
+		return newRequestError(http.StatusBadRequest, "Validation Error", "ids is required")
+	}
+	if len(req.IDs) > maxReorderIDs {
+		return newRequestError(http.StatusBadRequest, "Validation Error",
+			"At most "+strconv.Itoa(maxReorderIDs)+" ids can be reordered at once")
+	}
+	seen := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			return newRequestError(http.StatusBadRequest, "Validation Error", "ids lists "+id+" more than once")
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// ============================================================================
+// FILTERING AND SORTING - Query parameters of the favorites lists
+// ============================================================================
+
+// favoriteFilter selects and orders a favorites list. It is read from the
+// query of the list endpoints and from the params of the list RPC methods.
+type favoriteFilter struct {
+	Collection string   `json:"collection"` // collection ID, or "none"
+	Tags       []string `json:"tags"`       // favorites with all of these tags
+	Query      string   `json:"q"`          // text in the name, nickname or notes
+	Sort       string   `json:"sort"`       // position (default), name, created or updated
+	Order      string   `json:"order"`      // asc (default) or desc
+}
+
+// filterFromQuery reads a filter from ?collection=, ?tag= (repeatable or
+// comma-separated), ?q=, ?sort= and ?order=.
+func filterFromQuery(query url.Values) favoriteFilter {
+	var tags []string
+	for _, value := range query["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return favoriteFilter{
+		Collection: query.Get("collection"),
+		Tags:       tags,
+		Query:      query.Get("q"),
+		Sort:       query.Get("sort"),
+		Order:      query.Get("order"),
+	}
+}
+
+// normalize checks the filter and fills in its defaults.
+func (f *favoriteFilter) normalize() *models.RequestError {
+	switch f.Sort {
+	case "":
+		f.Sort = sortPosition
+	case sortPosition, sortName, sortCreated, sortUpdated:
+	default:
+		return newRequestError(http.StatusBadRequest, "Invalid Parameter",
+			"sort must be position, name, created or updated")
+	}
+	switch f.Order {
+	case "":
+		f.Order = "asc"
+	case "asc", "desc":
+	default:
+		return newRequestError(http.StatusBadRequest, "Invalid Parameter", "order must be asc or desc")
+	}
+	for i, tag := range f.Tags {
+		f.Tags[i] = strings.ToLower(strings.TrimSpace(tag))
+	}
+	f.Query = strings.ToLower(strings.TrimSpace(f.Query))
+	return nil
+}
+
+// meta returns the filter, with its defaults, for the response's meta.
+func (f favoriteFilter) meta() map[string]interface{} {
+	f.normalize()
+	filters := map[string]interface{}{"sort": f.Sort, "order": f.Order}
+	if f.Collection != "" {
+		filters["collection"] = f.Collection
+	}
+	if len(f.Tags) > 0 {
+		filters["tags"] = f.Tags
+	}
+	if f.Query != "" {
+		filters["q"] = f.Query
+	}
+	return filters
+}
+
+// favoriteEntry is what filters and sorts see of a favorite.
+type favoriteEntry struct {
+	collectionID string
+	tags         []string
+	name         string   // display name, sorted by
+	text         []string // searched by ?q=
+	position     int
+	createdAt    string
+	updatedAt    string
+}
+
+// apply returns the indexes of the entries the filter keeps, in its order.
+func (f favoriteFilter) apply(n int, entry func(i int) favoriteEntry) []int {
+	entries := make([]favoriteEntry, n)
+	kept := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		entries[i] = entry(i)
+		if f.matches(entries[i]) {
+			kept = append(kept, i)
+		}
+	}
+
+	sort.SliceStable(kept, func(a, b int) bool {
+		x, y := entries[kept[a]], entries[kept[b]]
+		if f.Order == "desc" {
+			x, y = y, x
+		}
+		switch f.Sort {
+		case sortName:
+			if nx, ny := strings.ToLower(x.name), strings.ToLower(y.name); nx != ny {
+				return nx < ny
+			}
+		case sortCreated:
+			if x.createdAt != y.createdAt {
+				return x.createdAt < y.createdAt
+			}
+		case sortUpdated:
+			if x.updatedAt != y.updatedAt {
+				return x.updatedAt < y.updatedAt
+			}
+		}
+		return x.position < y.position
+	})
+	return kept
+}
+
+// matches reports whether the filter keeps an entry.
+func (f favoriteFilter) matches(entry favoriteEntry) bool {
+	switch f.Collection {
+	case "":
+	case noCollection:
+		if entry.collectionID != "" {
+			return false
+		}
+	default:
+		if entry.collectionID != f.Collection {
+			return false
+		}
+	}
+	for _, tag := range f.Tags {
+		if !containsString(entry.tags, tag) {
+			return false
+		}
+	}
+	if f.Query == "" {
+		return true
+	}
+	for _, text := range entry.text {
+		if strings.Contains(strings.ToLower(text), f.Query) {
+			return true
+		}
+	}
+	return false
+}
+
+// stationEntry, trainEntry and journeyEntry describe favorites to filters.
+func stationEntry(favorite models.FavoriteStation) favoriteEntry {
+	name := favorite.Station.Name
+	if favorite.Nickname != "" {
+		name = favorite.Nickname
+	}
+	return favoriteEntry{
+		collectionID: favorite.CollectionID,
+		tags:         favorite.Tags,
+		name:         name,
+		text:         []string{favorite.Station.Name, favorite.Nickname, favorite.Notes},
+		position:     favorite.Position,
+		createdAt:    favorite.CreatedAt,
+		updatedAt:    favorite.UpdatedAt,
+	}
+}
+
+func trainEntry(favorite models.FavoriteTrain) favoriteEntry {
+	name := strings.TrimSpace(favorite.Train.Name + " " + favorite.Train.DepartureTime)
+	if favorite.Pattern != nil {
+		name = favorite.Pattern.Name + " " + favorite.Pattern.DepartureTime
+	}
+	if favorite.Nickname != "" {
+		name = favorite.Nickname
+	}
+	return favoriteEntry{
+		collectionID: favorite.CollectionID,
+		tags:         favorite.Tags,
+		name:         name,
+		text:         []string{name, favorite.Train.Name, favorite.Train.From, favorite.Nickname, favorite.Notes},
+		position:     favorite.Position,
+		createdAt:    favorite.CreatedAt,
+		updatedAt:    favorite.UpdatedAt,
+	}
+}
+
+func journeyEntry(favorite models.FavoriteJourney) favoriteEntry {
+	name := favorite.Origin.Name + " - " + favorite.Destination.Name
+	if favorite.Nickname != "" {
+		name = favorite.Nickname
+	}
+	return favoriteEntry{
+		collectionID: favorite.CollectionID,
+		tags:         favorite.Tags,
+		name:         name,
+		text:         []string{favorite.Origin.Name, favorite.Destination.Name, favorite.Nickname, favorite.Notes},
+		position:     favorite.Position,
+		createdAt:    favorite.CreatedAt,
+		updatedAt:    favorite.UpdatedAt,
+	}
+}
+
+// ============================================================================
+// COLLECTIONS - Store operations (shared by REST and WebSocket RPC)
+// ============================================================================
+
+// ListCollections returns the caller's collections in their order.
+func (h *FavoritesHandler) ListCollections(ctx context.Context) ([]models.FavoriteCollection, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	collections, err := h.repo.ListCollections(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	return collections, nil
+}
+
+// FindCollection returns one of the caller's collections by ID.
+func (h *FavoritesHandler) FindCollection(ctx context.Context, id string) (*models.FavoriteCollection, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	collection, err := h.repo.GetCollection(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Collection with ID "+id+" does not exist", "")
+	}
+	return collection, nil
+}
+
+// AddCollection creates a collection of the caller, last in their order.
+func (h *FavoritesHandler) AddCollection(ctx context.Context, req models.FavoriteCollectionRequest) (*models.FavoriteCollection, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	name, reqErr := validateCollectionName(req.Name)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	existing, err := h.repo.ListCollections(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	if len(existing) >= maxCollections {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"At most "+strconv.Itoa(maxCollections)+" collections are allowed")
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	collection := models.FavoriteCollection{
+		ID:        uuid.New().String(),
+		Owner:     owner,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := h.repo.CreateCollection(&collection); err != nil {
+		return nil, storeError(err, "", "A collection named "+name+" already exists")
+	}
+
+	log.Info().
+		Str("id", collection.ID).
+		Str("name", collection.Name).
+		Msg("Created favorite collection")
+
+	return &collection, nil
+}
+
+// RenameCollection renames one of the caller's collections.
+func (h *FavoritesHandler) RenameCollection(ctx context.Context, id string, req models.FavoriteCollectionRequest) (*models.FavoriteCollection, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	name, reqErr := validateCollectionName(req.Name)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	collection, err := h.repo.UpdateCollection(owner, id, func(collection *models.FavoriteCollection) {
+		collection.Name = name
+		collection.UpdatedAt = time.Now().Format(time.RFC3339)
+	})
+	if err != nil {
+		return nil, storeError(err, "Collection with ID "+id+" does not exist",
+			"A collection named "+name+" already exists")
+	}
+
+	log.Info().
+		Str("id", id).
+		Str("name", name).
+		Msg("Renamed favorite collection")
+
+	return collection, nil
+}
+
+// RemoveCollection deletes a collection. Its favorites are kept, outside of
+// any collection.
+func (h *FavoritesHandler) RemoveCollection(ctx context.Context, id string) (*models.FavoriteCollection, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	kinds := []string{models.AuditFavoriteStation, models.AuditFavoriteTrain, models.AuditFavoriteJourney}
+	before, err := h.favoriteVersions(owner, kinds...)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	collection, err := h.repo.DeleteCollection(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Collection with ID "+id+" does not exist", "")
+	}
+	h.auditRewrites(ctx, owner, before, kinds...)
+
+	log.Info().
+		Str("id", id).
+		Str("name", collection.Name).
+		Msg("Deleted favorite collection")
+
+	return collection, nil
+}
+
+// ReorderCollections moves the caller's collections to the order of the
+// request and returns all of them in their new order.
+func (h *FavoritesHandler) ReorderCollections(ctx context.Context, req models.ReorderRequest) ([]models.FavoriteCollection, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if reqErr := validateReorder(req); reqErr != nil {
+		return nil, reqErr
+	}
+	if err := h.repo.ReorderCollections(owner, req.IDs); err != nil {
+		return nil, storeError(err, "Some ids are not collections of yours", "")
+	}
+	return h.ListCollections(ctx)
+}
+
+// ============================================================================
+// REORDERING FAVORITES - Store operations (shared by REST and WebSocket RPC)
+// ============================================================================
+
+// ReorderFavorites moves the caller's favorite stations to the order of the
+// request and returns all of them in their new order.
+func (h *FavoritesHandler) ReorderFavorites(ctx context.Context, req models.ReorderRequest) ([]models.FavoriteStation, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if reqErr := validateReorder(req); reqErr != nil {
+		return nil, reqErr
+	}
+	before, err := h.favoriteVersions(owner, models.AuditFavoriteStation)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	if err := h.repo.ReorderStations(owner, req.IDs); err != nil {
+		return nil, storeError(err, "Some ids are not favorite stations of yours", "")
+	}
+	h.auditRewrites(ctx, owner, before, models.AuditFavoriteStation)
+	favorites, _, reqErr := h.ListFavorites(ctx, favoriteFilter{})
+	return favorites, reqErr
+}
+
+// ReorderFavoriteTrains moves the caller's favorite trains to the order of
+// the request and returns all of them in their new order.
+func (h *FavoritesHandler) ReorderFavoriteTrains(ctx context.Context, req models.ReorderRequest) ([]models.FavoriteTrain, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if reqErr := validateReorder(req); reqErr != nil {
+		return nil, reqErr
+	}
+	before, err := h.favoriteVersions(owner, models.AuditFavoriteTrain)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	if err := h.repo.ReorderTrains(owner, req.IDs); err != nil {
+		return nil, storeError(err, "Some ids are not favorite trains of yours", "")
+	}
+	h.auditRewrites(ctx, owner, before, models.AuditFavoriteTrain)
+	trains, _, reqErr := h.ListFavoriteTrains(ctx, favoriteFilter{})
+	return trains, reqErr
+}
+
+// ReorderFavoriteJourneys moves the caller's favorite journeys to the order
+// of the request and returns all of them in their new order.
+func (h *FavoritesHandler) ReorderFavoriteJourneys(ctx context.Context, req models.ReorderRequest) ([]models.FavoriteJourney, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if reqErr := validateReorder(req); reqErr != nil {
+		return nil, reqErr
+	}
+	before, err := h.favoriteVersions(owner, models.AuditFavoriteJourney)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	if err := h.repo.ReorderJourneys(owner, req.IDs); err != nil {
+		return nil, storeError(err, "Some ids are not favorite journeys of yours", "")
+	}
+	h.auditRewrites(ctx, owner, before, models.AuditFavoriteJourney)
+	journeys, _, reqErr := h.ListFavoriteJourneys(ctx, favoriteFilter{})
+	return journeys, reqErr
+}
+
+// ============================================================================
+// REST HANDLERS - /api/favorites/collections and the reorder endpoints
+// ============================================================================
+
+// GetCollections returns the caller's collections.
+func (h *FavoritesHandler) GetCollections(w http.ResponseWriter, r *http.Request) {
+	collections, reqErr := h.ListCollections(r.Context())
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: collections,
+		Meta: &models.APIMeta{
+			Total:     len(collections),
+			Count:     len(collections),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_collections_store",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetCollection returns one collection by ID.
+func (h *FavoritesHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	collection, reqErr := h.FindCollection(r.Context(), mux.Vars(r)["id"])
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: collection,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_collections_store",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateCollection adds a collection.
+func (h *FavoritesHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
+		return
+	}
+
+	var req models.FavoriteCollectionRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	collection, reqErr := h.AddCollection(r.Context(), req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	response := models.APIResponse{
+		Data: collection,
+		Meta: &models.APIMeta{
+			Timestamp: collection.CreatedAt,
+			Source:    "favorites_collections_store",
+			Note:      "Collection created successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateCollection renames a collection (PUT and PATCH).
+func (h *FavoritesHandler) UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
+		return
+	}
+
+	var req models.FavoriteCollectionRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	collection, reqErr := h.RenameCollection(r.Context(), mux.Vars(r)["id"], req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: collection,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_collections_store",
+			Note:      "Collection updated successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteCollection removes a collection, keeping its favorites.
+func (h *FavoritesHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, reqErr := h.RemoveCollection(r.Context(), id); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: deletedResult(id),
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_collections_store",
+			Note:      "Collection deleted successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PostReorderCollections reorders collections.
+func (h *FavoritesHandler) PostReorderCollections(w http.ResponseWriter, r *http.Request) {
+	h.reorder(w, r, "favorites_collections_store", func(ctx context.Context, req models.ReorderRequest) (interface{}, int, *models.RequestError) {
+		collections, reqErr := h.ReorderCollections(ctx, req)
+		return collections, len(collections), reqErr
+	})
+}
+
+// PostReorderFavorites reorders favorite stations.
+func (h *FavoritesHandler) PostReorderFavorites(w http.ResponseWriter, r *http.Request) {
+	h.reorder(w, r, "favorites_store", func(ctx context.Context, req models.ReorderRequest) (interface{}, int, *models.RequestError) {
+		favorites, reqErr := h.ReorderFavorites(ctx, req)
+		return favorites, len(favorites), reqErr
+	})
+}
+
+// PostReorderFavoriteTrains reorders favorite trains.
+func (h *FavoritesHandler) PostReorderFavoriteTrains(w http.ResponseWriter, r *http.Request) {
+	h.reorder(w, r, "favorites_trains_store", func(ctx context.Context, req models.ReorderRequest) (interface{}, int, *models.RequestError) {
+		trains, reqErr := h.ReorderFavoriteTrains(ctx, req)
+		return trains, len(trains), reqErr
+	})
+}
+
+// PostReorderFavoriteJourneys reorders favorite journeys.
+func (h *FavoritesHandler) PostReorderFavoriteJourneys(w http.ResponseWriter, r *http.Request) {
+	h.reorder(w, r, "favorites_journeys_store", func(ctx context.Context, req models.ReorderRequest) (interface{}, int, *models.RequestError) {
+		journeys, reqErr := h.ReorderFavoriteJourneys(ctx, req)
+		return journeys, len(journeys), reqErr
+	})
+}
+
+// reorder reads a reorder request, runs it and sends the reordered list.
+func (h *FavoritesHandler) reorder(w http.ResponseWriter, r *http.Request, source string,
+	run func(ctx context.Context, req models.ReorderRequest) (interface{}, int, *models.RequestError)) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
+		return
+	}
+
+	var req models.ReorderRequest
+	if reqErr := readBody(w, r, maxImportBytes, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	list, count, reqErr := run(r.Context(), req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: list,
+		Meta: &models.APIMeta{
+			Total:     count,
+			Count:     count,
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    source,
+			Note:      "Reordered successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
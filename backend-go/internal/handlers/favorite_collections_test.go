@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+func TestValidateReorder(t *testing.T) {
+	tooMany := make([]string, maxReorderIDs+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(i)
+	}
+	for _, tc := range []struct {
+		name string
+		ids  []string
+		want string // part of the message, "" if valid
+	}{
+		{"valid", []string{"a", "b"}, ""},
+		{"none", nil, "ids is required"},
+		{"duplicate", []string{"a", "b", "a"}, "ids lists a more than once"},
+		{"too many", tooMany, "At most 1000"},
+	} {
+		reqErr := validateReorder(models.ReorderRequest{IDs: tc.ids})
+		switch {
+		case tc.want == "" && reqErr != nil:
+			t.Errorf("%s: %+v", tc.name, reqErr)
+		case tc.want != "" && (reqErr == nil || reqErr.Status != http.StatusBadRequest || !strings.Contains(reqErr.Message, tc.want)):
+			t.Errorf("%s: %+v, want a 400 about %q", tc.name, reqErr, tc.want)
+		}
+	}
+}
+
+func TestReorderFavorites(t *testing.T) {
+	h := newTestFavorites(t)
+	alice := asUser("alice")
+
+	var ids []string
+	for _, stationID := range []string{"8503000", "8500218", "8507000"} {
+		favorite, reqErr := h.AddFavorite(alice, models.CreateFavoriteRequest{StationID: stationID})
+		if reqErr != nil {
+			t.Fatal(reqErr)
+		}
+		ids = append(ids, favorite.ID)
+	}
+	bobs, reqErr := h.AddFavorite(asUser("bob"), models.CreateFavoriteRequest{StationID: "8503000"})
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		return serve(h.PostReorderFavorites, newRequest(http.MethodPost, "/api/favorites/stations/reorder", body, "alice", nil))
+	}
+	reorder := func(ids ...string) string {
+		body, _ := json.Marshal(models.ReorderRequest{IDs: ids})
+		return string(body)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"partial", reorder(ids[2], ids[0]), http.StatusBadRequest},
+		{"duplicate", reorder(ids[2], ids[1], ids[0], ids[0]), http.StatusBadRequest},
+		{"foreign", reorder(ids[2], ids[1], ids[0], bobs.ID), http.StatusNotFound},
+		{"unknown", reorder(ids[2], ids[1], "nope"), http.StatusNotFound},
+		{"empty", `{"ids": []}`, http.StatusBadRequest},
+		{"not JSON", `ids=1`, http.StatusBadRequest},
+	} {
+		if rec := post(tc.body); rec.Code != tc.status {
+			t.Errorf("%s: %d %s, want %d", tc.name, rec.Code, rec.Body, tc.status)
+		}
+	}
+	if favorites, _, _ := h.ListFavorites(alice, favoriteFilter{}); favorites[0].ID != ids[0] || favorites[0].Version != 1 {
+		t.Errorf("favorites after rejected reorders = %+v", favorites)
+	}
+
+	// A full reorder renumbers all of them and returns the new order
+	rec := post(reorder(ids[2], ids[0], ids[1]))
+	var response struct {
+		Data []models.FavoriteStation `json:"data"`
+		Meta models.APIMeta           `json:"meta"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("reorder: %d %s", rec.Code, rec.Body)
+	}
+	for i, want := range []string{ids[2], ids[0], ids[1]} {
+		if favorite := response.Data[i]; favorite.ID != want || favorite.Position != i || favorite.Version != 2 {
+			t.Errorf("favorite %d = %+v, want %s at %d, version 2", i, favorite, want, i)
+		}
+	}
+	if response.Meta.Total != 3 {
+		t.Errorf("meta = %+v", response.Meta)
+	}
+	if favorite, _ := h.FindFavorite(asUser("bob"), bobs.ID); favorite.Version != 1 {
+		t.Errorf("bob's favorite = %+v", favorite)
+	}
+}
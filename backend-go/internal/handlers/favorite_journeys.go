@@ -0,0 +1,719 @@
+// Package handlers provides HTTP handlers for the Swiss Railway API.
+// This file implements favorite journeys (commutes such as home to work)
+// and their next connections, planned from the GTFS timetable or fetched
+// from the upstream timetable API, with live delays and a warning when
+// the usual connection is at risk.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+const (
+	// maxJourneyWindows limits the preferred time windows of a journey.
+	maxJourneyWindows = 10
+
+	// defaultJourneyConnections and maxJourneyConnections bound ?limit= of
+	// the next connections.
+	defaultJourneyConnections = 5
+	maxJourneyConnections     = 10
+
+	// atRiskDelay is the delay from which a connection counts as at risk.
+	atRiskDelay = 5 // minutes
+
+	// windowLookaheadDays is how far ahead the next preferred window is
+	// looked for.
+	windowLookaheadDays = 7
+)
+
+// Connection sources of GET /api/favorites/journeys/{id}/next.
+const (
+	sourceAuto     = "auto"     // local planner, upstream if it finds nothing
+	sourceGTFS     = "gtfs"     // local planner only
+	sourceUpstream = "upstream" // upstream timetable API only
+)
+
+// validateWindows checks preferred time windows and normalizes their
+// times (HH:MM) and days (every day when none are given). A window ending
+// at or before its start crosses midnight, e.g. 23:00 to 01:00.
+func validateWindows(windows []models.TimeWindow) ([]models.TimeWindow, *models.RequestError) {
+	if len(windows) > maxJourneyWindows {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"At most "+strconv.Itoa(maxJourneyWindows)+" windows are allowed")
+	}
+
+	normalized := make([]models.TimeWindow, 0, len(windows))
+	for i, window := range windows {
+		field := "windows[" + strconv.Itoa(i) + "]"
+		start, err := services.NormalizePatternTime(window.Start)
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "Validation Error", field+".start: "+err.Error())
+		}
+		end, err := services.NormalizePatternTime(window.End)
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "Validation Error", field+".end: "+err.Error())
+		}
+		if start >= "24:00" || end > "24:00" || end == start {
+			return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+				field+": start and end must differ, before 24:00 and at 24:00 at the latest")
+		}
+
+		days := services.AllDays()
+		if len(window.Days) > 0 {
+			if days, err = services.NormalizePatternDays(window.Days); err != nil {
+				return nil, newRequestError(http.StatusBadRequest, "Validation Error", field+".days: "+err.Error())
+			}
+		}
+		normalized = append(normalized, models.TimeWindow{Start: start, End: end, Days: days})
+	}
+	return normalized, nil
+}
+
+// ============================================================================
+// FAVORITE JOURNEYS - Store operations (shared by REST and WebSocket RPC)
+// ============================================================================
+
+// ListFavoriteJourneys returns the caller's favorite journeys the filter
+// keeps, in its order, and how many favorite journeys the caller has.
+func (h *FavoritesHandler) ListFavoriteJourneys(ctx context.Context, filter favoriteFilter) ([]models.FavoriteJourney, int, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, 0, reqErr
+	}
+	if reqErr := filter.normalize(); reqErr != nil {
+		return nil, 0, reqErr
+	}
+	journeys, err := h.repo.ListJourneys(owner)
+	if err != nil {
+		return nil, 0, storeError(err, "", "")
+	}
+
+	kept := filter.apply(len(journeys), func(i int) favoriteEntry { return journeyEntry(journeys[i]) })
+	filtered := make([]models.FavoriteJourney, len(kept))
+	for i, index := range kept {
+		filtered[i] = journeys[index]
+	}
+	return filtered, len(journeys), nil
+}
+
+// FindFavoriteJourney returns one of the caller's favorite journeys by ID.
+func (h *FavoritesHandler) FindFavoriteJourney(ctx context.Context, id string) (*models.FavoriteJourney, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	favorite, err := h.repo.GetJourney(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Favorite journey with ID "+id+" does not exist", "")
+	}
+	return favorite, nil
+}
+
+// AddFavoriteJourney validates and stores a new favorite journey of the
+// caller.
+func (h *FavoritesHandler) AddFavoriteJourney(ctx context.Context, req models.CreateFavoriteJourneyRequest) (*models.FavoriteJourney, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if req.OriginID == "" || req.DestinationID == "" {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error", "originId and destinationId are required")
+	}
+	if req.OriginID == req.DestinationID {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error", "originId and destinationId must differ")
+	}
+
+	origin := h.gtfsService.GetStationByID(req.OriginID)
+	if origin == nil {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"Station with ID "+req.OriginID+" does not exist")
+	}
+	destination := h.gtfsService.GetStationByID(req.DestinationID)
+	if destination == nil {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"Station with ID "+req.DestinationID+" does not exist")
+	}
+
+	windows, reqErr := validateWindows(req.Windows)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	nickname, notes, reqErr := validateNotes(req.Nickname, req.Notes)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	tags, reqErr := validateTags(req.Tags)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if reqErr := h.validateCollection(owner, req.CollectionID); reqErr != nil {
+		return nil, reqErr
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	favorite := models.FavoriteJourney{
+		ID:            uuid.New().String(),
+		Owner:         owner,
+		OriginID:      req.OriginID,
+		Origin:        *origin,
+		DestinationID: req.DestinationID,
+		Destination:   *destination,
+		Windows:       windows,
+		Nickname:      nickname,
+		Notes:         notes,
+		CollectionID:  req.CollectionID,
+		Tags:          tags,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if err := h.repo.CreateJourney(&favorite); err != nil {
+		return nil, storeError(err, "", "Journey is already in favorites")
+	}
+	h.audit(ctx, owner, createAction(ctx), models.AuditFavoriteJourney, favorite.ID, nil, favorite)
+
+	log.Info().
+		Str("id", favorite.ID).
+		Str("originId", favorite.OriginID).
+		Str("destinationId", favorite.DestinationID).
+		Msg("Created favorite journey")
+
+	return &favorite, nil
+}
+
+// EditFavoriteJourney updates the windows, nickname and notes of a favorite
+// journey. Windows are kept unless given.
+func (h *FavoritesHandler) EditFavoriteJourney(ctx context.Context, id string, req models.UpdateFavoriteJourneyRequest) (*models.FavoriteJourney, *models.RequestError) {
+	return h.AmendFavoriteJourney(ctx, id, models.PatchFavoriteJourneyRequest{
+		Nickname: &req.Nickname,
+		Notes:    &req.Notes,
+		Windows:  req.Windows,
+	})
+}
+
+// AmendFavoriteJourney changes the fields of a favorite journey the request
+// sets.
+func (h *FavoritesHandler) AmendFavoriteJourney(ctx context.Context, id string, req models.PatchFavoriteJourneyRequest) (*models.FavoriteJourney, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	nickname, notes, tags, reqErr := h.validatePatch(owner, req.Nickname, req.Notes, req.CollectionID, req.Tags)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	var windows []models.TimeWindow
+	if req.Windows != nil {
+		if windows, reqErr = validateWindows(req.Windows); reqErr != nil {
+			return nil, reqErr
+		}
+	}
+
+	var before json.RawMessage
+	favorite, err := h.repo.UpdateJourney(owner, id, expectedVersion(ctx), func(favorite *models.FavoriteJourney) {
+		before, _ = json.Marshal(favorite)
+		if req.Nickname != nil {
+			favorite.Nickname = nickname
+		}
+		if req.Notes != nil {
+			favorite.Notes = notes
+		}
+		if req.CollectionID != nil {
+			favorite.CollectionID = *req.CollectionID
+		}
+		if req.Tags != nil {
+			favorite.Tags = tags
+		}
+		if windows != nil {
+			favorite.Windows = windows
+		}
+		favorite.UpdatedAt = time.Now().Format(time.RFC3339)
+	})
+	if err != nil {
+		return nil, storeError(err, "Favorite journey with ID "+id+" does not exist", "")
+	}
+	h.audit(ctx, owner, models.AuditUpdate, models.AuditFavoriteJourney, id, before, favorite)
+
+	log.Info().
+		Str("id", id).
+		Int("windows", len(favorite.Windows)).
+		Msg("Updated favorite journey")
+
+	return favorite, nil
+}
+
+// RemoveFavoriteJourney deletes a favorite journey and returns it.
+func (h *FavoritesHandler) RemoveFavoriteJourney(ctx context.Context, id string) (*models.FavoriteJourney, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	favorite, err := h.repo.DeleteJourney(owner, id, expectedVersion(ctx))
+	if err != nil {
+		return nil, storeError(err, "Favorite journey with ID "+id+" does not exist", "")
+	}
+	h.audit(ctx, owner, models.AuditDelete, models.AuditFavoriteJourney, id, favorite, nil)
+	h.removeRules(owner, id)
+
+	log.Info().
+		Str("id", id).
+		Str("originId", favorite.OriginID).
+		Str("destinationId", favorite.DestinationID).
+		Msg("Deleted favorite journey")
+
+	return favorite, nil
+}
+
+// ============================================================================
+// NEXT CONNECTIONS
+// ============================================================================
+
+// NextConnections returns the next connections of one of the caller's
+// favorite journeys. The search starts now, or at the start of the next
+// preferred window when the journey has windows and none is open. source
+// is auto, gtfs or upstream; limit is the number of connections.
+func (h *FavoritesHandler) NextConnections(ctx context.Context, id, source string, limit int) (*models.JourneyConnections, *models.RequestError) {
+	favorite, reqErr := h.FindFavoriteJourney(ctx, id)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	switch source {
+	case "", sourceAuto, sourceGTFS:
+		if !h.gtfsService.IsDataLoaded() {
+			return nil, errDataLoading
+		}
+	case sourceUpstream:
+		if !h.useSwissAPI {
+			return nil, newRequestError(http.StatusBadRequest, "Invalid Parameter", "The upstream timetable API is disabled")
+		}
+	default:
+		return nil, newRequestError(http.StatusBadRequest, "Invalid Parameter", "source must be auto, gtfs or upstream")
+	}
+
+	return h.journeyConnections(favorite, source, limit, swissTime(time.Now()))
+}
+
+// journeyConnections returns the next connections of a journey from now on
+// (see NextConnections).
+func (h *FavoritesHandler) journeyConnections(favorite *models.FavoriteJourney, source string, limit int, now time.Time) (*models.JourneyConnections, *models.RequestError) {
+	window, from := nextWindow(favorite.Windows, now)
+
+	result := &models.JourneyConnections{
+		Journey:     favorite,
+		Window:      window,
+		Connections: make([]models.JourneyConnection, 0),
+	}
+
+	if source != sourceUpstream {
+		result.Source = sourceGTFS
+		result.Connections = h.plannedConnections(favorite, from, limit)
+	}
+	// The upstream API only plans from now on
+	if source == sourceUpstream || (len(result.Connections) == 0 && h.useSwissAPI && !from.After(now)) {
+		connections, err := h.transport.GetConnections(favorite.OriginID, favorite.DestinationID, limit)
+		if err != nil {
+			log.Error().Err(err).Str("id", favorite.ID).Msg("Failed to fetch connections")
+			if source == sourceUpstream {
+				return nil, newRequestError(http.StatusBadGateway, "Upstream Error", "Connections could not be fetched")
+			}
+		} else {
+			result.Source = h.transport.Name()
+			result.Connections = make([]models.JourneyConnection, 0, len(connections.Connections))
+			for _, c := range connections.Connections {
+				result.Connections = append(result.Connections, c.ToJourney())
+			}
+		}
+	}
+
+	for i := range result.Connections {
+		conn := &result.Connections[i]
+		conn.Usual = window != nil && inWindow(*window, conn.Departure)
+		assessRisk(conn)
+		if conn.Usual && result.Usual == nil {
+			result.Usual = conn
+		}
+	}
+	switch {
+	case result.Usual != nil:
+		result.AtRisk = result.Usual.AtRisk
+	case len(result.Connections) > 0:
+		result.AtRisk = result.Connections[0].AtRisk
+	}
+	return result, nil
+}
+
+// plannedConnections plans connections from the timetable with live
+// delays. When the day has no more connections, the next day is tried.
+func (h *FavoritesHandler) plannedConnections(favorite *models.FavoriteJourney, from time.Time, limit int) []models.JourneyConnection {
+	connections := h.gtfsService.PlanConnections(favorite.OriginID, favorite.DestinationID, from, limit)
+	if len(connections) == 0 {
+		y, m, d := from.Date()
+		tomorrow := time.Date(y, m, d+1, 0, 0, 0, 0, from.Location())
+		connections = h.gtfsService.PlanConnections(favorite.OriginID, favorite.DestinationID, tomorrow, limit)
+	}
+
+	for i := range connections {
+		conn := &connections[i]
+		for j := range conn.Legs {
+			leg := &conn.Legs[j]
+			if train := h.gtfsService.GetLiveTrain(leg.TripID); train != nil {
+				leg.DepartureDelay = train.Delay
+				leg.ArrivalDelay = train.Delay
+				leg.Cancelled = train.Cancelled
+			}
+			conn.Cancelled = conn.Cancelled || leg.Cancelled
+		}
+		if len(conn.Legs) > 0 {
+			conn.DepartureDelay = conn.Legs[0].DepartureDelay
+			conn.ArrivalDelay = conn.Legs[len(conn.Legs)-1].ArrivalDelay
+		}
+	}
+	return connections
+}
+
+// swissTime returns t in Swiss local time, the time of the timetable.
+func swissTime(t time.Time) time.Time {
+	if loc, err := time.LoadLocation("Europe/Zurich"); err == nil {
+		return t.In(loc)
+	}
+	return t
+}
+
+// nextWindow returns the preferred window open at now, or else the next
+// one to open, and when the search for connections starts. Without
+// windows (or none within a week), it is now. Windows crossing midnight
+// opened yesterday may still be open.
+func nextWindow(windows []models.TimeWindow, now time.Time) (*models.TimeWindow, time.Time) {
+	y, m, d := now.Date()
+	var next *models.TimeWindow
+	var nextStart time.Time
+
+	for offset := -1; offset <= windowLookaheadDays && next == nil; offset++ {
+		day := time.Date(y, m, d+offset, 0, 0, 0, 0, now.Location())
+		weekday := services.DayName(day.Weekday())
+		for i := range windows {
+			window := &windows[i]
+			if !containsString(window.Days, weekday) {
+				continue
+			}
+			start := atClock(day, window.Start)
+			end := atClock(day, window.End)
+			if !end.After(start) {
+				end = atClock(day.AddDate(0, 0, 1), window.End)
+			}
+			if !now.Before(start) && now.Before(end) {
+				return window, now
+			}
+			if start.After(now) && (next == nil || start.Before(nextStart)) {
+				next, nextStart = window, start
+			}
+		}
+	}
+	if next == nil {
+		return nil, now
+	}
+	return next, nextStart
+}
+
+// inWindow reports whether a departure (ISO8601) is within a window on
+// one of its days. Departures after midnight in a window crossing it count
+// for the day before.
+func inWindow(window models.TimeWindow, departure string) bool {
+	t, err := time.Parse(time.RFC3339, departure)
+	if err != nil {
+		return false
+	}
+	t = swissTime(t)
+	clock := t.Format("15:04")
+	opened := t
+	switch {
+	case window.End > window.Start:
+		if clock < window.Start || clock >= window.End {
+			return false
+		}
+	case clock >= window.Start:
+	case clock < window.End:
+		opened = t.AddDate(0, 0, -1)
+	default:
+		return false
+	}
+	return containsString(window.Days, services.DayName(opened.Weekday()))
+}
+
+// assessRisk flags a connection at risk when it is cancelled, departs or
+// arrives late, or a delay eats the time to change trains.
+func assessRisk(conn *models.JourneyConnection) {
+	conn.Risks = nil
+	if conn.Cancelled {
+		conn.Risks = append(conn.Risks, "cancelled")
+	}
+	if conn.DepartureDelay >= atRiskDelay {
+		conn.Risks = append(conn.Risks, "departs "+strconv.Itoa(conn.DepartureDelay)+" min late")
+	}
+	if conn.ArrivalDelay >= atRiskDelay {
+		conn.Risks = append(conn.Risks, "arrives "+strconv.Itoa(conn.ArrivalDelay)+" min late")
+	}
+	for i := 1; i < len(conn.Legs); i++ {
+		prev, next := conn.Legs[i-1], conn.Legs[i]
+		arrival, err1 := time.Parse(time.RFC3339, prev.Arrival)
+		departure, err2 := time.Parse(time.RFC3339, next.Departure)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		buffer := departure.Add(time.Duration(next.DepartureDelay) * time.Minute).
+			Sub(arrival.Add(time.Duration(prev.ArrivalDelay) * time.Minute))
+		if buffer < services.MinTransfer {
+			station := "the change"
+			if prev.To != nil {
+				station = prev.To.Name
+			}
+			conn.Risks = append(conn.Risks, "change at "+station+" may be missed ("+
+				strconv.Itoa(int(buffer.Minutes()))+" min left)")
+		}
+	}
+	conn.AtRisk = len(conn.Risks) > 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// atClock returns the wall-clock time HH:MM (up to 24:00) of a day, so
+// windows keep their local times across daylight saving changes.
+func atClock(day time.Time, clock string) time.Time {
+	var hour, minute int
+	fmt.Sscanf(clock, "%d:%d", &hour, &minute)
+	y, m, d := day.Date()
+	return time.Date(y, m, d, hour, minute, 0, 0, day.Location())
+}
+
+// ============================================================================
+// /api/favorites/journeys - REST endpoints
+// ============================================================================
+
+// GetFavoriteJourneys returns all favorite journeys.
+func (h *FavoritesHandler) GetFavoriteJourneys(w http.ResponseWriter, r *http.Request) {
+	filter := filterFromQuery(r.URL.Query())
+	journeys, total, reqErr := h.ListFavoriteJourneys(r.Context(), filter)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: journeys,
+		Meta: &models.APIMeta{
+			Total:     total,
+			Count:     len(journeys),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_journeys_store",
+			Filters:   filter.meta(),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetFavoriteJourney returns a specific favorite journey by ID.
+func (h *FavoritesHandler) GetFavoriteJourney(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	favorite, reqErr := h.FindFavoriteJourney(r.Context(), id)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	setETag(w, favorite.Version)
+	if notModified(w, r, favorite.Version) {
+		return
+	}
+
+	response := models.APIResponse{
+		Data: favorite,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_journeys_store",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateFavoriteJourney adds a new journey to favorites.
+func (h *FavoritesHandler) CreateFavoriteJourney(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
+		return
+	}
+
+	var req models.CreateFavoriteJourneyRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	favorite, reqErr := h.AddFavoriteJourney(r.Context(), req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	setETag(w, favorite.Version)
+	w.WriteHeader(http.StatusCreated)
+	response := models.APIResponse{
+		Data: favorite,
+		Meta: &models.APIMeta{
+			Timestamp: favorite.CreatedAt,
+			Source:    "favorites_journeys_store",
+			Note:      "Favorite journey created successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateFavoriteJourney modifies an existing favorite journey.
+func (h *FavoritesHandler) UpdateFavoriteJourney(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+
+	var req models.UpdateFavoriteJourneyRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	ctx := ifMatch(r) // the version the edit expects, if any
+	favorite, reqErr := h.EditFavoriteJourney(ctx, id, req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	setETag(w, favorite.Version)
+	response := models.APIResponse{
+		Data: favorite,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_journeys_store",
+			Note:      "Favorite journey updated successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PatchFavoriteJourney changes the fields of a favorite journey sent in the
+// body: windows, nickname, notes, collectionId and tags.
+func (h *FavoritesHandler) PatchFavoriteJourney(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
+		return
+	}
+
+	var req models.PatchFavoriteJourneyRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	ctx := ifMatch(r) // the version the edit expects, if any
+	favorite, reqErr := h.AmendFavoriteJourney(ctx, mux.Vars(r)["id"], req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	setETag(w, favorite.Version)
+	response := models.APIResponse{
+		Data: favorite,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_journeys_store",
+			Note:      "Favorite journey updated successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteFavoriteJourney removes a favorite journey.
+func (h *FavoritesHandler) DeleteFavoriteJourney(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, reqErr := h.RemoveFavoriteJourney(ifMatch(r), id); reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: deletedResult(id),
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_journeys_store",
+			Note:      "Favorite journey deleted successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetNextConnections returns the next connections of a favorite journey.
+// ?limit=N (1-10, default 5) and ?source=auto|gtfs|upstream.
+func (h *FavoritesHandler) GetNextConnections(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	limit := defaultJourneyConnections
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxJourneyConnections {
+			sendError(w, http.StatusBadRequest, "Invalid Parameter",
+				"limit must be between 1 and "+strconv.Itoa(maxJourneyConnections))
+			return
+		}
+		limit = n
+	}
+
+	result, reqErr := h.NextConnections(r.Context(), id, query.Get("source"), limit)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: result,
+		Meta: &models.APIMeta{
+			Count:     len(result.Connections),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    result.Source,
+			Filters:   map[string]interface{}{"limit": limit, "source": query.Get("source")},
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+func TestValidateWindows(t *testing.T) {
+	windows, reqErr := validateWindows([]models.TimeWindow{
+		{Start: "7:05", End: "08:30:00", Days: []string{"FRI", "mon", "mon"}},
+		{Start: "23:00", End: "01:00"},
+		{Start: "22:00", End: "24:00"},
+	})
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	want := []models.TimeWindow{
+		{Start: "07:05", End: "08:30", Days: []string{"mon", "fri"}},
+		{Start: "23:00", End: "01:00", Days: []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}},
+		{Start: "22:00", End: "24:00", Days: []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}},
+	}
+	if !reflect.DeepEqual(windows, want) {
+		t.Errorf("windows = %+v, want %+v", windows, want)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		windows []models.TimeWindow
+		field   string
+	}{
+		{"empty window", []models.TimeWindow{{Start: "08:00", End: "08:00"}}, "windows[0]:"},
+		{"end after midnight", []models.TimeWindow{{Start: "23:00", End: "24:30"}}, "windows[0]:"},
+		{"start at midnight", []models.TimeWindow{{Start: "24:00", End: "01:00"}}, "windows[0]:"},
+		{"bad start", []models.TimeWindow{{Start: "7h", End: "08:00"}}, "windows[0].start"},
+		{"bad end", []models.TimeWindow{{Start: "07:00", End: "08:60"}}, "windows[0].end"},
+		{"missing end", []models.TimeWindow{{Start: "07:00"}}, "windows[0].end"},
+		{"unknown day", []models.TimeWindow{{Start: "07:00", End: "08:00"}, {Start: "17:00", End: "18:00", Days: []string{"fr"}}}, "windows[1].days"},
+		{"too many", make([]models.TimeWindow, maxJourneyWindows+1), "At most 10"},
+	} {
+		_, reqErr := validateWindows(tc.windows)
+		if reqErr == nil || reqErr.Status != http.StatusBadRequest || !strings.Contains(reqErr.Message, tc.field) {
+			t.Errorf("%s: error = %+v, want a 400 about %s", tc.name, reqErr, tc.field)
+		}
+	}
+}
+
+func TestNextWindow(t *testing.T) {
+	morning := models.TimeWindow{Start: "07:30", End: "08:30", Days: []string{"mon", "tue", "wed", "thu", "fri"}}
+	night := models.TimeWindow{Start: "23:00", End: "01:00", Days: []string{"fri"}}
+	windows := []models.TimeWindow{morning, night}
+
+	// Friday 14 March 2025, then Saturday and Sunday
+	saturday := func(hour, min int) time.Time { return zurichTime(hour, min).AddDate(0, 0, 1) }
+	for _, tc := range []struct {
+		name   string
+		now    time.Time
+		window *models.TimeWindow
+		from   time.Time // zero for now
+	}{
+		{"before the morning", zurichTime(6, 0), &morning, zurichTime(7, 30)},
+		{"in the morning", zurichTime(8, 0), &morning, time.Time{}},
+		{"end of the morning", zurichTime(8, 30), &night, zurichTime(23, 0)},
+		{"late evening", zurichTime(23, 30), &night, time.Time{}},
+		{"after midnight", saturday(0, 30), &night, time.Time{}},
+		{"night over", saturday(1, 0), &morning, zurichTime(7, 30).AddDate(0, 0, 3)},
+		{"saturday night", saturday(23, 30), &morning, zurichTime(7, 30).AddDate(0, 0, 3)},
+	} {
+		window, from := nextWindow(windows, tc.now)
+		if tc.from.IsZero() {
+			tc.from = tc.now
+		}
+		if !reflect.DeepEqual(window, tc.window) || !from.Equal(tc.from) {
+			t.Errorf("%s: window %+v from %s, want %+v from %s", tc.name, window, from, tc.window, tc.from)
+		}
+	}
+
+	// Without windows the search starts now
+	if window, from := nextWindow(nil, zurichTime(12, 0)); window != nil || !from.Equal(zurichTime(12, 0)) {
+		t.Errorf("no windows: window %+v from %s", window, from)
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	weekdays := []string{"mon", "tue", "wed", "thu", "fri"}
+	morning := models.TimeWindow{Start: "07:30", End: "08:30", Days: weekdays}
+	night := models.TimeWindow{Start: "23:00", End: "01:00", Days: []string{"fri"}}
+	late := models.TimeWindow{Start: "22:00", End: "24:00", Days: weekdays}
+
+	// Friday 14 March 2025
+	for _, tc := range []struct {
+		window    models.TimeWindow
+		departure string
+		want      bool
+	}{
+		{morning, "2025-03-14T07:30:00+01:00", true},
+		{morning, "2025-03-14T07:15:00Z", true},
+		{morning, "2025-03-14T08:30:00+01:00", false},
+		{morning, "2025-03-14T23:30:00+01:00", false},
+		{morning, "2025-03-15T08:00:00+01:00", false},
+		{night, "2025-03-14T23:30:00+01:00", true},
+		{night, "2025-03-15T00:15:00+01:00", true},
+		{night, "2025-03-15T01:00:00+01:00", false},
+		{night, "2025-03-14T00:15:00+01:00", false},
+		{night, "2025-03-15T23:30:00+01:00", false},
+		{night, "2025-03-14T08:00:00+01:00", false},
+		{late, "2025-03-14T23:59:00+01:00", true},
+		{morning, "08:00", false},
+	} {
+		if got := inWindow(tc.window, tc.departure); got != tc.want {
+			t.Errorf("%s-%s %v at %s: %v, want %v", tc.window.Start, tc.window.End, tc.window.Days, tc.departure, got, tc.want)
+		}
+	}
+}
+
+func TestJourneyConnections(t *testing.T) {
+	h := newTestFavorites(t)
+	ctx := asUser("alice")
+
+	// An evening window crossing midnight: the 23:30 is the usual train
+	favorite, reqErr := h.AddFavoriteJourney(ctx, models.CreateFavoriteJourneyRequest{
+		OriginID:      "8503000",
+		DestinationID: "8507000",
+		Windows:       []models.TimeWindow{{Start: "23:00", End: "00:30", Days: []string{"fri"}}},
+	})
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+
+	// From the afternoon, the search starts when the window opens
+	result, reqErr := h.journeyConnections(favorite, sourceGTFS, 2, zurichTime(15, 0))
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	if result.Window == nil || result.Window.Start != "23:00" || len(result.Connections) != 1 ||
+		result.Usual == nil || result.Usual.Departure != "2025-03-14T23:30:00+01:00" || result.Usual.Arrival != "2025-03-15T00:26:00+01:00" {
+		t.Errorf("afternoon = %+v, want the 23:30 as the usual connection", result)
+	}
+
+	// After midnight the window is still open; the next train is tomorrow's
+	result, reqErr = h.journeyConnections(favorite, sourceGTFS, 2, zurichTime(0, 10).AddDate(0, 0, 1))
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	if result.Window == nil || len(result.Connections) == 0 || result.Usual != nil ||
+		result.Connections[0].Departure != "2025-03-15T08:00:00+01:00" {
+		t.Errorf("after midnight = %+v, want the next morning's train outside the window", result)
+	}
+
+	// Bad windows are rejected on update and leave the journey alone
+	if _, reqErr := h.EditFavoriteJourney(ctx, favorite.ID, models.UpdateFavoriteJourneyRequest{
+		Windows: []models.TimeWindow{{Start: "08:00", End: "08:00"}},
+	}); reqErr == nil || reqErr.Status != http.StatusBadRequest {
+		t.Errorf("update with an empty window: %+v", reqErr)
+	}
+	if stored, _ := h.FindFavoriteJourney(ctx, favorite.ID); stored.Windows[0].End != "00:30" {
+		t.Errorf("windows = %+v", stored.Windows)
+	}
+}
@@ -10,15 +10,18 @@
 // SECURITY PRACTICES IMPLEMENTED:
 // 1. Input validation (length limits, required fields)
 // 2. Input sanitization (HTML/script stripping to prevent XSS)
-// 3. Parameterized operations (no SQL injection as we use in-memory)
+// 3. Parameterized operations (no SQL injection; storage is a key-value store)
 // 4. CSRF protection via SameSite cookies + custom headers
 // 5. Content-Type validation
 // 6. Rate limiting (handled by middleware)
+// 7. Authorization: every operation is scoped to the caller's user or device
 
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"html"
 	"io"
 	"net/http"
@@ -30,28 +33,164 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/auth"
+	"github.com/swiss-railway/backend-go/internal/middleware"
 	"github.com/swiss-railway/backend-go/internal/models"
 	"github.com/swiss-railway/backend-go/internal/services"
+	"github.com/swiss-railway/backend-go/internal/store"
 )
 
-// FavoritesHandler handles favorite station and train operations.
-// Uses an in-memory store with mutex for thread safety.
+// FavoritesHandler handles favorite station, train and journey operations
+// and the alert rules attached to them. Favorites are kept in a repository
+// (in memory or in a database file).
 type FavoritesHandler struct {
-	gtfsService    *services.GTFSService
-	favorites      map[string]*models.Favorite      // Key: favorite station ID
-	favoriteTrains map[string]*models.FavoriteTrain // Key: favorite train ID
-	mutex          sync.RWMutex                     // Thread-safe access
+	gtfsService *services.GTFSService
+	transport   services.TransportProvider
+	repo        store.FavoritesRepository
+	useSwissAPI bool
+
+	// Alerts; nil while alerts are disabled
+	webhooks *services.WebhookDispatcher
+	events   *services.EventLog
+
+	// Everyone's favorite trains with their runs of an operating day and
+	// the day before, for the WebSocket hub; dropped whenever a favorite
+	// train is written
+	followMu   sync.Mutex
+	followDay  time.Time
+	followRuns []favoriteRuns
+
+	// Idempotency-Key replays of creates
+	replayTTL time.Duration
+	replayMu  sync.Mutex
+	inFlight  map[string]bool // owner and key of the creates running
+	lastPurge time.Time
+
+	// Audit log retention; 0 keeps entries forever
+	auditRetention time.Duration
+	auditMu        sync.Mutex
+	lastAuditPurge time.Time
 }
 
-// NewFavoritesHandler creates a new favorites handler.
-func NewFavoritesHandler(gtfsService *services.GTFSService) *FavoritesHandler {
+// NewFavoritesHandler creates a new favorites handler. The transport
+// provider plans journeys when the Swiss API is enabled.
+func NewFavoritesHandler(gtfsService *services.GTFSService, transport services.TransportProvider, repo store.FavoritesRepository, useSwissAPI bool) *FavoritesHandler {
 	return &FavoritesHandler{
-		gtfsService:    gtfsService,
-		favorites:      make(map[string]*models.Favorite),
-		favoriteTrains: make(map[string]*models.FavoriteTrain),
+		gtfsService: gtfsService,
+		transport:   transport,
+		repo:        repo,
+		useSwissAPI: useSwissAPI,
+		inFlight:    make(map[string]bool),
 	}
 }
 
+// FavoriteStationIDs returns the station IDs favorited by anyone.
+// Used by the stationboard poller to prioritize favorites.
+func (h *FavoritesHandler) FavoriteStationIDs() []string {
+	favorites, err := h.repo.AllStations()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list favorite stations")
+		return nil
+	}
+
+	seen := make(map[string]bool, len(favorites))
+	ids := make([]string, 0, len(favorites))
+	for _, fav := range favorites {
+		if !seen[fav.StationID] {
+			seen[fav.StationID] = true
+			ids = append(ids, fav.StationID)
+		}
+	}
+	return ids
+}
+
+// favoriteRuns is a favorite train with the trips it stands for around an
+// operating day. Trips are empty when the favorite doesn't run that day.
+type favoriteRuns struct {
+	favorite  models.FavoriteTrain
+	yesterday string
+	arrival   time.Time // of yesterday's run
+	today     string
+	stored    bool // trip unknown to the timetable: followed as stored
+}
+
+// lateRunGrace is how long after its scheduled arrival a run of the
+// previous operating day is still followed, so a delayed train keeps its
+// arrival event.
+const lateRunGrace = time.Hour
+
+// FavoriteTrains returns everyone's favorite trains, with their owners, or
+// none if they can't be read. Used by the WebSocket hub to follow favorites,
+// so TrainID is the trip of each favorite's current run: the previous
+// operating day's while that run is still on its way (trains past
+// midnight), otherwise today's. Favorites not running are left out. The
+// runs are cached for the day until a favorite train is written.
+func (h *FavoritesHandler) FavoriteTrains() []models.FavoriteTrain {
+	now := time.Now()
+	runs, ok := h.favoriteRuns(services.OperatingDay(now))
+	if !ok {
+		return nil
+	}
+
+	current := make([]models.FavoriteTrain, 0, len(runs))
+	for _, run := range runs {
+		favorite := run.favorite
+		switch {
+		case run.stored:
+		case run.yesterday != "" && now.Before(run.arrival.Add(lateRunGrace)):
+			favorite.TrainID = run.yesterday
+		case run.today != "":
+			favorite.TrainID = run.today
+		default:
+			continue
+		}
+		current = append(current, favorite)
+	}
+	return current
+}
+
+// favoriteRuns returns the runs of everyone's favorite trains on day and
+// the day before, from the cache if it holds them.
+func (h *FavoritesHandler) favoriteRuns(day time.Time) ([]favoriteRuns, bool) {
+	h.followMu.Lock()
+	defer h.followMu.Unlock()
+	if h.followRuns != nil && h.followDay.Equal(day) {
+		return h.followRuns, true
+	}
+
+	trains, err := h.repo.AllTrains()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list favorite trains")
+		return nil, false
+	}
+
+	yesterday := day.AddDate(0, 0, -1)
+	runs := make([]favoriteRuns, 0, len(trains))
+	for _, favorite := range trains {
+		run := favoriteRuns{favorite: favorite}
+		if pattern := h.patternOf(favorite); pattern == nil {
+			run.stored = true
+		} else {
+			run.yesterday, run.arrival, _ = h.gtfsService.ResolvePatternRun(*pattern, yesterday)
+			run.today, _ = h.gtfsService.ResolvePattern(*pattern, day)
+		}
+		runs = append(runs, run)
+	}
+
+	// Trips only resolve once the timetable is loaded
+	if h.gtfsService.IsDataLoaded() {
+		h.followDay, h.followRuns = day, runs
+	}
+	return runs, true
+}
+
+// trainsChanged drops the cached favorite trains after a write.
+func (h *FavoritesHandler) trainsChanged() {
+	h.followMu.Lock()
+	h.followRuns = nil
+	h.followMu.Unlock()
+}
+
 // ============================================================================
 // SECURITY HELPERS
 // ============================================================================
@@ -79,6 +218,276 @@ func validateContentType(r *http.Request) bool {
 	return strings.HasPrefix(contentType, "application/json")
 }
 
+// readJSONBody reads a size-limited JSON request body into v.
+func readJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) *models.RequestError {
+	return readBody(w, r, 1024*10, v) // 10KB max
+}
+
+// readBody reads a JSON request body of at most limit bytes into v.
+func readBody(w http.ResponseWriter, r *http.Request, limit int64, v interface{}) *models.RequestError {
+	// SECURITY: Limit request body size to prevent DoS
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read request body")
+		return newRequestError(http.StatusBadRequest, "Bad Request", "Failed to read request body")
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		log.Error().Err(err).Msg("Failed to parse JSON")
+		return newRequestError(http.StatusBadRequest, "Bad Request", "Invalid JSON format")
+	}
+	return nil
+}
+
+// validateNotes sanitizes and checks the user-provided nickname and notes.
+func validateNotes(nickname, notes string) (string, string, *models.RequestError) {
+	// SECURITY: Sanitize user input to prevent XSS
+	nickname = sanitizeString(nickname)
+	notes = sanitizeString(notes)
+
+	// VALIDATION: Length limits
+	if len(nickname) > 100 {
+		return "", "", newRequestError(http.StatusBadRequest, "Validation Error",
+			"Nickname must be 100 characters or less")
+	}
+	if len(notes) > 500 {
+		return "", "", newRequestError(http.StatusBadRequest, "Validation Error",
+			"Notes must be 500 characters or less")
+	}
+	return nickname, notes, nil
+}
+
+// errUnauthenticated is returned for favorites requests without an identity.
+var errUnauthenticated = newRequestError(http.StatusUnauthorized, "Unauthorized",
+	"Sign in or send an "+middleware.DeviceIDHeader+" header to use favorites")
+
+// ownerOf returns whose favorites a request works on: the authenticated
+// user's or device's. Every favorites operation is scoped to it.
+func ownerOf(ctx context.Context) (string, *models.RequestError) {
+	identity, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", errUnauthenticated
+	}
+	return identity.Owner(), nil
+}
+
+// storeError converts a repository error to a request error. notFound and
+// conflict are the messages for a missing and a duplicate favorite; a
+// favorite changed since the expected version is 412.
+func storeError(err error, notFound, conflict string) *models.RequestError {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return newRequestError(http.StatusNotFound, "Not Found", notFound)
+	case errors.Is(err, store.ErrDuplicate):
+		return newRequestError(http.StatusConflict, "Conflict", conflict)
+	case errors.Is(err, store.ErrVersionConflict):
+		return errVersionConflict
+	case errors.Is(err, store.ErrIncompleteOrder):
+		return newRequestError(http.StatusBadRequest, "Validation Error", "ids must list all of them, each once")
+	default:
+		log.Error().Err(err).Msg("Favorites store failed")
+		return newRequestError(http.StatusInternalServerError, "Storage Error", "Favorites could not be read or saved")
+	}
+}
+
+// ============================================================================
+// FAVORITE STATIONS - Store operations (shared by REST and WebSocket RPC)
+// ============================================================================
+
+// ListFavorites returns the caller's favorite stations the filter keeps, in
+// its order, and how many favorite stations the caller has.
+func (h *FavoritesHandler) ListFavorites(ctx context.Context, filter favoriteFilter) ([]models.Favorite, int, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, 0, reqErr
+	}
+	if reqErr := filter.normalize(); reqErr != nil {
+		return nil, 0, reqErr
+	}
+	favorites, err := h.repo.ListStations(owner)
+	if err != nil {
+		return nil, 0, storeError(err, "", "")
+	}
+
+	kept := filter.apply(len(favorites), func(i int) favoriteEntry { return stationEntry(favorites[i]) })
+	filtered := make([]models.Favorite, len(kept))
+	for i, index := range kept {
+		filtered[i] = favorites[index]
+	}
+	return filtered, len(favorites), nil
+}
+
+// FindFavorite returns one of the caller's favorite stations by ID.
+func (h *FavoritesHandler) FindFavorite(ctx context.Context, id string) (*models.Favorite, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	favorite, err := h.repo.GetStation(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Favorite with ID "+id+" does not exist", "")
+	}
+	return favorite, nil
+}
+
+// AddFavorite validates and stores a new favorite station of the caller.
+func (h *FavoritesHandler) AddFavorite(ctx context.Context, req models.CreateFavoriteRequest) (*models.Favorite, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	// VALIDATION: StationID is required
+	if req.StationID == "" {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error", "stationId is required")
+	}
+
+	// VALIDATION: Check if station exists
+	station := h.gtfsService.GetStationByID(req.StationID)
+	if station == nil {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"Station with ID "+req.StationID+" does not exist")
+	}
+
+	nickname, notes, reqErr := validateNotes(req.Nickname, req.Notes)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	tags, reqErr := validateTags(req.Tags)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if reqErr := h.validateCollection(owner, req.CollectionID); reqErr != nil {
+		return nil, reqErr
+	}
+
+	// Create the favorite
+	now := time.Now().Format(time.RFC3339)
+	favorite := models.Favorite{
+		ID:           uuid.New().String(), // Generate unique ID
+		Owner:        owner,
+		StationID:    req.StationID,
+		Station:      *station,
+		Nickname:     nickname,
+		Notes:        notes,
+		CollectionID: req.CollectionID,
+		Tags:         tags,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	// Save to store (the store rejects duplicates atomically, so two
+	// concurrent requests can't both pass; it places the favorite last)
+	if err := h.repo.CreateStation(&favorite); err != nil {
+		return nil, storeError(err, "", "Station is already in favorites")
+	}
+	h.audit(ctx, owner, createAction(ctx), models.AuditFavoriteStation, favorite.ID, nil, favorite)
+
+	log.Info().
+		Str("id", favorite.ID).
+		Str("stationId", favorite.StationID).
+		Msg("Created favorite")
+
+	return &favorite, nil
+}
+
+// EditFavorite updates the nickname and notes of a favorite station.
+func (h *FavoritesHandler) EditFavorite(ctx context.Context, id string, req models.UpdateFavoriteRequest) (*models.Favorite, *models.RequestError) {
+	return h.AmendFavorite(ctx, id, models.PatchFavoriteStationRequest{Nickname: &req.Nickname, Notes: &req.Notes})
+}
+
+// AmendFavorite changes the fields of a favorite station the request sets.
+func (h *FavoritesHandler) AmendFavorite(ctx context.Context, id string, req models.PatchFavoriteStationRequest) (*models.Favorite, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	nickname, notes, tags, reqErr := h.validatePatch(owner, req.Nickname, req.Notes, req.CollectionID, req.Tags)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	var before json.RawMessage
+	favorite, err := h.repo.UpdateStation(owner, id, expectedVersion(ctx), func(favorite *models.Favorite) {
+		before, _ = json.Marshal(favorite)
+		if req.Nickname != nil {
+			favorite.Nickname = nickname
+		}
+		if req.Notes != nil {
+			favorite.Notes = notes
+		}
+		if req.CollectionID != nil {
+			favorite.CollectionID = *req.CollectionID
+		}
+		if req.Tags != nil {
+			favorite.Tags = tags
+		}
+		favorite.UpdatedAt = time.Now().Format(time.RFC3339)
+	})
+	if err != nil {
+		return nil, storeError(err, "Favorite with ID "+id+" does not exist", "")
+	}
+	h.audit(ctx, owner, models.AuditUpdate, models.AuditFavoriteStation, id, before, favorite)
+
+	log.Info().
+		Str("id", id).
+		Str("nickname", favorite.Nickname).
+		Msg("Updated favorite")
+
+	return favorite, nil
+}
+
+// validatePatch checks the fields a PATCH of any favorite can set; unset
+// fields (nil) are skipped and come back empty.
+func (h *FavoritesHandler) validatePatch(owner string, nickname, notes, collectionID *string, tags *[]string) (string, string, []string, *models.RequestError) {
+	var newNickname, newNotes string
+	if nickname != nil {
+		newNickname = *nickname
+	}
+	if notes != nil {
+		newNotes = *notes
+	}
+	newNickname, newNotes, reqErr := validateNotes(newNickname, newNotes)
+	if reqErr != nil {
+		return "", "", nil, reqErr
+	}
+
+	var newTags []string
+	if tags != nil {
+		if newTags, reqErr = validateTags(*tags); reqErr != nil {
+			return "", "", nil, reqErr
+		}
+	}
+	if collectionID != nil {
+		if reqErr := h.validateCollection(owner, *collectionID); reqErr != nil {
+			return "", "", nil, reqErr
+		}
+	}
+	return newNickname, newNotes, newTags, nil
+}
+
+// RemoveFavorite deletes a favorite station and returns it.
+func (h *FavoritesHandler) RemoveFavorite(ctx context.Context, id string) (*models.Favorite, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	favorite, err := h.repo.DeleteStation(owner, id, expectedVersion(ctx))
+	if err != nil {
+		return nil, storeError(err, "Favorite with ID "+id+" does not exist", "")
+	}
+	h.audit(ctx, owner, models.AuditDelete, models.AuditFavoriteStation, id, favorite, nil)
+
+	log.Info().
+		Str("id", id).
+		Str("stationId", favorite.StationID).
+		Msg("Deleted favorite")
+
+	return favorite, nil
+}
+
 // ============================================================================
 // GET /api/favorites - Get all favorites
 // ============================================================================
@@ -86,13 +495,11 @@ func validateContentType(r *http.Request) bool {
 // GetFavorites returns all favorite stations.
 // This is a safe, idempotent operation (HTTP GET).
 func (h *FavoritesHandler) GetFavorites(w http.ResponseWriter, r *http.Request) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
-	// Convert map to slice
-	favorites := make([]*models.Favorite, 0, len(h.favorites))
-	for _, fav := range h.favorites {
-		favorites = append(favorites, fav)
+	filter := filterFromQuery(r.URL.Query())
+	favorites, total, reqErr := h.ListFavorites(r.Context(), filter)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
 	}
 
 	log.Debug().Int("count", len(favorites)).Msg("GET favorites")
@@ -100,10 +507,11 @@ func (h *FavoritesHandler) GetFavorites(w http.ResponseWriter, r *http.Request)
 	response := models.APIResponse{
 		Data: favorites,
 		Meta: &models.APIMeta{
-			Total:     len(favorites),
+			Total:     total,
 			Count:     len(favorites),
 			Timestamp: time.Now().Format(time.RFC3339),
 			Source:    "favorites_store",
+			Filters:   filter.meta(),
 		},
 	}
 
@@ -115,12 +523,14 @@ func (h *FavoritesHandler) GetFavorite(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	h.mutex.RLock()
-	favorite, exists := h.favorites[id]
-	h.mutex.RUnlock()
+	favorite, reqErr := h.FindFavorite(r.Context(), id)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
 
-	if !exists {
-		sendError(w, http.StatusNotFound, "Not Found", "Favorite with ID "+id+" does not exist")
+	setETag(w, favorite.Version)
+	if notModified(w, r, favorite.Version) {
 		return
 	}
 
@@ -157,94 +567,26 @@ func (h *FavoritesHandler) CreateFavorite(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// SECURITY: Limit request body size to prevent DoS
-	r.Body = http.MaxBytesReader(w, r.Body, 1024*10) // 10KB max
-
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to read request body")
-		sendError(w, http.StatusBadRequest, "Bad Request", "Failed to read request body")
-		return
-	}
-
 	var req models.CreateFavoriteRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		log.Error().Err(err).Msg("Failed to parse JSON")
-		sendError(w, http.StatusBadRequest, "Bad Request", "Invalid JSON format")
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	// VALIDATION: StationID is required
-	if req.StationID == "" {
-		sendError(w, http.StatusBadRequest, "Validation Error", "stationId is required")
+	favorite, reqErr := h.AddFavorite(r.Context(), req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	// VALIDATION: Check if station exists
-	station := h.gtfsService.GetStationByID(req.StationID)
-	if station == nil {
-		sendError(w, http.StatusBadRequest, "Validation Error",
-			"Station with ID "+req.StationID+" does not exist")
-		return
-	}
-
-	// VALIDATION: Check for duplicates
-	h.mutex.RLock()
-	for _, fav := range h.favorites {
-		if fav.StationID == req.StationID {
-			h.mutex.RUnlock()
-			sendError(w, http.StatusConflict, "Conflict",
-				"Station is already in favorites")
-			return
-		}
-	}
-	h.mutex.RUnlock()
-
-	// SECURITY: Sanitize user input to prevent XSS
-	nickname := sanitizeString(req.Nickname)
-	notes := sanitizeString(req.Notes)
-
-	// VALIDATION: Length limits
-	if len(nickname) > 100 {
-		sendError(w, http.StatusBadRequest, "Validation Error",
-			"Nickname must be 100 characters or less")
-		return
-	}
-	if len(notes) > 500 {
-		sendError(w, http.StatusBadRequest, "Validation Error",
-			"Notes must be 500 characters or less")
-		return
-	}
-
-	// Create the favorite
-	now := time.Now().Format(time.RFC3339)
-	favorite := &models.Favorite{
-		ID:        uuid.New().String(), // Generate unique ID
-		StationID: req.StationID,
-		Station:   *station,
-		Nickname:  nickname,
-		Notes:     notes,
-		CreatedAt: now,
-		UpdatedAt: now,
-	}
-
-	// Save to store
-	h.mutex.Lock()
-	h.favorites[favorite.ID] = favorite
-	h.mutex.Unlock()
-
-	log.Info().
-		Str("id", favorite.ID).
-		Str("stationId", favorite.StationID).
-		Msg("Created favorite")
-
 	// Return 201 Created with the new resource
+	setETag(w, favorite.Version)
 	w.WriteHeader(http.StatusCreated)
 	response := models.APIResponse{
 		Data: favorite,
 		Meta: &models.APIMeta{
-			Timestamp: now,
+			Timestamp: favorite.CreatedAt,
 			Source:    "favorites_store",
 			Note:      "Favorite created successfully",
 		},
@@ -277,61 +619,63 @@ func (h *FavoritesHandler) UpdateFavorite(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	// Check if favorite exists
-	h.mutex.RLock()
-	favorite, exists := h.favorites[id]
-	h.mutex.RUnlock()
+	ctx := ifMatch(r) // the version the edit expects, if any
 
-	if !exists {
-		sendError(w, http.StatusNotFound, "Not Found",
-			"Favorite with ID "+id+" does not exist")
+	// Check if favorite exists
+	if _, reqErr := h.FindFavorite(ctx, id); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	// SECURITY: Limit request body size
-	r.Body = http.MaxBytesReader(w, r.Body, 1024*10)
-
 	// Parse request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		sendError(w, http.StatusBadRequest, "Bad Request", "Failed to read request body")
+	var req models.UpdateFavoriteRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	var req models.UpdateFavoriteRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		sendError(w, http.StatusBadRequest, "Bad Request", "Invalid JSON format")
+	favorite, reqErr := h.EditFavorite(ctx, id, req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	// SECURITY: Sanitize inputs
-	nickname := sanitizeString(req.Nickname)
-	notes := sanitizeString(req.Notes)
+	setETag(w, favorite.Version)
+	response := models.APIResponse{
+		Data: favorite,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_store",
+			Note:      "Favorite updated successfully",
+		},
+	}
 
-	// VALIDATION: Length limits
-	if len(nickname) > 100 {
-		sendError(w, http.StatusBadRequest, "Validation Error",
-			"Nickname must be 100 characters or less")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PatchFavorite changes the fields of a favorite station sent in the body:
+// nickname, notes, collectionId and tags.
+func (h *FavoritesHandler) PatchFavorite(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
 		return
 	}
-	if len(notes) > 500 {
-		sendError(w, http.StatusBadRequest, "Validation Error",
-			"Notes must be 500 characters or less")
+
+	var req models.PatchFavoriteStationRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	// Update the favorite
-	h.mutex.Lock()
-	favorite.Nickname = nickname
-	favorite.Notes = notes
-	favorite.UpdatedAt = time.Now().Format(time.RFC3339)
-	h.mutex.Unlock()
-
-	log.Info().
-		Str("id", id).
-		Str("nickname", nickname).
-		Msg("Updated favorite")
+	ctx := ifMatch(r) // the version the edit expects, if any
+	favorite, reqErr := h.AmendFavorite(ctx, mux.Vars(r)["id"], req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
 
+	setETag(w, favorite.Version)
 	response := models.APIResponse{
 		Data: favorite,
 		Meta: &models.APIMeta{
@@ -361,33 +705,17 @@ func (h *FavoritesHandler) DeleteFavorite(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	h.mutex.Lock()
-	favorite, exists := h.favorites[id]
-	if !exists {
-		h.mutex.Unlock()
-		sendError(w, http.StatusNotFound, "Not Found",
-			"Favorite with ID "+id+" does not exist")
+	if _, reqErr := h.RemoveFavorite(ifMatch(r), id); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	// Delete the favorite
-	delete(h.favorites, id)
-	h.mutex.Unlock()
-
-	log.Info().
-		Str("id", id).
-		Str("stationId", favorite.StationID).
-		Msg("Deleted favorite")
-
 	// Option 1: Return 204 No Content (most RESTful for DELETE)
 	// w.WriteHeader(http.StatusNoContent)
 
 	// Option 2: Return 200 OK with confirmation (more informative)
 	response := models.APIResponse{
-		Data: map[string]interface{}{
-			"deleted": true,
-			"id":      id,
-		},
+		Data: deletedResult(id),
 		Meta: &models.APIMeta{
 			Timestamp: time.Now().Format(time.RFC3339),
 			Source:    "favorites_store",
@@ -398,18 +726,295 @@ func (h *FavoritesHandler) DeleteFavorite(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(response)
 }
 
+// deletedResult is the confirmation returned for deleted favorites.
+func deletedResult(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"deleted": true,
+		"id":      id,
+	}
+}
+
 // ============================================================================
 // FAVORITE TRAINS - With Auto-Follow Feature
 // ============================================================================
 
+// ListFavoriteTrains returns the caller's favorite trains the filter keeps,
+// in its order, and how many favorite trains the caller has.
+func (h *FavoritesHandler) ListFavoriteTrains(ctx context.Context, filter favoriteFilter) ([]models.FavoriteTrain, int, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, 0, reqErr
+	}
+	if reqErr := filter.normalize(); reqErr != nil {
+		return nil, 0, reqErr
+	}
+	trains, err := h.repo.ListTrains(owner)
+	if err != nil {
+		return nil, 0, storeError(err, "", "")
+	}
+
+	kept := filter.apply(len(trains), func(i int) favoriteEntry { return trainEntry(trains[i]) })
+	filtered := make([]models.FavoriteTrain, len(kept))
+	for i, index := range kept {
+		filtered[i] = trains[index]
+	}
+	return filtered, len(trains), nil
+}
+
+// FindFavoriteTrain returns one of the caller's favorite trains by ID.
+func (h *FavoritesHandler) FindFavoriteTrain(ctx context.Context, id string) (*models.FavoriteTrain, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	favorite, err := h.repo.GetTrain(owner, id)
+	if err != nil {
+		return nil, storeError(err, "Favorite train with ID "+id+" does not exist", "")
+	}
+	return favorite, nil
+}
+
+// AddFavoriteTrain validates and stores a new favorite train of the caller.
+// The train must be in the timetable; its recurring service is stored, so
+// the favorite outlives the trip ID.
+func (h *FavoritesHandler) AddFavoriteTrain(ctx context.Context, req models.CreateFavoriteTrainRequest) (*models.FavoriteTrain, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if req.TrainID == "" && req.Pattern == nil {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error", "trainId or pattern is required")
+	}
+	if !h.gtfsService.IsDataLoaded() {
+		return nil, errDataLoading
+	}
+
+	tripID, pattern, reqErr := h.newPattern(req)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	nickname, notes, reqErr := validateNotes(req.Nickname, req.Notes)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	tags, reqErr := validateTags(req.Tags)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if reqErr := h.validateCollection(owner, req.CollectionID); reqErr != nil {
+		return nil, reqErr
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	favorite := models.FavoriteTrain{
+		ID:      uuid.New().String(),
+		Owner:   owner,
+		TrainID: tripID,
+		Pattern: pattern,
+		Train: models.Train{
+			ID:            tripID,
+			TripID:        tripID,
+			RouteID:       pattern.RouteID,
+			Name:          pattern.Name,
+			From:          pattern.OriginName,
+			DepartureTime: pattern.DepartureTime,
+		},
+		Nickname:     nickname,
+		Notes:        notes,
+		AutoFollow:   req.AutoFollow,
+		CollectionID: req.CollectionID,
+		Tags:         tags,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := h.repo.CreateTrain(&favorite); err != nil {
+		return nil, storeError(err, "", "Train is already in favorites")
+	}
+	h.trainsChanged()
+	h.audit(ctx, owner, createAction(ctx), models.AuditFavoriteTrain, favorite.ID, nil, favorite)
+
+	log.Info().
+		Str("id", favorite.ID).
+		Str("trainId", favorite.TrainID).
+		Str("pattern", pattern.Name+" "+pattern.DepartureTime+" from "+pattern.OriginID).
+		Bool("autoFollow", favorite.AutoFollow).
+		Msg("Created favorite train")
+
+	return &favorite, nil
+}
+
+// newPattern validates the train of a new favorite against the timetable
+// and returns a trip of it and its pattern.
+func (h *FavoritesHandler) newPattern(req models.CreateFavoriteTrainRequest) (string, *models.ServicePattern, *models.RequestError) {
+	if req.Pattern == nil {
+		pattern := h.gtfsService.PatternOf(req.TrainID)
+		if pattern == nil {
+			return "", nil, newRequestError(http.StatusBadRequest, "Validation Error",
+				"Train with ID "+req.TrainID+" does not exist")
+		}
+		if req.Days != nil {
+			days, err := services.NormalizePatternDays(req.Days)
+			if err != nil {
+				return "", nil, newRequestError(http.StatusBadRequest, "Validation Error", "days: "+err.Error())
+			}
+			pattern.Days = days
+		}
+		return req.TrainID, pattern, nil
+	}
+
+	pattern := *req.Pattern
+	pattern.Name = strings.TrimSpace(pattern.Name)
+	if pattern.Name == "" || pattern.OriginID == "" {
+		return "", nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"pattern.name, pattern.originId and pattern.departureTime are required")
+	}
+	origin := h.gtfsService.GetStationByID(pattern.OriginID)
+	if origin == nil {
+		return "", nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"Station with ID "+pattern.OriginID+" does not exist")
+	}
+	departure, err := services.NormalizePatternTime(pattern.DepartureTime)
+	if err != nil {
+		return "", nil, newRequestError(http.StatusBadRequest, "Validation Error", "pattern.departureTime: "+err.Error())
+	}
+	pattern.DepartureTime = departure
+	pattern.OriginName = origin.Name
+
+	trips := h.gtfsService.PatternTrips(pattern)
+	if len(trips) == 0 {
+		return "", nil, newRequestError(http.StatusBadRequest, "Validation Error",
+			"No "+pattern.Name+" leaves "+origin.Name+" at "+departure)
+	}
+	tripID := trips[0]
+	pattern.RouteID = h.gtfsService.GetTrip(tripID).RouteID
+
+	// Without days, the pattern runs whenever the timetable runs the train
+	if pattern.Days == nil {
+		pattern.Days = h.gtfsService.PatternOf(tripID).Days
+	} else if pattern.Days, err = services.NormalizePatternDays(pattern.Days); err != nil {
+		return "", nil, newRequestError(http.StatusBadRequest, "Validation Error", "pattern.days: "+err.Error())
+	}
+	return tripID, &pattern, nil
+}
+
+// patternOf returns a favorite train's pattern. Favorites stored before
+// patterns get the pattern of their trip, or nil if the trip is gone.
+func (h *FavoritesHandler) patternOf(favorite models.FavoriteTrain) *models.ServicePattern {
+	if favorite.Pattern != nil {
+		return favorite.Pattern
+	}
+	return h.gtfsService.PatternOf(favorite.TrainID)
+}
+
+// EditFavoriteTrain updates a favorite train.
+func (h *FavoritesHandler) EditFavoriteTrain(ctx context.Context, id string, req models.UpdateFavoriteTrainRequest) (*models.FavoriteTrain, *models.RequestError) {
+	return h.AmendFavoriteTrain(ctx, id, models.PatchFavoriteTrainRequest{
+		Nickname:   &req.Nickname,
+		Notes:      &req.Notes,
+		AutoFollow: req.AutoFollow,
+		Days:       req.Days,
+	})
+}
+
+// AmendFavoriteTrain changes the fields of a favorite train the request
+// sets. Changing the days also stores the pattern of favorites created
+// before patterns.
+func (h *FavoritesHandler) AmendFavoriteTrain(ctx context.Context, id string, req models.PatchFavoriteTrainRequest) (*models.FavoriteTrain, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	nickname, notes, tags, reqErr := h.validatePatch(owner, req.Nickname, req.Notes, req.CollectionID, req.Tags)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	var pattern *models.ServicePattern
+	if req.Days != nil {
+		days, err := services.NormalizePatternDays(req.Days)
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "Validation Error", "days: "+err.Error())
+		}
+		current, err := h.repo.GetTrain(owner, id)
+		if err != nil {
+			return nil, storeError(err, "Favorite train with ID "+id+" does not exist", "")
+		}
+		if pattern = h.patternOf(*current); pattern == nil {
+			return nil, newRequestError(http.StatusConflict, "Conflict",
+				"Train "+current.TrainID+" is no longer in the timetable; its days can't be changed")
+		}
+		changed := *pattern
+		changed.Days = days
+		pattern = &changed
+	}
+
+	var before json.RawMessage
+	favorite, err := h.repo.UpdateTrain(owner, id, expectedVersion(ctx), func(favorite *models.FavoriteTrain) {
+		before, _ = json.Marshal(favorite)
+		if req.Nickname != nil {
+			favorite.Nickname = nickname
+		}
+		if req.Notes != nil {
+			favorite.Notes = notes
+		}
+		if req.CollectionID != nil {
+			favorite.CollectionID = *req.CollectionID
+		}
+		if req.Tags != nil {
+			favorite.Tags = tags
+		}
+		if req.AutoFollow != nil {
+			favorite.AutoFollow = *req.AutoFollow
+		}
+		if pattern != nil {
+			favorite.Pattern = pattern
+		}
+		favorite.UpdatedAt = time.Now().Format(time.RFC3339)
+	})
+	if err != nil {
+		return nil, storeError(err, "Favorite train with ID "+id+" does not exist", "")
+	}
+	h.trainsChanged()
+	h.audit(ctx, owner, models.AuditUpdate, models.AuditFavoriteTrain, id, before, favorite)
+
+	log.Info().
+		Str("id", id).
+		Bool("autoFollow", favorite.AutoFollow).
+		Msg("Updated favorite train")
+
+	return favorite, nil
+}
+
+// RemoveFavoriteTrain deletes a favorite train and returns it.
+func (h *FavoritesHandler) RemoveFavoriteTrain(ctx context.Context, id string) (*models.FavoriteTrain, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	favorite, err := h.repo.DeleteTrain(owner, id, expectedVersion(ctx))
+	if err != nil {
+		return nil, storeError(err, "Favorite train with ID "+id+" does not exist", "")
+	}
+	h.trainsChanged()
+	h.audit(ctx, owner, models.AuditDelete, models.AuditFavoriteTrain, id, favorite, nil)
+	h.removeRules(owner, id)
+
+	log.Info().
+		Str("id", id).
+		Str("trainId", favorite.TrainID).
+		Msg("Deleted favorite train")
+
+	return favorite, nil
+}
+
 // GetFavoriteTrains returns all favorite trains.
 func (h *FavoritesHandler) GetFavoriteTrains(w http.ResponseWriter, r *http.Request) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
-	trains := make([]*models.FavoriteTrain, 0, len(h.favoriteTrains))
-	for _, fav := range h.favoriteTrains {
-		trains = append(trains, fav)
+	filter := filterFromQuery(r.URL.Query())
+	trains, total, reqErr := h.ListFavoriteTrains(r.Context(), filter)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
 	}
 
 	log.Debug().Int("count", len(trains)).Msg("GET favorite trains")
@@ -417,10 +1022,11 @@ func (h *FavoritesHandler) GetFavoriteTrains(w http.ResponseWriter, r *http.Requ
 	response := models.APIResponse{
 		Data: trains,
 		Meta: &models.APIMeta{
-			Total:     len(trains),
+			Total:     total,
 			Count:     len(trains),
 			Timestamp: time.Now().Format(time.RFC3339),
 			Source:    "favorites_trains_store",
+			Filters:   filter.meta(),
 		},
 	}
 
@@ -432,12 +1038,14 @@ func (h *FavoritesHandler) GetFavoriteTrain(w http.ResponseWriter, r *http.Reque
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	h.mutex.RLock()
-	favorite, exists := h.favoriteTrains[id]
-	h.mutex.RUnlock()
+	favorite, reqErr := h.FindFavoriteTrain(r.Context(), id)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
 
-	if !exists {
-		sendError(w, http.StatusNotFound, "Not Found", "Favorite train with ID "+id+" does not exist")
+	setETag(w, favorite.Version)
+	if notModified(w, r, favorite.Version) {
 		return
 	}
 
@@ -460,75 +1068,24 @@ func (h *FavoritesHandler) CreateFavoriteTrain(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1024*10)
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		sendError(w, http.StatusBadRequest, "Bad Request", "Failed to read request body")
-		return
-	}
-
 	var req models.CreateFavoriteTrainRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		sendError(w, http.StatusBadRequest, "Bad Request", "Invalid JSON format")
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	if req.TrainID == "" {
-		sendError(w, http.StatusBadRequest, "Validation Error", "trainId is required")
+	favorite, reqErr := h.AddFavoriteTrain(r.Context(), req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	// Check for duplicates
-	h.mutex.RLock()
-	for _, fav := range h.favoriteTrains {
-		if fav.TrainID == req.TrainID {
-			h.mutex.RUnlock()
-			sendError(w, http.StatusConflict, "Conflict", "Train is already in favorites")
-			return
-		}
-	}
-	h.mutex.RUnlock()
-
-	nickname := sanitizeString(req.Nickname)
-	notes := sanitizeString(req.Notes)
-
-	if len(nickname) > 100 {
-		sendError(w, http.StatusBadRequest, "Validation Error", "Nickname must be 100 characters or less")
-		return
-	}
-	if len(notes) > 500 {
-		sendError(w, http.StatusBadRequest, "Validation Error", "Notes must be 500 characters or less")
-		return
-	}
-
-	now := time.Now().Format(time.RFC3339)
-	favorite := &models.FavoriteTrain{
-		ID:         uuid.New().String(),
-		TrainID:    req.TrainID,
-		Train:      models.Train{ID: req.TrainID},
-		Nickname:   nickname,
-		Notes:      notes,
-		AutoFollow: req.AutoFollow,
-		CreatedAt:  now,
-		UpdatedAt:  now,
-	}
-
-	h.mutex.Lock()
-	h.favoriteTrains[favorite.ID] = favorite
-	h.mutex.Unlock()
-
-	log.Info().
-		Str("id", favorite.ID).
-		Str("trainId", favorite.TrainID).
-		Bool("autoFollow", favorite.AutoFollow).
-		Msg("Created favorite train")
-
+	setETag(w, favorite.Version)
 	w.WriteHeader(http.StatusCreated)
 	response := models.APIResponse{
 		Data: favorite,
 		Meta: &models.APIMeta{
-			Timestamp: now,
+			Timestamp: favorite.CreatedAt,
 			Source:    "favorites_trains_store",
 			Note:      "Favorite train created successfully",
 		},
@@ -548,55 +1105,60 @@ func (h *FavoritesHandler) UpdateFavoriteTrain(w http.ResponseWriter, r *http.Re
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	h.mutex.RLock()
-	favorite, exists := h.favoriteTrains[id]
-	h.mutex.RUnlock()
-
-	if !exists {
-		sendError(w, http.StatusNotFound, "Not Found", "Favorite train with ID "+id+" does not exist")
+	ctx := ifMatch(r) // the version the edit expects, if any
+	if _, reqErr := h.FindFavoriteTrain(ctx, id); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, 1024*10)
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		sendError(w, http.StatusBadRequest, "Bad Request", "Failed to read request body")
+	var req models.UpdateFavoriteTrainRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	var req models.UpdateFavoriteTrainRequest
-	if err := json.Unmarshal(body, &req); err != nil {
-		sendError(w, http.StatusBadRequest, "Bad Request", "Invalid JSON format")
+	favorite, reqErr := h.EditFavoriteTrain(ctx, id, req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	nickname := sanitizeString(req.Nickname)
-	notes := sanitizeString(req.Notes)
+	setETag(w, favorite.Version)
+	response := models.APIResponse{
+		Data: favorite,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_trains_store",
+			Note:      "Favorite train updated successfully",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
 
-	if len(nickname) > 100 {
-		sendError(w, http.StatusBadRequest, "Validation Error", "Nickname must be 100 characters or less")
+// PatchFavoriteTrain changes the fields of a favorite train sent in the
+// body: nickname, notes, autoFollow, days, collectionId and tags.
+func (h *FavoritesHandler) PatchFavoriteTrain(w http.ResponseWriter, r *http.Request) {
+	if !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json")
 		return
 	}
-	if len(notes) > 500 {
-		sendError(w, http.StatusBadRequest, "Validation Error", "Notes must be 500 characters or less")
+
+	var req models.PatchFavoriteTrainRequest
+	if reqErr := readJSONBody(w, r, &req); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	h.mutex.Lock()
-	favorite.Nickname = nickname
-	favorite.Notes = notes
-	if req.AutoFollow != nil {
-		favorite.AutoFollow = *req.AutoFollow
+	ctx := ifMatch(r) // the version the edit expects, if any
+	favorite, reqErr := h.AmendFavoriteTrain(ctx, mux.Vars(r)["id"], req)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
 	}
-	favorite.UpdatedAt = time.Now().Format(time.RFC3339)
-	h.mutex.Unlock()
-
-	log.Info().
-		Str("id", id).
-		Bool("autoFollow", favorite.AutoFollow).
-		Msg("Updated favorite train")
 
+	setETag(w, favorite.Version)
 	response := models.APIResponse{
 		Data: favorite,
 		Meta: &models.APIMeta{
@@ -614,27 +1176,13 @@ func (h *FavoritesHandler) DeleteFavoriteTrain(w http.ResponseWriter, r *http.Re
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	h.mutex.Lock()
-	favorite, exists := h.favoriteTrains[id]
-	if !exists {
-		h.mutex.Unlock()
-		sendError(w, http.StatusNotFound, "Not Found", "Favorite train with ID "+id+" does not exist")
+	if _, reqErr := h.RemoveFavoriteTrain(ifMatch(r), id); reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
-	delete(h.favoriteTrains, id)
-	h.mutex.Unlock()
-
-	log.Info().
-		Str("id", id).
-		Str("trainId", favorite.TrainID).
-		Msg("Deleted favorite train")
-
 	response := models.APIResponse{
-		Data: map[string]interface{}{
-			"deleted": true,
-			"id":      id,
-		},
+		Data: deletedResult(id),
 		Meta: &models.APIMeta{
 			Timestamp: time.Now().Format(time.RFC3339),
 			Source:    "favorites_trains_store",
@@ -0,0 +1,248 @@
+// Package handlers provides HTTP handlers for the Swiss Railway API.
+// This file implements safe concurrent edits and retries of favorites:
+// versions sent as ETags and checked with If-Match (optimistic locking),
+// and Idempotency-Key replays of creates.
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/store"
+)
+
+// IdempotencyKeyHeader makes a create safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	// maxIdempotencyKey limits the length of an Idempotency-Key.
+	maxIdempotencyKey = 255
+
+	// replayPurgeInterval is how often expired responses are removed.
+	replayPurgeInterval = time.Minute
+)
+
+// errVersionConflict is returned when a favorite changed since the version
+// the caller sent.
+var errVersionConflict = newRequestError(http.StatusPreconditionFailed, "Precondition Failed",
+	"Favorite has changed since you loaded it; reload it and try again")
+
+// versionKey is the context key of the version an edit expects.
+type versionKey struct{}
+
+// withVersion returns a context whose edits expect the favorite to be at
+// version; 0 expects any version.
+func withVersion(ctx context.Context, version int) context.Context {
+	if version == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+// expectedVersion returns the version edits in ctx expect, 0 for any.
+func expectedVersion(ctx context.Context) int {
+	version, _ := ctx.Value(versionKey{}).(int)
+	return version
+}
+
+// etag is the ETag of a favorite at version.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag sends a favorite's version as the ETag of the response. It must
+// be called before the status is written.
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// ifMatch returns the request's context with the version its If-Match
+// header expects. "*" expects any version; an ETag that isn't one of ours
+// (including weak ones, which never match) expects a version no favorite
+// has, so the edit fails with 412.
+func ifMatch(r *http.Request) context.Context {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return r.Context()
+	}
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || version <= 0 || !strings.HasPrefix(header, `"`) {
+		version = -1
+	}
+	return withVersion(r.Context(), version)
+}
+
+// notModified answers a GET with 304 if its If-None-Match header has the
+// favorite's current ETag.
+func notModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == current || tag == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// SetReplayTTL sets how long responses to creates with an Idempotency-Key
+// are replayed; 0 ignores the header.
+func (h *FavoritesHandler) SetReplayTTL(ttl time.Duration) {
+	h.replayTTL = ttl
+}
+
+// Idempotent makes a create safe to retry. The first request with an
+// Idempotency-Key is handled and its response stored; retries with the key
+// get the stored response, with Idempotent-Replayed: true, until it
+// expires. A key reused for another request fails with 422, and a retry
+// while the first request is still running with 409. Server errors aren't
+// stored, so those can be retried.
+func (h *FavoritesHandler) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || h.replayTTL <= 0 {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			sendError(w, http.StatusBadRequest, "Bad Request",
+				IdempotencyKeyHeader+" must be "+strconv.Itoa(maxIdempotencyKey)+" characters or less")
+			return
+		}
+		owner, reqErr := ownerOf(r.Context())
+		if reqErr != nil {
+			sendRequestError(w, reqErr)
+			return
+		}
+
+		// The body is read here to fingerprint the request and handed on
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			sendError(w, http.StatusRequestEntityTooLarge, "Payload Too Large", "Request body is too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "\n" + string(body)))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		slot := owner + "\x00" + key
+		h.replayMu.Lock()
+		if h.inFlight[slot] {
+			h.replayMu.Unlock()
+			sendError(w, http.StatusConflict, "Conflict",
+				"A request with this "+IdempotencyKeyHeader+" is still in progress")
+			return
+		}
+		h.inFlight[slot] = true
+		h.replayMu.Unlock()
+		defer func() {
+			h.replayMu.Lock()
+			delete(h.inFlight, slot)
+			h.replayMu.Unlock()
+		}()
+
+		now := time.Now()
+		stored, err := h.repo.GetResponse(owner, key)
+		switch {
+		case err == nil && now.Before(stored.ExpiresAt):
+			if stored.Fingerprint != fingerprint {
+				sendError(w, http.StatusUnprocessableEntity, "Unprocessable Entity",
+					IdempotencyKeyHeader+" was already used for a different request")
+				return
+			}
+			for name, value := range stored.Header {
+				w.Header().Set(name, value)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		case err != nil && !errors.Is(err, store.ErrNotFound):
+			sendRequestError(w, storeError(err, "", ""))
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		response := models.IdempotentResponse{
+			Owner:       owner,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      recorder.status,
+			Header:      make(map[string]string),
+			Body:        recorder.body.Bytes(),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(h.replayTTL),
+		}
+		for _, name := range []string{"Content-Type", "ETag"} {
+			if value := w.Header().Get(name); value != "" {
+				response.Header[name] = value
+			}
+		}
+		if err := h.repo.SaveResponse(response); err != nil {
+			log.Error().Err(err).Str("key", key).Msg("Failed to store idempotent response")
+		}
+		h.purgeResponses(now)
+	}
+}
+
+// purgeResponses removes expired responses, at most once a
+// replayPurgeInterval.
+func (h *FavoritesHandler) purgeResponses(now time.Time) {
+	h.replayMu.Lock()
+	if now.Sub(h.lastPurge) < replayPurgeInterval {
+		h.replayMu.Unlock()
+		return
+	}
+	h.lastPurge = now
+	h.replayMu.Unlock()
+
+	purged, err := h.repo.PurgeResponses(now)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to purge idempotent responses")
+		return
+	}
+	if purged > 0 {
+		log.Debug().Int("purged", purged).Msg("Purged expired idempotent responses")
+	}
+}
+
+// responseRecorder passes a response through and keeps a copy of its
+// status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code.
+func (rr *responseRecorder) WriteHeader(code int) {
+	rr.status = code
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the body.
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	h := newTestFavorites(t)
+	favorite, reqErr := h.AddFavorite(asUser("alice"), models.CreateFavoriteRequest{StationID: "8503000"})
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	vars := map[string]string{"id": favorite.ID}
+	target := "/api/favorites/" + favorite.ID
+
+	get := func(ifNoneMatch string) *http.Request {
+		r := newRequest(http.MethodGet, target, "", "alice", vars)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		return r
+	}
+	for _, tc := range []struct {
+		ifNoneMatch string
+		status      int
+	}{
+		{`"1"`, http.StatusNotModified},
+		{`W/"1"`, http.StatusNotModified},
+		{`"3", "1"`, http.StatusNotModified},
+		{`*`, http.StatusNotModified},
+		{`"2"`, http.StatusOK},
+		{``, http.StatusOK},
+	} {
+		rec := serve(h.GetFavorite, get(tc.ifNoneMatch))
+		if rec.Code != tc.status || rec.Header().Get("ETag") != `"1"` {
+			t.Errorf("If-None-Match %s: %d with ETag %s, want %d", tc.ifNoneMatch, rec.Code, rec.Header().Get("ETag"), tc.status)
+		}
+		if tc.status == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: body %s", tc.ifNoneMatch, rec.Body)
+		}
+	}
+
+	edit := func(handler http.HandlerFunc, method, ifMatch, body string) *httptest.ResponseRecorder {
+		r := newRequest(method, target, body, "alice", vars)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		return serve(handler, r)
+	}
+
+	// Only the current version, or any with *, may be edited
+	for _, ifMatch := range []string{`"2"`, `W/"1"`, `1`, `"one"`} {
+		if rec := edit(h.UpdateFavorite, http.MethodPut, ifMatch, `{"nickname": "stale"}`); rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("If-Match %s: %d %s", ifMatch, rec.Code, rec.Body)
+		}
+	}
+	rec := edit(h.UpdateFavorite, http.MethodPut, `"1"`, `{"nickname": "home"}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("update: %d with ETag %s: %s", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+
+	// An edit made from version 1 is now stale
+	if rec := edit(h.PatchFavorite, http.MethodPatch, `"1"`, `{"notes": "lost update"}`); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale patch: %d", rec.Code)
+	}
+	if rec := edit(h.DeleteFavorite, http.MethodDelete, `"1"`, ""); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale delete: %d", rec.Code)
+	}
+	if stored, _ := h.FindFavorite(asUser("alice"), favorite.ID); stored.Nickname != "home" || stored.Notes != "" || stored.Version != 2 {
+		t.Errorf("stored = %+v", stored)
+	}
+	if rec := edit(h.PatchFavorite, http.MethodPatch, "*", `{"notes": "any version"}`); rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"3"` {
+		t.Errorf("patch with *: %d with ETag %s", rec.Code, rec.Header().Get("ETag"))
+	}
+	if rec := edit(h.DeleteFavorite, http.MethodDelete, `"3"`, ""); rec.Code != http.StatusOK {
+		t.Errorf("delete: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	h := newTestFavorites(t)
+	h.SetReplayTTL(time.Minute)
+	create := h.Idempotent(h.CreateFavorite)
+
+	post := func(subject, key, body string) *httptest.ResponseRecorder {
+		r := newRequest(http.MethodPost, "/api/favorites/stations", body, subject, nil)
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		return serve(create, r)
+	}
+	const zurich = `{"stationId": "8503000", "nickname": "home"}`
+
+	first := post("alice", "k1", zurich)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first: %d %s", first.Code, first.Body)
+	}
+
+	// A retry gets the stored response; nothing is created twice
+	retry := post("alice", "k1", zurich)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" ||
+		retry.Body.String() != first.Body.String() || retry.Header().Get("ETag") != `"1"` {
+		t.Errorf("retry: %d %v %s", retry.Code, retry.Header(), retry.Body)
+	}
+	if stations, _ := h.repo.ListStations("user:alice"); len(stations) != 1 {
+		t.Errorf("%d stations", len(stations))
+	}
+
+	// The key can't be reused for another request
+	if rec := post("alice", "k1", `{"stationId": "8507000"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("other body: %d %s", rec.Code, rec.Body)
+	}
+	if stations, _ := h.repo.ListStations("user:alice"); len(stations) != 1 {
+		t.Errorf("%d stations after reusing the key", len(stations))
+	}
+
+	// Keys are per user; without a key the create isn't replayed
+	if rec := post("bob", "k1", zurich); rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("bob: %d %s", rec.Code, rec.Body)
+	}
+	if rec := post("alice", "", zurich); rec.Code != http.StatusConflict {
+		t.Errorf("without a key: %d", rec.Code)
+	}
+
+	// Client errors are replayed too
+	for i := 0; i < 2; i++ {
+		rec := post("alice", "k2", `{"stationId": "0000000"}`)
+		if rec.Code != http.StatusBadRequest || (rec.Header().Get("Idempotent-Replayed") == "true") != (i == 1) {
+			t.Errorf("invalid station, try %d: %d %v", i+1, rec.Code, rec.Header())
+		}
+	}
+
+	// A retry while the first request runs conflicts
+	h.inFlight["user:alice\x00k3"] = true
+	if rec := post("alice", "k3", zurich); rec.Code != http.StatusConflict {
+		t.Errorf("in flight: %d", rec.Code)
+	}
+	if rec := post("alice", strings.Repeat("k", maxIdempotencyKey+1), zurich); rec.Code != http.StatusBadRequest {
+		t.Errorf("long key: %d", rec.Code)
+	}
+
+	// Without a replay TTL the header is ignored
+	h.SetReplayTTL(0)
+	if rec := post("alice", "k1", zurich); rec.Code != http.StatusConflict {
+		t.Errorf("replays off: %d", rec.Code)
+	}
+}
@@ -0,0 +1,233 @@
+// Package handlers provides HTTP handlers for the Swiss Railway API.
+// This file implements the favorites dashboard: the next departures of each
+// favorite station, the live state (or next run) of each favorite train and
+// the next connections of each favorite journey, read fresh from the
+// timetable instead of the snapshots stored with them.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+const (
+	// defaultDashboardDepartures is how many departures each favorite
+	// station shows unless ?departures= says otherwise.
+	defaultDashboardDepartures = 5
+
+	// nextRunLookaheadDays is how far ahead the dashboard looks for the next
+	// run of a favorite train that isn't running.
+	nextRunLookaheadDays = 7
+
+	// dashboardConnections is how many next connections each favorite
+	// journey shows.
+	dashboardConnections = 3
+)
+
+// Dashboard returns the caller's favorites with their current state. Each
+// station lists its next departures (at most services.MaxStationDepartures).
+func (h *FavoritesHandler) Dashboard(ctx context.Context, departures int) (*models.FavoritesDashboard, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if !h.gtfsService.IsDataLoaded() {
+		return nil, errDataLoading
+	}
+
+	// Times are reported in Swiss local time, like the timetable
+	return h.dashboardAt(owner, departures, swissTime(time.Now()))
+}
+
+// dashboardAt returns owner's dashboard at now.
+func (h *FavoritesHandler) dashboardAt(owner string, departures int, now time.Time) (*models.FavoritesDashboard, *models.RequestError) {
+	stations, err := h.repo.ListStations(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	trains, err := h.repo.ListTrains(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	journeys, err := h.repo.ListJourneys(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+
+	dashboard := &models.FavoritesDashboard{
+		Stations: make([]models.FavoriteStationBoard, 0, len(stations)),
+		Trains:   make([]models.FavoriteTrainStatus, 0, len(trains)),
+		Journeys: make([]models.JourneyConnections, 0, len(journeys)),
+	}
+	for _, favorite := range stations {
+		dashboard.Stations = append(dashboard.Stations, h.stationBoard(favorite, departures, now))
+	}
+	for _, favorite := range trains {
+		dashboard.Trains = append(dashboard.Trains, h.trainStatus(favorite, now))
+	}
+	for i := range journeys {
+		// Planned locally: the dashboard doesn't spend upstream calls
+		connections, reqErr := h.journeyConnections(&journeys[i], sourceGTFS, dashboardConnections, now)
+		if reqErr != nil {
+			return nil, reqErr
+		}
+		dashboard.Journeys = append(dashboard.Journeys, *connections)
+	}
+	return dashboard, nil
+}
+
+// stationBoard returns the next departures of a favorite station. A station
+// no longer in the timetable keeps its stored data and has no departures.
+func (h *FavoritesHandler) stationBoard(favorite models.FavoriteStation, limit int, now time.Time) models.FavoriteStationBoard {
+	board := models.FavoriteStationBoard{
+		FavoriteID: favorite.ID,
+		Nickname:   favorite.Nickname,
+		Station:    &favorite.Station,
+		Departures: make([]models.Departure, 0),
+	}
+
+	departures := h.gtfsService.GetStationDeparturesAt(favorite.StationID, now)
+	if departures == nil {
+		return board
+	}
+	board.Station = departures.Station
+	if len(departures.Departures) > limit {
+		board.Departures = departures.Departures[:limit]
+	} else if departures.Departures != nil {
+		board.Departures = departures.Departures
+	}
+	return board
+}
+
+// trainStatus returns where a favorite train is while it runs, otherwise
+// when it next runs. The trip is looked up from the favorite's pattern.
+func (h *FavoritesHandler) trainStatus(favorite models.FavoriteTrain, now time.Time) models.FavoriteTrainStatus {
+	status := models.FavoriteTrainStatus{
+		FavoriteID: favorite.ID,
+		TrainID:    favorite.TrainID,
+		Nickname:   favorite.Nickname,
+		Status:     models.FavoriteTrainUnknown,
+	}
+
+	pattern := h.patternOf(favorite)
+	if pattern == nil {
+		return status
+	}
+	status.Pattern = pattern
+	status.Name = pattern.Name
+	if len(h.gtfsService.PatternTrips(*pattern)) == 0 {
+		status.Status = models.FavoriteTrainDiscontinued
+		return status
+	}
+
+	if tripID, ok := h.gtfsService.ResolvePattern(*pattern, now); ok {
+		if train := h.gtfsService.GetTrainAt(tripID, now); train != nil {
+			status.TripID = tripID
+			status.Status = models.FavoriteTrainRunning
+			status.Name = train.Name
+			status.Headsign = h.gtfsService.GetTrip(tripID).TripHeadsign
+			status.Position = train.Position
+			status.Delay = train.Delay
+			status.Destination = destination(train)
+			status.ETA = eta(train.ArrivalTime, train.Delay, now)
+			if stop := nextStop(train); stop != nil {
+				status.NextStop = stop
+				arrival := stop.ArrivalTime
+				if arrival == "" {
+					arrival = stop.DepartureTime
+				}
+				status.NextStopETA = eta(arrival, train.Delay, now)
+			}
+			return status
+		}
+	}
+
+	tripID, day, departure, ok := h.gtfsService.NextPatternRun(*pattern, now, nextRunLookaheadDays)
+	if !ok {
+		status.Status = models.FavoriteTrainNotRunning
+		return status
+	}
+	status.TripID = tripID
+	status.Status = models.FavoriteTrainScheduled
+	status.Headsign = h.gtfsService.GetTrip(tripID).TripHeadsign
+	status.NextRun = &models.ScheduledRun{
+		Date:      day.Format("2006-01-02"),
+		Departure: departure.Format(time.RFC3339),
+		From:      h.gtfsService.GetStationByID(pattern.OriginID),
+	}
+	return status
+}
+
+// nextStop returns the first stop of a running train it hasn't departed.
+func nextStop(train *models.Train) *models.TrainStop {
+	for i := range train.Timetable {
+		if !train.Timetable[i].IsPassed {
+			stop := train.Timetable[i]
+			return &stop
+		}
+	}
+	return nil
+}
+
+// destination returns the last stop of a train.
+func destination(train *models.Train) *models.Station {
+	if len(train.Timetable) == 0 {
+		return nil
+	}
+	return train.Timetable[len(train.Timetable)-1].Station
+}
+
+// eta returns when a train reaches a GTFS time with its delay, or "" if the
+// time can't be parsed.
+func eta(gtfsTime string, delay int, now time.Time) string {
+	until, ok := services.UntilGTFSTime(gtfsTime, now)
+	if !ok {
+		return ""
+	}
+	return now.Add(until + time.Duration(delay)*time.Minute).Format(time.RFC3339)
+}
+
+// ============================================================================
+// GET /api/favorites/dashboard - Current state of all favorites
+// ============================================================================
+
+// GetDashboard returns the caller's favorite stations with their next
+// departures, favorite trains with their live state or next run and
+// favorite journeys with their next connections.
+// ?departures=N sets the departures per station (default 5).
+func (h *FavoritesHandler) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	departures := defaultDashboardDepartures
+	if value := r.URL.Query().Get("departures"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > services.MaxStationDepartures {
+			sendError(w, http.StatusBadRequest, "Invalid Parameter",
+				"departures must be between 1 and "+strconv.Itoa(services.MaxStationDepartures))
+			return
+		}
+		departures = n
+	}
+
+	dashboard, reqErr := h.Dashboard(r.Context(), departures)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: dashboard,
+		Meta: &models.APIMeta{
+			Count:     len(dashboard.Stations) + len(dashboard.Trains) + len(dashboard.Journeys),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_store",
+			Filters:   map[string]interface{}{"departures": departures},
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
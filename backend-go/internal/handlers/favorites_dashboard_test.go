@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// zurichTime returns Friday 14 March 2025 at the given Swiss time.
+func zurichTime(hour, min int) time.Time {
+	return swissTime(time.Date(2025, 3, 14, hour-1, min, 0, 0, time.UTC))
+}
+
+func TestDashboard(t *testing.T) {
+	h := newTestFavorites(t)
+	ctx := asUser("alice")
+
+	for _, id := range []string{"8500218", "8503000"} {
+		if _, reqErr := h.AddFavorite(ctx, models.CreateFavoriteRequest{StationID: id}); reqErr != nil {
+			t.Fatal(reqErr)
+		}
+	}
+	// A station dropped from the timetable since
+	gone := &models.FavoriteStation{ID: "gone", Owner: "user:alice", StationID: "8500010", Station: models.Station{ID: "8500010", Name: "Basel SBB"}}
+	if err := h.repo.CreateStation(gone); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, trip := range []string{"ic1-0800", "ic1-2330"} {
+		if _, reqErr := h.AddFavoriteTrain(ctx, models.CreateFavoriteTrainRequest{TrainID: trip}); reqErr != nil {
+			t.Fatal(reqErr)
+		}
+	}
+	discontinued := &models.FavoriteTrain{ID: "noon", Owner: "user:alice", TrainID: "ic1-1200",
+		Pattern: &models.ServicePattern{Name: "IC 1", OriginID: "8503000", DepartureTime: "12:00", Days: []string{"fri"}}}
+	if err := h.repo.CreateTrain(discontinued); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []models.CreateFavoriteJourneyRequest{
+		{OriginID: "8503000", DestinationID: "8507000", Windows: []models.TimeWindow{{Start: "07:30", End: "08:30"}}},
+		{OriginID: "8507000", DestinationID: "8503000"}, // no trains that way
+	} {
+		if _, reqErr := h.AddFavoriteJourney(ctx, req); reqErr != nil {
+			t.Fatal(reqErr)
+		}
+	}
+
+	// Before the morning train: nothing runs
+	dashboard, reqErr := h.dashboardAt("user:alice", 1, zurichTime(7, 50))
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	if len(dashboard.Stations) != 3 || len(dashboard.Trains) != 3 || len(dashboard.Journeys) != 2 {
+		t.Fatalf("dashboard = %+v", dashboard)
+	}
+
+	olten, zurich, basel := dashboard.Stations[0], dashboard.Stations[1], dashboard.Stations[2]
+	if len(olten.Departures) != 1 || olten.Departures[0].TripID != "ic1-0800" || olten.Departures[0].DepartureTime != "08:30:00" {
+		t.Errorf("Olten = %+v, want the 08:30 departure only", olten.Departures)
+	}
+	if len(zurich.Departures) != 1 || zurich.Departures[0].DepartureTime != "08:00:00" {
+		t.Errorf("Zürich HB = %+v", zurich.Departures)
+	}
+	if basel.FavoriteID != "gone" || basel.Station.Name != "Basel SBB" || len(basel.Departures) != 0 {
+		t.Errorf("Basel = %+v, want the stored station without departures", basel)
+	}
+
+	morning, late, noon := dashboard.Trains[0], dashboard.Trains[1], dashboard.Trains[2]
+	if morning.Status != models.FavoriteTrainScheduled || morning.TripID != "ic1-0800" || morning.Position != nil ||
+		morning.NextRun == nil || morning.NextRun.Date != "2025-03-14" || morning.NextRun.Departure != "2025-03-14T08:00:00+01:00" ||
+		morning.NextRun.From == nil || morning.NextRun.From.ID != "8503000" {
+		t.Errorf("morning train = %+v", morning)
+	}
+	if late.Status != models.FavoriteTrainScheduled || late.NextRun == nil || late.NextRun.Departure != "2025-03-14T23:30:00+01:00" {
+		t.Errorf("late train = %+v", late)
+	}
+	if noon.Status != models.FavoriteTrainDiscontinued || noon.TripID != "" {
+		t.Errorf("noon train = %+v, want discontinued", noon)
+	}
+
+	commute, back := dashboard.Journeys[0], dashboard.Journeys[1]
+	if commute.Source != "gtfs" || commute.Window == nil || len(commute.Connections) != 2 ||
+		commute.Usual == nil || commute.Usual.Departure != "2025-03-14T08:00:00+01:00" || commute.AtRisk {
+		t.Errorf("commute = %+v, want the 08:00 as the usual connection", commute)
+	}
+	if back.Window != nil || len(back.Connections) != 0 || back.Usual != nil || back.AtRisk {
+		t.Errorf("way back = %+v, want no connections", back)
+	}
+
+	// On the way: the morning train is live, the commute's usual train gone
+	dashboard, reqErr = h.dashboardAt("user:alice", 5, zurichTime(8, 10))
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	morning = dashboard.Trains[0]
+	if morning.Status != models.FavoriteTrainRunning || morning.TripID != "ic1-0800" || morning.Position == nil || morning.NextRun != nil {
+		t.Fatalf("morning train = %+v, want running", morning)
+	}
+	if morning.NextStop == nil || morning.NextStop.Station.ID != "8500218" || morning.Destination == nil || morning.Destination.ID != "8507000" {
+		t.Errorf("morning train stops = %+v", morning)
+	}
+	eta, err := time.Parse(time.RFC3339, morning.ETA)
+	if err != nil || eta.Before(zurichTime(8, 56)) || eta.After(zurichTime(8, 56).Add(time.Hour)) {
+		t.Errorf("ETA = %q (%v), want 08:56 and the delay", morning.ETA, err)
+	}
+	if nextStop, err := time.Parse(time.RFC3339, morning.NextStopETA); err != nil || !nextStop.Before(eta) {
+		t.Errorf("next stop ETA = %q, want before %s", morning.NextStopETA, morning.ETA)
+	}
+	if olten := dashboard.Stations[0]; len(olten.Departures) != 2 {
+		t.Errorf("Olten = %+v, want both departures", olten.Departures)
+	}
+	commute = dashboard.Journeys[0]
+	if commute.Window == nil || len(commute.Connections) != 1 || commute.Usual != nil ||
+		commute.Connections[0].Departure != "2025-03-14T23:30:00+01:00" {
+		t.Errorf("commute = %+v, want the 23:30 outside the window", commute)
+	}
+
+	// Only the owner's favorites
+	if dashboard, _ := h.dashboardAt("user:bob", 5, zurichTime(8, 10)); len(dashboard.Stations)+len(dashboard.Trains)+len(dashboard.Journeys) != 0 {
+		t.Errorf("bob's dashboard = %+v", dashboard)
+	}
+}
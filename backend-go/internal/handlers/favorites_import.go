@@ -0,0 +1,762 @@
+// Package handlers provides HTTP handlers for the Swiss Railway API.
+// This file implements importing and exporting favorites, so users can
+// move them between devices and bring along the favorites the frontend
+// kept in localStorage.
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/store"
+)
+
+const (
+	// maxImportBytes and maxImportItems limit the size of an import.
+	maxImportBytes = 1 << 20 // 1 MB
+	maxImportItems = 1000
+
+	// exportVersion is the version of the export file format.
+	exportVersion = 1
+)
+
+// Item types of imports and exports.
+const (
+	itemStation = "station"
+	itemTrain   = "train"
+	itemJourney = "journey"
+)
+
+// localStorage keys of the frontend's favorites (see
+// frontend/src/services/favoritesStorage.ts).
+const (
+	localStationsKey = "swiss-railway-favorites-stations"
+	localTrainsKey   = "swiss-railway-favorites-trains"
+)
+
+// csvColumns are the columns of CSV exports. Imports find columns by their
+// header, so they may come in any order and only "type" is required.
+var csvColumns = []string{
+	"type", "stationId", "trainId", "name", "originId", "departureTime",
+	"destinationId", "days", "windows", "collection", "tags", "nickname",
+	"notes", "autoFollow", csvEscapedColumn,
+}
+
+// csvEscapedColumn lists the columns of a row whose value an export quoted
+// (see escapeCSVField), separated by ";". Imports only unquote those, so a
+// value that really starts with a quote keeps it.
+const csvEscapedColumn = "escaped"
+
+// importItem is an item of an import: the request creating it, or why it
+// couldn't be read.
+type importItem struct {
+	result     models.ImportResult
+	station    *models.CreateFavoriteStationRequest
+	train      *models.CreateFavoriteTrainRequest
+	journey    *models.CreateFavoriteJourneyRequest
+	collection string // name of the collection to file it in (created if missing)
+	invalid    string
+}
+
+// ============================================================================
+// IMPORT / EXPORT - Store operations (shared by REST and WebSocket RPC)
+// ============================================================================
+
+// ImportFavorites stores the items of an import as favorites of the caller
+// and reports the outcome of each. Items are validated like new favorites;
+// items already in favorites, or earlier in the import, are skipped.
+func (h *FavoritesHandler) ImportFavorites(ctx context.Context, items []importItem) (*models.ImportReport, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	if len(items) == 0 {
+		return nil, newRequestError(http.StatusBadRequest, "Validation Error", "The import contains no favorites")
+	}
+	if len(items) > maxImportItems {
+		return nil, newRequestError(http.StatusRequestEntityTooLarge, "Too Many Items",
+			"At most "+strconv.Itoa(maxImportItems)+" favorites can be imported at once")
+	}
+	// Stations would otherwise all be reported as unknown
+	if !h.gtfsService.IsDataLoaded() {
+		return nil, errDataLoading
+	}
+
+	ctx = withAuditAction(ctx, models.AuditImport)
+	report := &models.ImportReport{Total: len(items), Results: make([]models.ImportResult, 0, len(items))}
+	seen := make(map[string]int) // type and key of the items read so far -> their result
+	collections := make(map[string]string)
+	for _, item := range items {
+		unescapeItem(&item)
+		result := item.result
+		if item.collection != "" && item.invalid == "" {
+			item.invalid = h.importCollection(ctx, owner, item, collections)
+		}
+		if first, ok := seen[result.Type+"|"+result.Key]; ok && result.Key != "" && item.invalid == "" {
+			result.Status = models.ImportDuplicate
+			result.Message = "Same " + result.Type + " as " + describeItem(report.Results[first])
+		} else {
+			if result.Key != "" && item.invalid == "" {
+				seen[result.Type+"|"+result.Key] = len(report.Results)
+			}
+			h.importItem(ctx, item, &result)
+		}
+
+		switch result.Status {
+		case models.ImportCreated:
+			report.Created++
+		case models.ImportDuplicate:
+			report.Duplicates++
+		case models.ImportInvalid:
+			report.Invalid++
+		default:
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	log.Info().
+		Str("owner", owner).
+		Int("total", report.Total).
+		Int("created", report.Created).
+		Int("duplicates", report.Duplicates).
+		Int("invalid", report.Invalid).
+		Int("failed", report.Failed).
+		Msg("Imported favorites")
+
+	return report, nil
+}
+
+// importItem creates the favorite of an item and records the outcome.
+func (h *FavoritesHandler) importItem(ctx context.Context, item importItem, result *models.ImportResult) {
+	if item.invalid != "" {
+		result.Status = models.ImportInvalid
+		result.Message = item.invalid
+		return
+	}
+
+	var reqErr *models.RequestError
+	switch {
+	case item.station != nil:
+		var favorite *models.FavoriteStation
+		if favorite, reqErr = h.AddFavorite(ctx, *item.station); reqErr == nil {
+			result.FavoriteID = favorite.ID
+		}
+	case item.train != nil:
+		var favorite *models.FavoriteTrain
+		if favorite, reqErr = h.AddFavoriteTrain(ctx, *item.train); reqErr == nil {
+			result.FavoriteID = favorite.ID
+			result.Key = favorite.TrainID
+		}
+	default:
+		var favorite *models.FavoriteJourney
+		if favorite, reqErr = h.AddFavoriteJourney(ctx, *item.journey); reqErr == nil {
+			result.FavoriteID = favorite.ID
+		}
+	}
+
+	switch {
+	case reqErr == nil:
+		result.Status = models.ImportCreated
+	case reqErr.Status == http.StatusConflict:
+		result.Status = models.ImportDuplicate
+		result.Message = reqErr.Message
+	case reqErr.Status == http.StatusBadRequest:
+		result.Status = models.ImportInvalid
+		result.Message = reqErr.Message
+	default:
+		result.Status = models.ImportFailed
+		result.Message = reqErr.Message
+	}
+}
+
+// unescapeItem undoes the HTML escaping of an item's text. Exports carry
+// text as stored, escaped by sanitizeString, and creating the favorite
+// escapes it again.
+func unescapeItem(item *importItem) {
+	item.collection = html.UnescapeString(item.collection)
+	unescape := func(nickname, notes *string, tags []string) {
+		*nickname = html.UnescapeString(*nickname)
+		*notes = html.UnescapeString(*notes)
+		for i := range tags {
+			tags[i] = html.UnescapeString(tags[i])
+		}
+	}
+	switch {
+	case item.station != nil:
+		unescape(&item.station.Nickname, &item.station.Notes, item.station.Tags)
+	case item.train != nil:
+		unescape(&item.train.Nickname, &item.train.Notes, item.train.Tags)
+	case item.journey != nil:
+		unescape(&item.journey.Nickname, &item.journey.Notes, item.journey.Tags)
+	}
+}
+
+// importCollection files an item in the collection it names, creating the
+// collection unless the caller has one of the name. collections caches the
+// IDs by lowercase name. It returns why the item is invalid, or "".
+func (h *FavoritesHandler) importCollection(ctx context.Context, owner string, item importItem, collections map[string]string) string {
+	key := store.CollectionKey(models.FavoriteCollection{Name: sanitizeString(item.collection)})
+	if len(collections) == 0 {
+		existing, err := h.repo.ListCollections(owner)
+		if err != nil {
+			return storeError(err, "", "").Message
+		}
+		for _, collection := range existing {
+			collections[store.CollectionKey(collection)] = collection.ID
+		}
+	}
+
+	id, ok := collections[key]
+	if !ok {
+		collection, reqErr := h.AddCollection(ctx, models.FavoriteCollectionRequest{Name: item.collection})
+		if reqErr != nil {
+			return "collection: " + reqErr.Message
+		}
+		id = collection.ID
+		collections[key] = id
+	}
+
+	switch {
+	case item.station != nil:
+		item.station.CollectionID = id
+	case item.train != nil:
+		item.train.CollectionID = id
+	case item.journey != nil:
+		item.journey.CollectionID = id
+	}
+	return ""
+}
+
+// describeItem names an item in messages, e.g. "station 3" or "line 4".
+func describeItem(result models.ImportResult) string {
+	if result.Line > 0 {
+		return "line " + strconv.Itoa(result.Line)
+	}
+	return result.Type + " " + strconv.Itoa(result.Index)
+}
+
+// ExportFavorites returns all favorites of the caller. Alert rules aren't
+// exported: their secrets shouldn't end up in files.
+func (h *FavoritesHandler) ExportFavorites(ctx context.Context) (*models.FavoritesExport, *models.RequestError) {
+	owner, reqErr := ownerOf(ctx)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	stations, err := h.repo.ListStations(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	trains, err := h.repo.ListTrains(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	journeys, err := h.repo.ListJourneys(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+	collections, err := h.repo.ListCollections(owner)
+	if err != nil {
+		return nil, storeError(err, "", "")
+	}
+
+	// Trip IDs change between timetables; the pattern finds the train again
+	for i := range trains {
+		trains[i].Pattern = h.patternOf(trains[i])
+	}
+
+	return &models.FavoritesExport{
+		Version:     exportVersion,
+		ExportedAt:  time.Now().Format(time.RFC3339),
+		Collections: collections,
+		Stations:    stations,
+		Trains:      trains,
+		Journeys:    journeys,
+	}, nil
+}
+
+// ============================================================================
+// JSON - Export files and the frontend's localStorage
+// ============================================================================
+
+// favoritesFile is an import in JSON: an export file, or the frontend's
+// localStorage with its favorites keys.
+type favoritesFile struct {
+	Collections   []models.FavoriteCollection `json:"collections"`
+	Stations      json.RawMessage             `json:"stations"`
+	Trains        json.RawMessage             `json:"trains"`
+	Journeys      json.RawMessage             `json:"journeys"`
+	LocalStations json.RawMessage             `json:"swiss-railway-favorites-stations"`
+	LocalTrains   json.RawMessage             `json:"swiss-railway-favorites-trains"`
+}
+
+// importedStation is a favorite station of an export file or of
+// localStorage, where only the embedded station may carry the ID.
+type importedStation struct {
+	models.CreateFavoriteStationRequest
+	Station struct {
+		ID string `json:"id"`
+	} `json:"station"`
+}
+
+// parseImportJSON reads the items of a JSON import. Each list may also be
+// the string localStorage holds. Unreadable items are returned invalid, so
+// one bad item doesn't fail the import.
+func parseImportJSON(body []byte) ([]importItem, *models.RequestError) {
+	var file favoritesFile
+	if err := json.Unmarshal(body, &file); err != nil {
+		return nil, newRequestError(http.StatusBadRequest, "Bad Request",
+			"Invalid JSON format: expected an export with stations, trains and journeys, or "+
+				localStationsKey+" and "+localTrainsKey)
+	}
+
+	// Favorites name their collection by its ID in the file
+	collectionNames := make(map[string]string, len(file.Collections))
+	for _, collection := range file.Collections {
+		collectionNames[collection.ID] = collection.Name
+	}
+	fileCollection := func(id *string) string {
+		name, ok := collectionNames[*id]
+		if ok {
+			*id = ""
+		}
+		return name
+	}
+
+	var items []importItem
+	sections := []struct {
+		kind string
+		raws []json.RawMessage
+	}{
+		{itemStation, nil},
+		{itemTrain, nil},
+		{itemJourney, nil},
+	}
+	for i, lists := range [][]json.RawMessage{
+		{file.Stations, file.LocalStations},
+		{file.Trains, file.LocalTrains},
+		{file.Journeys},
+	} {
+		for _, list := range lists {
+			raws, reqErr := decodeList(list, sections[i].kind)
+			if reqErr != nil {
+				return nil, reqErr
+			}
+			sections[i].raws = append(sections[i].raws, raws...)
+		}
+	}
+
+	for _, section := range sections {
+		for index, raw := range section.raws {
+			item := importItem{result: models.ImportResult{Type: section.kind, Index: index}}
+			var err error
+			switch section.kind {
+			case itemStation:
+				var station importedStation
+				if err = json.Unmarshal(raw, &station); err == nil {
+					if station.StationID == "" {
+						station.StationID = station.Station.ID
+					}
+					item.station = &station.CreateFavoriteStationRequest
+					item.collection = fileCollection(&item.station.CollectionID)
+					item.result.Key = station.StationID
+				}
+			case itemTrain:
+				var train models.CreateFavoriteTrainRequest
+				if err = json.Unmarshal(raw, &train); err == nil {
+					item.train = &train
+					item.collection = fileCollection(&train.CollectionID)
+					if train.Pattern == nil {
+						item.result.Key = train.TrainID
+					}
+				}
+			default:
+				var journey models.CreateFavoriteJourneyRequest
+				if err = json.Unmarshal(raw, &journey); err == nil {
+					item.journey = &journey
+					item.collection = fileCollection(&journey.CollectionID)
+					item.result.Key = store.JourneyKey(models.FavoriteJourney{
+						OriginID: journey.OriginID, DestinationID: journey.DestinationID,
+					})
+				}
+			}
+			if err != nil {
+				item.invalid = "Invalid " + section.kind + ": " + err.Error()
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// decodeList splits a JSON list of favorites into its items. A string is
+// decoded once more, as localStorage stores JSON strings.
+func decodeList(list json.RawMessage, kind string) ([]json.RawMessage, *models.RequestError) {
+	list = bytes.TrimSpace(list)
+	if len(list) == 0 || string(list) == "null" {
+		return nil, nil
+	}
+	if list[0] == '"' {
+		var inner string
+		if err := json.Unmarshal(list, &inner); err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "Bad Request", "Invalid "+kind+" list: "+err.Error())
+		}
+		list = json.RawMessage(inner)
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(list, &raws); err != nil {
+		return nil, newRequestError(http.StatusBadRequest, "Bad Request", "The "+kind+" list must be a JSON array")
+	}
+	return raws, nil
+}
+
+// ============================================================================
+// CSV - One favorite per row
+// ============================================================================
+
+// parseImportCSV reads the items of a CSV import. Trains are given by a
+// pattern (name, originId, departureTime and days) or a trip (trainId).
+// Windows of journeys and tags are separated by ";", e.g. "07:00-08:00 mon
+// tue;17:00-18:00"; collections are given by name.
+func parseImportCSV(body []byte) ([]importItem, *models.RequestError) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, newRequestError(http.StatusBadRequest, "Bad Request", "Invalid CSV: missing header row")
+	}
+	// Spreadsheets may start the file with a byte order mark
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	if _, ok := columns["type"]; !ok {
+		return nil, newRequestError(http.StatusBadRequest, "Bad Request", "Invalid CSV: the header has no type column")
+	}
+
+	var items []importItem
+	for index := 0; ; index++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "Bad Request", "Invalid CSV: "+err.Error())
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		escaped := make(map[string]bool)
+		for _, name := range strings.Split(value(csvEscapedColumn), ";") {
+			escaped[strings.TrimSpace(name)] = true
+		}
+		field := func(name string) string {
+			if escaped[name] {
+				return unescapeCSVField(value(name))
+			}
+			return value(name)
+		}
+		items = append(items, csvItem(field, index, line))
+	}
+	return items, nil
+}
+
+// csvItem reads the item of a CSV row.
+func csvItem(field func(string) string, index, line int) importItem {
+	kind := strings.ToLower(field("type"))
+	item := importItem{
+		result:     models.ImportResult{Type: kind, Index: index, Line: line},
+		collection: field("collection"),
+	}
+	var tags []string
+	if value := field("tags"); value != "" {
+		tags = strings.Split(value, ";")
+	}
+	switch kind {
+	case itemStation:
+		item.station = &models.CreateFavoriteStationRequest{
+			StationID: field("stationId"),
+			Nickname:  field("nickname"),
+			Notes:     field("notes"),
+			Tags:      tags,
+		}
+		item.result.Key = item.station.StationID
+
+	case itemTrain:
+		train := &models.CreateFavoriteTrainRequest{
+			TrainID:  field("trainId"),
+			Nickname: field("nickname"),
+			Notes:    field("notes"),
+			Tags:     tags,
+		}
+		var days []string
+		if value := field("days"); value != "" {
+			days = strings.Fields(strings.ReplaceAll(value, ",", " "))
+		}
+		if name := field("name"); name != "" {
+			train.Pattern = &models.ServicePattern{
+				Name:          name,
+				OriginID:      field("originId"),
+				DepartureTime: field("departureTime"),
+				Days:          days,
+			}
+		} else {
+			train.Days = days
+			item.result.Key = train.TrainID
+		}
+		if value := field("autoFollow"); value != "" {
+			autoFollow, err := strconv.ParseBool(value)
+			if err != nil {
+				item.invalid = "autoFollow must be true or false"
+			}
+			train.AutoFollow = autoFollow
+		}
+		item.train = train
+
+	case itemJourney:
+		windows, err := parseWindows(field("windows"))
+		if err != nil {
+			item.invalid = "windows: " + err.Error()
+		}
+		item.journey = &models.CreateFavoriteJourneyRequest{
+			OriginID:      field("originId"),
+			DestinationID: field("destinationId"),
+			Windows:       windows,
+			Nickname:      field("nickname"),
+			Notes:         field("notes"),
+			Tags:          tags,
+		}
+		item.result.Key = store.JourneyKey(models.FavoriteJourney{
+			OriginID: item.journey.OriginID, DestinationID: item.journey.DestinationID,
+		})
+
+	default:
+		item.invalid = "type must be station, train or journey"
+	}
+	return item
+}
+
+// parseWindows reads windows written by formatWindows, e.g.
+// "07:00-08:00 mon tue;17:00-18:00". Times and days are validated later.
+func parseWindows(value string) ([]models.TimeWindow, error) {
+	var windows []models.TimeWindow
+	for _, part := range strings.Split(value, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		start, end, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			return nil, errors.New(`expected "HH:MM-HH:MM [days]", got "` + strings.TrimSpace(part) + `"`)
+		}
+		windows = append(windows, models.TimeWindow{Start: start, End: end, Days: fields[1:]})
+	}
+	return windows, nil
+}
+
+// formatWindows writes windows for the windows column of CSV exports.
+func formatWindows(windows []models.TimeWindow) string {
+	parts := make([]string, 0, len(windows))
+	for _, window := range windows {
+		parts = append(parts, strings.Join(append([]string{window.Start + "-" + window.End}, window.Days...), " "))
+	}
+	return strings.Join(parts, ";")
+}
+
+// writeExportCSV writes an export as CSV, one favorite per row.
+func writeExportCSV(w io.Writer, export *models.FavoritesExport) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	collections := make(map[string]string, len(export.Collections))
+	for _, collection := range export.Collections {
+		collections[collection.ID] = collection.Name
+	}
+	row := func(values map[string]string, collectionID string, tags []string) error {
+		values["collection"] = collections[collectionID]
+		values["tags"] = strings.Join(tags, ";")
+		record := make([]string, len(csvColumns))
+		var escaped []string
+		for i, column := range csvColumns {
+			var quoted bool
+			if record[i], quoted = escapeCSVField(values[column]); quoted {
+				escaped = append(escaped, column)
+			}
+		}
+		record[len(record)-1] = strings.Join(escaped, ";")
+		return writer.Write(record)
+	}
+	for _, favorite := range export.Stations {
+		if err := row(map[string]string{
+			"type":      itemStation,
+			"stationId": favorite.StationID,
+			"nickname":  favorite.Nickname,
+			"notes":     favorite.Notes,
+		}, favorite.CollectionID, favorite.Tags); err != nil {
+			return err
+		}
+	}
+	for _, favorite := range export.Trains {
+		values := map[string]string{
+			"type":       itemTrain,
+			"trainId":    favorite.TrainID,
+			"nickname":   favorite.Nickname,
+			"notes":      favorite.Notes,
+			"autoFollow": strconv.FormatBool(favorite.AutoFollow),
+		}
+		if pattern := favorite.Pattern; pattern != nil {
+			values["name"] = pattern.Name
+			values["originId"] = pattern.OriginID
+			values["departureTime"] = pattern.DepartureTime
+			values["days"] = strings.Join(pattern.Days, " ")
+		}
+		if err := row(values, favorite.CollectionID, favorite.Tags); err != nil {
+			return err
+		}
+	}
+	for _, favorite := range export.Journeys {
+		if err := row(map[string]string{
+			"type":          itemJourney,
+			"originId":      favorite.OriginID,
+			"destinationId": favorite.DestinationID,
+			"windows":       formatWindows(favorite.Windows),
+			"nickname":      favorite.Nickname,
+			"notes":         favorite.Notes,
+		}, favorite.CollectionID, favorite.Tags); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeCSVField keeps spreadsheets from running a field as a formula
+// (CSV injection) by quoting a leading =, +, - or @, and reports whether
+// it did.
+func escapeCSVField(value string) (string, bool) {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value, true
+	}
+	return value, false
+}
+
+// unescapeCSVField undoes escapeCSVField for a column the row lists as
+// escaped.
+func unescapeCSVField(value string) string {
+	return strings.TrimPrefix(value, "'")
+}
+
+// ============================================================================
+// POST /api/favorites/import - Import favorites in bulk
+// ============================================================================
+
+// PostImport imports favorites from an export file, the frontend's
+// localStorage (application/json) or a CSV file (text/csv) and returns a
+// report on each item.
+func (h *FavoritesHandler) PostImport(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	isCSV := strings.HasPrefix(contentType, "text/csv")
+	if !isCSV && !validateContentType(r) {
+		sendError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type",
+			"Content-Type must be application/json or text/csv")
+		return
+	}
+
+	// SECURITY: Limit request body size to prevent DoS
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendError(w, http.StatusRequestEntityTooLarge, "Request Too Large",
+			"Imports are limited to "+strconv.Itoa(maxImportBytes>>10)+" KB")
+		return
+	}
+
+	var items []importItem
+	var reqErr *models.RequestError
+	if isCSV {
+		items, reqErr = parseImportCSV(body)
+	} else {
+		items, reqErr = parseImportJSON(body)
+	}
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	report, reqErr := h.ImportFavorites(r.Context(), items)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: report,
+		Meta: &models.APIMeta{
+			Total:     report.Total,
+			Count:     report.Created,
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "favorites_import",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// ============================================================================
+// GET /api/favorites/export - Download all favorites
+// ============================================================================
+
+// GetExport downloads the caller's favorites as a JSON file, or as CSV with
+// ?format=csv. Both can be imported again.
+func (h *FavoritesHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		sendError(w, http.StatusBadRequest, "Invalid Parameter", "format must be json or csv")
+		return
+	}
+
+	export, reqErr := h.ExportFavorites(r.Context())
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	filename := "swiss-railway-favorites-" + time.Now().Format("2006-01-02") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		if err := writeExportCSV(w, export); err != nil {
+			log.Error().Err(err).Msg("Failed to write favorites export")
+		}
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(export)
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// importStatuses returns the status of each item of a report.
+func importStatuses(report *models.ImportReport) []string {
+	statuses := make([]string, 0, len(report.Results))
+	for _, result := range report.Results {
+		statuses = append(statuses, result.Status)
+	}
+	return statuses
+}
+
+func TestImportLocalStorage(t *testing.T) {
+	h := newTestFavorites(t)
+	ctx := asUser("alice")
+
+	// The frontend's localStorage: stations as the JSON string it holds,
+	// one with the ID only in the embedded station
+	stations, _ := json.Marshal(`[
+		{"id": "1710000000000-abc", "stationId": "8503000", "station": {"id": "8503000", "name": "Zürich HB"}, "nickname": "home"},
+		{"id": "1710000000001-def", "station": {"id": "8500218", "name": "Olten"}},
+		{"id": "1710000000002-ghi", "stationId": "9999999", "station": {"id": "9999999"}},
+		{"id": "1710000000003-jkl", "stationId": "8503000", "station": {"id": "8503000"}}
+	]`)
+	body := `{
+		"swiss-railway-favorites-stations": ` + string(stations) + `,
+		"swiss-railway-favorites-trains": [
+			{"id": "1710000000004-mno", "trainId": "ic1-0800", "train": {"id": "ic1-0800"}, "autoFollow": true},
+			{"id": "1710000000005-pqr", "trainId": 5}
+		]
+	}`
+	items, reqErr := parseImportJSON([]byte(body))
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	report, reqErr := h.ImportFavorites(ctx, items)
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+
+	want := []string{models.ImportCreated, models.ImportCreated, models.ImportInvalid, models.ImportDuplicate, models.ImportCreated, models.ImportInvalid}
+	if got := importStatuses(report); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statuses = %v, want %v (%+v)", got, want, report.Results)
+	}
+	if report.Total != 6 || report.Created != 3 || report.Duplicates != 1 || report.Invalid != 2 || report.Failed != 0 {
+		t.Errorf("totals = %+v", report)
+	}
+	for i, want := range []models.ImportResult{
+		{Type: itemStation, Index: 1, Key: "8500218"},
+		{Type: itemStation, Index: 2, Key: "9999999"},
+		{Type: itemStation, Index: 3, Key: "8503000"},
+		{Type: itemTrain, Index: 0, Key: "ic1-0800"},
+		{Type: itemTrain, Index: 1},
+	} {
+		got := report.Results[i+1]
+		if got.Type != want.Type || got.Index != want.Index || got.Key != want.Key {
+			t.Errorf("result %d = %+v, want %+v", i+1, got, want)
+		}
+	}
+	if msg := report.Results[3].Message; msg != "Same station as station 0" {
+		t.Errorf("duplicate message = %q", msg)
+	}
+	if msg := report.Results[2].Message; !strings.Contains(msg, "9999999") {
+		t.Errorf("unknown station message = %q", msg)
+	}
+
+	favorite, reqErr := h.FindFavorite(ctx, report.Results[0].FavoriteID)
+	if reqErr != nil || favorite.StationID != "8503000" || favorite.Nickname != "home" {
+		t.Errorf("imported station = %+v (%v)", favorite, reqErr)
+	}
+	train, reqErr := h.FindFavoriteTrain(ctx, report.Results[4].FavoriteID)
+	if reqErr != nil || !train.AutoFollow || train.Pattern == nil || train.Pattern.DepartureTime != "08:00" {
+		t.Errorf("imported train = %+v (%v)", train, reqErr)
+	}
+
+	// Importing again changes nothing
+	items, _ = parseImportJSON([]byte(body))
+	report, reqErr = h.ImportFavorites(ctx, items)
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	if report.Created != 0 || report.Duplicates != 4 || report.Invalid != 2 {
+		t.Errorf("second import = %+v", report)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	h := newTestFavorites(t)
+	ctx := asUser("alice")
+
+	// Columns in any order, with a byte order mark
+	body := "\ufeffnotes,type,stationId,collection,tags,name,originId,departureTime,days,destinationId,windows,autoFollow\n" +
+		"Office,station,8507000,Work,Commute;Daily,,,,,,,\n" +
+		",train,,Work,,IC 1,8503000,08:00,mon fri,,,yes\n" +
+		",train,,work,,IC 1,8503000,23:30,mon fri,,,true\n" +
+		",journey,,,,,8503000,,,8507000,07:00-08:00 mon tue;23:00-01:00,\n" +
+		",journey,,,,,8503000,,,8507000,,\n" +
+		",journey,,,,,8507000,,,8503000,07:00,\n" +
+		",bus,8503000,,,,,,,,,\n"
+	items, reqErr := parseImportCSV([]byte(body))
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	report, reqErr := h.ImportFavorites(ctx, items)
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+
+	want := []string{models.ImportCreated, models.ImportInvalid, models.ImportCreated, models.ImportCreated,
+		models.ImportDuplicate, models.ImportInvalid, models.ImportInvalid}
+	if got := importStatuses(report); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statuses = %v, want %v (%+v)", got, want, report.Results)
+	}
+	for i, result := range report.Results {
+		if result.Line != i+2 || result.Index != i {
+			t.Errorf("result %d: line %d, index %d", i, result.Line, result.Index)
+		}
+	}
+	if msg := report.Results[1].Message; msg != "autoFollow must be true or false" {
+		t.Errorf("autoFollow message = %q", msg)
+	}
+	if msg := report.Results[4].Message; msg != "Same journey as line 5" {
+		t.Errorf("duplicate message = %q", msg)
+	}
+	if msg := report.Results[5].Message; !strings.HasPrefix(msg, "windows: ") {
+		t.Errorf("windows message = %q", msg)
+	}
+
+	// One collection, matched by name
+	collections, _ := h.repo.ListCollections("user:alice")
+	if len(collections) != 1 || collections[0].Name != "Work" {
+		t.Fatalf("collections = %+v", collections)
+	}
+	station, _ := h.FindFavorite(ctx, report.Results[0].FavoriteID)
+	if station.CollectionID != collections[0].ID || station.Notes != "Office" || !reflect.DeepEqual(station.Tags, []string{"commute", "daily"}) {
+		t.Errorf("station = %+v", station)
+	}
+	train, _ := h.FindFavoriteTrain(ctx, report.Results[2].FavoriteID)
+	if train.CollectionID != collections[0].ID || !train.AutoFollow || train.TrainID != "ic1-2330" ||
+		!reflect.DeepEqual(train.Pattern.Days, []string{"mon", "fri"}) {
+		t.Errorf("train = %+v", train)
+	}
+	journey, _ := h.FindFavoriteJourney(ctx, report.Results[3].FavoriteID)
+	if len(journey.Windows) != 2 || journey.Windows[1].End != "01:00" || len(journey.Windows[1].Days) != 7 {
+		t.Errorf("journey windows = %+v", journey.Windows)
+	}
+
+	for name, body := range map[string]string{
+		"empty":          "",
+		"no type column": "stationId\n8503000\n",
+		"bad quoting":    "type,notes\nstation,\"open\n",
+	} {
+		if _, reqErr := parseImportCSV([]byte(body)); reqErr == nil || reqErr.Status != http.StatusBadRequest {
+			t.Errorf("%s: %+v", name, reqErr)
+		}
+	}
+}
+
+func TestExportCSVRoundTrip(t *testing.T) {
+	h := newTestFavorites(t)
+	alice, bob := asUser("alice"), asUser("bob")
+
+	if _, reqErr := h.AddFavorite(alice, models.CreateFavoriteRequest{StationID: "8503000", Nickname: "=SUM(A1)", Notes: "'=not a formula", Tags: []string{"-x"}}); reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	if _, reqErr := h.AddFavorite(alice, models.CreateFavoriteRequest{StationID: "8507000", Nickname: "Tom & Jerry's", Notes: "@home, +41 -"}); reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	if _, reqErr := h.AddFavoriteJourney(alice, models.CreateFavoriteJourneyRequest{
+		OriginID: "8503000", DestinationID: "8507000", Notes: "-late",
+		Windows: []models.TimeWindow{{Start: "23:00", End: "01:00", Days: []string{"fri"}}},
+	}); reqErr != nil {
+		t.Fatal(reqErr)
+	}
+
+	export, reqErr := h.ExportFavorites(alice)
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	var csvFile bytes.Buffer
+	if err := writeExportCSV(&csvFile, export); err != nil {
+		t.Fatal(err)
+	}
+	// Formulas are quoted, and the quoted columns listed
+	for _, want := range []string{",'-x,'=SUM(A1),&#39;=not a formula,,tags;nickname\n", ",\"'@home, +41 -\",,notes\n", ",'-late,,notes\n"} {
+		if !strings.Contains(csvFile.String(), want) {
+			t.Errorf("export lacks %q:\n%s", want, csvFile.String())
+		}
+	}
+
+	items, reqErr := parseImportCSV(csvFile.Bytes())
+	if reqErr != nil {
+		t.Fatal(reqErr)
+	}
+	report, reqErr := h.ImportFavorites(bob, items)
+	if reqErr != nil || report.Created != 3 {
+		t.Fatalf("import = %+v (%v)", report, reqErr)
+	}
+
+	// Bob's favorites read like Alice's
+	aliceStations, _ := h.repo.ListStations("user:alice")
+	bobStations, _ := h.repo.ListStations("user:bob")
+	for i := range aliceStations {
+		a, b := aliceStations[i], bobStations[i]
+		if a.Nickname != b.Nickname || a.Notes != b.Notes || !reflect.DeepEqual(a.Tags, b.Tags) {
+			t.Errorf("station %d: %q %q %v, want %q %q %v", i, b.Nickname, b.Notes, b.Tags, a.Nickname, a.Notes, a.Tags)
+		}
+	}
+	journeys, _ := h.repo.ListJourneys("user:bob")
+	if len(journeys) != 1 || journeys[0].Notes != "-late" || !reflect.DeepEqual(journeys[0].Windows, export.Journeys[0].Windows) {
+		t.Errorf("journeys = %+v", journeys)
+	}
+
+	// Only the columns listed as escaped lose their quote
+	items, _ = parseImportCSV([]byte("type,stationId,nickname,notes,escaped\n" +
+		"station,8503000,'=SUM(A1),'=as typed,nickname\n" +
+		"station,8507000,'=SUM(A1),'=as typed,\n"))
+	for i, want := range [][2]string{{"=SUM(A1)", "'=as typed"}, {"'=SUM(A1)", "'=as typed"}} {
+		if station := items[i].station; station.Nickname != want[0] || station.Notes != want[1] {
+			t.Errorf("row %d: %q %q, want %q", i, station.Nickname, station.Notes, want)
+		}
+	}
+}
+
+func TestImportLimits(t *testing.T) {
+	h := newTestFavorites(t)
+	ctx := asUser("alice")
+
+	items := make([]importItem, maxImportItems+1)
+	for i := range items {
+		items[i] = importItem{result: models.ImportResult{Type: itemStation, Index: i}, station: &models.CreateFavoriteStationRequest{StationID: "8503000"}}
+	}
+	if _, reqErr := h.ImportFavorites(ctx, items); reqErr == nil || reqErr.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("%d items: %+v", len(items), reqErr)
+	}
+	if report, reqErr := h.ImportFavorites(ctx, items[:maxImportItems]); reqErr != nil || report.Created != 1 || report.Duplicates != maxImportItems-1 {
+		t.Errorf("%d items: %+v", maxImportItems, reqErr)
+	}
+	if _, reqErr := h.ImportFavorites(ctx, nil); reqErr == nil || reqErr.Status != http.StatusBadRequest {
+		t.Errorf("no items: %+v", reqErr)
+	}
+
+	for name, body := range map[string]string{
+		"not an object":      `[1, 2]`,
+		"stations an object": `{"stations": {"stationId": "8503000"}}`,
+		"bad string list":    `{"swiss-railway-favorites-trains": "[{"}`,
+	} {
+		if _, reqErr := parseImportJSON([]byte(body)); reqErr == nil || reqErr.Status != http.StatusBadRequest {
+			t.Errorf("%s: %+v", name, reqErr)
+		}
+	}
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/favorites/import", strings.NewReader(body)).WithContext(asUser("bob"))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.PostImport(rec, req)
+		return rec
+	}
+
+	// Bodies are limited in bytes, whatever their format
+	big := "type,stationId,notes\n" + strings.Repeat("station,8503000,"+strings.Repeat("x", 1000)+"\n", maxImportBytes/1000)
+	if rec := post("text/csv", big); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("%d bytes: %d %s", len(big), rec.Code, rec.Body)
+	}
+	if rec := post("text/plain", "type\n"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain: %d", rec.Code)
+	}
+
+	rec := post("text/csv; charset=utf-8", "type,stationId\nstation,8503000\nstation,0000000\n")
+	var response struct {
+		Data models.ImportReport `json:"data"`
+		Meta models.APIMeta      `json:"meta"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("import: %d %s", rec.Code, rec.Body)
+	}
+	if response.Data.Created != 1 || response.Data.Invalid != 1 || response.Meta.Total != 2 || response.Meta.Count != 1 {
+		t.Errorf("response = %+v", response)
+	}
+}
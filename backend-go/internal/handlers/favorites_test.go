@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/swiss-railway/backend-go/internal/auth"
+	"github.com/swiss-railway/backend-go/internal/services"
+	"github.com/swiss-railway/backend-go/internal/store"
+)
+
+// testGTFS loads a timetable of Zürich HB, Olten and Bern with the daily
+// IC 1 leaving Zürich HB at 08:00 (ic1-0800) and at 23:30 (ic1-2330, in
+// Bern after midnight).
+func testGTFS(t *testing.T) *services.GTFSService {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"agency.txt": "agency_id,agency_name,agency_url,agency_timezone\nSBB,SBB,https://www.sbb.ch,Europe/Zurich\n",
+		"stops.txt": "stop_id,stop_name,stop_lat,stop_lon\n" +
+			"8503000,Zürich HB,47.378177,8.540192\n8500218,Olten,47.351935,7.907699\n8507000,Bern,46.948825,7.439122\n",
+		"routes.txt":         "route_id,agency_id,route_short_name,route_long_name,route_type\n1,SBB,IC 1,IC 1,2\n",
+		"calendar.txt":       "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\ndaily,1,1,1,1,1,1,1,20240101,20301231\n",
+		"calendar_dates.txt": "service_id,date,exception_type\n",
+		"trips.txt":          "route_id,service_id,trip_id,trip_short_name,direction_id\n1,daily,ic1-0800,IC 1,0\n1,daily,ic1-2330,IC 1,0\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"ic1-0800,,08:00:00,8503000,1\nic1-0800,08:28:00,08:30:00,8500218,2\nic1-0800,08:56:00,,8507000,3\n" +
+			"ic1-2330,,23:30:00,8503000,1\nic1-2330,23:58:00,24:00:00,8500218,2\nic1-2330,24:26:00,,8507000,3\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := services.NewGTFSService(dir)
+	if err := s.LoadData(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestFavorites returns a favorites handler on the test timetable with
+// an in-memory store.
+func newTestFavorites(t *testing.T) *FavoritesHandler {
+	t.Helper()
+	return NewFavoritesHandler(testGTFS(t), nil, store.NewMemoryFavorites(), false)
+}
+
+// asUser returns a context of a signed-in user.
+func asUser(subject string) context.Context {
+	return auth.NewContext(context.Background(), auth.Identity{Subject: subject})
+}
+
+// newRequest returns a request of a signed-in user with a JSON body (none
+// if empty) and the route's variables.
+func newRequest(method, target, body, subject string, vars map[string]string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(asUser(subject))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return mux.SetURLVars(r, vars)
+}
+
+// serve records the response of a handler.
+func serve(handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
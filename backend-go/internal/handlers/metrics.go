@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// MetricsHandler exposes runtime metrics of registered components.
+type MetricsHandler struct {
+	mu        sync.RWMutex
+	providers map[string]func() interface{}
+}
+
+// NewMetricsHandler creates a new metrics handler.
+func NewMetricsHandler() *MetricsHandler {
+	return &MetricsHandler{
+		providers: make(map[string]func() interface{}),
+	}
+}
+
+// Register adds a named metrics source. The function must not block.
+func (h *MetricsHandler) Register(name string, provider func() interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.providers[name] = provider
+}
+
+// Metrics returns the metrics of all registered components.
+func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	h.mu.RUnlock()
+	sort.Strings(names)
+
+	data := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		h.mu.RLock()
+		provider := h.providers[name]
+		h.mu.RUnlock()
+		data[name] = provider()
+	}
+
+	response := models.APIResponse{
+		Data: data,
+		Meta: &models.APIMeta{
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "runtime",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
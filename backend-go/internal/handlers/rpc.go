@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+// RPCMethod is a WebSocket RPC method: it takes the connection's context
+// (carrying the caller's identity, as on REST requests) and the request's
+// params and returns the result (the "data" of the matching REST response).
+type RPCMethod = func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// RPCMethods returns the WebSocket RPC methods backed by the REST handlers.
+// Errors are *models.RequestError with the status the REST endpoint would send.
+func RPCMethods(stations *StationsHandler, trains *TrainsHandler, favorites *FavoritesHandler) map[string]RPCMethod {
+	type idParams struct {
+		ID      string `json:"id"`
+		Version int    `json:"version"` // edits of favorites: the version expected, like If-Match
+	}
+
+	return map[string]RPCMethod{
+		// Stations and trips
+		"stations.search": func(_ context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				Query string `json:"query"`
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			results, _, reqErr := stations.Search(p.Query)
+			return rpcResult(results, reqErr)
+		},
+		"stations.departures": func(_ context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				StationID string `json:"stationId"`
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			data, _, reqErr := stations.Departures(p.StationID)
+			return rpcResult(data, reqErr)
+		},
+		"trips.get": func(_ context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				TripID string `json:"tripId"`
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(trains.TripDetails(p.TripID))
+		},
+
+		// Favorite stations
+		"favorites.stations.list": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var filter favoriteFilter
+			if reqErr := decodeParams(params, &filter); reqErr != nil {
+				return nil, reqErr
+			}
+			list, _, reqErr := favorites.ListFavorites(ctx, filter)
+			return rpcResult(list, reqErr)
+		},
+		"favorites.stations.get": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.FindFavorite(ctx, p.ID))
+		},
+		"favorites.stations.create": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.CreateFavoriteRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.AddFavorite(ctx, p))
+		},
+		"favorites.stations.update": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				idParams
+				models.UpdateFavoriteRequest
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.EditFavorite(withVersion(ctx, p.Version), p.ID, p.UpdateFavoriteRequest))
+		},
+		"favorites.stations.patch": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				idParams
+				models.PatchFavoriteStationRequest
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.AmendFavorite(withVersion(ctx, p.Version), p.ID, p.PatchFavoriteStationRequest))
+		},
+		"favorites.stations.reorder": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.ReorderRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.ReorderFavorites(ctx, p))
+		},
+		"favorites.stations.delete": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			if _, reqErr := favorites.RemoveFavorite(withVersion(ctx, p.Version), p.ID); reqErr != nil {
+				return nil, reqErr
+			}
+			return deletedResult(p.ID), nil
+		},
+
+		// Favorite trains
+		"favorites.trains.list": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var filter favoriteFilter
+			if reqErr := decodeParams(params, &filter); reqErr != nil {
+				return nil, reqErr
+			}
+			list, _, reqErr := favorites.ListFavoriteTrains(ctx, filter)
+			return rpcResult(list, reqErr)
+		},
+		"favorites.trains.get": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.FindFavoriteTrain(ctx, p.ID))
+		},
+		"favorites.trains.create": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.CreateFavoriteTrainRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.AddFavoriteTrain(ctx, p))
+		},
+		"favorites.trains.update": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				idParams
+				models.UpdateFavoriteTrainRequest
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.EditFavoriteTrain(withVersion(ctx, p.Version), p.ID, p.UpdateFavoriteTrainRequest))
+		},
+		"favorites.trains.patch": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				idParams
+				models.PatchFavoriteTrainRequest
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.AmendFavoriteTrain(withVersion(ctx, p.Version), p.ID, p.PatchFavoriteTrainRequest))
+		},
+		"favorites.trains.reorder": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.ReorderRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.ReorderFavoriteTrains(ctx, p))
+		},
+		"favorites.trains.delete": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			if _, reqErr := favorites.RemoveFavoriteTrain(withVersion(ctx, p.Version), p.ID); reqErr != nil {
+				return nil, reqErr
+			}
+			return deletedResult(p.ID), nil
+		},
+
+		// Favorite journeys
+		"favorites.journeys.list": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var filter favoriteFilter
+			if reqErr := decodeParams(params, &filter); reqErr != nil {
+				return nil, reqErr
+			}
+			list, _, reqErr := favorites.ListFavoriteJourneys(ctx, filter)
+			return rpcResult(list, reqErr)
+		},
+		"favorites.journeys.get": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.FindFavoriteJourney(ctx, p.ID))
+		},
+		"favorites.journeys.create": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.CreateFavoriteJourneyRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.AddFavoriteJourney(ctx, p))
+		},
+		"favorites.journeys.update": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				idParams
+				models.UpdateFavoriteJourneyRequest
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.EditFavoriteJourney(withVersion(ctx, p.Version), p.ID, p.UpdateFavoriteJourneyRequest))
+		},
+		"favorites.journeys.patch": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				idParams
+				models.PatchFavoriteJourneyRequest
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.AmendFavoriteJourney(withVersion(ctx, p.Version), p.ID, p.PatchFavoriteJourneyRequest))
+		},
+		"favorites.journeys.reorder": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.ReorderRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.ReorderFavoriteJourneys(ctx, p))
+		},
+		"favorites.journeys.delete": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			if _, reqErr := favorites.RemoveFavoriteJourney(withVersion(ctx, p.Version), p.ID); reqErr != nil {
+				return nil, reqErr
+			}
+			return deletedResult(p.ID), nil
+		},
+		"favorites.journeys.next": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			p := struct {
+				idParams
+				Source string `json:"source"`
+				Limit  int    `json:"limit"`
+			}{Limit: defaultJourneyConnections}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			if p.Limit < 1 || p.Limit > maxJourneyConnections {
+				return nil, newRequestError(http.StatusBadRequest, "Invalid Parameter",
+					"limit must be between 1 and "+strconv.Itoa(maxJourneyConnections))
+			}
+			return rpcResult(favorites.NextConnections(ctx, p.ID, p.Source, p.Limit))
+		},
+
+		// Collections
+		"favorites.collections.list": func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+			return rpcResult(favorites.ListCollections(ctx))
+		},
+		"favorites.collections.get": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.FindCollection(ctx, p.ID))
+		},
+		"favorites.collections.create": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.FavoriteCollectionRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.AddCollection(ctx, p))
+		},
+		"favorites.collections.update": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				idParams
+				models.FavoriteCollectionRequest
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.RenameCollection(ctx, p.ID, p.FavoriteCollectionRequest))
+		},
+		"favorites.collections.delete": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			if _, reqErr := favorites.RemoveCollection(ctx, p.ID); reqErr != nil {
+				return nil, reqErr
+			}
+			return deletedResult(p.ID), nil
+		},
+		"favorites.collections.reorder": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.ReorderRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.ReorderCollections(ctx, p))
+		},
+
+		// Alert rules
+		"favorites.alerts.list": func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+			return rpcResult(favorites.ListAlertRules(ctx))
+		},
+		"favorites.alerts.get": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.FindAlertRule(ctx, p.ID))
+		},
+		"favorites.alerts.create": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p models.CreateAlertRuleRequest
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.AddAlertRule(ctx, p))
+		},
+		"favorites.alerts.update": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				idParams
+				models.UpdateAlertRuleRequest
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.EditAlertRule(ctx, p.ID, p.UpdateAlertRuleRequest))
+		},
+		"favorites.alerts.delete": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			if _, reqErr := favorites.RemoveAlertRule(ctx, p.ID); reqErr != nil {
+				return nil, reqErr
+			}
+			return deletedResult(p.ID), nil
+		},
+		"favorites.alerts.test": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p idParams
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.TestAlertRule(ctx, p.ID))
+		},
+		"favorites.alerts.deliveries": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			p := struct {
+				RuleID string `json:"ruleId"`
+				Status string `json:"status"`
+				Limit  int    `json:"limit"`
+			}{Limit: defaultDeliveries}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			if p.Limit < 1 || p.Limit > maxDeliveries {
+				return nil, newRequestError(http.StatusBadRequest, "Invalid Parameter",
+					"limit must be between 1 and "+strconv.Itoa(maxDeliveries))
+			}
+			deliveries, _, reqErr := favorites.ListDeliveries(ctx, p.RuleID, p.Status, p.Limit)
+			return rpcResult(deliveries, reqErr)
+		},
+
+		// Audit log
+		"favorites.history": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			var p struct {
+				ID    string `json:"id"`
+				From  string `json:"from"`
+				To    string `json:"to"`
+				Limit int    `json:"limit"`
+			}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			values := url.Values{"from": {p.From}, "to": {p.To}}
+			if p.Limit != 0 {
+				values.Set("limit", strconv.Itoa(p.Limit))
+			}
+			query, reqErr := auditQuery(values)
+			if reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.FavoriteHistory(ctx, p.ID, query))
+		},
+
+		// Import and export
+		"favorites.import": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			items, reqErr := parseImportJSON(params)
+			if reqErr != nil {
+				return nil, reqErr
+			}
+			return rpcResult(favorites.ImportFavorites(ctx, items))
+		},
+		"favorites.export": func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+			return rpcResult(favorites.ExportFavorites(ctx))
+		},
+
+		// Dashboard
+		"favorites.dashboard": func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			p := struct {
+				Departures int `json:"departures"`
+			}{Departures: defaultDashboardDepartures}
+			if reqErr := decodeParams(params, &p); reqErr != nil {
+				return nil, reqErr
+			}
+			if p.Departures < 1 || p.Departures > services.MaxStationDepartures {
+				return nil, newRequestError(http.StatusBadRequest, "Invalid Parameter",
+					"departures must be between 1 and "+strconv.Itoa(services.MaxStationDepartures))
+			}
+			return rpcResult(favorites.Dashboard(ctx, p.Departures))
+		},
+	}
+}
+
+// decodeParams decodes RPC params; missing params leave v unchanged.
+func decodeParams(params json.RawMessage, v interface{}) *models.RequestError {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return newRequestError(http.StatusBadRequest, "Bad Request", "Invalid params: "+err.Error())
+	}
+	return nil
+}
+
+// rpcResult converts a handler result to an RPC result. A nil
+// *models.RequestError must not become a non-nil error.
+func rpcResult(result interface{}, reqErr *models.RequestError) (interface{}, error) {
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/swiss-railway/backend-go/internal/middleware"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/store"
+)
+
+func TestRPCMethods(t *testing.T) {
+	favorites := newTestFavorites(t)
+	methods := RPCMethods(
+		NewStationsHandler(favorites.gtfsService, nil, nil, false),
+		NewTrainsHandler(favorites.gtfsService, nil, nil, false),
+		favorites,
+	)
+	ctx := middleware.WithClientRequestID(middleware.WithRequestID(asUser("alice"), "ws:s1/c1"), "call-7")
+
+	call := func(ctx context.Context, method, params string) (interface{}, error) {
+		t.Helper()
+		fn, ok := methods[method]
+		if !ok {
+			t.Fatalf("no method %s", method)
+		}
+		var raw json.RawMessage
+		if params != "" {
+			raw = json.RawMessage(params)
+		}
+		return fn(ctx, raw)
+	}
+
+	// Successful calls return a nil error, not a nil *models.RequestError
+	result, err := call(ctx, "favorites.stations.create", `{"stationId": "8503000", "nickname": "home"}`)
+	if err != nil {
+		t.Fatalf("create: %#v", err)
+	}
+	created, ok := result.(*models.Favorite)
+	if !ok || created.StationID != "8503000" || created.Nickname != "home" {
+		t.Fatalf("create = %#v", result)
+	}
+	if _, err := call(ctx, "favorites.stations.list", ""); err != nil {
+		t.Errorf("list without params: %#v", err)
+	}
+	if _, err := call(ctx, "favorites.stations.get", `{"id": "`+created.ID+`"}`); err != nil {
+		t.Errorf("get: %#v", err)
+	}
+
+	// The request IDs of the call are audited
+	entries, auditErr := favorites.repo.ListAudit(store.AuditQuery{Owner: "user:alice"})
+	if auditErr != nil || len(entries) != 1 || entries[0].RequestID != "ws:s1/c1" || entries[0].ClientRequestID != "call-7" {
+		t.Errorf("audit = %+v (%v), want the call's request IDs", entries, auditErr)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		ctx     context.Context
+		method  string
+		params  string
+		status  int
+		errType string
+	}{
+		{"bad params", ctx, "favorites.stations.create", `{"stationId": 8503000}`, http.StatusBadRequest, "Bad Request"},
+		{"params not an object", ctx, "favorites.stations.get", `"abc"`, http.StatusBadRequest, "Bad Request"},
+		{"invalid value", ctx, "favorites.stations.create", `{"stationId": "0000000"}`, http.StatusBadRequest, "Validation Error"},
+		{"limit out of range", ctx, "favorites.journeys.next", `{"id": "j1", "limit": 0}`, http.StatusBadRequest, "Invalid Parameter"},
+		{"not found", ctx, "favorites.stations.get", `{"id": "missing"}`, http.StatusNotFound, "Not Found"},
+		{"someone else's", asUser("bob"), "favorites.stations.get", `{"id": "` + created.ID + `"}`, http.StatusNotFound, "Not Found"},
+		{"stale version", ctx, "favorites.stations.delete", `{"id": "` + created.ID + `", "version": 7}`, http.StatusPreconditionFailed, ""},
+		{"anonymous", context.Background(), "favorites.stations.list", "", http.StatusUnauthorized, ""},
+	} {
+		result, err := call(tc.ctx, tc.method, tc.params)
+		var reqErr *models.RequestError
+		if !errors.As(err, &reqErr) || reqErr == nil {
+			t.Errorf("%s: result %#v, error %#v; want a request error", tc.name, result, err)
+			continue
+		}
+		if reqErr.Status != tc.status || (tc.errType != "" && reqErr.Type != tc.errType) {
+			t.Errorf("%s: error = %+v, want %d %s", tc.name, reqErr, tc.status, tc.errType)
+		}
+	}
+
+	// Deletes return the ID
+	result, err = call(ctx, "favorites.stations.delete", `{"id": "`+created.ID+`", "version": 1}`)
+	if err != nil {
+		t.Fatalf("delete: %#v", err)
+	}
+	if data, _ := json.Marshal(result); string(data) != `{"deleted":true,"id":"`+created.ID+`"}` {
+		t.Errorf("delete = %s", data)
+	}
+}
@@ -13,17 +13,19 @@ import (
 
 // StationsHandler handles station-related requests.
 type StationsHandler struct {
-	gtfsService  *services.GTFSService
-	swissService *services.SwissTransportService
-	useSwissAPI  bool
+	gtfsService *services.GTFSService
+	transport   services.TransportProvider
+	poller      *services.StationBoardPoller
+	useSwissAPI bool
 }
 
 // NewStationsHandler creates a new stations handler.
-func NewStationsHandler(gtfsService *services.GTFSService, swissService *services.SwissTransportService, useSwissAPI bool) *StationsHandler {
+func NewStationsHandler(gtfsService *services.GTFSService, transport services.TransportProvider, poller *services.StationBoardPoller, useSwissAPI bool) *StationsHandler {
 	return &StationsHandler{
-		gtfsService:  gtfsService,
-		swissService: swissService,
-		useSwissAPI:  useSwissAPI,
+		gtfsService: gtfsService,
+		transport:   transport,
+		poller:      poller,
+		useSwissAPI: useSwissAPI,
 	}
 }
 
@@ -39,9 +41,22 @@ func sendError(w http.ResponseWriter, status int, errType, message string) {
 
 // sendServiceUnavailable sends a service unavailable response.
 func sendServiceUnavailable(w http.ResponseWriter) {
-	sendError(w, http.StatusServiceUnavailable, "Service Unavailable", "GTFS data is still loading. Please try again later.")
+	sendRequestError(w, errDataLoading)
 }
 
+// newRequestError creates an error for sendRequestError or WebSocket RPC.
+func newRequestError(status int, errType, message string) *models.RequestError {
+	return &models.RequestError{Status: status, Type: errType, Message: message}
+}
+
+// sendRequestError sends a request error response.
+func sendRequestError(w http.ResponseWriter, err *models.RequestError) {
+	sendError(w, err.Status, err.Type, err.Message)
+}
+
+// errDataLoading is returned while GTFS data is still loading.
+var errDataLoading = newRequestError(http.StatusServiceUnavailable, "Service Unavailable", "GTFS data is still loading. Please try again later.")
+
 // GetStations returns all stations with pagination.
 func (h *StationsHandler) GetStations(w http.ResponseWriter, r *http.Request) {
 	if !h.gtfsService.IsDataLoaded() {
@@ -111,25 +126,17 @@ func (h *StationsHandler) GetStation(w http.ResponseWriter, r *http.Request) {
 
 // GetStationDepartures returns departures from a station.
 func (h *StationsHandler) GetStationDepartures(w http.ResponseWriter, r *http.Request) {
-	if !h.gtfsService.IsDataLoaded() {
-		sendServiceUnavailable(w)
-		return
-	}
-
 	vars := mux.Vars(r)
 	stationID := vars["id"]
 
-	departures := h.gtfsService.GetStationDepartures(stationID)
-	if departures == nil || departures.Station == nil {
-		sendError(w, http.StatusNotFound, "Station not found", "Station with ID "+stationID+" does not exist")
+	data, departures, reqErr := h.Departures(stationID)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
 		return
 	}
 
 	response := models.APIResponse{
-		Data: map[string]interface{}{
-			"station":    departures.Station,
-			"departures": departures.Departures,
-		},
+		Data: data,
 		Meta: &models.APIMeta{
 			Count:     departures.Count,
 			Timestamp: departures.Timestamp,
@@ -141,32 +148,71 @@ func (h *StationsHandler) GetStationDepartures(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(response)
 }
 
-// SearchStations searches stations by name or ID.
-func (h *StationsHandler) SearchStations(w http.ResponseWriter, r *http.Request) {
+// Departures returns the departures of a station, with the cached upstream
+// board as "live" when the poller has one (never blocks).
+func (h *StationsHandler) Departures(stationID string) (map[string]interface{}, *models.StationDepartures, *models.RequestError) {
 	if !h.gtfsService.IsDataLoaded() {
-		sendServiceUnavailable(w)
-		return
+		return nil, nil, errDataLoading
+	}
+
+	departures := h.gtfsService.GetStationDepartures(stationID)
+	if departures == nil || departures.Station == nil {
+		return nil, nil, newRequestError(http.StatusNotFound, "Station not found", "Station with ID "+stationID+" does not exist")
 	}
 
+	data := map[string]interface{}{
+		"station":    departures.Station,
+		"departures": departures.Departures,
+	}
+
+	if h.poller != nil {
+		if live := h.poller.Board(stationID); live != nil && live.Board != nil {
+			data["live"] = live
+		}
+	}
+
+	return data, departures, nil
+}
+
+// GetStationLiveBoard returns the cached upstream stationboard of a station.
+// The board is refreshed in the background by the stationboard poller.
+func (h *StationsHandler) GetStationLiveBoard(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	query := vars["query"]
+	stationID := vars["id"]
 
-	if len(query) < 2 {
-		sendError(w, http.StatusBadRequest, "Invalid query", "Search query must be at least 2 characters")
+	var live *services.LiveBoard
+	if h.poller != nil {
+		live = h.poller.Board(stationID)
+	}
+
+	if live == nil || live.Board == nil {
+		sendError(w, http.StatusNotFound, "Live board not available",
+			"No upstream stationboard cached for station "+stationID+" (not watched or not polled yet)")
 		return
 	}
 
-	// Try Swiss Transport API first if enabled
-	var results []models.Station
-	if h.useSwissAPI && h.swissService != nil {
-		var err error
-		results, err = h.swissService.SearchStations(query)
-		if err != nil {
-			// Fall back to GTFS data
-			results = h.gtfsService.SearchStations(query)
-		}
-	} else {
-		results = h.gtfsService.SearchStations(query)
+	response := models.APIResponse{
+		Data: live,
+		Meta: &models.APIMeta{
+			Count:     len(live.Board.Stationboard),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    live.Provider,
+			Note:      "Cached upstream stationboard fetched at " + live.FetchedAt.Format(time.RFC3339),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// SearchStations searches stations by name or ID.
+func (h *StationsHandler) SearchStations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := vars["query"]
+
+	results, source, reqErr := h.Search(query)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
 	}
 
 	response := models.APIResponse{
@@ -174,13 +220,33 @@ func (h *StationsHandler) SearchStations(w http.ResponseWriter, r *http.Request)
 		Meta: &models.APIMeta{
 			Total:     len(results),
 			Timestamp: time.Now().Format(time.RFC3339),
-			Source:    "swiss_gtfs_data",
+			Source:    source,
 		},
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
+// Search finds stations by name or ID and reports the data source.
+func (h *StationsHandler) Search(query string) ([]models.Station, string, *models.RequestError) {
+	if !h.gtfsService.IsDataLoaded() {
+		return nil, "", errDataLoading
+	}
+	if len(query) < 2 {
+		return nil, "", newRequestError(http.StatusBadRequest, "Invalid query", "Search query must be at least 2 characters")
+	}
+
+	// Try the upstream transport provider(s) first if enabled
+	if h.useSwissAPI && h.transport != nil {
+		results, err := h.transport.SearchStations(query)
+		if err == nil {
+			return results, h.transport.Name(), nil
+		}
+		// Fall back to GTFS data
+	}
+	return h.gtfsService.SearchStations(query), "swiss_gtfs_data", nil
+}
+
 // GetNearbyStations returns stations near a location.
 func (h *StationsHandler) GetNearbyStations(w http.ResponseWriter, r *http.Request) {
 	if !h.gtfsService.IsDataLoaded() {
@@ -14,17 +14,19 @@ import (
 
 // TrainsHandler handles train-related requests.
 type TrainsHandler struct {
-	gtfsService  *services.GTFSService
-	swissService *services.SwissTransportService
-	useSwissAPI  bool
+	gtfsService *services.GTFSService
+	transport   services.TransportProvider
+	poller      *services.StationBoardPoller
+	useSwissAPI bool
 }
 
 // NewTrainsHandler creates a new trains handler.
-func NewTrainsHandler(gtfsService *services.GTFSService, swissService *services.SwissTransportService, useSwissAPI bool) *TrainsHandler {
+func NewTrainsHandler(gtfsService *services.GTFSService, transport services.TransportProvider, poller *services.StationBoardPoller, useSwissAPI bool) *TrainsHandler {
 	return &TrainsHandler{
-		gtfsService:  gtfsService,
-		swissService: swissService,
-		useSwissAPI:  useSwissAPI,
+		gtfsService: gtfsService,
+		transport:   transport,
+		poller:      poller,
+		useSwissAPI: useSwissAPI,
 	}
 }
 
@@ -90,7 +92,13 @@ func (h *TrainsHandler) GetTrains(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetLiveTrains returns live train positions with optional time multiplier.
+// With ?source=upstream the trains come from the cached upstream stationboards.
 func (h *TrainsHandler) GetLiveTrains(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("source") == "upstream" {
+		h.getUpstreamTrains(w)
+		return
+	}
+
 	if !h.gtfsService.IsDataLoaded() {
 		sendServiceUnavailable(w)
 		return
@@ -120,6 +128,28 @@ func (h *TrainsHandler) GetLiveTrains(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// getUpstreamTrains returns trains derived from the poller's cached stationboards.
+func (h *TrainsHandler) getUpstreamTrains(w http.ResponseWriter) {
+	if h.poller == nil || !h.useSwissAPI {
+		sendError(w, http.StatusServiceUnavailable, "Service Unavailable", "Upstream transport API is disabled")
+		return
+	}
+
+	trains := h.poller.GetLiveTrainPositions()
+
+	response := models.APIResponse{
+		Data: trains,
+		Meta: &models.APIMeta{
+			Total:     len(trains),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    h.transport.Name(),
+			Note:      "Approximate positions from cached upstream stationboards",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
 // GetTrain returns a specific train by ID.
 func (h *TrainsHandler) GetTrain(w http.ResponseWriter, r *http.Request) {
 	if !h.gtfsService.IsDataLoaded() {
@@ -156,6 +186,60 @@ func (h *TrainsHandler) GetTrain(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetTrip returns the scheduled stops of a GTFS trip and, while it runs,
+// its live state.
+func (h *TrainsHandler) GetTrip(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tripID := vars["id"]
+
+	details, reqErr := h.TripDetails(tripID)
+	if reqErr != nil {
+		sendRequestError(w, reqErr)
+		return
+	}
+
+	response := models.APIResponse{
+		Data: details,
+		Meta: &models.APIMeta{
+			Count:     len(details.Stops),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Source:    "swiss_gtfs_data",
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// TripDetails builds the details of a GTFS trip.
+func (h *TrainsHandler) TripDetails(tripID string) (*models.TripDetails, *models.RequestError) {
+	if !h.gtfsService.IsDataLoaded() {
+		return nil, errDataLoading
+	}
+
+	trip := h.gtfsService.GetTrip(tripID)
+	if trip == nil {
+		return nil, newRequestError(http.StatusNotFound, "Trip not found", "Trip with ID "+tripID+" does not exist")
+	}
+
+	details := &models.TripDetails{
+		TripID:   trip.TripID,
+		RouteID:  trip.RouteID,
+		Name:     trip.TripShortName,
+		Headsign: trip.TripHeadsign,
+		Stops:    make([]models.TrainStop, 0),
+		Live:     h.gtfsService.GetLiveTrain(tripID),
+	}
+	for _, st := range h.gtfsService.GetTripStopTimes(tripID) {
+		details.Stops = append(details.Stops, models.TrainStop{
+			Station:       h.gtfsService.GetStationByID(st.StopID),
+			ArrivalTime:   st.ArrivalTime,
+			DepartureTime: st.DepartureTime,
+		})
+	}
+
+	return details, nil
+}
+
 // GetTrainStats returns train statistics summary.
 func (h *TrainsHandler) GetTrainStats(w http.ResponseWriter, r *http.Request) {
 	if !h.gtfsService.IsDataLoaded() {
@@ -0,0 +1,87 @@
+// Package middleware - Authentication Concern
+// This file identifies callers by JWT or device ID.
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/auth"
+)
+
+// DeviceIDHeader carries the device ID of clients without an account.
+const DeviceIDHeader = "X-Device-ID"
+
+// Authenticate stores the caller's identity in the request context: the
+// user of a bearer token, otherwise the device of a device ID. Requests
+// with neither continue without an identity; handlers decide whether they
+// need one. Invalid credentials are rejected with 401 rather than ignored,
+// so a client never silently falls back to another identity.
+//
+// Browsers can't set headers on WebSocket and EventSource requests, so
+// those may pass access_token and device_id as query parameters instead.
+func Authenticate(authenticator *auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, device := credentials(r)
+
+			var identity auth.Identity
+			var err error
+			switch {
+			case token != "":
+				identity, err = authenticator.Authenticate(token)
+			case device != "":
+				identity, err = authenticator.Device(device)
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err != nil {
+				log.Warn().
+					Err(err).
+					Str("path", r.URL.Path).
+					Msg("Authentication failed")
+
+				message := "Invalid or expired token"
+				if errors.Is(err, auth.ErrInvalidDevice) {
+					message = err.Error()
+				}
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"Unauthorized","message":"` + message + `"}`))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), identity)))
+		})
+	}
+}
+
+// credentials returns the bearer token and device ID of a request.
+func credentials(r *http.Request) (token, device string) {
+	if header := r.Header.Get("Authorization"); len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		token = strings.TrimSpace(header[7:])
+	}
+	device = r.Header.Get(DeviceIDHeader)
+
+	if streaming(r) {
+		query := r.URL.Query()
+		if token == "" {
+			token = query.Get("access_token")
+		}
+		if device == "" {
+			device = query.Get("device_id")
+		}
+	}
+	return token, device
+}
+
+// streaming reports whether r opens a WebSocket or an event stream.
+func streaming(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
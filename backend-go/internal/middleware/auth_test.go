@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/auth"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/store"
+)
+
+// subject is both a user's subject and a device's ID, the closest a device
+// can get to posing as the user.
+const subject = "alice-0123456789abcdef"
+
+func hs256Token(t *testing.T, secret, sub string) string {
+	t.Helper()
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signing := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." +
+		encode(map[string]interface{}{"sub": sub, "exp": time.Now().Add(time.Hour).Unix()})
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signing))
+	return signing + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// favoritesServer serves the caller's favorite stations from repo: GET
+// lists them, GET ?id= reads one.
+func favoritesServer(t *testing.T, repo store.FavoritesRepository) http.Handler {
+	t.Helper()
+	authenticator, err := auth.New(auth.Config{HS256Secret: "secret", Anonymous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Authenticate(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := auth.FromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if id := r.URL.Query().Get("id"); id != "" {
+			favorite, err := repo.GetStation(identity.Owner(), id)
+			if errors.Is(err, store.ErrNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(favorite)
+			return
+		}
+		favorites, _ := repo.ListStations(identity.Owner())
+		json.NewEncoder(w).Encode(favorites)
+	}))
+}
+
+func TestDeviceCannotReadUserFavorites(t *testing.T) {
+	repo := store.NewMemoryFavorites()
+	favorite := &models.FavoriteStation{
+		ID:        "fav-1",
+		Owner:     auth.Identity{Subject: subject}.Owner(),
+		StationID: "8503000",
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if err := repo.CreateStation(favorite); err != nil {
+		t.Fatal(err)
+	}
+	server := favoritesServer(t, repo)
+	userToken := hs256Token(t, "secret", subject)
+
+	get := func(target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+	count := func(rec *httptest.ResponseRecorder) int {
+		var favorites []models.FavoriteStation
+		json.Unmarshal(rec.Body.Bytes(), &favorites)
+		return len(favorites)
+	}
+
+	// The user sees the favorite
+	if rec := get("/favorites", map[string]string{"Authorization": "Bearer " + userToken}); count(rec) != 1 {
+		t.Fatalf("user sees %d favorites, want 1 (%d %s)", count(rec), rec.Code, rec.Body)
+	}
+
+	// A device with the user's subject as its ID sees nothing of it
+	device := map[string]string{DeviceIDHeader: subject}
+	if rec := get("/favorites", device); rec.Code != http.StatusOK || count(rec) != 0 {
+		t.Errorf("device sees %d favorites (%d), want 0", count(rec), rec.Code)
+	}
+	if rec := get("/favorites?id=fav-1", device); rec.Code != http.StatusNotFound {
+		t.Errorf("device reading the user's favorite = %d, want 404", rec.Code)
+	}
+
+	// Device IDs can't name another namespace
+	for _, id := range []string{"user:" + subject, "user%3A" + subject, "../user/" + subject} {
+		if rec := get("/favorites", map[string]string{DeviceIDHeader: id}); rec.Code != http.StatusUnauthorized {
+			t.Errorf("device ID %q = %d, want 401", id, rec.Code)
+		}
+	}
+
+	// An invalid token doesn't fall back to the device
+	rec := get("/favorites", map[string]string{"Authorization": "Bearer " + hs256Token(t, "wrong", subject), DeviceIDHeader: subject})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("invalid token with a device ID = %d, want 401", rec.Code)
+	}
+}
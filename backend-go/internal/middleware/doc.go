@@ -4,7 +4,9 @@
 //
 //   - logging.go:   Request logging with structured output
 //   - security.go:  Security headers, CSRF protection, content validation
-//   - ratelimit.go: In-memory rate limiting per IP
+//   - ratelimit.go: Token-bucket rate limiting per client, route and API key
+//   - auth.go:      Caller identity from JWTs or device IDs
+//   - requestid.go: Request IDs for logs and audit records
 //   - recovery.go:  Panic recovery with stack traces
 //   - chain.go:     Middleware chaining utilities
 //
@@ -3,104 +3,369 @@
 package middleware
 
 import (
+	"container/list"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
-// RateLimiter provides thread-safe in-memory rate limiting per IP.
-// For production, consider using Redis-backed rate limiting.
-type RateLimiter struct {
-	requests map[string][]time.Time
-	limit    int
-	window   time.Duration
-	mutex    sync.RWMutex
+// APIKeyHeader identifies API clients with a rate limit of their own.
+const APIKeyHeader = "X-API-Key"
+
+// RatePolicy is a token bucket: a client may send Burst requests at once,
+// and the bucket refills at Rate requests per minute. A Rate of 0 is
+// unlimited.
+type RatePolicy struct {
+	Rate  int // requests per minute
+	Burst int // requests at once
 }
 
-// NewRateLimiter creates a new rate limiter.
-// limit: maximum requests per window
-// window: time duration for the sliding window
-func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		requests: make(map[string][]time.Time),
-		limit:    limit,
-		window:   window,
-	}
+// RateLimitConfig configures a RateLimiter.
+type RateLimitConfig struct {
+	Default        RatePolicy // every client without a policy of its own
+	Routes         []string   // "[METHOD ]/path/prefix=rate[:burst]"
+	APIKeys        []string   // "key=rate[:burst]"
+	TrustedProxies []string   // IPs or CIDRs whose X-Forwarded-For is believed
+	MaxBuckets     int        // buckets kept in memory, about one per client and limited route
+}
 
-	// Start cleanup goroutine to prevent memory leaks
-	go rl.cleanup()
+// routePolicy limits requests to the paths under prefix.
+type routePolicy struct {
+	method string // "" for every method
+	prefix string
+	policy RatePolicy
+}
 
-	return rl
+// bucket holds the tokens one client has left for a policy.
+type bucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
 }
 
-// cleanup periodically removes old entries to prevent memory leaks
-func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(rl.window)
-	defer ticker.Stop()
+// RateLimiter limits requests per client with token buckets. Clients are
+// identified by API key when they send a configured one, otherwise by IP
+// (the X-Forwarded-For client if the request came through a trusted
+// proxy). Each client has a bucket of the default or its API key's policy,
+// and one per route policy for requests under that route. Buckets are kept
+// in memory up to a limit, dropping the least recently used first.
+type RateLimiter struct {
+	defaultPolicy RatePolicy
+	routes        []routePolicy // longest prefix first
+	keys          map[string]RatePolicy
+	proxies       []*net.IPNet
+	maxBuckets    int
 
-	for range ticker.C {
-		rl.mutex.Lock()
-		now := time.Now()
-		windowStart := now.Add(-rl.window)
+	mutex   sync.Mutex
+	buckets map[string]*list.Element
+	recent  *list.List // of *bucket, most recently used first
+}
 
-		for ip, times := range rl.requests {
-			var valid []time.Time
-			for _, t := range times {
-				if t.After(windowStart) {
-					valid = append(valid, t)
-				}
-			}
-			if len(valid) == 0 {
-				delete(rl.requests, ip)
+// NewRateLimiter creates a rate limiter from its configuration.
+func NewRateLimiter(cfg RateLimitConfig) (*RateLimiter, error) {
+	if cfg.Default.Rate < 0 || cfg.Default.Burst < 0 {
+		return nil, fmt.Errorf("default rate limit must not be negative")
+	}
+	rl := &RateLimiter{
+		defaultPolicy: withBurst(cfg.Default),
+		keys:          make(map[string]RatePolicy, len(cfg.APIKeys)),
+		maxBuckets:    cfg.MaxBuckets,
+		buckets:       make(map[string]*list.Element),
+		recent:        list.New(),
+	}
+	if rl.maxBuckets <= 0 {
+		rl.maxBuckets = 10000
+	}
+
+	for _, route := range cfg.Routes {
+		target, policy, err := parsePolicy(route)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit route %q: %w", route, err)
+		}
+		var rp routePolicy
+		if fields := strings.Fields(target); len(fields) == 2 {
+			rp.method, rp.prefix = strings.ToUpper(fields[0]), fields[1]
+		} else if len(fields) == 1 {
+			rp.prefix = fields[0]
+		}
+		if !strings.HasPrefix(rp.prefix, "/") {
+			return nil, fmt.Errorf("rate limit route %q: expected [METHOD ]/path=rate[:burst]", route)
+		}
+		rp.policy = policy
+		rl.routes = append(rl.routes, rp)
+	}
+	sort.SliceStable(rl.routes, func(i, j int) bool {
+		return len(rl.routes[i].prefix) > len(rl.routes[j].prefix)
+	})
+
+	for _, entry := range cfg.APIKeys {
+		key, policy, err := parsePolicy(entry)
+		if err != nil || key == "" {
+			// Don't echo the entry: it holds the key
+			return nil, fmt.Errorf("rate limit API key: expected key=rate[:burst]")
+		}
+		rl.keys[key] = policy
+	}
+
+	for _, proxy := range cfg.TrustedProxies {
+		cidr := proxy
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
 			} else {
-				rl.requests[ip] = valid
+				cidr += "/32"
 			}
 		}
-		rl.mutex.Unlock()
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: expected an IP or CIDR", proxy)
+		}
+		rl.proxies = append(rl.proxies, network)
+	}
+	return rl, nil
+}
+
+// parsePolicy splits "target=rate[:burst]".
+func parsePolicy(entry string) (string, RatePolicy, error) {
+	var policy RatePolicy
+	i := strings.LastIndex(entry, "=")
+	if i < 0 {
+		return "", policy, fmt.Errorf("expected =rate[:burst]")
+	}
+	target, limits := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+
+	rate, burst, hasBurst := strings.Cut(limits, ":")
+	var err error
+	if policy.Rate, err = strconv.Atoi(rate); err != nil || policy.Rate < 0 {
+		return "", policy, fmt.Errorf("rate must be a number of requests per minute")
+	}
+	if hasBurst {
+		if policy.Burst, err = strconv.Atoi(burst); err != nil || policy.Burst < 0 {
+			return "", policy, fmt.Errorf("burst must be a number of requests")
+		}
+	}
+	return target, withBurst(policy), nil
+}
+
+// withBurst defaults a policy's burst to a minute's worth of requests.
+func withBurst(policy RatePolicy) RatePolicy {
+	if policy.Burst == 0 {
+		policy.Burst = policy.Rate
 	}
+	return policy
 }
 
-// Limit is the middleware handler for rate limiting.
+// Limit is the middleware handler for rate limiting. Responses carry the
+// client's most constrained bucket as X-RateLimit-Limit (its burst),
+// X-RateLimit-Remaining and X-RateLimit-Reset (seconds until it is full);
+// rejected requests get 429 with Retry-After.
 func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-
-		rl.mutex.Lock()
-		now := time.Now()
-		windowStart := now.Add(-rl.window)
+		route := rl.route(r)
+		if route != nil && route.policy.Rate == 0 {
+			next.ServeHTTP(w, r) // an unlimited route
+			return
+		}
 
-		// Filter to only recent requests
-		var recentRequests []time.Time
-		for _, t := range rl.requests[ip] {
-			if t.After(windowStart) {
-				recentRequests = append(recentRequests, t)
+		ip := rl.ClientIP(r)
+		client, policy := "ip:"+ip, rl.defaultPolicy
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			if keyPolicy, ok := rl.keys[key]; ok {
+				client, policy = "key:"+key, keyPolicy
 			}
 		}
 
-		if len(recentRequests) >= rl.limit {
-			rl.mutex.Unlock()
+		limits := make([]limit, 0, 2)
+		if policy.Rate > 0 {
+			limits = append(limits, limit{key: client, policy: policy})
+		}
+		if route != nil {
+			limits = append(limits, limit{key: client + " " + route.method + " " + route.prefix, policy: route.policy})
+		}
+		if len(limits) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status, allowed := rl.take(limits, time.Now())
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(status.policy.Burst))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(status.remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(status.reset)))
+
+		if !allowed {
 			log.Warn().
+				Str("request_id", RequestIDFromContext(r.Context())).
 				Str("ip", ip).
-				Int("requests", len(recentRequests)).
-				Int("limit", rl.limit).
+				Bool("api_key", strings.HasPrefix(client, "key:")).
+				Str("path", r.URL.Path).
+				Int("limit", status.policy.Burst).
 				Msg("Rate limit exceeded")
 
-			w.Header().Set("Retry-After", "60")
-			w.Header().Set("X-RateLimit-Limit", string(rune(rl.limit)))
-			w.Header().Set("X-RateLimit-Remaining", "0")
-			http.Error(w, `{"error":"Too Many Requests","message":"Rate limit exceeded"}`, http.StatusTooManyRequests)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(status.retry)))
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"Too Many Requests","message":"Rate limit exceeded, retry in ` +
+				strconv.Itoa(seconds(status.retry)) + ` seconds"}`))
 			return
 		}
 
-		rl.requests[ip] = append(recentRequests, now)
-		remaining := rl.limit - len(rl.requests[ip])
-		rl.mutex.Unlock()
-
-		// Add rate limit headers
-		w.Header().Set("X-RateLimit-Remaining", string(rune(remaining)))
-
 		next.ServeHTTP(w, r)
 	})
 }
+
+// route returns the route policy of a request, the longest matching
+// prefix, or nil.
+func (rl *RateLimiter) route(r *http.Request) *routePolicy {
+	for i := range rl.routes {
+		route := &rl.routes[i]
+		if route.method != "" && route.method != r.Method {
+			continue
+		}
+		path := r.URL.Path
+		if path == route.prefix || strings.HasPrefix(path, strings.TrimSuffix(route.prefix, "/")+"/") {
+			return route
+		}
+	}
+	return nil
+}
+
+// ClientIP returns the IP of the client that sent a request. The
+// X-Forwarded-For header is only believed from trusted proxies: it is read
+// from the right, skipping trusted proxies, so a client can't pose as
+// another by sending the header itself.
+func (rl *RateLimiter) ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !rl.trusted(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break // not an address: nothing to its left can be believed
+		}
+		ip = hop.String()
+		if !rl.trusted(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// trusted reports whether ip is a trusted proxy.
+func (rl *RateLimiter) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range rl.proxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// limit is a bucket a request takes a token from.
+type limit struct {
+	key    string
+	policy RatePolicy
+}
+
+// limitStatus describes a bucket after a request.
+type limitStatus struct {
+	policy    RatePolicy
+	remaining int
+	reset     time.Duration // until the bucket is full
+	retry     time.Duration // until a request is allowed again
+}
+
+// take takes a token from each of the buckets, or from none if any is
+// empty, and returns the status of the most constrained one.
+func (rl *RateLimiter) take(limits []limit, now time.Time) (limitStatus, bool) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	buckets := make([]*bucket, len(limits))
+	allowed := true
+	for i, l := range limits {
+		b := rl.bucket(l.key, l.policy, now)
+		elapsed := now.Sub(b.updated).Minutes()
+		b.tokens = math.Min(float64(l.policy.Burst), b.tokens+elapsed*float64(l.policy.Rate))
+		b.updated = now
+		if b.tokens < 1 {
+			allowed = false
+		}
+		buckets[i] = b
+	}
+
+	var status limitStatus
+	for i, b := range buckets {
+		if allowed {
+			b.tokens--
+		}
+		policy := limits[i].policy
+		perToken := time.Duration(float64(time.Minute) / float64(policy.Rate))
+		current := limitStatus{
+			policy:    policy,
+			remaining: int(b.tokens),
+			reset:     time.Duration((float64(policy.Burst) - b.tokens) * float64(perToken)),
+		}
+		if b.tokens < 1 {
+			current.retry = time.Duration((1 - b.tokens) * float64(perToken))
+		}
+		if i == 0 || current.remaining < status.remaining ||
+			(current.remaining == status.remaining && current.retry > status.retry) {
+			status = current
+		}
+	}
+	return status, allowed
+}
+
+// bucket returns the bucket for key, creating a full one and dropping the
+// least recently used bucket beyond the limit. A dropped bucket starts
+// full again; idle clients' buckets have refilled anyway. The caller must
+// hold the mutex.
+func (rl *RateLimiter) bucket(key string, policy RatePolicy, now time.Time) *bucket {
+	if element, ok := rl.buckets[key]; ok {
+		rl.recent.MoveToFront(element)
+		return element.Value.(*bucket)
+	}
+	b := &bucket{key: key, tokens: float64(policy.Burst), updated: now}
+	rl.buckets[key] = rl.recent.PushFront(b)
+	for rl.recent.Len() > rl.maxBuckets {
+		oldest := rl.recent.Back()
+		rl.recent.Remove(oldest)
+		delete(rl.buckets, oldest.Value.(*bucket).key)
+	}
+	return b
+}
+
+// Stats returns the number of buckets in memory and their limit.
+func (rl *RateLimiter) Stats() map[string]interface{} {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	return map[string]interface{}{
+		"buckets":    rl.recent.Len(),
+		"maxBuckets": rl.maxBuckets,
+		"routes":     len(rl.routes),
+		"apiKeys":    len(rl.keys),
+	}
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, cfg RateLimitConfig) *RateLimiter {
+	t.Helper()
+	rl, err := NewRateLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+func TestClientIP(t *testing.T) {
+	rl := newTestLimiter(t, RateLimitConfig{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"}})
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string // X-Forwarded-For header lines
+		want       string
+	}{
+		{"direct", "203.0.113.5:4711", nil, "203.0.113.5"},
+		{"untrusted peer spoofing", "203.0.113.5:4711", []string{"198.51.100.7"}, "203.0.113.5"},
+		{"trusted proxy without header", "10.0.0.1:4711", nil, "10.0.0.1"},
+		{"trusted proxy", "10.0.0.1:4711", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"chain of trusted proxies", "10.0.0.1:4711", []string{"198.51.100.7, 192.168.1.1, 10.0.0.2"}, "198.51.100.7"},
+		{"client spoofing behind proxies", "10.0.0.1:4711", []string{"1.2.3.4, 198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"header over several lines", "10.0.0.1:4711", []string{"1.2.3.4", "198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"non-IP hop", "10.0.0.1:4711", []string{"198.51.100.7, unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"non-IP client", "10.0.0.1:4711", []string{"unknown"}, "10.0.0.1"},
+		{"IPv6", "[fd00::1]:4711", []string{"2001:db8::7"}, "2001:db8::7"},
+		{"untrusted IPv6", "[2001:db8::1]:4711", []string{"2001:db8::7"}, "2001:db8::1"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/stations", nil)
+		r.RemoteAddr = tc.remoteAddr
+		for _, value := range tc.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if got := rl.ClientIP(r); got != tc.want {
+			t.Errorf("%s: ClientIP = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTakeRefills(t *testing.T) {
+	rl := newTestLimiter(t, RateLimitConfig{})
+	limits := []limit{{key: "ip:203.0.113.5", policy: RatePolicy{Rate: 60, Burst: 2}}}
+	start := time.Date(2025, 3, 14, 8, 0, 0, 0, time.UTC)
+
+	for _, step := range []struct {
+		after     time.Duration
+		allowed   bool
+		remaining int
+		reset     time.Duration
+		retry     time.Duration
+	}{
+		{0, true, 1, time.Second, 0},
+		{0, true, 0, 2 * time.Second, time.Second},
+		{0, false, 0, 2 * time.Second, time.Second},
+		{500 * time.Millisecond, false, 0, 1500 * time.Millisecond, 500 * time.Millisecond},
+		{time.Second, true, 0, 2 * time.Second, time.Second},
+		{time.Minute, true, 1, time.Second, 0}, // never more than the burst
+	} {
+		status, allowed := rl.take(limits, start.Add(step.after))
+		if allowed != step.allowed || status.remaining != step.remaining ||
+			status.reset != step.reset || status.retry != step.retry {
+			t.Errorf("after %v: allowed %v, %+v; want %v, remaining %d, reset %v, retry %v",
+				step.after, allowed, status, step.allowed, step.remaining, step.reset, step.retry)
+		}
+	}
+}
+
+func TestTakeMostConstrained(t *testing.T) {
+	rl := newTestLimiter(t, RateLimitConfig{})
+	client := limit{key: "ip:203.0.113.5", policy: RatePolicy{Rate: 600, Burst: 100}}
+	route := limit{key: "ip:203.0.113.5 POST /api/favorites/import", policy: RatePolicy{Rate: 10, Burst: 2}}
+	now := time.Date(2025, 3, 14, 8, 0, 0, 0, time.UTC)
+
+	// The route's bucket has fewer tokens left
+	status, _ := rl.take([]limit{client, route}, now)
+	if status.policy != route.policy || status.remaining != 1 {
+		t.Errorf("status = %+v, want the route's with 1 remaining", status)
+	}
+	rl.take([]limit{client, route}, now)
+
+	// Once the route is empty nothing is taken, from the client's bucket
+	// neither
+	status, allowed := rl.take([]limit{client, route}, now)
+	if allowed || status.policy != route.policy || status.retry != 6*time.Second {
+		t.Errorf("status = %v, %+v; want the route's, retry in 6s", allowed, status)
+	}
+	if status, _ := rl.take([]limit{client}, now); status.remaining != 97 {
+		t.Errorf("client has %d remaining, want 97", status.remaining)
+	}
+
+	// With the same tokens left, the one that takes longer to allow again
+	equal := limit{key: "key:partner", policy: RatePolicy{Rate: 60, Burst: 1}}
+	slower := limit{key: "key:partner GET /api/journeys", policy: RatePolicy{Rate: 6, Burst: 1}}
+	status, _ = rl.take([]limit{equal, slower}, now)
+	if status.policy != slower.policy || status.retry != 10*time.Second {
+		t.Errorf("status = %+v, want the slower bucket's, retry in 10s", status)
+	}
+}
+
+func TestLimitHeaders(t *testing.T) {
+	rl := newTestLimiter(t, RateLimitConfig{
+		Default: RatePolicy{Rate: 60, Burst: 2},
+		Routes:  []string{"/health=0", "POST /api/favorites/import=1:1"},
+	})
+	handler := rl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = "203.0.113.5:4711"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i, want := range []struct {
+		status    int
+		remaining string
+		reset     string
+	}{
+		{http.StatusOK, "1", "1"},
+		{http.StatusOK, "0", "2"},
+		{http.StatusTooManyRequests, "0", "2"},
+	} {
+		w := send(http.MethodGet, "/api/stations")
+		if w.Code != want.status || w.Header().Get("X-RateLimit-Limit") != "2" ||
+			w.Header().Get("X-RateLimit-Remaining") != want.remaining || w.Header().Get("X-RateLimit-Reset") != want.reset {
+			t.Errorf("request %d: %d %v; want %d, remaining %s, reset %s", i+1, w.Code, w.Header(), want.status, want.remaining, want.reset)
+		}
+		if retry := w.Header().Get("Retry-After"); (w.Code == http.StatusTooManyRequests) != (retry == "1") {
+			t.Errorf("request %d: Retry-After %q", i+1, retry)
+		}
+	}
+
+	// Unlimited routes neither count nor send headers
+	if w := send(http.MethodGet, "/health"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("/health: %d %v", w.Code, w.Header())
+	}
+
+	// A route's limit applies besides the client's, which is used up
+	w := send(http.MethodPost, "/api/favorites/import")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("import: %d %v, want 429 by the client's bucket", w.Code, w.Header())
+	}
+}
+
+func TestBucketEviction(t *testing.T) {
+	rl := newTestLimiter(t, RateLimitConfig{MaxBuckets: 2})
+	policy := RatePolicy{Rate: 60, Burst: 2}
+	now := time.Date(2025, 3, 14, 8, 0, 0, 0, time.UTC)
+	take := func(key string) limitStatus {
+		status, _ := rl.take([]limit{{key: key, policy: policy}}, now)
+		return status
+	}
+
+	take("a")
+	take("b")
+	take("a") // a is now used more recently than b
+	take("c")
+	if _, ok := rl.buckets["b"]; ok || rl.recent.Len() != 2 {
+		t.Fatalf("buckets = %v, want b dropped", rl.buckets)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := rl.buckets[key]; !ok {
+			t.Errorf("bucket %s dropped", key)
+		}
+	}
+
+	// A dropped bucket starts full again
+	if status := take("b"); status.remaining != 1 {
+		t.Errorf("b has %d remaining, want 1", status.remaining)
+	}
+	if stats := rl.Stats(); stats["buckets"] != 2 {
+		t.Errorf("stats = %v, want 2 buckets", stats)
+	}
+}
@@ -0,0 +1,61 @@
+// Package middleware - Request ID Concern
+// This file gives every request an ID for logs and audit records.
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a request; it is echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern accepts IDs set by clients or proxies; others are dropped.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:/-]{1,64}$`)
+
+type requestIDKey struct{}
+
+type clientRequestIDKey struct{}
+
+// RequestID gives every request an ID generated here, stores it in the
+// request's context and sends it in the X-Request-ID header. An ID set by
+// the client or a proxy is kept beside it as the client request ID, so a
+// request can be followed across services, but never replaces it: audit
+// records must not carry IDs a caller chose.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := uuid.New().String()
+		ctx := WithRequestID(r.Context(), id)
+		if clientID := r.Header.Get(RequestIDHeader); requestIDPattern.MatchString(clientID) {
+			ctx = WithClientRequestID(ctx, clientID)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithRequestID returns a context carrying a request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithClientRequestID returns a context carrying the request ID the client
+// sent.
+func WithClientRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientRequestIDKey{}, id)
+}
+
+// ClientRequestIDFromContext returns the request ID the client sent, or "".
+func ClientRequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(clientRequestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDIgnoresClientIDs(t *testing.T) {
+	var id, clientID string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestIDFromContext(r.Context())
+		clientID = ClientRequestIDFromContext(r.Context())
+	}))
+
+	for _, tc := range []struct {
+		header, clientID string
+	}{
+		{"", ""},
+		{"trace-42", "trace-42"},
+		{"not an id\n", ""},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			r.Header.Set(RequestIDHeader, tc.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		// The request ID is always generated here and sent back
+		if id == "" || id == tc.header || w.Header().Get(RequestIDHeader) != id {
+			t.Errorf("%q: request ID %q, header %q", tc.header, id, w.Header().Get(RequestIDHeader))
+		}
+		if clientID != tc.clientID {
+			t.Errorf("%q: client request ID %q, want %q", tc.header, clientID, tc.clientID)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package models - Alert Domain
+// This file contains alert rules attached to favorites, the alerts they
+// raise and the webhook deliveries that carry them.
+package models
+
+// Alert rule conditions.
+const (
+	AlertDelay           = "delay"            // delayed by Minutes or more
+	AlertCancelled       = "cancelled"        // cancelled
+	AlertPlatformChanged = "platform_changed" // an upcoming stop moved to another platform
+	AlertDepartingSoon   = "departing_soon"   // departs within Minutes
+	AlertAtRisk          = "at_risk"          // the usual connection is at risk (journeys)
+)
+
+// Favorite types an alert rule can be attached to.
+const (
+	AlertFavoriteTrain   = "train"
+	AlertFavoriteJourney = "journey"
+)
+
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending"   // queued or waiting for a retry
+	DeliveryDelivered = "delivered" // the receiver answered 2xx
+	DeliveryDead      = "dead"      // gave up; kept in the dead-letter list
+)
+
+// AlertRule notifies a webhook when a condition holds for a favorite.
+type AlertRule struct {
+	ID           string `json:"id"`
+	Owner        string `json:"-"`                 // user or device the rule belongs to
+	FavoriteType string `json:"favoriteType"`      // "train" or "journey"
+	FavoriteID   string `json:"favoriteId"`        // favorite train or journey ID
+	Condition    string `json:"condition"`         // one of the Alert* conditions
+	Minutes      int    `json:"minutes,omitempty"` // delay and departing_soon threshold
+	WebhookURL   string `json:"webhookUrl"`
+	Secret       string `json:"secret,omitempty"` // signs deliveries; only returned on create
+	Enabled      bool   `json:"enabled"`
+	CreatedAt    string `json:"createdAt"`
+	UpdatedAt    string `json:"updatedAt"`
+}
+
+// CreateAlertRuleRequest is the request body for creating an alert rule.
+// Without a secret, one is generated. Rules are enabled unless told
+// otherwise.
+type CreateAlertRuleRequest struct {
+	FavoriteType string `json:"favoriteType"`
+	FavoriteID   string `json:"favoriteId"`
+	Condition    string `json:"condition"`
+	Minutes      int    `json:"minutes,omitempty"`
+	WebhookURL   string `json:"webhookUrl"`
+	Secret       string `json:"secret,omitempty"`
+	Enabled      *bool  `json:"enabled,omitempty"`
+}
+
+// UpdateAlertRuleRequest is the request body for updating an alert rule.
+// Omitted fields are kept; a new secret replaces the old one.
+type UpdateAlertRuleRequest struct {
+	Minutes    *int   `json:"minutes,omitempty"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
+	Secret     string `json:"secret,omitempty"`
+	Enabled    *bool  `json:"enabled,omitempty"`
+}
+
+// Alert is a rule's condition holding for a favorite; the body of a
+// webhook delivery.
+type Alert struct {
+	ID               string   `json:"id"`
+	Key              string   `json:"-"` // same key while the same condition holds
+	RuleID           string   `json:"ruleId"`
+	Owner            string   `json:"-"`
+	FavoriteType     string   `json:"favoriteType"`
+	FavoriteID       string   `json:"favoriteId"`
+	Nickname         string   `json:"nickname,omitempty"`
+	Condition        string   `json:"condition"`
+	Message          string   `json:"message"`
+	TripID           string   `json:"tripId,omitempty"`
+	Name             string   `json:"name,omitempty"`
+	Station          *Station `json:"station,omitempty"`
+	Departure        string   `json:"departure,omitempty"` // ISO8601, scheduled
+	Delay            int      `json:"delay"`
+	Platform         string   `json:"platform,omitempty"`
+	PreviousPlatform string   `json:"previousPlatform,omitempty"`
+	Risks            []string `json:"risks,omitempty"`
+	Test             bool     `json:"test,omitempty"` // sent from the test endpoint
+	Timestamp        string   `json:"timestamp"`
+}
+
+// WebhookDelivery is the delivery of an alert to a rule's webhook.
+type WebhookDelivery struct {
+	ID             string `json:"id"`
+	Owner          string `json:"-"`
+	RuleID         string `json:"ruleId"`
+	AlertID        string `json:"alertId"`
+	Condition      string `json:"condition"`
+	URL            string `json:"url"`
+	Status         string `json:"status"` // pending, delivered or dead
+	Attempts       int    `json:"attempts"`
+	ResponseStatus int    `json:"responseStatus,omitempty"` // of the last attempt
+	LastError      string `json:"lastError,omitempty"`
+	CreatedAt      string `json:"createdAt"`
+	LastAttemptAt  string `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt  string `json:"nextAttemptAt,omitempty"`
+	DeliveredAt    string `json:"deliveredAt,omitempty"`
+	Alert          *Alert `json:"alert"`
+}
@@ -35,11 +35,29 @@ type APIError struct {
 	Timestamp string `json:"timestamp"`
 }
 
+// RequestError is a failed request with its HTTP status. REST handlers send
+// it as an APIError; WebSocket RPC sends it as the error of a response.
+type RequestError struct {
+	Status  int    `json:"status"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (e *RequestError) Error() string {
+	return e.Type + ": " + e.Message
+}
+
 // WebSocketMessage represents a WebSocket message.
+//
+// RPC responses carry the request's ID and either a Result or an Error.
 type WebSocketMessage struct {
-	Type      string      `json:"type"`
-	Message   string      `json:"message,omitempty"`
-	Data      interface{} `json:"data,omitempty"`
-	Timestamp string      `json:"timestamp"`
-	GTFSReady bool        `json:"gtfs_loaded,omitempty"`
+	Type      string        `json:"type"`
+	ID        string        `json:"id,omitempty"`
+	Message   string        `json:"message,omitempty"`
+	Data      interface{}   `json:"data,omitempty"`
+	Result    interface{}   `json:"result,omitempty"`
+	Error     *RequestError `json:"error,omitempty"`
+	SessionID string        `json:"sessionId,omitempty"`
+	Timestamp string        `json:"timestamp"`
+	GTFSReady bool          `json:"gtfs_loaded,omitempty"`
 }
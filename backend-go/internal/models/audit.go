@@ -0,0 +1,36 @@
+// Package models - Audit Domain
+// This file contains the audit log of changes to favorites.
+package models
+
+import "encoding/json"
+
+// Audited actions on favorites.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update" // PUT, PATCH, or moved by a reorder or collection delete
+	AuditDelete = "delete"
+	AuditImport = "import" // created by a bulk import
+)
+
+// Favorite types in the audit log.
+const (
+	AuditFavoriteStation = "station"
+	AuditFavoriteTrain   = "train"
+	AuditFavoriteJourney = "journey"
+)
+
+// AuditEntry records one change to a favorite. Entries are only ever
+// appended; they outlive the favorite they describe.
+type AuditEntry struct {
+	ID              string          `json:"id"`
+	Owner           string          `json:"owner"`                     // whose favorite changed
+	Actor           string          `json:"actor"`                     // who changed it (user:… or device:…)
+	RequestID       string          `json:"requestId,omitempty"`       // generated by the server: X-Request-ID, or the WebSocket session and call
+	ClientRequestID string          `json:"clientRequestId,omitempty"` // the ID the client sent, if any (not trusted)
+	Action          string          `json:"action"`                    // one of the Audit* actions
+	FavoriteType    string          `json:"favoriteType"`              // station, train or journey
+	FavoriteID      string          `json:"favoriteId"`
+	Before          json.RawMessage `json:"before,omitempty"` // the favorite before the change (updates, deletes)
+	After           json.RawMessage `json:"after,omitempty"`  // the favorite after the change (creates, imports, updates)
+	Timestamp       string          `json:"timestamp"`        // ISO8601 timestamp
+}
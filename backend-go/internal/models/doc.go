@@ -4,7 +4,11 @@
 //
 //   - station.go:   Station, Coordinate, Departure structures
 //   - train.go:     Train, Position, TrainStop structures
-//   - favorites.go: FavoriteStation, FavoriteTrain structures
+//   - favorites.go: FavoriteStation, FavoriteTrain, FavoriteJourney, FavoriteCollection, IdempotentResponse structures
+//   - journey.go:   JourneyConnection, JourneyLeg structures
+//   - alert.go:     AlertRule, Alert, WebhookDelivery structures
+//   - audit.go:     AuditEntry of changes to favorites
+//   - event.go:     TrainEvent detected from live snapshots
 //   - api.go:       APIResponse, APIError, Pagination structures
 //   - gtfs.go:      GTFS data parsing structures
 //   - health.go:    HealthResponse and system status
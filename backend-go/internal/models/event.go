@@ -0,0 +1,32 @@
+// Package models - Event Domain
+// This file contains train events detected from successive live snapshots.
+package models
+
+// Train event types.
+const (
+	EventArrival        = "arrival"
+	EventDeparture      = "departure"
+	EventPassThrough    = "pass_through"
+	EventDelayChange    = "delay_change"
+	EventPlatformChange = "platform_change"
+	EventCancellation   = "cancellation"
+)
+
+// TrainEvent is something that happened to a train between two live
+// snapshots. IDs increase monotonically, also across restarts of the leader.
+type TrainEvent struct {
+	ID               uint64   `json:"id"`
+	Type             string   `json:"type"`
+	TrainID          string   `json:"trainId"`
+	RouteID          string   `json:"routeId,omitempty"`
+	Name             string   `json:"name"`
+	Station          *Station `json:"station,omitempty"`
+	Origin           bool     `json:"origin,omitempty"`      // event at the first stop
+	Destination      bool     `json:"destination,omitempty"` // event at the last stop
+	Scheduled        string   `json:"scheduled,omitempty"`   // scheduled time at the station (HH:MM:SS)
+	Delay            int      `json:"delay"`
+	PreviousDelay    *int     `json:"previousDelay,omitempty"`
+	Platform         string   `json:"platform,omitempty"`
+	PreviousPlatform string   `json:"previousPlatform,omitempty"`
+	Timestamp        string   `json:"timestamp"` // when the event was detected (RFC3339)
+}
@@ -3,26 +3,35 @@
 // Demonstrates HTTP POST/PUT/DELETE operations.
 package models
 
+import "time"
+
 // ============================================================================
 // FAVORITE STATION - For learning HTTP methods
 // ============================================================================
 
 // FavoriteStation represents a user's favorite station with optional notes.
 type FavoriteStation struct {
-	ID        string  `json:"id"`                  // Unique favorite ID (UUID)
-	StationID string  `json:"stationId"`           // Reference to station
-	Station   Station `json:"station"`             // Embedded station data
-	Nickname  string  `json:"nickname,omitempty"`  // User-defined nickname
-	Notes     string  `json:"notes,omitempty"`     // User notes (max 500 chars)
-	CreatedAt string  `json:"createdAt"`           // ISO8601 timestamp
-	UpdatedAt string  `json:"updatedAt,omitempty"` // ISO8601 timestamp
+	ID           string   `json:"id"`                     // Unique favorite ID (UUID)
+	Owner        string   `json:"-"`                      // Owning user or device (never sent to clients)
+	StationID    string   `json:"stationId"`              // Reference to station
+	Station      Station  `json:"station"`                // Embedded station data
+	Nickname     string   `json:"nickname,omitempty"`     // User-defined nickname
+	Notes        string   `json:"notes,omitempty"`        // User notes (max 500 chars)
+	CollectionID string   `json:"collectionId,omitempty"` // Collection the favorite is in
+	Tags         []string `json:"tags,omitempty"`         // Lowercase tags
+	Position     int      `json:"position"`               // Sort position; new favorites go last
+	Version      int      `json:"version"`                // Starts at 1, bumped by every update (the ETag)
+	CreatedAt    string   `json:"createdAt"`              // ISO8601 timestamp
+	UpdatedAt    string   `json:"updatedAt,omitempty"`    // ISO8601 timestamp
 }
 
 // CreateFavoriteStationRequest is the request body for POST /api/favorites/stations
 type CreateFavoriteStationRequest struct {
-	StationID string `json:"stationId"` // Required: station ID to favorite
-	Nickname  string `json:"nickname"`  // Optional: custom name (max 100 chars)
-	Notes     string `json:"notes"`     // Optional: notes (max 500 chars)
+	StationID    string   `json:"stationId"`    // Required: station ID to favorite
+	Nickname     string   `json:"nickname"`     // Optional: custom name (max 100 chars)
+	Notes        string   `json:"notes"`        // Optional: notes (max 500 chars)
+	CollectionID string   `json:"collectionId"` // Optional: collection to file it in
+	Tags         []string `json:"tags"`         // Optional: tags
 }
 
 // UpdateFavoriteStationRequest is the request body for PUT /api/favorites/stations/{id}
@@ -31,35 +40,297 @@ type UpdateFavoriteStationRequest struct {
 	Notes    string `json:"notes"`    // Optional: update notes
 }
 
+// PatchFavoriteStationRequest is the request body for PATCH
+// /api/favorites/stations/{id}. Only the fields sent are changed.
+type PatchFavoriteStationRequest struct {
+	Nickname     *string   `json:"nickname"`
+	Notes        *string   `json:"notes"`
+	CollectionID *string   `json:"collectionId"` // "" takes it out of its collection
+	Tags         *[]string `json:"tags"`         // replaces the tags; [] removes them
+}
+
 // ============================================================================
 // FAVORITE TRAIN - For learning HTTP methods + auto-follow
 // ============================================================================
 
 // FavoriteTrain represents a user's favorite train with auto-follow settings.
+// The train is a recurring service (Pattern); TrainID is the trip it was
+// created from, and the trip of each day is looked up from the pattern.
 type FavoriteTrain struct {
-	ID         string `json:"id"`                  // Unique favorite ID (UUID)
-	TrainID    string `json:"trainId"`             // Reference to train
-	Train      Train  `json:"train"`               // Embedded train data (snapshot)
-	Nickname   string `json:"nickname,omitempty"`  // User-defined nickname
-	Notes      string `json:"notes,omitempty"`     // User notes (max 500 chars)
-	AutoFollow bool   `json:"autoFollow"`          // Auto-follow when selected
-	CreatedAt  string `json:"createdAt"`           // ISO8601 timestamp
-	UpdatedAt  string `json:"updatedAt,omitempty"` // ISO8601 timestamp
+	ID           string          `json:"id"`                     // Unique favorite ID (UUID)
+	Owner        string          `json:"-"`                      // Owning user or device (never sent to clients)
+	TrainID      string          `json:"trainId"`                // Trip the favorite was created from
+	Pattern      *ServicePattern `json:"pattern,omitempty"`      // Recurring service (unset on favorites created before patterns)
+	Train        Train           `json:"train"`                  // Embedded train data (snapshot)
+	Nickname     string          `json:"nickname,omitempty"`     // User-defined nickname
+	Notes        string          `json:"notes,omitempty"`        // User notes (max 500 chars)
+	AutoFollow   bool            `json:"autoFollow"`             // Auto-follow when selected
+	CollectionID string          `json:"collectionId,omitempty"` // Collection the favorite is in
+	Tags         []string        `json:"tags,omitempty"`         // Lowercase tags
+	Position     int             `json:"position"`               // Sort position; new favorites go last
+	Version      int             `json:"version"`                // Starts at 1, bumped by every update (the ETag)
+	CreatedAt    string          `json:"createdAt"`              // ISO8601 timestamp
+	UpdatedAt    string          `json:"updatedAt,omitempty"`    // ISO8601 timestamp
+}
+
+// ServicePattern is a recurring train: the train named Name leaving
+// OriginID at DepartureTime on Days, e.g. the IC 1 at 07:32 from
+// St. Gallen on weekdays.
+type ServicePattern struct {
+	RouteID       string   `json:"routeId,omitempty"`    // Informational: route IDs change between timetables
+	Name          string   `json:"name"`                 // trip_short_name, e.g. "IC 1"
+	OriginID      string   `json:"originId"`             // First stop
+	OriginName    string   `json:"originName,omitempty"` // Set by the server
+	DepartureTime string   `json:"departureTime"`        // HH:MM at the origin
+	Days          []string `json:"days"`                 // mon, tue, wed, thu, fri, sat, sun
 }
 
-// CreateFavoriteTrainRequest is the request body for POST /api/favorites/trains
+// CreateFavoriteTrainRequest is the request body for POST /api/favorites/trains.
+// The train is given either as a trip (trainId) or as a pattern.
 type CreateFavoriteTrainRequest struct {
-	TrainID    string `json:"trainId"`    // Required: train ID to favorite
-	Nickname   string `json:"nickname"`   // Optional: custom name
-	Notes      string `json:"notes"`      // Optional: notes
-	AutoFollow bool   `json:"autoFollow"` // Optional: auto-follow on select
+	TrainID      string          `json:"trainId"`      // Trip to favorite (its pattern is stored)
+	Pattern      *ServicePattern `json:"pattern"`      // Or: the recurring service (routeId and originName optional)
+	Days         []string        `json:"days"`         // Optional: limit a trip's pattern to these days
+	Nickname     string          `json:"nickname"`     // Optional: custom name
+	Notes        string          `json:"notes"`        // Optional: notes
+	AutoFollow   bool            `json:"autoFollow"`   // Optional: auto-follow on select
+	CollectionID string          `json:"collectionId"` // Optional: collection to file it in
+	Tags         []string        `json:"tags"`         // Optional: tags
 }
 
 // UpdateFavoriteTrainRequest is the request body for PUT /api/favorites/trains/{id}
 type UpdateFavoriteTrainRequest struct {
-	Nickname   string `json:"nickname"`   // Optional: update nickname
-	Notes      string `json:"notes"`      // Optional: update notes
-	AutoFollow *bool  `json:"autoFollow"` // Optional: update auto-follow (pointer to distinguish from false)
+	Nickname   string   `json:"nickname"`   // Optional: update nickname
+	Notes      string   `json:"notes"`      // Optional: update notes
+	AutoFollow *bool    `json:"autoFollow"` // Optional: update auto-follow (pointer to distinguish from false)
+	Days       []string `json:"days"`       // Optional: update the pattern's days
+}
+
+// PatchFavoriteTrainRequest is the request body for PATCH
+// /api/favorites/trains/{id}. Only the fields sent are changed.
+type PatchFavoriteTrainRequest struct {
+	Nickname     *string   `json:"nickname"`
+	Notes        *string   `json:"notes"`
+	AutoFollow   *bool     `json:"autoFollow"`
+	Days         []string  `json:"days"`         // the pattern's days
+	CollectionID *string   `json:"collectionId"` // "" takes it out of its collection
+	Tags         *[]string `json:"tags"`         // replaces the tags; [] removes them
+}
+
+// ============================================================================
+// FAVORITE JOURNEY - Commutes between two stations
+// ============================================================================
+
+// FavoriteJourney is a trip the user makes regularly, e.g. home to work,
+// with the times they usually travel.
+type FavoriteJourney struct {
+	ID            string       `json:"id"`                     // Unique favorite ID (UUID)
+	Owner         string       `json:"-"`                      // Owning user or device (never sent to clients)
+	OriginID      string       `json:"originId"`               // Reference to the departure station
+	Origin        Station      `json:"origin"`                 // Embedded station data
+	DestinationID string       `json:"destinationId"`          // Reference to the arrival station
+	Destination   Station      `json:"destination"`            // Embedded station data
+	Windows       []TimeWindow `json:"windows"`                // Preferred departure times (may be empty)
+	Nickname      string       `json:"nickname,omitempty"`     // User-defined nickname
+	Notes         string       `json:"notes,omitempty"`        // User notes (max 500 chars)
+	CollectionID  string       `json:"collectionId,omitempty"` // Collection the favorite is in
+	Tags          []string     `json:"tags,omitempty"`         // Lowercase tags
+	Position      int          `json:"position"`               // Sort position; new favorites go last
+	Version       int          `json:"version"`                // Starts at 1, bumped by every update (the ETag)
+	CreatedAt     string       `json:"createdAt"`              // ISO8601 timestamp
+	UpdatedAt     string       `json:"updatedAt,omitempty"`    // ISO8601 timestamp
+}
+
+// TimeWindow is a range of departure times on some days, e.g. 07:00 to
+// 08:00 on weekdays. A window ending before it starts crosses midnight and
+// belongs to the day it opens.
+type TimeWindow struct {
+	Start string   `json:"start"` // HH:MM
+	End   string   `json:"end"`   // HH:MM, before Start to cross midnight
+	Days  []string `json:"days"`  // mon ... sun (every day if empty on create)
+}
+
+// CreateFavoriteJourneyRequest is the request body for POST /api/favorites/journeys
+type CreateFavoriteJourneyRequest struct {
+	OriginID      string       `json:"originId"`      // Required: departure station
+	DestinationID string       `json:"destinationId"` // Required: arrival station
+	Windows       []TimeWindow `json:"windows"`       // Optional: preferred departure times
+	Nickname      string       `json:"nickname"`      // Optional: custom name (max 100 chars)
+	Notes         string       `json:"notes"`         // Optional: notes (max 500 chars)
+	CollectionID  string       `json:"collectionId"`  // Optional: collection to file it in
+	Tags          []string     `json:"tags"`          // Optional: tags
+}
+
+// UpdateFavoriteJourneyRequest is the request body for PUT /api/favorites/journeys/{id}
+type UpdateFavoriteJourneyRequest struct {
+	Windows  []TimeWindow `json:"windows"`  // Optional: replace the windows (null keeps them)
+	Nickname string       `json:"nickname"` // Optional: update nickname
+	Notes    string       `json:"notes"`    // Optional: update notes
+}
+
+// PatchFavoriteJourneyRequest is the request body for PATCH
+// /api/favorites/journeys/{id}. Only the fields sent are changed.
+type PatchFavoriteJourneyRequest struct {
+	Nickname     *string      `json:"nickname"`
+	Notes        *string      `json:"notes"`
+	Windows      []TimeWindow `json:"windows"`      // replaces the windows
+	CollectionID *string      `json:"collectionId"` // "" takes it out of its collection
+	Tags         *[]string    `json:"tags"`         // replaces the tags; [] removes them
+}
+
+// ============================================================================
+// COLLECTIONS - Grouping and ordering favorites
+// ============================================================================
+
+// FavoriteCollection is a named group of favorites, e.g. "Commute". A
+// collection can hold stations, trains and journeys.
+type FavoriteCollection struct {
+	ID        string `json:"id"`                  // Unique collection ID (UUID)
+	Owner     string `json:"-"`                   // Owning user or device (never sent to clients)
+	Name      string `json:"name"`                // Unique per owner, ignoring case
+	Position  int    `json:"position"`            // Sort position; new collections go last
+	CreatedAt string `json:"createdAt"`           // ISO8601 timestamp
+	UpdatedAt string `json:"updatedAt,omitempty"` // ISO8601 timestamp
+}
+
+// FavoriteCollectionRequest is the request body for creating
+// (POST /api/favorites/collections) and renaming a collection.
+type FavoriteCollectionRequest struct {
+	Name string `json:"name"` // Required (max 50 chars)
+}
+
+// ReorderRequest is the request body of the reorder endpoints: IDs in their
+// new order. The favorites listed swap positions among themselves, so a
+// filtered list (such as one collection) can be reordered on its own.
+type ReorderRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// JourneyConnections is the response of GET /api/favorites/journeys/{id}/next.
+type JourneyConnections struct {
+	Journey     *FavoriteJourney    `json:"journey"`
+	Source      string              `json:"source"`           // "gtfs" (local planner) or the upstream provider
+	Window      *TimeWindow         `json:"window,omitempty"` // preferred window the search starts in
+	Connections []JourneyConnection `json:"connections"`
+	Usual       *JourneyConnection  `json:"usual,omitempty"` // first connection in the window
+	AtRisk      bool                `json:"atRisk"`          // the usual (or else the next) connection is at risk
+}
+
+// ============================================================================
+// DASHBOARD - Current state of all favorites at a glance
+// ============================================================================
+
+// Favorite train statuses on the dashboard.
+const (
+	FavoriteTrainRunning      = "running"      // on its way now
+	FavoriteTrainScheduled    = "scheduled"    // not running; NextRun is set
+	FavoriteTrainNotRunning   = "not_running"  // no run within the lookahead
+	FavoriteTrainDiscontinued = "discontinued" // the pattern matches no trip of the timetable
+	FavoriteTrainUnknown      = "unknown"      // trip not in the timetable (favorites without a pattern)
+)
+
+// FavoritesDashboard is the response of GET /api/favorites/dashboard.
+type FavoritesDashboard struct {
+	Stations []FavoriteStationBoard `json:"stations"`
+	Trains   []FavoriteTrainStatus  `json:"trains"`
+	Journeys []JourneyConnections   `json:"journeys"`
+}
+
+// FavoriteStationBoard is a favorite station with its next departures.
+type FavoriteStationBoard struct {
+	FavoriteID string      `json:"favoriteId"`
+	Nickname   string      `json:"nickname,omitempty"`
+	Station    *Station    `json:"station"` // current timetable data
+	Departures []Departure `json:"departures"`
+}
+
+// FavoriteTrainStatus is the current state of a favorite train: where it
+// is while running, otherwise when it runs next.
+type FavoriteTrainStatus struct {
+	FavoriteID  string          `json:"favoriteId"`
+	TrainID     string          `json:"trainId"`
+	TripID      string          `json:"tripId,omitempty"` // trip of the current or next run
+	Pattern     *ServicePattern `json:"pattern,omitempty"`
+	Nickname    string          `json:"nickname,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	Headsign    string          `json:"headsign,omitempty"`
+	Status      string          `json:"status"` // running, scheduled, not_running, discontinued or unknown
+	Position    *Position       `json:"position,omitempty"`
+	Delay       int             `json:"delay"`                 // minutes (running only)
+	NextStop    *TrainStop      `json:"nextStop,omitempty"`    // next stop not yet departed
+	NextStopETA string          `json:"nextStopEta,omitempty"` // ISO8601, including delay
+	Destination *Station        `json:"destination,omitempty"`
+	ETA         string          `json:"eta,omitempty"` // arrival at the destination, ISO8601, including delay
+	NextRun     *ScheduledRun   `json:"nextRun,omitempty"`
+}
+
+// ScheduledRun is an upcoming run of a train.
+type ScheduledRun struct {
+	Date      string   `json:"date"`      // operating day (YYYY-MM-DD)
+	Departure string   `json:"departure"` // ISO8601
+	From      *Station `json:"from,omitempty"`
+}
+
+// ============================================================================
+// IMPORT / EXPORT - Moving favorites between devices
+// ============================================================================
+
+// FavoritesExport is the file of GET /api/favorites/export. POST
+// /api/favorites/import takes it back, as well as the frontend's
+// localStorage favorites.
+type FavoritesExport struct {
+	Version     int                  `json:"version"`
+	ExportedAt  string               `json:"exportedAt"` // ISO8601 timestamp
+	Collections []FavoriteCollection `json:"collections"`
+	Stations    []FavoriteStation    `json:"stations"`
+	Trains      []FavoriteTrain      `json:"trains"`
+	Journeys    []FavoriteJourney    `json:"journeys"`
+}
+
+// Import item statuses.
+const (
+	ImportCreated   = "created"   // stored as a new favorite
+	ImportDuplicate = "duplicate" // already in favorites or earlier in the file
+	ImportInvalid   = "invalid"   // rejected by validation; Message says why
+	ImportFailed    = "failed"    // the store failed; the item may be retried
+)
+
+// ImportReport is the response of POST /api/favorites/import.
+type ImportReport struct {
+	Total      int            `json:"total"`
+	Created    int            `json:"created"`
+	Duplicates int            `json:"duplicates"`
+	Invalid    int            `json:"invalid"`
+	Failed     int            `json:"failed"`
+	Results    []ImportResult `json:"results"` // one per item, in file order
+}
+
+// ImportResult is the outcome of importing one item.
+type ImportResult struct {
+	Type       string `json:"type"`                 // station, train or journey
+	Index      int    `json:"index"`                // position in the file's list of its type (JSON) or in the file (CSV), from 0
+	Line       int    `json:"line,omitempty"`       // CSV line
+	Key        string `json:"key,omitempty"`        // stationId, trainId or originId>destinationId
+	Status     string `json:"status"`               // created, duplicate, invalid or failed
+	Message    string `json:"message,omitempty"`    // reason for anything but created
+	FavoriteID string `json:"favoriteId,omitempty"` // ID of the created favorite
+}
+
+// ============================================================================
+// IDEMPOTENCY - Safe retries of creates
+// ============================================================================
+
+// IdempotentResponse is the response to a create sent with an
+// Idempotency-Key header, replayed to retries with the same key.
+type IdempotentResponse struct {
+	Owner       string            `json:"-"`           // Owning user or device
+	Key         string            `json:"key"`         // Idempotency-Key of the request
+	Fingerprint string            `json:"fingerprint"` // Hash of the request's method, path and body
+	Status      int               `json:"status"`
+	Header      map[string]string `json:"header"` // Content-Type and ETag
+	Body        []byte            `json:"body"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	ExpiresAt   time.Time         `json:"expiresAt"` // Retries after this create again
 }
 
 // ============================================================================
@@ -29,11 +29,12 @@ type GTFSRoute struct {
 
 // GTFSTrip represents a trip from trips.txt
 type GTFSTrip struct {
-	RouteID      string `csv:"route_id"`
-	ServiceID    string `csv:"service_id"`
-	TripID       string `csv:"trip_id"`
-	TripHeadsign string `csv:"trip_headsign"`
-	DirectionID  string `csv:"direction_id"`
+	RouteID       string `csv:"route_id"`
+	ServiceID     string `csv:"service_id"`
+	TripID        string `csv:"trip_id"`
+	TripHeadsign  string `csv:"trip_headsign"`
+	TripShortName string `csv:"trip_short_name"`
+	DirectionID   string `csv:"direction_id"`
 }
 
 // GTFSStopTime represents a stop time from stop_times.txt
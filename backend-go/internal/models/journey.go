@@ -0,0 +1,37 @@
+// Package models - Journey Domain
+// This file contains connections between two stations, as planned from the
+// GTFS timetable or returned by the upstream timetable API.
+package models
+
+// JourneyLeg is the ride on one train of a connection.
+type JourneyLeg struct {
+	TripID         string   `json:"tripId,omitempty"`
+	Name           string   `json:"name"`
+	Headsign       string   `json:"headsign,omitempty"`
+	From           *Station `json:"from"`
+	To             *Station `json:"to"`
+	Departure      string   `json:"departure"`      // ISO8601, scheduled
+	Arrival        string   `json:"arrival"`        // ISO8601, scheduled
+	DepartureDelay int      `json:"departureDelay"` // minutes
+	ArrivalDelay   int      `json:"arrivalDelay"`   // minutes
+	Platform       string   `json:"platform,omitempty"`
+	Cancelled      bool     `json:"cancelled,omitempty"`
+}
+
+// JourneyConnection is one way to travel between two stations.
+type JourneyConnection struct {
+	From           *Station     `json:"from"`
+	To             *Station     `json:"to"`
+	Departure      string       `json:"departure"`      // ISO8601, scheduled
+	Arrival        string       `json:"arrival"`        // ISO8601, scheduled
+	DepartureDelay int          `json:"departureDelay"` // minutes
+	ArrivalDelay   int          `json:"arrivalDelay"`   // minutes
+	Duration       int          `json:"duration"`       // minutes, scheduled
+	Transfers      int          `json:"transfers"`
+	Products       []string     `json:"products"`
+	Legs           []JourneyLeg `json:"legs,omitempty"` // planned connections only
+	Cancelled      bool         `json:"cancelled,omitempty"`
+	Usual          bool         `json:"usual,omitempty"` // departs in a preferred time window
+	AtRisk         bool         `json:"atRisk"`          // delays or cancellations threaten it
+	Risks          []string     `json:"risks,omitempty"` // why it is at risk
+}
@@ -24,6 +24,8 @@ type TrainStop struct {
 // Train represents a train with its current status and position.
 type Train struct {
 	ID             string      `json:"id"`
+	TripID         string      `json:"tripId,omitempty"`  // GTFS trip, when known
+	RouteID        string      `json:"routeId,omitempty"` // GTFS route, when known
 	Name           string      `json:"name"`
 	Category       string      `json:"category"`
 	Number         string      `json:"number"`
@@ -34,6 +36,7 @@ type Train struct {
 	CurrentStation *Station    `json:"currentStation,omitempty"`
 	Delay          int         `json:"delay"`
 	Cancelled      bool        `json:"cancelled"`
+	Realtime       bool        `json:"realtime,omitempty"` // delays, platforms and cancellation reported upstream
 	Speed          int         `json:"speed"`
 	Direction      int         `json:"direction"`
 	LastUpdate     string      `json:"lastUpdate"`
@@ -42,6 +45,17 @@ type Train struct {
 	Timetable      []TrainStop `json:"timetable,omitempty"`
 }
 
+// TripDetails describes a scheduled GTFS trip and, while it runs, its
+// live state.
+type TripDetails struct {
+	TripID   string      `json:"tripId"`
+	RouteID  string      `json:"routeId"`
+	Name     string      `json:"name"`
+	Headsign string      `json:"headsign"`
+	Stops    []TrainStop `json:"stops"`
+	Live     *Train      `json:"live,omitempty"`
+}
+
 // TrainStats contains aggregated train statistics.
 type TrainStats struct {
 	Total        int            `json:"total"`
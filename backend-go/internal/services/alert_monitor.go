@@ -0,0 +1,103 @@
+// Package services - Alert Monitor
+// This file evaluates alert rules against the live state on an interval
+// and hands new alerts to the webhook dispatcher.
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// AlertMatch is an alert raised by a rule.
+type AlertMatch struct {
+	Rule  models.AlertRule
+	Alert models.Alert
+}
+
+// AlertEvaluator returns the alerts whose conditions hold at now. Events
+// (such as platform changes) are those detected after since.
+type AlertEvaluator func(since, now time.Time) []AlertMatch
+
+// AlertMonitor runs an evaluator on an interval. An alert is delivered when
+// its key first shows up; it is delivered again only after its condition
+// stopped holding for a tick, so a train that stays late notifies once.
+type AlertMonitor struct {
+	evaluate   AlertEvaluator
+	dispatcher *WebhookDispatcher
+	interval   time.Duration
+
+	active map[string]bool // keys of the last tick's alerts
+	last   time.Time
+
+	done chan struct{}
+}
+
+// NewAlertMonitor creates a monitor evaluating every interval.
+func NewAlertMonitor(evaluate AlertEvaluator, dispatcher *WebhookDispatcher, interval time.Duration) *AlertMonitor {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &AlertMonitor{
+		evaluate:   evaluate,
+		dispatcher: dispatcher,
+		interval:   interval,
+		active:     make(map[string]bool),
+		done:       make(chan struct{}),
+	}
+}
+
+// Run starts the monitor's main loop.
+func (m *AlertMonitor) Run() {
+	log.Info().Dur("interval", m.interval).Msg("🔔 Alert monitor started")
+
+	m.last = time.Now()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			m.tick(now)
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Stop stops the monitor.
+func (m *AlertMonitor) Stop() {
+	close(m.done)
+}
+
+// tick evaluates the rules and dispatches the alerts not raised last time.
+func (m *AlertMonitor) tick(now time.Time) {
+	matches := m.evaluate(m.last, now)
+	m.last = now
+
+	active := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		key := match.Alert.Key
+		if active[key] {
+			continue
+		}
+		active[key] = true
+		if m.active[key] {
+			continue
+		}
+
+		alert := match.Alert
+		alert.ID = uuid.New().String()
+		alert.Timestamp = now.Format(time.RFC3339)
+		delivery := m.dispatcher.Dispatch(match.Rule, alert)
+
+		log.Info().
+			Str("rule", match.Rule.ID).
+			Str("condition", alert.Condition).
+			Str("delivery", delivery.ID).
+			Msg("Alert raised")
+	}
+	m.active = active
+}
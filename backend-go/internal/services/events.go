@@ -0,0 +1,357 @@
+// Package services - Train Events
+// This file contains the event detector, which compares successive live
+// snapshots, and the event log serving recent events.
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// stopPhase is where a train is relative to one of its stops.
+type stopPhase int
+
+const (
+	phaseUpcoming stopPhase = iota
+	phaseAt
+	phasePassed
+)
+
+// trainSnapshot is what the detector remembers of a train between ticks.
+type trainSnapshot struct {
+	train  models.Train
+	phases []stopPhase
+}
+
+// EventDetector derives train events from successive live snapshots.
+// The first snapshot only sets the baseline, so a restart doesn't report
+// every running train as departed.
+type EventDetector struct {
+	mu     sync.Mutex
+	nextID uint64
+	last   map[string]*trainSnapshot
+	primed bool
+}
+
+// NewEventDetector creates a detector. Event IDs start from the current
+// time, so they keep increasing when the detector is recreated.
+func NewEventDetector() *EventDetector {
+	return &EventDetector{
+		nextID: uint64(time.Now().UnixMilli()) * 1000,
+		last:   make(map[string]*trainSnapshot),
+	}
+}
+
+// Detect compares the running trains at time at with the previous snapshot
+// and returns what happened in between, in train and stop order.
+func (d *EventDetector) Detect(at time.Time, trains []models.Train) []models.TrainEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var events []models.TrainEvent
+	emit := func(event models.TrainEvent) {
+		d.nextID++
+		event.ID = d.nextID
+		event.Timestamp = at.Format(time.RFC3339)
+		events = append(events, event)
+	}
+
+	midnight := swissMidnight(at)
+	current := make(map[string]*trainSnapshot, len(trains))
+	for i := range trains {
+		snapshot := &trainSnapshot{train: trains[i], phases: stopPhases(&trains[i], at, midnight)}
+		current[trains[i].ID] = snapshot
+
+		if !d.primed {
+			continue
+		}
+		prev := d.last[trains[i].ID]
+		switch {
+		case prev == nil:
+			// A train that just started has been upcoming everywhere
+			prev = &trainSnapshot{train: trains[i], phases: make([]stopPhase, len(snapshot.phases))}
+		case len(prev.phases) != len(snapshot.phases):
+			// Its timetable changed (e.g. seen upstream now): stops can't be
+			// compared, so they start over from where the train is
+			before := prev.train
+			before.Timetable = trains[i].Timetable
+			prev = &trainSnapshot{train: before, phases: snapshot.phases}
+		}
+		detectTrainEvents(prev, snapshot, emit)
+	}
+
+	// A train that left the snapshot right after reaching its last stop
+	// arrived, even if no snapshot caught it at the platform
+	if d.primed {
+		for id, prev := range d.last {
+			if current[id] != nil {
+				continue
+			}
+			last := len(prev.phases) - 1
+			if last > 0 && prev.phases[last] == phaseUpcoming && prev.phases[last-1] == phasePassed {
+				emit(stopEvent(models.EventArrival, &prev.train, last))
+			}
+		}
+	}
+
+	d.last = current
+	d.primed = true
+	return events
+}
+
+// Reset forgets the previous snapshot, so the next one only sets a new
+// baseline. Used when snapshots stop for a while: comparing across the gap
+// would report everything that happened in it as happening now.
+func (d *EventDetector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last = make(map[string]*trainSnapshot)
+	d.primed = false
+}
+
+// detectTrainEvents compares two snapshots of the same train. Delays,
+// platforms and cancellations are only known for trains seen upstream
+// (Realtime); the timetable's are placeholders, so changes are only
+// reported between two realtime snapshots.
+func detectTrainEvents(prev, cur *trainSnapshot, emit func(models.TrainEvent)) {
+	train := &cur.train
+	realtime := train.Realtime && prev.train.Realtime
+
+	if train.Cancelled && !prev.train.Cancelled && train.Realtime {
+		event := trainEvent(models.EventCancellation, train)
+		event.Station = train.CurrentStation
+		emit(event)
+	}
+	if realtime && train.Delay != prev.train.Delay {
+		event := trainEvent(models.EventDelayChange, train)
+		event.Station = train.CurrentStation
+		previous := prev.train.Delay
+		event.PreviousDelay = &previous
+		emit(event)
+	}
+
+	last := len(cur.phases) - 1
+	for i, phase := range cur.phases {
+		before := prev.phases[i]
+		switch {
+		case before == phaseUpcoming && phase == phaseAt:
+			// Trains start at their origin; they don't arrive there
+			if i > 0 {
+				emit(stopEvent(models.EventArrival, train, i))
+			}
+		case before == phaseAt && phase == phasePassed:
+			// ... and end at their destination
+			if i < last {
+				emit(stopEvent(models.EventDeparture, train, i))
+			}
+		case before == phaseUpcoming && phase == phasePassed:
+			// Never seen at the platform: no dwell time, so it passed through
+			switch i {
+			case 0:
+				emit(stopEvent(models.EventDeparture, train, i))
+			case last:
+				emit(stopEvent(models.EventArrival, train, i))
+			default:
+				emit(stopEvent(models.EventPassThrough, train, i))
+			}
+		}
+
+		if realtime && phase != phasePassed && i < len(prev.train.Timetable) {
+			previous := prev.train.Timetable[i].Platform
+			if current := train.Timetable[i].Platform; previous != "" && current != previous {
+				event := stopEvent(models.EventPlatformChange, train, i)
+				event.PreviousPlatform = previous
+				emit(event)
+			}
+		}
+	}
+}
+
+// stopPhases returns where a train is relative to each of its stops;
+// midnight is the Swiss midnight of at's day.
+func stopPhases(train *models.Train, at, midnight time.Time) []stopPhase {
+	phases := make([]stopPhase, len(train.Timetable))
+	for i, stop := range train.Timetable {
+		scheduled := stop.ArrivalTime
+		if scheduled == "" {
+			scheduled = stop.DepartureTime
+		}
+		switch {
+		case stop.IsPassed:
+			phases[i] = phasePassed
+		case untilReached(scheduled, at, midnight):
+			phases[i] = phaseAt
+		default:
+			phases[i] = phaseUpcoming
+		}
+	}
+	return phases
+}
+
+func untilReached(scheduled string, at, midnight time.Time) bool {
+	until, ok := untilGTFSTime(scheduled, at, midnight)
+	return ok && until <= 0
+}
+
+func trainEvent(eventType string, train *models.Train) models.TrainEvent {
+	return models.TrainEvent{
+		Type:    eventType,
+		TrainID: train.ID,
+		RouteID: train.RouteID,
+		Name:    train.Name,
+		Delay:   train.Delay,
+	}
+}
+
+// stopEvent describes an event at the train's i-th stop.
+func stopEvent(eventType string, train *models.Train, i int) models.TrainEvent {
+	stop := train.Timetable[i]
+	event := trainEvent(eventType, train)
+	event.Station = stop.Station
+	event.Origin = i == 0
+	event.Destination = i == len(train.Timetable)-1
+	event.Platform = stop.Platform
+
+	event.Scheduled = stop.DepartureTime
+	if eventType == models.EventArrival || event.Scheduled == "" {
+		event.Scheduled = stop.ArrivalTime
+	}
+	return event
+}
+
+// EventQuery selects events from the log. Zero values match everything.
+type EventQuery struct {
+	AfterID   uint64          // events with a greater ID
+	Since     time.Time       // events detected at or after
+	TrainID   string          // events of this train
+	StationID string          // events at this station
+	Types     map[string]bool // events of these types
+	Limit     int             // at most this many, oldest first (0 for all)
+}
+
+// EventLogStats summarizes the event log.
+type EventLogStats struct {
+	Size     int               `json:"size"`
+	Capacity int               `json:"capacity"`
+	LastID   uint64            `json:"lastId,omitempty"`
+	Total    uint64            `json:"total"`
+	ByType   map[string]uint64 `json:"byType"`
+}
+
+type loggedEvent struct {
+	at    time.Time
+	event models.TrainEvent
+}
+
+// EventLog keeps the most recent train events for queries and counts all
+// events by type.
+type EventLog struct {
+	mu       sync.RWMutex
+	events   []loggedEvent // oldest first
+	capacity int
+	total    uint64
+	byType   map[string]uint64
+
+	lastQuery int64 // Unix nanoseconds of the last Query (atomic)
+}
+
+// NewEventLog creates a log keeping up to capacity events.
+func NewEventLog(capacity int) *EventLog {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &EventLog{
+		capacity: capacity,
+		byType:   make(map[string]uint64),
+	}
+}
+
+// Append adds events, dropping the oldest beyond the capacity. Events with
+// an ID not greater than the last one (e.g. replayed) are ignored.
+func (l *EventLog) Append(events []models.TrainEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, event := range events {
+		if n := len(l.events); n > 0 && event.ID <= l.events[n-1].event.ID {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, event.Timestamp)
+		if err != nil {
+			at = time.Now()
+		}
+		l.events = append(l.events, loggedEvent{at: at, event: event})
+		l.total++
+		l.byType[event.Type]++
+	}
+
+	if excess := len(l.events) - l.capacity; excess > 0 {
+		l.events = append(l.events[:0:0], l.events[excess:]...)
+	}
+}
+
+// Query returns the matching events, oldest first, and how many matched
+// before the limit was applied.
+func (l *EventLog) Query(q EventQuery) ([]models.TrainEvent, int) {
+	atomic.StoreInt64(&l.lastQuery, time.Now().UnixNano())
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	events := make([]models.TrainEvent, 0)
+	total := 0
+	for _, logged := range l.events {
+		event := &logged.event
+		if event.ID <= q.AfterID || logged.at.Before(q.Since) {
+			continue
+		}
+		if q.TrainID != "" && event.TrainID != q.TrainID {
+			continue
+		}
+		if q.StationID != "" && (event.Station == nil || event.Station.ID != q.StationID) {
+			continue
+		}
+		if len(q.Types) > 0 && !q.Types[event.Type] {
+			continue
+		}
+
+		total++
+		if q.Limit <= 0 || len(events) < q.Limit {
+			events = append(events, *event)
+		}
+	}
+	return events, total
+}
+
+// QueriedSince reports whether the log was queried at or after t, i.e.
+// whether anyone still reads the events.
+func (l *EventLog) QueriedSince(t time.Time) bool {
+	return atomic.LoadInt64(&l.lastQuery) >= t.UnixNano()
+}
+
+// Stats returns the log's size and event counts since startup.
+func (l *EventLog) Stats() EventLogStats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := EventLogStats{
+		Size:     len(l.events),
+		Capacity: l.capacity,
+		Total:    l.total,
+		ByType:   make(map[string]uint64, len(l.byType)),
+	}
+	if n := len(l.events); n > 0 {
+		stats.LastID = l.events[n-1].event.ID
+	}
+	for eventType, count := range l.byType {
+		stats.ByType[eventType] = count
+	}
+	return stats
+}
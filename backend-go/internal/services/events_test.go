@@ -0,0 +1,231 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// swissTime returns 14 March 2025 at the given Swiss time.
+func swissTime(hour, min int) time.Time {
+	return time.Date(2025, 3, 14, hour, min, 0, 0, swissLocation)
+}
+
+// stop is a timetable stop at station id; passed marks it as left behind.
+func stop(id, arrival, departure, platform string, passed bool) models.TrainStop {
+	return models.TrainStop{
+		Station:       &models.Station{ID: id, Name: id},
+		ArrivalTime:   arrival,
+		DepartureTime: departure,
+		Platform:      platform,
+		IsPassed:      passed,
+	}
+}
+
+// icTrain is the IC 1 from Zürich HB (09:50) via Olten (10:00-10:02) to
+// Bern (10:30), with the first passed stops marked.
+func icTrain(passed int) models.Train {
+	train := models.Train{
+		ID:   "ic1",
+		Name: "IC 1",
+		Timetable: []models.TrainStop{
+			stop("8503000", "", "09:50:00", "31", false),
+			stop("8500218", "10:00:00", "10:02:00", "7", false),
+			stop("8507000", "10:30:00", "", "5", false),
+		},
+	}
+	for i := 0; i < passed; i++ {
+		train.Timetable[i].IsPassed = true
+	}
+	return train
+}
+
+func TestStopPhases(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		train models.Train
+		at    time.Time
+		want  []stopPhase
+	}{
+		{"before departure", icTrain(0), swissTime(9, 45), []stopPhase{phaseUpcoming, phaseUpcoming, phaseUpcoming}},
+		{"departing", icTrain(0), swissTime(9, 50), []stopPhase{phaseAt, phaseUpcoming, phaseUpcoming}},
+		{"on the way", icTrain(1), swissTime(9, 55), []stopPhase{phasePassed, phaseUpcoming, phaseUpcoming}},
+		{"at a stop", icTrain(1), swissTime(10, 1), []stopPhase{phasePassed, phaseAt, phaseUpcoming}},
+		{"at the destination", icTrain(2), swissTime(10, 30), []stopPhase{phasePassed, phasePassed, phaseAt}},
+		{"past midnight, before", models.Train{Timetable: []models.TrainStop{stop("8503000", "", "23:50:00", "", true), stop("8507000", "24:10:00", "", "", false)}},
+			time.Date(2025, 3, 15, 0, 5, 0, 0, swissLocation), []stopPhase{phasePassed, phaseUpcoming}},
+		{"past midnight, reached", models.Train{Timetable: []models.TrainStop{stop("8503000", "", "23:50:00", "", true), stop("8507000", "24:10:00", "", "", false)}},
+			time.Date(2025, 3, 15, 0, 10, 0, 0, swissLocation), []stopPhase{phasePassed, phaseAt}},
+	} {
+		if got := stopPhases(&tc.train, tc.at, swissMidnight(tc.at)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: phases = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// eventTypes lists the types and station IDs of events.
+func eventTypes(events []models.TrainEvent) []string {
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+		if event.Station != nil {
+			types[i] += "@" + event.Station.ID
+		}
+	}
+	return types
+}
+
+func TestDetectStopEvents(t *testing.T) {
+	d := NewEventDetector()
+
+	// The first snapshot only sets the baseline
+	if events := d.Detect(swissTime(9, 55), []models.Train{icTrain(1)}); len(events) != 0 {
+		t.Fatalf("baseline events = %v", eventTypes(events))
+	}
+
+	for _, step := range []struct {
+		at     time.Time
+		trains []models.Train
+		want   []string
+	}{
+		// At Olten, then gone from it
+		{swissTime(10, 1), []models.Train{icTrain(1)}, []string{"arrival@8500218"}},
+		{swissTime(10, 5), []models.Train{icTrain(2)}, []string{"departure@8500218"}},
+		{swissTime(10, 6), []models.Train{icTrain(2)}, nil},
+		// A train that just started has left its origin, and is at Olten
+		{swissTime(10, 7), []models.Train{icTrain(2), func() models.Train {
+			train := icTrain(1)
+			train.ID = "ic3"
+			return train
+		}()}, []string{"departure@8503000", "arrival@8500218"}},
+		// Gone right after reaching Bern: it arrived
+		{swissTime(10, 31), nil, []string{"arrival@8507000"}},
+	} {
+		events := d.Detect(step.at, step.trains)
+		if got := eventTypes(events); !reflect.DeepEqual(got, step.want) && !(len(got) == 0 && len(step.want) == 0) {
+			t.Errorf("at %s: events = %v, want %v", step.at.Format("15:04"), got, step.want)
+		}
+		for _, event := range events {
+			if event.Timestamp != step.at.Format(time.RFC3339) {
+				t.Errorf("%s detected at %s", event.Type, event.Timestamp)
+			}
+		}
+	}
+
+	// Snapshots that miss the dwell report a pass-through
+	d = NewEventDetector()
+	d.Detect(swissTime(9, 55), []models.Train{icTrain(1)})
+	if got := eventTypes(d.Detect(swissTime(10, 5), []models.Train{icTrain(2)})); !reflect.DeepEqual(got, []string{"pass_through@8500218"}) {
+		t.Errorf("events = %v, want a pass-through at Olten", got)
+	}
+}
+
+func TestDetectRealtimeChanges(t *testing.T) {
+	simulated := icTrain(1)
+	simulated.Delay = 3
+
+	realtime := icTrain(1)
+	realtime.Realtime = true
+	realtime.Delay = 2
+	realtime.Timetable = append(realtime.Timetable, stop("8500010", "11:30:00", "", "", false))
+
+	d := NewEventDetector()
+	d.Detect(swissTime(9, 55), []models.Train{simulated})
+
+	// Seen upstream now: a new timetable and delay, but nothing changed
+	if events := d.Detect(swissTime(9, 56), []models.Train{realtime}); len(events) != 0 {
+		t.Errorf("switching to realtime gave %v", eventTypes(events))
+	}
+
+	// Later, delayed and on another platform at Olten, then cancelled
+	changed := realtime
+	changed.Delay = 5
+	changed.Timetable = append([]models.TrainStop(nil), realtime.Timetable...)
+	changed.Timetable[1].Platform = "9"
+	events := d.Detect(swissTime(9, 57), []models.Train{changed})
+	if got := eventTypes(events); !reflect.DeepEqual(got, []string{"delay_change", "platform_change@8500218"}) {
+		t.Fatalf("events = %v", got)
+	}
+	if events[0].Delay != 5 || events[0].PreviousDelay == nil || *events[0].PreviousDelay != 2 {
+		t.Errorf("delay change = %+v", events[0])
+	}
+	if events[1].Platform != "9" || events[1].PreviousPlatform != "7" || events[1].ID <= events[0].ID {
+		t.Errorf("platform change = %+v", events[1])
+	}
+
+	cancelled := changed
+	cancelled.Cancelled = true
+	if got := eventTypes(d.Detect(swissTime(9, 58), []models.Train{cancelled})); !reflect.DeepEqual(got, []string{"cancellation"}) {
+		t.Errorf("events = %v, want the cancellation", got)
+	}
+
+	// Timetable placeholders never report changes
+	d = NewEventDetector()
+	d.Detect(swissTime(9, 55), []models.Train{simulated})
+	other := simulated
+	other.Delay = 6
+	other.Timetable = append([]models.TrainStop(nil), simulated.Timetable...)
+	other.Timetable[2].Platform = "12"
+	if events := d.Detect(swissTime(9, 56), []models.Train{other}); len(events) != 0 {
+		t.Errorf("simulated train changes gave %v", eventTypes(events))
+	}
+
+	// After a reset the next snapshot is a baseline again
+	d.Reset()
+	if events := d.Detect(swissTime(10, 5), []models.Train{icTrain(2)}); len(events) != 0 {
+		t.Errorf("events after reset = %v", eventTypes(events))
+	}
+}
+
+func TestEventLogQuery(t *testing.T) {
+	log := NewEventLog(3)
+	bern := &models.Station{ID: "8507000"}
+	at := func(min int) string { return swissTime(10, min).Format(time.RFC3339) }
+	log.Append([]models.TrainEvent{
+		{ID: 1, Type: models.EventDeparture, TrainID: "ic1", Timestamp: at(0)},
+		{ID: 2, Type: models.EventArrival, TrainID: "ic1", Station: bern, Timestamp: at(1)},
+	})
+	log.Append([]models.TrainEvent{
+		{ID: 2, Type: models.EventArrival, TrainID: "ic1", Timestamp: at(1)}, // replayed
+		{ID: 3, Type: models.EventDelayChange, TrainID: "ir2", Timestamp: at(2)},
+		{ID: 4, Type: models.EventArrival, TrainID: "ir2", Station: bern, Timestamp: at(3)},
+	})
+
+	ids := func(q EventQuery) ([]uint64, int) {
+		events, total := log.Query(q)
+		ids := make([]uint64, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		return ids, total
+	}
+	for _, tc := range []struct {
+		name  string
+		query EventQuery
+		want  []uint64
+		total int
+	}{
+		{"all kept", EventQuery{}, []uint64{2, 3, 4}, 3},
+		{"since an ID", EventQuery{AfterID: 2}, []uint64{3, 4}, 2},
+		{"since a time", EventQuery{Since: swissTime(10, 2)}, []uint64{3, 4}, 2},
+		{"since a later time", EventQuery{Since: swissTime(10, 2).Add(time.Second)}, []uint64{4}, 1},
+		{"by train", EventQuery{TrainID: "ir2"}, []uint64{3, 4}, 2},
+		{"by station", EventQuery{StationID: "8507000"}, []uint64{2, 4}, 2},
+		{"by type", EventQuery{Types: map[string]bool{models.EventArrival: true}}, []uint64{2, 4}, 2},
+		{"limited", EventQuery{Limit: 1}, []uint64{2}, 3},
+	} {
+		if got, total := ids(tc.query); !reflect.DeepEqual(got, tc.want) || total != tc.total {
+			t.Errorf("%s: events %v of %d, want %v of %d", tc.name, got, total, tc.want, tc.total)
+		}
+	}
+
+	stats := log.Stats()
+	if stats.Size != 3 || stats.Total != 4 || stats.LastID != 4 || stats.ByType[models.EventArrival] != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if !log.QueriedSince(time.Now().Add(-time.Minute)) || log.QueriedSince(time.Now().Add(time.Minute)) {
+		t.Error("QueriedSince doesn't reflect the last query")
+	}
+}
@@ -31,6 +31,8 @@ type GTFSService struct {
 	stopTimes []map[string]string
 	calendar  []map[string]string
 
+	calendarDates []map[string]string
+
 	dataLoaded bool
 	dataPath   string
 
@@ -39,6 +41,13 @@ type GTFSService struct {
 	tripsIndex    map[string]map[string]string
 	routesIndex   map[string]map[string]string
 	agenciesIndex map[string]map[string]string
+
+	stopTimesByTrip    map[string][]map[string]string // sorted by stop_sequence
+	tripsByShortName   map[string][]string            // normalized trip_short_name -> trip IDs
+	tripsByStop        map[string][]string            // stop_id -> trip IDs calling there
+	calendarIndex      map[string]map[string]string   // service_id -> calendar row
+	calendarExceptions map[string]map[string]string   // service_id -> date -> exception_type
+	feedEndDate        string                         // latest calendar end_date (YYYYMMDD)
 }
 
 // NewGTFSService creates a new GTFS service instance.
@@ -51,10 +60,17 @@ func NewGTFSService(dataPath string) *GTFSService {
 		trips:         make([]map[string]string, 0),
 		stopTimes:     make([]map[string]string, 0),
 		calendar:      make([]map[string]string, 0),
+		calendarDates: make([]map[string]string, 0),
 		stopsIndex:    make(map[string]map[string]string),
 		tripsIndex:    make(map[string]map[string]string),
 		routesIndex:   make(map[string]map[string]string),
 		agenciesIndex: make(map[string]map[string]string),
+
+		stopTimesByTrip:    make(map[string][]map[string]string),
+		tripsByShortName:   make(map[string][]string),
+		tripsByStop:        make(map[string][]string),
+		calendarIndex:      make(map[string]map[string]string),
+		calendarExceptions: make(map[string]map[string]string),
 	}
 }
 
@@ -134,13 +150,14 @@ func (s *GTFSService) LoadData() error {
 		*dest = data
 	}
 
-	wg.Add(6)
+	wg.Add(7)
 	go loadFile("agency.txt", &s.agencies)
 	go loadFile("stops.txt", &s.stops)
 	go loadFile("routes.txt", &s.routes)
 	go loadFile("trips.txt", &s.trips)
 	go loadFile("stop_times.txt", &s.stopTimes)
 	go loadFile("calendar.txt", &s.calendar)
+	go loadFile("calendar_dates.txt", &s.calendarDates)
 
 	wg.Wait()
 	close(errChan)
@@ -177,13 +194,53 @@ func (s *GTFSService) buildIndexes() {
 		}
 	}
 
-	// Index trips by trip_id
+	// Index trips by trip_id and trip_short_name
 	for _, trip := range s.trips {
 		if id, ok := trip["trip_id"]; ok {
 			s.tripsIndex[id] = trip
+			if shortName := normalizeShortName(trip["trip_short_name"]); shortName != "" {
+				s.tripsByShortName[shortName] = append(s.tripsByShortName[shortName], id)
+			}
 		}
 	}
 
+	// Group stop_times by trip_id, ordered by stop_sequence
+	for _, st := range s.stopTimes {
+		tripID := st["trip_id"]
+		s.stopTimesByTrip[tripID] = append(s.stopTimesByTrip[tripID], st)
+	}
+	for tripID, tripStops := range s.stopTimesByTrip {
+		sort.Slice(tripStops, func(i, j int) bool {
+			seqI, _ := strconv.Atoi(tripStops[i]["stop_sequence"])
+			seqJ, _ := strconv.Atoi(tripStops[j]["stop_sequence"])
+			return seqI < seqJ
+		})
+		seen := make(map[string]bool, len(tripStops))
+		for _, st := range tripStops {
+			if stopID := st["stop_id"]; !seen[stopID] {
+				seen[stopID] = true
+				s.tripsByStop[stopID] = append(s.tripsByStop[stopID], tripID)
+			}
+		}
+	}
+
+	// Index service calendars and their exceptions
+	for _, cal := range s.calendar {
+		if id, ok := cal["service_id"]; ok {
+			s.calendarIndex[id] = cal
+			if cal["end_date"] > s.feedEndDate {
+				s.feedEndDate = cal["end_date"]
+			}
+		}
+	}
+	for _, cd := range s.calendarDates {
+		id := cd["service_id"]
+		if s.calendarExceptions[id] == nil {
+			s.calendarExceptions[id] = make(map[string]string)
+		}
+		s.calendarExceptions[id][cd["date"]] = cd["exception_type"]
+	}
+
 	// Index routes by route_id
 	for _, route := range s.routes {
 		if id, ok := route["route_id"]; ok {
@@ -269,7 +326,11 @@ func (s *GTFSService) GetStations(limit, offset int) ([]models.Station, int) {
 func (s *GTFSService) GetStationByID(id string) *models.Station {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.stationByID(id)
+}
 
+// stationByID is GetStationByID. Callers must hold s.mu.
+func (s *GTFSService) stationByID(id string) *models.Station {
 	stop, exists := s.stopsIndex[id]
 	if !exists {
 		return nil
@@ -382,8 +443,17 @@ func haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	return math.Round(R*c*100) / 100
 }
 
+// MaxStationDepartures limits GetStationDepartures.
+const MaxStationDepartures = 20
+
 // GetStationDepartures returns departures from a station.
 func (s *GTFSService) GetStationDepartures(stopID string) *models.StationDepartures {
+	return s.GetStationDeparturesAt(stopID, time.Now())
+}
+
+// GetStationDeparturesAt returns the departures from a station after the
+// time of day of at.
+func (s *GTFSService) GetStationDeparturesAt(stopID string, at time.Time) *models.StationDepartures {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -392,8 +462,8 @@ func (s *GTFSService) GetStationDepartures(stopID string) *models.StationDepartu
 		return nil
 	}
 
-	loc, _ := time.LoadLocation("Europe/Zurich")
-	currentTime := time.Now().In(loc).Format("15:04:05")
+	at = at.In(swissLocation)
+	currentTime := at.Format("15:04:05")
 
 	var departures []models.Departure
 
@@ -443,22 +513,22 @@ func (s *GTFSService) GetStationDepartures(stopID string) *models.StationDepartu
 			ArrivalTime:   st["arrival_time"],
 			Sequence:      seq,
 		})
-
-		if len(departures) >= 20 {
-			break
-		}
 	}
 
-	// Sort by departure time
+	// Sort by departure time, then keep the next ones (stop times aren't
+	// ordered by time, so capping while collecting would skip departures)
 	sort.Slice(departures, func(i, j int) bool {
 		return departures[i].DepartureTime < departures[j].DepartureTime
 	})
+	if len(departures) > MaxStationDepartures {
+		departures = departures[:MaxStationDepartures]
+	}
 
 	return &models.StationDepartures{
 		Station:    station,
 		Departures: departures,
 		Count:      len(departures),
-		Timestamp:  s.getSwissTime(),
+		Timestamp:  at.Format("1/2/2006, 15:04:05"),
 	}
 }
 
@@ -480,6 +550,15 @@ func (s *GTFSService) GetLiveTrains() []models.Train {
 
 // GetLiveTrainsWithMultiplier returns real-time train positions with time multiplier support.
 func (s *GTFSService) GetLiveTrainsWithMultiplier(multiplier float64) []models.Train {
+	return s.GetLiveTrainsMatching(multiplier, MaxLiveTrains, nil)
+}
+
+// MaxLiveTrains limits GetLiveTrains for performance.
+const MaxLiveTrains = 30
+
+// GetLiveTrainsMatching returns the running trains accepted by match (nil
+// accepts all), at most limit trains (0 for no limit).
+func (s *GTFSService) GetLiveTrainsMatching(multiplier float64, limit int, match func(*models.Train) bool) []models.Train {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -487,301 +566,385 @@ func (s *GTFSService) GetLiveTrainsWithMultiplier(multiplier float64) []models.T
 		return []models.Train{}
 	}
 
-	// Get current Swiss time
-	loc, _ := time.LoadLocation("Europe/Zurich")
-	now := time.Now().In(loc)
+	now, effectiveMinutes := liveClock(multiplier)
+	return s.collectTrains(now, effectiveMinutes, false, limit, match)
+}
 
-	// Apply time multiplier by offsetting minutes from midnight
-	baseMinutes := now.Hour()*60 + now.Minute()
-	effectiveMinutes := int(float64(baseMinutes)*multiplier) % (24 * 60)
-	if effectiveMinutes < 0 {
-		effectiveMinutes += 24 * 60
+// GetTrainsAt returns the trains running at a given (simulated) time, like
+// GetLiveTrainsMatching. Only trips whose service runs on that day count.
+func (s *GTFSService) GetTrainsAt(at time.Time, limit int, match func(*models.Train) bool) []models.Train {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.trips) == 0 {
+		return []models.Train{}
 	}
 
-	currentSeconds := now.Second()
+	now, effectiveMinutes := simulatedClock(at)
+	return s.collectTrains(now, effectiveMinutes, true, limit, match)
+}
 
+// collectTrains builds the trains running at the effective time. With
+// byCalendar, trips not in service on now's day are skipped. Callers must
+// hold s.mu.
+func (s *GTFSService) collectTrains(now time.Time, effectiveMinutes int, byCalendar bool, limit int, match func(*models.Train) bool) []models.Train {
 	var trains []models.Train
 
-	// Group stop_times by trip_id for efficient lookup
-	tripStopTimes := make(map[string][]map[string]string)
-	for _, st := range s.stopTimes {
-		tripID := st["trip_id"]
-		tripStopTimes[tripID] = append(tripStopTimes[tripID], st)
+	var day time.Time
+	if byCalendar {
+		day = swissMidnight(now)
 	}
 
 	// Process each trip and find active trains
-	trainCount := 0
-	maxTrains := 30 // Limit for performance
-
-	for tripID, tripStops := range tripStopTimes {
-		if trainCount >= maxTrains {
+	for tripID, tripStops := range s.stopTimesByTrip {
+		if limit > 0 && len(trains) >= limit {
 			break
 		}
 
-		if len(tripStops) < 2 {
-			continue
+		if byCalendar {
+			trip := s.tripsIndex[tripID]
+			if trip == nil || !s.isServiceActive(trip["service_id"], day) {
+				continue
+			}
 		}
 
-		trip := s.tripsIndex[tripID]
-		if trip == nil {
+		train, ok := s.buildLiveTrain(tripID, tripStops, now, effectiveMinutes)
+		if !ok || (match != nil && !match(train)) {
 			continue
 		}
 
-		route := s.routesIndex[trip["route_id"]]
-		if route == nil {
-			continue
-		}
+		trains = append(trains, *train)
+	}
 
-		// Sort stops by sequence
-		sort.Slice(tripStops, func(i, j int) bool {
-			seqI, _ := strconv.Atoi(tripStops[i]["stop_sequence"])
-			seqJ, _ := strconv.Atoi(tripStops[j]["stop_sequence"])
-			return seqI < seqJ
-		})
+	// Sort trains by name for consistent ordering
+	sort.Slice(trains, func(i, j int) bool {
+		return trains[i].Name < trains[j].Name
+	})
 
-		// Get first departure time and last arrival time
-		firstDeparture := tripStops[0]["departure_time"]
-		lastArrival := tripStops[len(tripStops)-1]["arrival_time"]
+	return trains
+}
 
-		if firstDeparture == "" || lastArrival == "" {
-			continue
-		}
+// GetLiveTrain returns the live state of a single trip, or nil if the trip
+// is unknown or not running right now.
+func (s *GTFSService) GetLiveTrain(tripID string) *models.Train {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		tripStartMinutes := parseTimeToMinutes(firstDeparture)
-		tripEndMinutes := parseTimeToMinutes(lastArrival)
+	tripStops, ok := s.stopTimesByTrip[tripID]
+	if !ok {
+		return nil
+	}
 
-		if tripStartMinutes < 0 || tripEndMinutes < 0 {
-			continue
-		}
+	now, effectiveMinutes := liveClock(1.0)
+	train, ok := s.buildLiveTrain(tripID, tripStops, now, effectiveMinutes)
+	if !ok {
+		return nil
+	}
+	return train
+}
 
-		// Check if this train is currently active
-		if effectiveMinutes < tripStartMinutes || effectiveMinutes > tripEndMinutes {
-			continue
-		}
+// GetTrainAt returns the state of a single trip at a given (simulated) time,
+// or nil if the trip is unknown, not in service that day or not running.
+func (s *GTFSService) GetTrainAt(tripID string, at time.Time) *models.Train {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		// Find current position between stops
-		var fromStopIdx, toStopIdx int
-		var fromMinutes, toMinutes int
+	tripStops, ok := s.stopTimesByTrip[tripID]
+	trip := s.tripsIndex[tripID]
+	if !ok || trip == nil {
+		return nil
+	}
 
-		for i := 0; i < len(tripStops)-1; i++ {
-			depTime := tripStops[i]["departure_time"]
-			nextArrTime := tripStops[i+1]["arrival_time"]
+	now, effectiveMinutes := simulatedClock(at)
+	if !s.isServiceActive(trip["service_id"], swissMidnight(now)) {
+		return nil
+	}
 
-			if depTime == "" {
-				depTime = tripStops[i]["arrival_time"]
-			}
-			if nextArrTime == "" {
-				nextArrTime = tripStops[i+1]["departure_time"]
-			}
+	train, ok := s.buildLiveTrain(tripID, tripStops, now, effectiveMinutes)
+	if !ok {
+		return nil
+	}
+	return train
+}
 
-			fromMins := parseTimeToMinutes(depTime)
-			toMins := parseTimeToMinutes(nextArrTime)
+// simulatedClock returns a time in Swiss local time and its minutes from
+// midnight.
+func simulatedClock(at time.Time) (time.Time, int) {
+	now := at.In(swissMidnight(at).Location())
+	return now, now.Hour()*60 + now.Minute()
+}
 
-			if fromMins >= 0 && toMins >= 0 && effectiveMinutes >= fromMins && effectiveMinutes <= toMins {
-				fromStopIdx = i
-				toStopIdx = i + 1
-				fromMinutes = fromMins
-				toMinutes = toMins
-				break
-			}
-		}
+// liveClock returns the current Swiss time and the effective minutes from
+// midnight after applying the time multiplier.
+func liveClock(multiplier float64) (time.Time, int) {
+	// Get current Swiss time
+	loc, _ := time.LoadLocation("Europe/Zurich")
+	now := time.Now().In(loc)
 
-		// If we found a valid segment, interpolate position
-		fromStop := s.stopsIndex[tripStops[fromStopIdx]["stop_id"]]
-		toStop := s.stopsIndex[tripStops[toStopIdx]["stop_id"]]
+	// Apply time multiplier by offsetting minutes from midnight
+	baseMinutes := now.Hour()*60 + now.Minute()
+	effectiveMinutes := int(float64(baseMinutes)*multiplier) % (24 * 60)
+	if effectiveMinutes < 0 {
+		effectiveMinutes += 24 * 60
+	}
 
-		if fromStop == nil || toStop == nil {
-			continue
-		}
+	return now, effectiveMinutes
+}
 
-		fromLat, _ := strconv.ParseFloat(fromStop["stop_lat"], 64)
-		fromLon, _ := strconv.ParseFloat(fromStop["stop_lon"], 64)
-		toLat, _ := strconv.ParseFloat(toStop["stop_lat"], 64)
-		toLon, _ := strconv.ParseFloat(toStop["stop_lon"], 64)
-
-		// Calculate interpolation progress
-		var progress float64
-		segmentDuration := toMinutes - fromMinutes
-		if segmentDuration > 0 {
-			elapsedInSegment := effectiveMinutes - fromMinutes
-			progress = float64(elapsedInSegment) / float64(segmentDuration)
-			// Add second-level precision
-			secondProgress := float64(currentSeconds) / 60.0 / float64(segmentDuration)
-			progress += secondProgress
-			if progress > 1.0 {
-				progress = 1.0
-			}
-		}
+// buildLiveTrain computes position and timetable state of a trip at the
+// effective time. Callers must hold s.mu.
+func (s *GTFSService) buildLiveTrain(tripID string, tripStops []map[string]string, now time.Time, effectiveMinutes int) (*models.Train, bool) {
+	currentSeconds := now.Second()
 
-		// Interpolate position
-		currentLat := fromLat + (toLat-fromLat)*progress
-		currentLon := fromLon + (toLon-fromLon)*progress
+	if len(tripStops) < 2 {
+		return nil, false
+	}
 
-		// Calculate direction (bearing)
-		direction := calculateBearing(fromLat, fromLon, toLat, toLon)
+	trip := s.tripsIndex[tripID]
+	if trip == nil {
+		return nil, false
+	}
 
-		// Calculate speed based on distance and time
-		distance := haversineDistance(fromLat, fromLon, toLat, toLon)
-		var speed int
-		if segmentDuration > 0 {
-			speed = int(distance / (float64(segmentDuration) / 60.0)) // km/h
-		}
-		if speed < 20 {
-			speed = 60 + rand.Intn(40) // Default speed for short segments
-		}
-		if speed > 200 {
-			speed = 160 + rand.Intn(40) // Cap high-speed trains
-		}
+	route := s.routesIndex[trip["route_id"]]
+	if route == nil {
+		return nil, false
+	}
+
+	// Get first departure time and last arrival time
+	firstDeparture := tripStops[0]["departure_time"]
+	lastArrival := tripStops[len(tripStops)-1]["arrival_time"]
+
+	if firstDeparture == "" || lastArrival == "" {
+		return nil, false
+	}
+
+	tripStartMinutes := parseTimeToMinutes(firstDeparture)
+	tripEndMinutes := parseTimeToMinutes(lastArrival)
+
+	if tripStartMinutes < 0 || tripEndMinutes < 0 {
+		return nil, false
+	}
+
+	// Check if this train is currently active
+	if effectiveMinutes < tripStartMinutes || effectiveMinutes > tripEndMinutes {
+		return nil, false
+	}
+
+	// Find current position between stops
+	var fromStopIdx, toStopIdx int
+	var fromMinutes, toMinutes int
 
-		// Get first and last stops
-		firstStop := s.stopsIndex[tripStops[0]["stop_id"]]
-		lastStop := s.stopsIndex[tripStops[len(tripStops)-1]["stop_id"]]
+	for i := 0; i < len(tripStops)-1; i++ {
+		depTime := tripStops[i]["departure_time"]
+		nextArrTime := tripStops[i+1]["arrival_time"]
 
-		fromName := "Unknown"
-		toName := "Unknown"
-		if firstStop != nil {
-			fromName = firstStop["stop_name"]
+		if depTime == "" {
+			depTime = tripStops[i]["arrival_time"]
 		}
-		if lastStop != nil {
-			toName = lastStop["stop_name"]
+		if nextArrTime == "" {
+			nextArrTime = tripStops[i+1]["departure_time"]
 		}
 
-		routeShortName := route["route_short_name"]
-		if routeShortName == "" {
-			routeShortName = "Train"
+		fromMins := parseTimeToMinutes(depTime)
+		toMins := parseTimeToMinutes(nextArrTime)
+
+		if fromMins >= 0 && toMins >= 0 && effectiveMinutes >= fromMins && effectiveMinutes <= toMins {
+			fromStopIdx = i
+			toStopIdx = i + 1
+			fromMinutes = fromMins
+			toMinutes = toMins
+			break
 		}
+	}
 
-		agencyName := "SBB"
-		if agency := s.agenciesIndex[route["agency_id"]]; agency != nil {
-			agencyName = agency["agency_name"]
+	// If we found a valid segment, interpolate position
+	fromStop := s.stopsIndex[tripStops[fromStopIdx]["stop_id"]]
+	toStop := s.stopsIndex[tripStops[toStopIdx]["stop_id"]]
+
+	if fromStop == nil || toStop == nil {
+		return nil, false
+	}
+
+	fromLat, _ := strconv.ParseFloat(fromStop["stop_lat"], 64)
+	fromLon, _ := strconv.ParseFloat(fromStop["stop_lon"], 64)
+	toLat, _ := strconv.ParseFloat(toStop["stop_lat"], 64)
+	toLon, _ := strconv.ParseFloat(toStop["stop_lon"], 64)
+
+	// Calculate interpolation progress
+	var progress float64
+	segmentDuration := toMinutes - fromMinutes
+	if segmentDuration > 0 {
+		elapsedInSegment := effectiveMinutes - fromMinutes
+		progress = float64(elapsedInSegment) / float64(segmentDuration)
+		// Add second-level precision
+		secondProgress := float64(currentSeconds) / 60.0 / float64(segmentDuration)
+		progress += secondProgress
+		if progress > 1.0 {
+			progress = 1.0
 		}
+	}
 
-		// Build timetable with correct passed/current status based on effective time
-		timetable := make([]models.TrainStop, len(tripStops))
+	// Interpolate position
+	currentLat := fromLat + (toLat-fromLat)*progress
+	currentLon := fromLon + (toLon-fromLon)*progress
 
-		// Current effective time in seconds for precise comparison
-		effectiveTimeSeconds := effectiveMinutes*60 + currentSeconds
+	// Calculate direction (bearing)
+	direction := calculateBearing(fromLat, fromLon, toLat, toLon)
 
-		// First pass: mark all passed stations
-		for i, ts := range tripStops {
-			stop := s.stopsIndex[ts["stop_id"]]
-			var station *models.Station
-			if stop != nil {
-				lat, _ := strconv.ParseFloat(stop["stop_lat"], 64)
-				lon, _ := strconv.ParseFloat(stop["stop_lon"], 64)
-				station = &models.Station{
-					ID:         stop["stop_id"],
-					Name:       stop["stop_name"],
-					Coordinate: models.Coordinate{X: lon, Y: lat},
-				}
-			}
+	// Calculate speed based on distance and time
+	distance := haversineDistance(fromLat, fromLon, toLat, toLon)
+	var speed int
+	if segmentDuration > 0 {
+		speed = int(distance / (float64(segmentDuration) / 60.0)) // km/h
+	}
+	if speed < 20 {
+		speed = 60 + rand.Intn(40) // Default speed for short segments
+	}
+	if speed > 200 {
+		speed = 160 + rand.Intn(40) // Cap high-speed trains
+	}
 
-			// Parse times
-			stopArrMinutes := parseTimeToMinutes(ts["arrival_time"])
-			stopDepMinutes := parseTimeToMinutes(ts["departure_time"])
+	// Get first and last stops
+	firstStop := s.stopsIndex[tripStops[0]["stop_id"]]
+	lastStop := s.stopsIndex[tripStops[len(tripStops)-1]["stop_id"]]
 
-			// For first stop, use departure time as arrival
-			if stopArrMinutes < 0 && stopDepMinutes >= 0 {
-				stopArrMinutes = stopDepMinutes
-			}
-			// For last stop, use arrival time as departure
-			if stopDepMinutes < 0 && stopArrMinutes >= 0 {
-				stopDepMinutes = stopArrMinutes
-			}
+	fromName := "Unknown"
+	toName := "Unknown"
+	if firstStop != nil {
+		fromName = firstStop["stop_name"]
+	}
+	if lastStop != nil {
+		toName = lastStop["stop_name"]
+	}
 
-			isPassed := false
-			isCurrent := false
+	routeShortName := route["route_short_name"]
+	if routeShortName == "" {
+		routeShortName = "Train"
+	}
 
-			// Determine status based on effective time
-			if stopDepMinutes >= 0 {
-				stopDepSeconds := stopDepMinutes * 60
-				stopArrSeconds := stopArrMinutes * 60
+	agencyName := "SBB"
+	if agency := s.agenciesIndex[route["agency_id"]]; agency != nil {
+		agencyName = agency["agency_name"]
+	}
 
-				if effectiveTimeSeconds > stopDepSeconds {
-					// Train has departed from this station
-					isPassed = true
-				} else if stopArrSeconds >= 0 && effectiveTimeSeconds >= stopArrSeconds && effectiveTimeSeconds <= stopDepSeconds {
-					// Train is currently at this station (stopped at platform)
-					isCurrent = true
-				}
-			}
+	// Build timetable with correct passed/current status based on effective time
+	timetable := make([]models.TrainStop, len(tripStops))
 
-			platform := strconv.Itoa((i % 10) + 1) // Deterministic platform based on index
+	// Current effective time in seconds for precise comparison
+	effectiveTimeSeconds := effectiveMinutes*60 + currentSeconds
 
-			timetable[i] = models.TrainStop{
-				Station:          station,
-				ArrivalTime:      ts["arrival_time"],
-				DepartureTime:    ts["departure_time"],
-				Platform:         platform,
-				IsCurrentStation: isCurrent,
-				IsPassed:         isPassed,
-				IsSkipped:        false,
+	// First pass: mark all passed stations
+	for i, ts := range tripStops {
+		stop := s.stopsIndex[ts["stop_id"]]
+		var station *models.Station
+		if stop != nil {
+			lat, _ := strconv.ParseFloat(stop["stop_lat"], 64)
+			lon, _ := strconv.ParseFloat(stop["stop_lon"], 64)
+			station = &models.Station{
+				ID:         stop["stop_id"],
+				Name:       stop["stop_name"],
+				Coordinate: models.Coordinate{X: lon, Y: lat},
 			}
 		}
 
-		// Second pass: if no station is marked as current, mark the next upcoming station
-		hasCurrentStation := false
-		for _, stop := range timetable {
-			if stop.IsCurrentStation {
-				hasCurrentStation = true
-				break
-			}
+		// Parse times
+		stopArrMinutes := parseTimeToMinutes(ts["arrival_time"])
+		stopDepMinutes := parseTimeToMinutes(ts["departure_time"])
+
+		// For first stop, use departure time as arrival
+		if stopArrMinutes < 0 && stopDepMinutes >= 0 {
+			stopArrMinutes = stopDepMinutes
+		}
+		// For last stop, use arrival time as departure
+		if stopDepMinutes < 0 && stopArrMinutes >= 0 {
+			stopDepMinutes = stopArrMinutes
 		}
 
-		if !hasCurrentStation {
-			// Find the first non-passed station and mark it as current
-			for i := range timetable {
-				if !timetable[i].IsPassed {
-					timetable[i].IsCurrentStation = true
-					break
-				}
+		isPassed := false
+		isCurrent := false
+
+		// Determine status based on effective time
+		if stopDepMinutes >= 0 {
+			stopDepSeconds := stopDepMinutes * 60
+			stopArrSeconds := stopArrMinutes * 60
+
+			if effectiveTimeSeconds > stopDepSeconds {
+				// Train has departed from this station
+				isPassed = true
+			} else if stopArrSeconds >= 0 && effectiveTimeSeconds >= stopArrSeconds && effectiveTimeSeconds <= stopDepSeconds {
+				// Train is currently at this station (stopped at platform)
+				isCurrent = true
 			}
 		}
 
-		// Determine current station
-		var currentStation *models.Station
-		if progress < 0.5 {
-			currentStation = timetable[fromStopIdx].Station
-		} else {
-			currentStation = timetable[toStopIdx].Station
-		}
-
-		// Generate consistent delay based on trip ID
-		tripHash := 0
-		for _, c := range tripID {
-			tripHash += int(c)
-		}
-		delay := tripHash % 7 // 0-6 minutes delay
-
-		trains = append(trains, models.Train{
-			ID:             tripID,
-			Name:           routeShortName,
-			Category:       strings.Split(routeShortName, " ")[0],
-			Number:         tripID,
-			Operator:       agencyName,
-			From:           fromName,
-			To:             toName,
-			Position:       &models.Position{Lat: currentLat, Lng: currentLon},
-			CurrentStation: currentStation,
-			Delay:          delay,
-			Cancelled:      false,
-			Speed:          speed,
-			Direction:      direction,
-			LastUpdate:     now.Format(time.RFC3339),
-			DepartureTime:  tripStops[0]["departure_time"],
-			ArrivalTime:    tripStops[len(tripStops)-1]["arrival_time"],
-			Timetable:      timetable,
-		})
+		platform := strconv.Itoa((i % 10) + 1) // Deterministic platform based on index
 
-		trainCount++
+		timetable[i] = models.TrainStop{
+			Station:          station,
+			ArrivalTime:      ts["arrival_time"],
+			DepartureTime:    ts["departure_time"],
+			Platform:         platform,
+			IsCurrentStation: isCurrent,
+			IsPassed:         isPassed,
+			IsSkipped:        false,
+		}
 	}
 
-	// Sort trains by name for consistent ordering
-	sort.Slice(trains, func(i, j int) bool {
-		return trains[i].Name < trains[j].Name
-	})
+	// Second pass: if no station is marked as current, mark the next upcoming station
+	hasCurrentStation := false
+	for _, stop := range timetable {
+		if stop.IsCurrentStation {
+			hasCurrentStation = true
+			break
+		}
+	}
 
-	return trains
+	if !hasCurrentStation {
+		// Find the first non-passed station and mark it as current
+		for i := range timetable {
+			if !timetable[i].IsPassed {
+				timetable[i].IsCurrentStation = true
+				break
+			}
+		}
+	}
+
+	// Determine current station
+	var currentStation *models.Station
+	if progress < 0.5 {
+		currentStation = timetable[fromStopIdx].Station
+	} else {
+		currentStation = timetable[toStopIdx].Station
+	}
+
+	// Generate consistent delay based on trip ID
+	tripHash := 0
+	for _, c := range tripID {
+		tripHash += int(c)
+	}
+	delay := tripHash % 7 // 0-6 minutes delay
+
+	return &models.Train{
+		ID:             tripID,
+		TripID:         tripID,
+		RouteID:        trip["route_id"],
+		Name:           routeShortName,
+		Category:       strings.Split(routeShortName, " ")[0],
+		Number:         tripID,
+		Operator:       agencyName,
+		From:           fromName,
+		To:             toName,
+		Position:       &models.Position{Lat: currentLat, Lng: currentLon},
+		CurrentStation: currentStation,
+		Delay:          delay,
+		Cancelled:      false,
+		Speed:          speed,
+		Direction:      direction,
+		LastUpdate:     now.Format(time.RFC3339),
+		DepartureTime:  tripStops[0]["departure_time"],
+		ArrivalTime:    tripStops[len(tripStops)-1]["arrival_time"],
+		Timetable:      timetable,
+	}, true
 }
 
 // calculateBearing calculates the bearing between two coordinates
@@ -834,3 +997,204 @@ func (s *GTFSService) GetTrainStats() models.TrainStats {
 
 	return stats
 }
+
+// normalizeShortName normalizes a trip_short_name / line name for lookups,
+// e.g. " ic  1" -> "IC 1".
+func normalizeShortName(name string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(name), " "))
+}
+
+// parseTimeToSeconds converts HH:MM:SS to seconds from midnight.
+// GTFS allows hours >= 24 for trips running past midnight.
+func parseTimeToSeconds(timeStr string) int {
+	parts := strings.Split(timeStr, ":")
+	if len(parts) < 2 {
+		return -1
+	}
+	hours, _ := strconv.Atoi(parts[0])
+	minutes, _ := strconv.Atoi(parts[1])
+	seconds := 0
+	if len(parts) > 2 {
+		seconds, _ = strconv.Atoi(parts[2])
+	}
+	return hours*3600 + minutes*60 + seconds
+}
+
+// UntilGTFSTime returns how long from now until a GTFS time (HH:MM:SS,
+// hours may exceed 24) on the nearest service day. ok is false if the time
+// can't be parsed.
+func UntilGTFSTime(timeStr string, now time.Time) (until time.Duration, ok bool) {
+	return untilGTFSTime(timeStr, now, swissMidnight(now))
+}
+
+// untilGTFSTime is UntilGTFSTime with the Swiss midnight of now's day given,
+// for callers converting many times at once.
+func untilGTFSTime(timeStr string, now, midnight time.Time) (until time.Duration, ok bool) {
+	seconds := parseTimeToSeconds(timeStr)
+	if seconds < 0 {
+		return 0, false
+	}
+
+	until = midnight.Add(time.Duration(seconds) * time.Second).Sub(now)
+	// Trips past midnight belong to the previous service day
+	if until > 12*time.Hour {
+		until -= 24 * time.Hour
+	} else if until < -12*time.Hour {
+		until += 24 * time.Hour
+	}
+	return until, true
+}
+
+// OperatingDay returns the start (Swiss midnight) of the calendar day t
+// falls on in Swiss local time.
+func OperatingDay(t time.Time) time.Time {
+	return swissMidnight(t)
+}
+
+// swissLocation is Swiss local time (UTC if the zone database is missing),
+// loaded once: lookups read the zone database every time.
+var swissLocation = func() *time.Location {
+	loc, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+// swissMidnight returns midnight of t's day in Swiss local time.
+func swissMidnight(t time.Time) time.Time {
+	t = t.In(swissLocation)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, swissLocation)
+}
+
+// IsServiceActive reports whether a GTFS service runs on the given day.
+func (s *GTFSService) IsServiceActive(serviceID string, day time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.isServiceActive(serviceID, day)
+}
+
+// isServiceActive checks calendar_dates exceptions first, then calendar.txt.
+// Once the feed has expired (day after the last end_date) only the weekday
+// pattern is used, so an old timetable keeps matching today's trains.
+// Callers must hold s.mu.
+func (s *GTFSService) isServiceActive(serviceID string, day time.Time) bool {
+	date := day.Format("20060102")
+
+	switch s.calendarExceptions[serviceID][date] {
+	case "1":
+		return true
+	case "2":
+		return false
+	}
+
+	cal := s.calendarIndex[serviceID]
+	if cal == nil {
+		// No calendar entry: assume the service runs daily
+		return true
+	}
+
+	weekday := strings.ToLower(day.Weekday().String())
+	if cal[weekday] != "1" {
+		return false
+	}
+
+	if date > s.feedEndDate {
+		return true
+	}
+	return date >= cal["start_date"] && date <= cal["end_date"]
+}
+
+// GetTrip returns a trip by its ID.
+func (s *GTFSService) GetTrip(tripID string) *models.GTFSTrip {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trip := s.tripsIndex[tripID]
+	if trip == nil {
+		return nil
+	}
+
+	return &models.GTFSTrip{
+		RouteID:       trip["route_id"],
+		ServiceID:     trip["service_id"],
+		TripID:        trip["trip_id"],
+		TripHeadsign:  trip["trip_headsign"],
+		TripShortName: trip["trip_short_name"],
+		DirectionID:   trip["direction_id"],
+	}
+}
+
+// GetTripStopTimes returns the stop times of a trip ordered by sequence.
+func (s *GTFSService) GetTripStopTimes(tripID string) []models.GTFSStopTime {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tripStops := s.stopTimesByTrip[tripID]
+	stopTimes := make([]models.GTFSStopTime, len(tripStops))
+	for i, st := range tripStops {
+		stopTimes[i] = models.GTFSStopTime{
+			TripID:        st["trip_id"],
+			ArrivalTime:   st["arrival_time"],
+			DepartureTime: st["departure_time"],
+			StopID:        st["stop_id"],
+			StopSequence:  st["stop_sequence"],
+		}
+	}
+
+	return stopTimes
+}
+
+// MatchTrip finds the trip with one of the given short names that is
+// scheduled to depart stopID closest to scheduled, within tolerance.
+// It returns the trip ID and the trip's operating day (Swiss midnight).
+func (s *GTFSService) MatchTrip(shortNames []string, stopID string, scheduled time.Time, tolerance time.Duration) (string, time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var bestTrip string
+	var bestDay time.Time
+	bestDiff := tolerance + 1
+
+	today := swissMidnight(scheduled)
+
+	for _, name := range shortNames {
+		for _, tripID := range s.tripsByShortName[normalizeShortName(name)] {
+			trip := s.tripsIndex[tripID]
+
+			for _, st := range s.stopTimesByTrip[tripID] {
+				if st["stop_id"] != stopID {
+					continue
+				}
+
+				depTime := st["departure_time"]
+				if depTime == "" {
+					depTime = st["arrival_time"]
+				}
+				secs := parseTimeToSeconds(depTime)
+				if secs < 0 {
+					continue
+				}
+
+				// Trips running past midnight belong to the previous operating day
+				for _, day := range []time.Time{today, today.AddDate(0, 0, -1)} {
+					if !s.isServiceActive(trip["service_id"], day) {
+						continue
+					}
+
+					diff := day.Add(time.Duration(secs) * time.Second).Sub(scheduled)
+					if diff < 0 {
+						diff = -diff
+					}
+					if diff < bestDiff {
+						bestTrip = tripID
+						bestDay = day
+						bestDiff = diff
+					}
+				}
+			}
+		}
+	}
+
+	return bestTrip, bestDay, bestTrip != ""
+}
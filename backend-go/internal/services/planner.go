@@ -0,0 +1,229 @@
+// Package services - Journey Planner
+// This file plans connections between two stops from the GTFS timetable:
+// direct trains and trains with one change. It is meant for a handful of
+// favorite journeys, not as a general router; the upstream timetable API
+// covers everything else.
+package services
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// MinTransfer is the time a change between trains needs.
+const MinTransfer = 3 * time.Minute
+
+// plannedLeg is a ride on a trip between two of its stops (indexes into
+// the trip's stop times).
+type plannedLeg struct {
+	tripID      string
+	board, exit int
+	dep, arr    int // seconds after the operating day's midnight
+}
+
+// PlanConnections returns connections from one stop to another departing
+// after from on from's operating day, by departure. Connections that leave
+// earlier but arrive no sooner than another are dropped.
+func (s *GTFSService) PlanConnections(fromID, toID string, from time.Time, limit int) []models.JourneyConnection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	connections := make([]models.JourneyConnection, 0)
+	if fromID == toID || s.stopsIndex[fromID] == nil || s.stopsIndex[toID] == nil {
+		return connections
+	}
+
+	day := swissMidnight(from)
+	after := int(from.Sub(day).Seconds())
+	active := func(tripID string) bool {
+		trip := s.tripsIndex[tripID]
+		return trip != nil && s.isServiceActive(trip["service_id"], day)
+	}
+
+	var plans [][]plannedLeg
+	for _, tripID := range s.tripsByStop[fromID] {
+		if !active(tripID) {
+			continue
+		}
+		board := s.stopIndex(tripID, fromID, 0)
+		first, ok := s.rideFrom(tripID, board, after)
+		if !ok {
+			continue
+		}
+
+		// Direct, or change at a later stop to a train that reaches toID
+		tripStops := s.stopTimesByTrip[tripID]
+		for exit := board + 1; exit < len(tripStops); exit++ {
+			leg := first
+			leg.exit = exit
+			leg.arr = stopSeconds(tripStops[exit], "arrival_time")
+			if leg.arr < 0 {
+				continue
+			}
+			stopID := tripStops[exit]["stop_id"]
+			if stopID == toID {
+				plans = append(plans, []plannedLeg{leg})
+				break
+			}
+			if second, ok := s.bestRide(stopID, toID, leg.arr+int(MinTransfer.Seconds()), tripID, active); ok {
+				plans = append(plans, []plannedLeg{leg, second})
+			}
+		}
+	}
+
+	plans = fastestPlans(plans)
+	for _, plan := range plans {
+		if limit > 0 && len(connections) >= limit {
+			break
+		}
+		connections = append(connections, s.buildConnection(plan, day))
+	}
+	return connections
+}
+
+// rideFrom returns a leg boarding a trip at its board-th stop, if the trip
+// departs there at or after after (seconds).
+func (s *GTFSService) rideFrom(tripID string, board, after int) (plannedLeg, bool) {
+	tripStops := s.stopTimesByTrip[tripID]
+	if board < 0 || board >= len(tripStops)-1 {
+		return plannedLeg{}, false
+	}
+	dep := stopSeconds(tripStops[board], "departure_time")
+	if dep < after {
+		return plannedLeg{}, false
+	}
+	return plannedLeg{tripID: tripID, board: board, dep: dep}, true
+}
+
+// bestRide returns the trip from stopID that reaches toID earliest,
+// departing at or after after (seconds). skip is the trip already ridden.
+func (s *GTFSService) bestRide(stopID, toID string, after int, skip string, active func(string) bool) (plannedLeg, bool) {
+	var best plannedLeg
+	found := false
+	for _, tripID := range s.tripsByStop[stopID] {
+		if tripID == skip || !active(tripID) {
+			continue
+		}
+		board := s.stopIndex(tripID, stopID, 0)
+		leg, ok := s.rideFrom(tripID, board, after)
+		if !ok {
+			continue
+		}
+		exit := s.stopIndex(tripID, toID, board+1)
+		if exit < 0 {
+			continue
+		}
+		leg.exit = exit
+		leg.arr = stopSeconds(s.stopTimesByTrip[tripID][exit], "arrival_time")
+		if leg.arr >= 0 && (!found || leg.arr < best.arr) {
+			best, found = leg, true
+		}
+	}
+	return best, found
+}
+
+// stopIndex returns where a trip calls at stopID, from index start on, or -1.
+func (s *GTFSService) stopIndex(tripID, stopID string, start int) int {
+	tripStops := s.stopTimesByTrip[tripID]
+	for i := start; i < len(tripStops); i++ {
+		if tripStops[i]["stop_id"] == stopID {
+			return i
+		}
+	}
+	return -1
+}
+
+// stopSeconds returns a stop time's field (falling back to the other one)
+// in seconds after midnight, or -1.
+func stopSeconds(st map[string]string, field string) int {
+	value := st[field]
+	if value == "" {
+		if field == "arrival_time" {
+			value = st["departure_time"]
+		} else {
+			value = st["arrival_time"]
+		}
+	}
+	if value == "" {
+		return -1
+	}
+	return parseTimeToSeconds(value)
+}
+
+// fastestPlans sorts plans by departure and drops those another plan beats:
+// departing no earlier and arriving no later, with no more changes.
+func fastestPlans(plans [][]plannedLeg) [][]plannedLeg {
+	sort.SliceStable(plans, func(i, j int) bool {
+		di, dj := plans[i][0].dep, plans[j][0].dep
+		if di != dj {
+			return di < dj
+		}
+		ai, aj := plans[i][len(plans[i])-1].arr, plans[j][len(plans[j])-1].arr
+		if ai != aj {
+			return ai < aj
+		}
+		return len(plans[i]) < len(plans[j])
+	})
+
+	kept := make([][]plannedLeg, 0, len(plans))
+	for i, plan := range plans {
+		dep, arr := plan[0].dep, plan[len(plan)-1].arr
+		beaten := false
+		for j, other := range plans {
+			if i == j {
+				continue
+			}
+			odep, oarr := other[0].dep, other[len(other)-1].arr
+			if odep >= dep && oarr <= arr && len(other) <= len(plan) &&
+				(odep > dep || oarr < arr || len(other) < len(plan) || j < i) {
+				beaten = true
+				break
+			}
+		}
+		if !beaten {
+			kept = append(kept, plan)
+		}
+	}
+	return kept
+}
+
+// buildConnection turns a plan into a connection with scheduled times.
+func (s *GTFSService) buildConnection(plan []plannedLeg, day time.Time) models.JourneyConnection {
+	at := func(seconds int) string {
+		return day.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339)
+	}
+
+	conn := models.JourneyConnection{
+		Transfers: len(plan) - 1,
+		Products:  make([]string, 0, len(plan)),
+		Legs:      make([]models.JourneyLeg, 0, len(plan)),
+	}
+	for _, leg := range plan {
+		tripStops := s.stopTimesByTrip[leg.tripID]
+		trip := s.tripsIndex[leg.tripID]
+		name := trip["trip_short_name"]
+		if route := s.routesIndex[trip["route_id"]]; route != nil && name == "" {
+			name = route["route_short_name"]
+		}
+		conn.Products = append(conn.Products, name)
+		conn.Legs = append(conn.Legs, models.JourneyLeg{
+			TripID:    leg.tripID,
+			Name:      name,
+			Headsign:  trip["trip_headsign"],
+			From:      s.stationByID(tripStops[leg.board]["stop_id"]),
+			To:        s.stationByID(tripStops[leg.exit]["stop_id"]),
+			Departure: at(leg.dep),
+			Arrival:   at(leg.arr),
+			Platform:  strconv.Itoa((leg.board % 10) + 1), // as buildLiveTrain
+		})
+	}
+
+	first, last := conn.Legs[0], conn.Legs[len(conn.Legs)-1]
+	conn.From, conn.To = first.From, last.To
+	conn.Departure, conn.Arrival = first.Departure, last.Arrival
+	conn.Duration = (plan[len(plan)-1].arr - plan[0].dep) / 60
+	return conn
+}
@@ -0,0 +1,543 @@
+// Package services contains the search.ch timetable API integration.
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// SearchChService handles search.ch timetable API integration.
+// API Documentation: https://search.ch/timetable/api/help
+// Rate Limit: 1000 route and 10000 stationboard requests per 24 hours
+//
+// Responses are mapped onto the transport.opendata.ch shapes
+// (StationBoardResponse, ConnectionsResponse) so it can be used as a
+// drop-in TransportProvider.
+type SearchChService struct {
+	baseURL    string
+	httpClient *http.Client
+
+	// Rate limiting (per endpoint)
+	mu            sync.Mutex
+	requestCounts map[string]int
+	windowStart   time.Time
+}
+
+// NewSearchChService creates a new search.ch timetable API service.
+func NewSearchChService(baseURL string) *SearchChService {
+	return &SearchChService{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		requestCounts: make(map[string]int),
+		windowStart:   time.Now(),
+	}
+}
+
+// searchChLimits are the daily request quotas per endpoint.
+var searchChLimits = map[string]int{
+	"/route.json":        1000,
+	"/stationboard.json": 10000,
+	"/completion.json":   10000,
+}
+
+// searchChTimeLayout is the timestamp format used by search.ch.
+const searchChTimeLayout = "2006-01-02 15:04:05"
+
+// openDataTimeLayout is the timestamp format used by transport.opendata.ch.
+const openDataTimeLayout = "2006-01-02T15:04:05-0700"
+
+// Name returns the provider name.
+func (s *SearchChService) Name() string {
+	return ProviderSearchCh
+}
+
+// checkRateLimit checks if we're within the endpoint's API rate limit.
+func (s *SearchChService) checkRateLimit(endpoint string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Reset counters if window has passed
+	if time.Since(s.windowStart) > rateLimitWindow {
+		s.requestCounts = make(map[string]int)
+		s.windowStart = time.Now()
+	}
+
+	if s.requestCounts[endpoint] >= searchChLimits[endpoint] {
+		log.Warn().Str("endpoint", endpoint).Msg("search.ch API rate limit exceeded")
+		return false
+	}
+
+	s.requestCounts[endpoint]++
+	return true
+}
+
+// GetRateLimitStatus returns current rate limit status per endpoint.
+func (s *SearchChService) GetRateLimitStatus() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoints := make(map[string]interface{}, len(searchChLimits))
+	for endpoint, limit := range searchChLimits {
+		endpoints[endpoint] = map[string]interface{}{
+			"used":      s.requestCounts[endpoint],
+			"limit":     limit,
+			"remaining": limit - s.requestCounts[endpoint],
+		}
+	}
+
+	return map[string]interface{}{
+		"endpoints":  endpoints,
+		"reset_time": s.windowStart.Add(rateLimitWindow).Format(time.RFC3339),
+	}
+}
+
+// apiRequest makes a request to the search.ch timetable API.
+func (s *SearchChService) apiRequest(endpoint string, params url.Values) ([]byte, error) {
+	if !s.checkRateLimit(endpoint) {
+		return nil, fmt.Errorf("rate limit exceeded, please try again later")
+	}
+
+	reqURL, err := url.Parse(s.baseURL + endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	reqURL.RawQuery = params.Encode()
+
+	log.Debug().Str("url", reqURL.String()).Msg("🚂 Fetching from search.ch API")
+
+	req, err := http.NewRequest(http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "SwissRailwayNetwork-Go/1.0.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error: %d %s - %s", resp.StatusCode, resp.Status, string(body))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return body, nil
+}
+
+// searchChMessages carries the error messages search.ch returns with HTTP 200.
+type searchChMessages struct {
+	Messages []string `json:"messages"`
+}
+
+// err returns the upstream error messages as an error, if any.
+func (m searchChMessages) err() error {
+	if len(m.Messages) == 0 {
+		return nil
+	}
+	return fmt.Errorf("search.ch: %s", strings.Join(m.Messages, "; "))
+}
+
+// ============================================================================
+// COMPLETION - /completion.json
+// ============================================================================
+
+// searchChCompletion is one entry of the /completion.json response.
+type searchChCompletion struct {
+	Label     string  `json:"label"`
+	ID        string  `json:"id"`
+	IconClass string  `json:"iconclass"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	Dist      float64 `json:"dist"`
+}
+
+// SearchStations searches for stations by name using the completion endpoint.
+func (s *SearchChService) SearchStations(query string) ([]models.Station, error) {
+	params := url.Values{}
+	params.Set("term", query)
+	params.Set("show_ids", "1")
+	params.Set("show_coordinates", "1")
+
+	body, err := s.apiRequest("/completion.json", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []searchChCompletion
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	stations := make([]models.Station, 0, len(resp))
+	for _, c := range resp {
+		// Addresses and POIs have no stop ID
+		if c.ID == "" {
+			continue
+		}
+
+		station := models.Station{
+			ID:   c.ID,
+			Name: c.Label,
+			Coordinate: models.Coordinate{
+				X: c.Lon,
+				Y: c.Lat,
+			},
+		}
+		if c.Dist > 0 {
+			dist := c.Dist
+			station.Distance = &dist
+		}
+		stations = append(stations, station)
+	}
+
+	return stations, nil
+}
+
+// ============================================================================
+// STATIONBOARD - /stationboard.json
+// ============================================================================
+
+// searchChStop is a stop as returned by the stationboard endpoint.
+type searchChStop struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+// searchChSubsequentStop is a stop after the requested station.
+type searchChSubsequentStop struct {
+	searchChStop
+	Arr      string `json:"arr"`
+	Dep      string `json:"dep"`
+	ArrDelay string `json:"arr_delay"`
+	DepDelay string `json:"dep_delay"`
+}
+
+// searchChBoardEntry is one departure of the /stationboard.json response.
+type searchChBoardEntry struct {
+	Time            string                   `json:"time"`
+	Category        string                   `json:"*G"`
+	Line            string                   `json:"*L"`
+	Number          string                   `json:"*Z"`
+	Type            string                   `json:"type"`
+	LineName        string                   `json:"line"`
+	Operator        string                   `json:"operator"`
+	Terminal        searchChStop             `json:"terminal"`
+	Track           string                   `json:"track"`
+	DepDelay        string                   `json:"dep_delay"`
+	SubsequentStops []searchChSubsequentStop `json:"subsequent_stops"`
+}
+
+// searchChStationBoard is the /stationboard.json response.
+type searchChStationBoard struct {
+	searchChMessages
+	Stop        searchChStop         `json:"stop"`
+	Connections []searchChBoardEntry `json:"connections"`
+}
+
+// GetStationBoard retrieves the departure board for a station.
+func (s *SearchChService) GetStationBoard(stationID string, limit int) (*StationBoardResponse, error) {
+	params := url.Values{}
+	params.Set("stop", stationID)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("mode", "depart")
+	params.Set("show_delays", "1")
+	params.Set("show_tracks", "1")
+	params.Set("show_subsequent_stops", "1")
+
+	body, err := s.apiRequest("/stationboard.json", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var board searchChStationBoard
+	if err := json.Unmarshal(body, &board); err != nil {
+		return nil, fmt.Errorf("failed to parse stationboard response: %w", err)
+	}
+	if err := board.err(); err != nil {
+		return nil, err
+	}
+
+	station := board.Stop.toTransportStation()
+	resp := &StationBoardResponse{
+		Station:      station,
+		Stationboard: make([]StationBoardJourney, 0, len(board.Connections)),
+	}
+
+	for _, c := range board.Connections {
+		number := strings.TrimLeft(c.Number, "0")
+		delay, cancelled := parseSearchChDelay(c.DepDelay)
+
+		platform, changed := parseSearchChTrack(c.Track)
+		stop := StationBoardStop{
+			Station:   station,
+			Departure: searchChTime(c.Time),
+			Delay:     delay,
+			Platform:  platform,
+		}
+		if changed {
+			stop.Prognosis = &StationBoardPrognosis{Platform: platform}
+		}
+
+		passList := make([]PassListStop, 0, len(c.SubsequentStops))
+		for _, sub := range c.SubsequentStops {
+			arrDelay, _ := parseSearchChDelay(sub.ArrDelay)
+			passList = append(passList, PassListStop{
+				Station:   sub.toTransportStation(),
+				Arrival:   searchChTime(sub.Arr),
+				Departure: searchChTime(sub.Dep),
+				Delay:     arrDelay,
+			})
+		}
+
+		name := c.LineName
+		if name == "" {
+			name = strings.TrimSpace(c.Category + " " + c.Line)
+		}
+
+		resp.Stationboard = append(resp.Stationboard, StationBoardJourney{
+			Stop:      stop,
+			Name:      name,
+			Category:  c.Category,
+			Number:    number,
+			Operator:  c.Operator,
+			To:        c.Terminal.Name,
+			PassList:  passList,
+			Cancelled: cancelled,
+		})
+	}
+
+	return resp, nil
+}
+
+// toTransportStation converts a search.ch stop into the opendata station shape.
+func (st searchChStop) toTransportStation() TransportStation {
+	return TransportStation{
+		ID:   st.ID,
+		Name: st.Name,
+		Coordinate: TransportCoordinate{
+			X: st.Lon,
+			Y: st.Lat,
+		},
+	}
+}
+
+// ============================================================================
+// ROUTE - /route.json
+// ============================================================================
+
+// searchChExit is where a leg is left.
+type searchChExit struct {
+	Arrival  string  `json:"arrival"`
+	StopID   string  `json:"stopid"`
+	Name     string  `json:"name"`
+	Track    string  `json:"track"`
+	ArrDelay string  `json:"arr_delay"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+}
+
+// searchChLeg is one leg of a search.ch connection.
+type searchChLeg struct {
+	Departure string        `json:"departure"`
+	Arrival   string        `json:"arrival"`
+	TripID    string        `json:"tripid"`
+	StopID    string        `json:"stopid"`
+	Name      string        `json:"name"`
+	Type      string        `json:"type"`
+	Line      string        `json:"line"`
+	Terminal  string        `json:"terminal"`
+	Operator  string        `json:"operator"`
+	Track     string        `json:"track"`
+	DepDelay  string        `json:"dep_delay"`
+	Exit      *searchChExit `json:"exit"`
+	Lat       float64       `json:"lat"`
+	Lon       float64       `json:"lon"`
+}
+
+// searchChConnection is one connection of the /route.json response.
+type searchChConnection struct {
+	From      string        `json:"from"`
+	Departure string        `json:"departure"`
+	To        string        `json:"to"`
+	Arrival   string        `json:"arrival"`
+	Duration  float64       `json:"duration"`
+	Legs      []searchChLeg `json:"legs"`
+}
+
+// searchChRoute is the /route.json response. one_to_many requests wrap
+// the connections in per-destination results.
+type searchChRoute struct {
+	searchChMessages
+	Connections []searchChConnection `json:"connections"`
+	Results     []struct {
+		Connections []searchChConnection `json:"connections"`
+	} `json:"results"`
+}
+
+// GetConnections retrieves connections between two stations.
+func (s *SearchChService) GetConnections(from, to string, limit int) (*ConnectionsResponse, error) {
+	params := url.Values{}
+	params.Set("from", from)
+	params.Set("to", to)
+	params.Set("num", strconv.Itoa(limit))
+	params.Set("show_delays", "1")
+
+	body, err := s.apiRequest("/route.json", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var route searchChRoute
+	if err := json.Unmarshal(body, &route); err != nil {
+		return nil, fmt.Errorf("failed to parse connections response: %w", err)
+	}
+	if err := route.err(); err != nil {
+		return nil, err
+	}
+
+	connections := route.Connections
+	if len(connections) == 0 && len(route.Results) > 0 {
+		connections = route.Results[0].Connections
+	}
+
+	resp := &ConnectionsResponse{Connections: make([]Connection, 0, len(connections))}
+	for _, c := range connections {
+		if len(c.Legs) == 0 {
+			continue
+		}
+		resp.Connections = append(resp.Connections, c.toConnection())
+	}
+
+	return resp, nil
+}
+
+// toConnection converts a search.ch connection into the opendata shape.
+// Legs with an exit are rides; the final leg without one is the destination.
+func (c searchChConnection) toConnection() Connection {
+	first := c.Legs[0]
+	last := c.Legs[len(c.Legs)-1]
+
+	conn := Connection{
+		From: ConnectionDeparture{
+			Station: TransportStation{
+				ID:         first.StopID,
+				Name:       c.From,
+				Coordinate: TransportCoordinate{X: first.Lon, Y: first.Lat},
+			},
+			Departure: derefString(searchChTime(c.Departure)),
+			Platform:  first.Track,
+		},
+		To: ConnectionArrival{
+			Station: TransportStation{
+				ID:         last.StopID,
+				Name:       c.To,
+				Coordinate: TransportCoordinate{X: last.Lon, Y: last.Lat},
+			},
+			Arrival: derefString(searchChTime(c.Arrival)),
+		},
+		Duration: formatOpenDataDuration(time.Duration(c.Duration) * time.Second),
+		Products: []string{},
+	}
+
+	rides := 0
+	for _, leg := range c.Legs {
+		if leg.Exit == nil || leg.Type == "walk" {
+			continue
+		}
+		depDelay, cancelled := parseSearchChDelay(leg.DepDelay)
+		if rides == 0 {
+			conn.From.Delay = depDelay
+		}
+		conn.Cancelled = conn.Cancelled || cancelled
+		rides++
+		conn.Products = append(conn.Products, leg.Line)
+		conn.To.Platform, _ = parseSearchChTrack(leg.Exit.Track)
+		conn.To.Delay, _ = parseSearchChDelay(leg.Exit.ArrDelay)
+	}
+	if rides > 0 {
+		conn.Transfers = rides - 1
+	}
+
+	return conn
+}
+
+// ============================================================================
+// HELPERS
+// ============================================================================
+
+// searchChTime converts a search.ch local timestamp into opendata format.
+// Returns nil for empty or unparsable values.
+func searchChTime(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		loc = time.UTC
+	}
+
+	t, err := time.ParseInLocation(searchChTimeLayout, value, loc)
+	if err != nil {
+		return nil
+	}
+
+	formatted := t.Format(openDataTimeLayout)
+	return &formatted
+}
+
+// parseSearchChDelay parses delays like "+3" or "-1" (minutes).
+// search.ch reports cancellations as "X"; cancelled is true in that case.
+func parseSearchChDelay(value string) (delay int, cancelled bool) {
+	value = strings.TrimSpace(value)
+	if value == "X" {
+		return 0, true
+	}
+	delay, _ = strconv.Atoi(strings.TrimPrefix(value, "+"))
+	return delay, false
+}
+
+// parseSearchChTrack strips the "!" search.ch appends to changed tracks.
+func parseSearchChTrack(value string) (track string, changed bool) {
+	return strings.TrimSuffix(value, "!"), strings.HasSuffix(value, "!")
+}
+
+// formatOpenDataDuration formats a duration like "00d01:02:00".
+func formatOpenDataDuration(d time.Duration) string {
+	total := int(d.Seconds())
+	days := total / 86400
+	hours := (total % 86400) / 3600
+	minutes := (total % 3600) / 60
+	seconds := total % 60
+	return fmt.Sprintf("%02dd%02d:%02d:%02d", days, hours, minutes, seconds)
+}
+
+// derefString returns the pointed-to string or "".
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
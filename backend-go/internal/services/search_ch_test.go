@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// routeSample is a search.ch one_to_many route.json response from Bern,
+// kept with the timetable data. Its first line comments are the request.
+const routeSample = "../../../data-swiss/gtfs-out/json/journey-train-bern-to-basel.json"
+
+// readRouteSample returns the sample without its comment lines.
+func readRouteSample(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(routeSample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	for len(lines) > 0 && strings.HasPrefix(lines[0], "/*") {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// searchChServer serves body for every request and counts them.
+func searchChServer(t *testing.T, status int, body string) (*SearchChService, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return NewSearchChService(server.URL), &requests
+}
+
+func TestSearchChRouteSample(t *testing.T) {
+	sample := readRouteSample(t)
+	s, _ := searchChServer(t, http.StatusOK, sample)
+
+	resp, err := s.GetConnections("Bern", "Basel SBB", 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Connections) != 6 {
+		t.Fatalf("connections = %d, want the 6 to Basel SBB", len(resp.Connections))
+	}
+	first := resp.Connections[0]
+	if first.From.Station.ID != "8507000" || first.From.Departure != "2025-12-14T07:04:00+0100" || first.From.Platform != "5" {
+		t.Errorf("from = %+v", first.From)
+	}
+	if first.To.Station.Name != "Basel SBB" || first.To.Arrival != "2025-12-14T08:00:00+0100" || first.To.Platform != "10" {
+		t.Errorf("to = %+v", first.To)
+	}
+	if first.Duration != "00d00:56:00" || first.Transfers != 0 || len(first.Products) != 1 || first.Products[0] != "ICE" || first.Cancelled {
+		t.Errorf("connection = %+v", first)
+	}
+
+	// The first ride cancelled ("X") and arriving on a changed track ("!")
+	changed := strings.Replace(sample, `"tripid": "T2026_000374_000011_101_b6570c6_0",`,
+		`"tripid": "T2026_000374_000011_101_b6570c6_0", "dep_delay": "X",`, 1)
+	changed = strings.Replace(changed, `"track": "10",`, `"track": "10!",`, 1)
+	if changed == sample {
+		t.Fatal("sample changed upstream; update the test")
+	}
+	s, _ = searchChServer(t, http.StatusOK, changed)
+	resp, err = s.GetConnections("Bern", "Basel SBB", 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := resp.Connections[0]; !first.Cancelled || first.From.Delay != 0 || first.To.Platform != "10" {
+		t.Errorf("changed connection = %+v, want cancelled arriving on 10", first)
+	}
+	if resp.Connections[1].Cancelled {
+		t.Error("the next connection is cancelled too")
+	}
+}
+
+func TestSearchChStationBoard(t *testing.T) {
+	s, _ := searchChServer(t, http.StatusOK, `{
+		"stop": {"id": "8507000", "name": "Bern", "lat": 46.948832, "lon": 7.439136},
+		"connections": [
+			{"time": "2025-12-14 07:04:00", "*G": "ICE", "*Z": "000374", "line": "ICE", "operator": "SBB",
+			 "terminal": {"id": "8500090", "name": "Basel Bad Bf"}, "track": "5", "dep_delay": "+3",
+			 "subsequent_stops": [{"id": "8500218", "name": "Olten", "arr": "2025-12-14 07:30:00", "dep": "2025-12-14 07:33:00", "arr_delay": "+4"}]},
+			{"time": "2025-12-14 07:33:00", "*G": "IC", "*L": "61", "*Z": "000608", "operator": "SBB",
+			 "terminal": {"id": "8500010", "name": "Basel SBB"}, "track": "7!", "dep_delay": "X"}
+		]}`)
+
+	board, err := s.GetStationBoard("8507000", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(board.Stationboard) != 2 || board.Station.Name != "Bern" {
+		t.Fatalf("board = %+v", board)
+	}
+	ice, ic := board.Stationboard[0], board.Stationboard[1]
+	if ice.Number != "374" || ice.Stop.Delay != 3 || ice.Cancelled || ice.Stop.Prognosis != nil ||
+		len(ice.PassList) != 1 || ice.PassList[0].Delay != 4 {
+		t.Errorf("ICE = %+v", ice)
+	}
+	if ic.Name != "IC 61" || !ic.Cancelled || ic.Stop.Platform != "7" ||
+		ic.Stop.Prognosis == nil || ic.Stop.Prognosis.Platform != "7" {
+		t.Errorf("IC = %+v, want cancelled on changed track 7", ic)
+	}
+
+	// Errors come with status 200
+	s, _ = searchChServer(t, http.StatusOK, `{"messages": ["Stop not found"]}`)
+	if _, err := s.GetStationBoard("0", 2); err == nil || !strings.Contains(err.Error(), "Stop not found") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+// fakeProvider answers every request with its stations or err, and records
+// the stationboards asked for.
+type fakeProvider struct {
+	name     string
+	stations []models.Station
+	err      error
+	calls    int
+	boards   []string
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) SearchStations(string) ([]models.Station, error) {
+	p.calls++
+	return p.stations, p.err
+}
+
+func (p *fakeProvider) GetStationBoard(stationID string, _ int) (*StationBoardResponse, error) {
+	p.calls++
+	p.boards = append(p.boards, stationID)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &StationBoardResponse{Station: TransportStation{Name: p.name}}, nil
+}
+
+func (p *fakeProvider) GetConnections(string, string, int) (*ConnectionsResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &ConnectionsResponse{}, nil
+}
+
+func (p *fakeProvider) GetRateLimitStatus() map[string]interface{} { return nil }
+
+func TestFallbackTransport(t *testing.T) {
+	// search.ch failing upstream falls back to the secondary provider
+	primary, requests := searchChServer(t, http.StatusServiceUnavailable, "down")
+	secondary := &fakeProvider{name: "opendata"}
+	chain := NewFallbackTransport(primary, secondary)
+	if chain.Name() != "searchch+opendata" {
+		t.Errorf("name = %s", chain.Name())
+	}
+	board, err := chain.GetStationBoard("8507000", 5)
+	if err != nil || board.Station.Name != "opendata" || *requests != 1 || secondary.calls != 1 {
+		t.Errorf("board = %+v, %v after %d requests; want the secondary's", board, err, *requests)
+	}
+
+	// A working primary is the only one asked
+	working := &fakeProvider{name: "searchch", stations: []models.Station{{ID: "8507000"}}}
+	secondary.calls = 0
+	chain = NewFallbackTransport(working, secondary)
+	if stations, err := chain.SearchStations("Bern"); err != nil || len(stations) != 1 || secondary.calls != 0 {
+		t.Errorf("stations = %v, %v; secondary asked %d times", stations, err, secondary.calls)
+	}
+
+	// Without a secondary the primary's error is returned
+	down := errors.New("down")
+	chain = NewFallbackTransport(&fakeProvider{name: "searchch", err: down}, nil)
+	if _, err := chain.GetConnections("Bern", "Basel SBB", 3); !errors.Is(err, down) {
+		t.Errorf("error = %v, want the primary's", err)
+	}
+}
@@ -0,0 +1,232 @@
+// Package services - Service Patterns
+// This file resolves recurring services ("the IC 1 at 07:32 from
+// St. Gallen on weekdays") to GTFS trips. Trip IDs change with every
+// timetable, so favorite trains keep the pattern and look up the trip of
+// the day when they need one.
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// weekOrder lists the weekdays of service patterns from Monday.
+var weekOrder = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday,
+	time.Friday, time.Saturday, time.Sunday,
+}
+
+// DayName returns the pattern name of a weekday ("mon").
+func DayName(day time.Weekday) string {
+	return strings.ToLower(day.String()[:3])
+}
+
+// AllDays returns the names of every weekday, from Monday.
+func AllDays() []string {
+	days := make([]string, 0, len(weekOrder))
+	for _, weekday := range weekOrder {
+		days = append(days, DayName(weekday))
+	}
+	return days
+}
+
+// NormalizePatternDays validates the days of a service pattern ("mon" to
+// "sun", case-insensitive) and returns them in week order without
+// duplicates.
+func NormalizePatternDays(days []string) ([]string, error) {
+	if len(days) == 0 {
+		return nil, fmt.Errorf("at least one day is required")
+	}
+	set := make(map[string]bool, len(days))
+	for _, day := range days {
+		set[strings.ToLower(strings.TrimSpace(day))] = true
+	}
+
+	normalized := make([]string, 0, len(set))
+	for _, weekday := range weekOrder {
+		if name := DayName(weekday); set[name] {
+			normalized = append(normalized, name)
+			delete(set, name)
+		}
+	}
+	for day := range set {
+		return nil, fmt.Errorf("unknown day %q (expected mon, tue, wed, thu, fri, sat or sun)", day)
+	}
+	return normalized, nil
+}
+
+// patternTimeFormat matches HH:MM and HH:MM:SS.
+var patternTimeFormat = regexp.MustCompile(`^[0-9]{1,2}:[0-5][0-9](:[0-5][0-9])?$`)
+
+// NormalizePatternTime validates a departure time of a service pattern
+// (HH:MM, or HH:MM:SS with seconds dropped; hours may exceed 23 for trains
+// after midnight) and returns it as HH:MM.
+func NormalizePatternTime(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !patternTimeFormat.MatchString(value) {
+		return "", fmt.Errorf("departure time %q must be HH:MM", value)
+	}
+	seconds := parseTimeToSeconds(value)
+	return fmt.Sprintf("%02d:%02d", seconds/3600, seconds/60%60), nil
+}
+
+// patternRunsOn reports whether a pattern's days include day's weekday.
+func patternRunsOn(p models.ServicePattern, day time.Time) bool {
+	name := DayName(day.Weekday())
+	for _, d := range p.Days {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tripDeparture returns the departure time of a trip at its first stop.
+func tripDeparture(tripStops []map[string]string) string {
+	if len(tripStops) == 0 {
+		return ""
+	}
+	if dep := tripStops[0]["departure_time"]; dep != "" {
+		return dep
+	}
+	return tripStops[0]["arrival_time"]
+}
+
+// PatternOf returns the service pattern of a trip, with the weekdays its
+// service runs on in calendar.txt, or nil if the trip is unknown. Services
+// without weekdays (calendar_dates.txt only) get every day; the calendar
+// still decides which days they run.
+func (s *GTFSService) PatternOf(tripID string) *models.ServicePattern {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trip := s.tripsIndex[tripID]
+	tripStops := s.stopTimesByTrip[tripID]
+	if trip == nil || len(tripStops) == 0 {
+		return nil
+	}
+	departure, err := NormalizePatternTime(tripDeparture(tripStops))
+	if err != nil {
+		return nil
+	}
+
+	pattern := &models.ServicePattern{
+		RouteID:       trip["route_id"],
+		Name:          trip["trip_short_name"],
+		OriginID:      tripStops[0]["stop_id"],
+		DepartureTime: departure,
+		Days:          make([]string, 0, len(weekOrder)),
+	}
+	if origin := s.stopsIndex[pattern.OriginID]; origin != nil {
+		pattern.OriginName = origin["stop_name"]
+	}
+
+	cal := s.calendarIndex[trip["service_id"]]
+	for _, weekday := range weekOrder {
+		if cal == nil || cal[strings.ToLower(weekday.String())] == "1" {
+			pattern.Days = append(pattern.Days, DayName(weekday))
+		}
+	}
+	if len(pattern.Days) == 0 {
+		pattern.Days = AllDays()
+	}
+	return pattern
+}
+
+// PatternTrips returns the trips of the timetable matching a pattern on
+// any day. Trips match by name, origin and departure time; the route isn't
+// compared, as route IDs are renumbered between timetables. No trips means
+// the service no longer runs.
+func (s *GTFSService) PatternTrips(p models.ServicePattern) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.patternTrips(p)
+}
+
+// patternTrips is PatternTrips. Callers must hold s.mu.
+func (s *GTFSService) patternTrips(p models.ServicePattern) []string {
+	var trips []string
+	for _, tripID := range s.tripsByShortName[normalizeShortName(p.Name)] {
+		tripStops := s.stopTimesByTrip[tripID]
+		if len(tripStops) == 0 || tripStops[0]["stop_id"] != p.OriginID {
+			continue
+		}
+		if departure, err := NormalizePatternTime(tripDeparture(tripStops)); err == nil && departure == p.DepartureTime {
+			trips = append(trips, tripID)
+		}
+	}
+	return trips
+}
+
+// ResolvePattern returns the trip a pattern stands for on an operating day
+// (Swiss midnight), or false if the pattern doesn't include the day or no
+// matching trip is in service that day.
+func (s *GTFSService) ResolvePattern(p models.ServicePattern, day time.Time) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resolvePattern(p, swissMidnight(day))
+}
+
+// resolvePattern is ResolvePattern for a Swiss midnight. Callers must hold
+// s.mu.
+func (s *GTFSService) resolvePattern(p models.ServicePattern, day time.Time) (string, bool) {
+	if !patternRunsOn(p, day) {
+		return "", false
+	}
+	for _, tripID := range s.patternTrips(p) {
+		if trip := s.tripsIndex[tripID]; trip != nil && s.isServiceActive(trip["service_id"], day) {
+			return tripID, true
+		}
+	}
+	return "", false
+}
+
+// ResolvePatternRun is ResolvePattern, also returning when the run is
+// scheduled to reach its last stop.
+func (s *GTFSService) ResolvePatternRun(p models.ServicePattern, day time.Time) (tripID string, arrival time.Time, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	day = swissMidnight(day)
+	tripID, ok = s.resolvePattern(p, day)
+	if !ok {
+		return "", time.Time{}, false
+	}
+	tripStops := s.stopTimesByTrip[tripID]
+	seconds := stopSeconds(tripStops[len(tripStops)-1], "arrival_time")
+	if seconds < 0 {
+		return "", time.Time{}, false
+	}
+	return tripID, day.Add(time.Duration(seconds) * time.Second), true
+}
+
+// NextPatternRun returns the next run of a pattern departing after from:
+// its trip, operating day and departure, looking up to days ahead. ok is
+// false if the pattern doesn't run in that window.
+func (s *GTFSService) NextPatternRun(p models.ServicePattern, from time.Time, days int) (tripID string, day, departure time.Time, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seconds := parseTimeToSeconds(p.DepartureTime)
+	if seconds < 0 {
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	// Start a day back: a run past midnight belongs to the previous day
+	today := swissMidnight(from)
+	for d := -1; d <= days; d++ {
+		day := today.AddDate(0, 0, d)
+		departure := day.Add(time.Duration(seconds) * time.Second)
+		if !departure.After(from) {
+			continue
+		}
+		if tripID, ok := s.resolvePattern(p, day); ok {
+			return tripID, day, departure, true
+		}
+	}
+	return "", time.Time{}, time.Time{}, false
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// loadTestGTFS loads a timetable of the given files, with a single agency
+// and the stops Zürich HB and Bern.
+func loadTestGTFS(t *testing.T, files map[string]string) *GTFSService {
+	t.Helper()
+	dir := t.TempDir()
+	all := map[string]string{
+		"agency.txt":         "agency_id,agency_name,agency_url,agency_timezone\nSBB,SBB,https://www.sbb.ch,Europe/Zurich\n",
+		"stops.txt":          "stop_id,stop_name,stop_lat,stop_lon\n8503000,Zürich HB,47.378177,8.540192\n8507000,Bern,46.948825,7.439122\n",
+		"routes.txt":         "route_id,agency_id,route_short_name,route_long_name,route_type\n1,SBB,IC 1,IC 1,2\n",
+		"calendar_dates.txt": "service_id,date,exception_type\n",
+	}
+	for name, data := range files {
+		all[name] = data
+	}
+	for name, data := range all {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewGTFSService(dir)
+	if err := s.LoadData(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestResolvePatternRunPastMidnight(t *testing.T) {
+	// The 23:30 IC 1 runs as trip "wd" Monday to Friday and as "we" at
+	// weekends; both arrive at 00:40 the next calendar day
+	s := loadTestGTFS(t, map[string]string{
+		"calendar.txt": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+			"weekdays,1,1,1,1,1,0,0,20240101,20301231\n" +
+			"weekends,0,0,0,0,0,1,1,20240101,20301231\n",
+		"trips.txt": "route_id,service_id,trip_id,trip_short_name,direction_id\n" +
+			"1,weekdays,wd,IC 1,0\n" +
+			"1,weekends,we,IC 1,0\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"wd,,23:30:00,8503000,1\nwd,24:40:00,,8507000,2\n" +
+			"we,,23:30:00,8503000,1\nwe,24:40:00,,8507000,2\n",
+	})
+	pattern := models.ServicePattern{Name: "IC 1", OriginID: "8503000", DepartureTime: "23:30", Days: AllDays()}
+
+	zurich, _ := time.LoadLocation("Europe/Zurich")
+	friday := time.Date(2025, 3, 14, 0, 0, 0, 0, zurich)
+	saturday := friday.AddDate(0, 0, 1)
+
+	tripID, arrival, ok := s.ResolvePatternRun(pattern, friday)
+	if !ok || tripID != "wd" {
+		t.Fatalf("Friday's run = %q, %v; want wd", tripID, ok)
+	}
+	if want := saturday.Add(40 * time.Minute); !arrival.Equal(want) {
+		t.Errorf("Friday's run arrives %v, want %v", arrival, want)
+	}
+
+	// Asked for a time on Saturday, the run is Saturday's
+	if tripID, _, ok := s.ResolvePatternRun(pattern, saturday.Add(20*time.Minute)); !ok || tripID != "we" {
+		t.Errorf("Saturday's run = %q, %v; want we", tripID, ok)
+	}
+
+	// Not on days the pattern leaves out
+	weekdays := pattern
+	weekdays.Days = []string{"mon", "tue", "wed", "thu", "fri"}
+	if tripID, _, ok := s.ResolvePatternRun(weekdays, saturday); ok {
+		t.Errorf("weekday pattern ran on Saturday as %s", tripID)
+	}
+}
@@ -0,0 +1,340 @@
+// Package services - Stationboard Poller
+// This file polls upstream stationboards in the background and keeps the
+// latest board per station in memory.
+package services
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// LiveBoard is the latest upstream stationboard of a watched station.
+// LiveBoards are replaced, never mutated, so callers may keep the pointer.
+type LiveBoard struct {
+	StationID string                `json:"stationId"`
+	Board     *StationBoardResponse `json:"board,omitempty"`
+	FetchedAt time.Time             `json:"fetchedAt"`
+	Provider  string                `json:"provider"`
+	Error     string                `json:"error,omitempty"`
+}
+
+// StationBoardPoller refreshes stationboards of a watch list within a daily
+// request budget. Calls are spread evenly over the day; favorite stations
+// are refreshed before plain watch list stations of the same age. Stations
+// whose board fails to load are retried with exponential backoff.
+//
+// Readers (handlers, WebSocket hub) only ever see the in-memory cache and
+// never block on upstream HTTP.
+type StationBoardPoller struct {
+	provider    TransportProvider
+	resolver    *TrainIdentityResolver
+	watchList   []string
+	dailyBudget int
+	boardLimit  int
+	interval    time.Duration
+
+	mu           sync.RWMutex
+	boards       map[string]*LiveBoard
+	lastPolled   map[string]time.Time
+	failures     map[string]int       // Consecutive failed polls
+	retryAt      map[string]time.Time // Backoff of failing stations
+	priorityFunc func() []string
+	listeners    []func(*LiveBoard)
+
+	// Budget accounting
+	callsToday int
+	dayStart   time.Time
+
+	done chan struct{}
+}
+
+const (
+	// minPollInterval keeps the poller from hammering upstream with a large budget.
+	minPollInterval = 10 * time.Second
+
+	// favoritePriority is how much faster favorite stations age.
+	favoritePriority = 2
+
+	// maxPollBackoff caps the wait before a failing station is retried.
+	maxPollBackoff = time.Hour
+)
+
+// NewStationBoardPoller creates a poller for the given watch list.
+// dailyBudget is the number of upstream calls the poller may spend per 24h.
+func NewStationBoardPoller(provider TransportProvider, resolver *TrainIdentityResolver, watchList []string, dailyBudget, boardLimit int) *StationBoardPoller {
+	if dailyBudget <= 0 {
+		dailyBudget = 1
+	}
+	if boardLimit <= 0 {
+		boardLimit = 10
+	}
+
+	interval := rateLimitWindow / time.Duration(dailyBudget)
+	if interval < minPollInterval {
+		interval = minPollInterval
+	}
+
+	return &StationBoardPoller{
+		provider:    provider,
+		resolver:    resolver,
+		watchList:   watchList,
+		dailyBudget: dailyBudget,
+		boardLimit:  boardLimit,
+		interval:    interval,
+		boards:      make(map[string]*LiveBoard),
+		lastPolled:  make(map[string]time.Time),
+		failures:    make(map[string]int),
+		retryAt:     make(map[string]time.Time),
+		dayStart:    time.Now(),
+		done:        make(chan struct{}),
+	}
+}
+
+// SetPriorityFunc sets the source of priority station IDs (e.g. favorites).
+// The function is called on every poll, outside the poller's lock.
+func (p *StationBoardPoller) SetPriorityFunc(fn func() []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.priorityFunc = fn
+}
+
+// OnUpdate registers a callback invoked after every refreshed board.
+// Callbacks run on the poller goroutine and must not block.
+func (p *StationBoardPoller) OnUpdate(fn func(*LiveBoard)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listeners = append(p.listeners, fn)
+}
+
+// Run starts the poller's main loop.
+func (p *StationBoardPoller) Run() {
+	log.Info().
+		Int("stations", len(p.watchList)).
+		Int("dailyBudget", p.dailyBudget).
+		Dur("interval", p.interval).
+		Msg("📡 Stationboard poller started")
+
+	// Poll once right away so the cache isn't empty for a whole interval
+	p.pollNext(time.Now())
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			p.pollNext(now)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Stop stops the poller.
+func (p *StationBoardPoller) Stop() {
+	close(p.done)
+}
+
+// pollNext refreshes the most overdue station, if the budget allows it.
+func (p *StationBoardPoller) pollNext(now time.Time) {
+	stationID, ok := p.nextStation(now)
+	if !ok {
+		return
+	}
+
+	board, err := p.provider.GetStationBoard(stationID, p.boardLimit)
+
+	live := &LiveBoard{
+		StationID: stationID,
+		FetchedAt: now,
+		Provider:  p.provider.Name(),
+	}
+
+	p.mu.Lock()
+	p.lastPolled[stationID] = now
+	var backoff time.Duration
+	if err != nil {
+		// Keep serving the previous board; only record the failure
+		if prev := p.boards[stationID]; prev != nil {
+			live.Board = prev.Board
+			live.FetchedAt = prev.FetchedAt
+		}
+		live.Error = err.Error()
+
+		p.failures[stationID]++
+		backoff = p.backoff(p.failures[stationID])
+		p.retryAt[stationID] = now.Add(backoff)
+	} else {
+		live.Board = board
+		delete(p.failures, stationID)
+		delete(p.retryAt, stationID)
+	}
+	p.boards[stationID] = live
+	listeners := p.listeners
+	p.mu.Unlock()
+
+	if err != nil {
+		log.Warn().Err(err).Str("station", stationID).Dur("retryIn", backoff).Msg("Failed to poll stationboard")
+		return
+	}
+
+	log.Debug().
+		Str("station", stationID).
+		Int("departures", len(board.Stationboard)).
+		Msg("Stationboard refreshed")
+
+	for _, fn := range listeners {
+		fn(live)
+	}
+}
+
+// backoff returns how long a station is left alone after its n-th
+// consecutive failure: one poll interval, doubling up to maxPollBackoff.
+func (p *StationBoardPoller) backoff(failures int) time.Duration {
+	backoff := p.interval
+	for i := 1; i < failures && backoff < maxPollBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxPollBackoff {
+		backoff = maxPollBackoff
+	}
+	return backoff
+}
+
+// nextStation picks the station to poll and reserves one call of the budget.
+// Favorites and watch list stations are ranked by age since their last poll;
+// stations never polled come first, stations backing off are skipped.
+func (p *StationBoardPoller) nextStation(now time.Time) (string, bool) {
+	// The priority source reads the favorites store; a slow read must not
+	// hold up readers of the cache
+	p.mu.RLock()
+	priorityFunc := p.priorityFunc
+	p.mu.RUnlock()
+
+	favorites := make(map[string]bool)
+	if priorityFunc != nil {
+		for _, id := range priorityFunc() {
+			favorites[id] = true
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if now.Sub(p.dayStart) > rateLimitWindow {
+		p.callsToday = 0
+		p.dayStart = now
+	}
+	if p.callsToday >= p.dailyBudget {
+		return "", false
+	}
+
+	candidates := make([]string, 0, len(p.watchList)+len(favorites))
+	seen := make(map[string]bool)
+	for _, id := range p.watchList {
+		if !seen[id] {
+			seen[id] = true
+			candidates = append(candidates, id)
+		}
+	}
+	for id := range favorites {
+		if !seen[id] {
+			seen[id] = true
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// Stable order so ties are broken deterministically
+	sort.Strings(candidates)
+
+	var best string
+	var bestAge time.Duration = -1
+	for _, id := range candidates {
+		if now.Before(p.retryAt[id]) {
+			continue
+		}
+		last, polled := p.lastPolled[id]
+		age := time.Duration(math.MaxInt64)
+		if polled {
+			age = now.Sub(last)
+			if favorites[id] {
+				age *= favoritePriority
+			}
+		}
+		if age > bestAge {
+			best = id
+			bestAge = age
+		}
+	}
+
+	if bestAge < 0 {
+		return "", false
+	}
+
+	p.callsToday++
+	return best, true
+}
+
+// Board returns the cached board for a station, or nil if it has none.
+func (p *StationBoardPoller) Board(stationID string) *LiveBoard {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.boards[stationID]
+}
+
+// Boards returns all cached boards ordered by station ID.
+func (p *StationBoardPoller) Boards() []*LiveBoard {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	boards := make([]*LiveBoard, 0, len(p.boards))
+	for _, board := range p.boards {
+		boards = append(boards, board)
+	}
+
+	sort.Slice(boards, func(i, j int) bool {
+		return boards[i].StationID < boards[j].StationID
+	})
+
+	return boards
+}
+
+// GetStatus returns the poller's budget and cache status.
+func (p *StationBoardPoller) GetStatus() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return map[string]interface{}{
+		"provider":     p.provider.Name(),
+		"watchList":    p.watchList,
+		"cached":       len(p.boards),
+		"interval":     p.interval.String(),
+		"dailyBudget":  p.dailyBudget,
+		"callsToday":   p.callsToday,
+		"backingOff":   len(p.retryAt),
+		"budgetResets": p.dayStart.Add(rateLimitWindow).Format(time.RFC3339),
+	}
+}
+
+// GetLiveTrainPositions gets train positions from the cached stationboards.
+// Journeys seen on several boards are merged into one train per physical run
+// (see TrainIdentityResolver). Swiss Transport API doesn't provide real-time
+// GPS, so positions are interpolated between stops using the reported delays.
+func (p *StationBoardPoller) GetLiveTrainPositions() []models.Train {
+	return p.resolver.MergeBoards(p.Boards(), time.Now())
+}
+
+// GetRunningTrains is GetLiveTrainPositions limited to the trains running at
+// now, between their first departure and last arrival. The hub merges them
+// into the live state.
+func (p *StationBoardPoller) GetRunningTrains(now time.Time) []models.Train {
+	return p.resolver.RunningTrains(p.Boards(), now)
+}
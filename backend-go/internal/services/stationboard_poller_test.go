@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+func TestPollerBudget(t *testing.T) {
+	provider := &fakeProvider{name: "searchch"}
+	p := NewStationBoardPoller(provider, nil, []string{"8507000", "8503000"}, 3, 5)
+	if p.interval != 8*time.Hour {
+		t.Errorf("interval = %v, want the day spread over 3 calls", p.interval)
+	}
+
+	start := p.dayStart
+	for i := 0; i < 5; i++ {
+		p.pollNext(start.Add(time.Duration(i) * time.Minute))
+	}
+	if provider.calls != 3 {
+		t.Fatalf("%d calls, want the budget of 3", provider.calls)
+	}
+	if status := p.GetStatus(); status["callsToday"] != 3 || status["cached"] != 2 {
+		t.Errorf("status = %v", status)
+	}
+
+	// The budget is renewed a day later
+	p.pollNext(start.Add(rateLimitWindow + time.Minute))
+	if provider.calls != 4 || p.GetStatus()["callsToday"] != 1 {
+		t.Errorf("%d calls, status %v; want a fresh budget", provider.calls, p.GetStatus())
+	}
+
+	// Large budgets are still spread out
+	if p := NewStationBoardPoller(provider, nil, nil, 1000000, 5); p.interval != minPollInterval {
+		t.Errorf("interval = %v, want %v", p.interval, minPollInterval)
+	}
+}
+
+func TestPollerPriority(t *testing.T) {
+	provider := &fakeProvider{name: "searchch"}
+	p := NewStationBoardPoller(provider, nil, []string{"B", "A"}, 100, 5)
+
+	// The priorities are read without holding the poller's lock
+	p.SetPriorityFunc(func() []string {
+		p.Board("C")
+		return []string{"C"}
+	})
+
+	start := p.dayStart
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 6; i++ {
+			p.pollNext(start.Add(time.Duration(i) * time.Minute))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("poller deadlocked on its priority function")
+	}
+
+	// Never polled first (in order), then by age; the favorite C ages twice
+	// as fast. At 3m: A 3m, B 2m, C 1m*2; at 4m: A 1m, B 3m, C 2m*2; at 5m:
+	// A 2m, B 4m, C 1m*2
+	want := []string{"A", "B", "C", "A", "C", "B"}
+	if !reflect.DeepEqual(provider.boards, want) {
+		t.Errorf("polled %v, want %v", provider.boards, want)
+	}
+}
+
+func TestPollerBackoff(t *testing.T) {
+	provider := &fakeProvider{name: "searchch"}
+	p := NewStationBoardPoller(provider, nil, []string{"8507000"}, 8640, 5)
+	if p.interval != 10*time.Second {
+		t.Fatalf("interval = %v", p.interval)
+	}
+
+	start := p.dayStart
+	p.pollNext(start)
+	provider.err = errors.New("upstream down")
+
+	// Failures keep the previous board and are retried after 10s, 20s, 40s
+	for _, step := range []struct {
+		after  time.Duration
+		polled bool
+	}{
+		{time.Minute, true},
+		{time.Minute + 5*time.Second, false},
+		{time.Minute + 10*time.Second, true},
+		{time.Minute + 25*time.Second, false},
+		{time.Minute + 30*time.Second, true},
+		{time.Minute + 65*time.Second, false},
+	} {
+		calls := provider.calls
+		p.pollNext(start.Add(step.after))
+		if polled := provider.calls > calls; polled != step.polled {
+			t.Errorf("after %v: polled %v, want %v", step.after, polled, step.polled)
+		}
+	}
+	board := p.Board("8507000")
+	if board.Board == nil || board.Error != "upstream down" || !board.FetchedAt.Equal(start) {
+		t.Errorf("board = %+v, want the first one with the error", board)
+	}
+	if status := p.GetStatus(); status["callsToday"] != 4 || status["backingOff"] != 1 {
+		t.Errorf("status = %v; skipped polls must not use the budget", status)
+	}
+
+	// A success ends the backoff
+	provider.err = nil
+	p.pollNext(start.Add(time.Minute + 70*time.Second))
+	p.pollNext(start.Add(time.Minute + 71*time.Second))
+	if provider.calls != 6 || p.Board("8507000").Error != "" || p.GetStatus()["backingOff"] != 0 {
+		t.Errorf("%d calls, board %+v, want polls again", provider.calls, p.Board("8507000"))
+	}
+
+	if backoff := p.backoff(30); backoff != maxPollBackoff {
+		t.Errorf("backoff = %v, want at most %v", backoff, maxPollBackoff)
+	}
+}
+
+func TestMergeRealtime(t *testing.T) {
+	simulated := []models.Train{{ID: "trip-1", Delay: 3}, {ID: "trip-2", Delay: 5}}
+	upstream := []models.Train{{ID: "trip-2", Delay: 12, Cancelled: true}, {ID: "IR-2517-20250314"}}
+
+	merged := MergeRealtime(simulated, upstream)
+	ids := make([]string, len(merged))
+	for i, train := range merged {
+		ids[i] = train.ID
+	}
+	if !reflect.DeepEqual(ids, []string{"trip-1", "trip-2", "IR-2517-20250314"}) {
+		t.Fatalf("trains = %v", ids)
+	}
+	if merged[1].Delay != 12 || !merged[1].Cancelled {
+		t.Errorf("trip-2 = %+v, want the upstream train", merged[1])
+	}
+}
@@ -38,6 +38,11 @@ func NewSwissTransportService(baseURL string) *SwissTransportService {
 	}
 }
 
+// Name returns the provider name.
+func (s *SwissTransportService) Name() string {
+	return ProviderOpenData
+}
+
 const (
 	maxRequestsPerDay = 1000
 	rateLimitWindow   = 24 * time.Hour
@@ -122,17 +127,23 @@ func (s *SwissTransportService) apiRequest(endpoint string, params map[string]st
 	return body, nil
 }
 
+// TransportCoordinate is a coordinate as returned by the transport APIs.
+type TransportCoordinate struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// TransportStation is a station as returned by the transport APIs.
+type TransportStation struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	Coordinate TransportCoordinate `json:"coordinate"`
+	Distance   float64             `json:"distance,omitempty"`
+}
+
 // LocationsResponse is the response from the /locations endpoint.
 type LocationsResponse struct {
-	Stations []struct {
-		ID         string `json:"id"`
-		Name       string `json:"name"`
-		Coordinate struct {
-			X float64 `json:"x"`
-			Y float64 `json:"y"`
-		} `json:"coordinate"`
-		Distance float64 `json:"distance,omitempty"`
-	} `json:"stations"`
+	Stations []TransportStation `json:"stations"`
 }
 
 // SearchStations searches for stations by name or coordinates.
@@ -168,60 +179,52 @@ func (s *SwissTransportService) SearchStations(query string) ([]models.Station,
 	return stations, nil
 }
 
+// StationBoardPrognosis holds the realtime forecast for a stationboard stop.
+type StationBoardPrognosis struct {
+	Platform  string  `json:"platform"`
+	Arrival   *string `json:"arrival"`
+	Departure *string `json:"departure"`
+}
+
+// StationBoardStop is the stop of a journey at the requested station.
+type StationBoardStop struct {
+	Station   TransportStation       `json:"station"`
+	Arrival   *string                `json:"arrival"`
+	Departure *string                `json:"departure"`
+	Delay     int                    `json:"delay"`
+	Platform  string                 `json:"platform"`
+	Prognosis *StationBoardPrognosis `json:"prognosis"`
+}
+
+// PassListStop is one stop of a journey's pass list.
+type PassListStop struct {
+	Station   TransportStation `json:"station"`
+	Arrival   *string          `json:"arrival"`
+	Departure *string          `json:"departure"`
+	Delay     int              `json:"delay"`
+	Platform  string           `json:"platform"`
+}
+
+// StationBoardJourney is a single departure on a stationboard.
+type StationBoardJourney struct {
+	Stop         StationBoardStop `json:"stop"`
+	Name         string           `json:"name"`
+	Category     string           `json:"category"`
+	Subcategory  string           `json:"subcategory,omitempty"`
+	CategoryCode int              `json:"categoryCode,omitempty"`
+	Number       string           `json:"number"`
+	Operator     string           `json:"operator"`
+	To           string           `json:"to"`
+	PassList     []PassListStop   `json:"passList"`
+	Capacity1st  *int             `json:"capacity1st,omitempty"`
+	Capacity2nd  *int             `json:"capacity2nd,omitempty"`
+	Cancelled    bool             `json:"cancelled,omitempty"` // Only reported by search.ch
+}
+
 // StationBoardResponse is the response from the /stationboard endpoint.
 type StationBoardResponse struct {
-	Station struct {
-		ID         string `json:"id"`
-		Name       string `json:"name"`
-		Coordinate struct {
-			X float64 `json:"x"`
-			Y float64 `json:"y"`
-		} `json:"coordinate"`
-	} `json:"station"`
-	Stationboard []struct {
-		Stop struct {
-			Station struct {
-				ID         string `json:"id"`
-				Name       string `json:"name"`
-				Coordinate struct {
-					X float64 `json:"x"`
-					Y float64 `json:"y"`
-				} `json:"coordinate"`
-			} `json:"station"`
-			Arrival   *string `json:"arrival"`
-			Departure *string `json:"departure"`
-			Delay     int     `json:"delay"`
-			Platform  string  `json:"platform"`
-			Prognosis *struct {
-				Platform  string  `json:"platform"`
-				Arrival   *string `json:"arrival"`
-				Departure *string `json:"departure"`
-			} `json:"prognosis"`
-		} `json:"stop"`
-		Name         string `json:"name"`
-		Category     string `json:"category"`
-		Subcategory  string `json:"subcategory,omitempty"`
-		CategoryCode int    `json:"categoryCode,omitempty"`
-		Number       string `json:"number"`
-		Operator     string `json:"operator"`
-		To           string `json:"to"`
-		PassList     []struct {
-			Station struct {
-				ID         string `json:"id"`
-				Name       string `json:"name"`
-				Coordinate struct {
-					X float64 `json:"x"`
-					Y float64 `json:"y"`
-				} `json:"coordinate"`
-			} `json:"station"`
-			Arrival   *string `json:"arrival"`
-			Departure *string `json:"departure"`
-			Delay     int     `json:"delay"`
-			Platform  string  `json:"platform"`
-		} `json:"passList"`
-		Capacity1st *int `json:"capacity1st,omitempty"`
-		Capacity2nd *int `json:"capacity2nd,omitempty"`
-	} `json:"stationboard"`
+	Station      TransportStation      `json:"station"`
+	Stationboard []StationBoardJourney `json:"stationboard"`
 }
 
 // GetStationBoard retrieves the departure board for a station.
@@ -244,37 +247,65 @@ func (s *SwissTransportService) GetStationBoard(stationID string, limit int) (*S
 	return &resp, nil
 }
 
+// ConnectionDeparture is the departure end of a connection.
+type ConnectionDeparture struct {
+	Station   TransportStation `json:"station"`
+	Departure string           `json:"departure"`
+	Delay     int              `json:"delay"` // minutes
+	Platform  string           `json:"platform"`
+}
+
+// ConnectionArrival is the arrival end of a connection.
+type ConnectionArrival struct {
+	Station  TransportStation `json:"station"`
+	Arrival  string           `json:"arrival"`
+	Delay    int              `json:"delay"` // minutes
+	Platform string           `json:"platform"`
+}
+
+// Connection is a single journey between two stations.
+type Connection struct {
+	From      ConnectionDeparture `json:"from"`
+	To        ConnectionArrival   `json:"to"`
+	Duration  string              `json:"duration"`
+	Transfers int                 `json:"transfers"`
+	Products  []string            `json:"products"`
+	Cancelled bool                `json:"cancelled,omitempty"`
+}
+
+// ToJourney converts an upstream connection into a journey connection.
+// Upstream connections carry no legs.
+func (c Connection) ToJourney() models.JourneyConnection {
+	departure := parseOpenDataTime(&c.From.Departure)
+	arrival := parseOpenDataTime(&c.To.Arrival)
+
+	conn := models.JourneyConnection{
+		From:           c.From.Station.toStation(),
+		To:             c.To.Station.toStation(),
+		DepartureDelay: c.From.Delay,
+		ArrivalDelay:   c.To.Delay,
+		Transfers:      c.Transfers,
+		Products:       c.Products,
+		Cancelled:      c.Cancelled,
+	}
+	if conn.Products == nil {
+		conn.Products = []string{}
+	}
+	if !departure.IsZero() {
+		conn.Departure = departure.Format(time.RFC3339)
+	}
+	if !arrival.IsZero() {
+		conn.Arrival = arrival.Format(time.RFC3339)
+	}
+	if !departure.IsZero() && !arrival.IsZero() {
+		conn.Duration = int(arrival.Sub(departure).Minutes())
+	}
+	return conn
+}
+
 // ConnectionsResponse is the response from the /connections endpoint.
 type ConnectionsResponse struct {
-	Connections []struct {
-		From struct {
-			Station struct {
-				ID         string `json:"id"`
-				Name       string `json:"name"`
-				Coordinate struct {
-					X float64 `json:"x"`
-					Y float64 `json:"y"`
-				} `json:"coordinate"`
-			} `json:"station"`
-			Departure string `json:"departure"`
-			Platform  string `json:"platform"`
-		} `json:"from"`
-		To struct {
-			Station struct {
-				ID         string `json:"id"`
-				Name       string `json:"name"`
-				Coordinate struct {
-					X float64 `json:"x"`
-					Y float64 `json:"y"`
-				} `json:"coordinate"`
-			} `json:"station"`
-			Arrival  string `json:"arrival"`
-			Platform string `json:"platform"`
-		} `json:"to"`
-		Duration  string   `json:"duration"`
-		Transfers int      `json:"transfers"`
-		Products  []string `json:"products"`
-	} `json:"connections"`
+	Connections []Connection `json:"connections"`
 }
 
 // GetConnections retrieves connections between two stations.
@@ -345,99 +376,3 @@ func (s *SwissTransportService) GetMajorStations() ([]models.Station, error) {
 
 	return stations, nil
 }
-
-// GetLiveTrainPositions gets approximate train positions based on stationboard data.
-// Note: Swiss Transport API doesn't provide real-time GPS, so positions are approximated.
-func (s *SwissTransportService) GetLiveTrainPositions(majorStations []models.Station) ([]models.Train, error) {
-	var trains []models.Train
-	seenTrains := make(map[string]bool)
-
-	// Limit stations to avoid rate limits
-	stationsToCheck := majorStations
-	if len(stationsToCheck) > 5 {
-		stationsToCheck = stationsToCheck[:5]
-	}
-
-	for _, station := range stationsToCheck {
-		board, err := s.GetStationBoard(station.ID, 5)
-		if err != nil {
-			log.Warn().Str("station", station.Name).Err(err).Msg("Failed to get stationboard")
-			continue
-		}
-
-		for _, departure := range board.Stationboard {
-			// Create unique ID for train
-			trainID := fmt.Sprintf("%s-%s-%s", departure.Category, departure.Number, station.ID)
-			if seenTrains[trainID] {
-				continue
-			}
-			seenTrains[trainID] = true
-
-			// Parse departure time
-			var depTime string
-			if departure.Stop.Departure != nil {
-				depTime = *departure.Stop.Departure
-			}
-
-			// Calculate approximate position based on current time
-			now := time.Now()
-			position := &models.Position{
-				Lat: departure.Stop.Station.Coordinate.Y,
-				Lng: departure.Stop.Station.Coordinate.X,
-			}
-
-			// Add slight movement if train has departed
-			if depTime != "" {
-				parsedTime, err := time.Parse(time.RFC3339, depTime)
-				if err == nil && now.After(parsedTime) {
-					// Simulate movement along route if passlist is available
-					if len(departure.PassList) > 1 {
-						elapsed := now.Sub(parsedTime).Minutes()
-						progress := elapsed / 60 // Assume 1 hour journey
-						if progress > 1 {
-							progress = 1
-						}
-
-						// Interpolate between first and second stop
-						nextStop := departure.PassList[0]
-						position.Lat = departure.Stop.Station.Coordinate.Y +
-							(nextStop.Station.Coordinate.Y-departure.Stop.Station.Coordinate.Y)*progress
-						position.Lng = departure.Stop.Station.Coordinate.X +
-							(nextStop.Station.Coordinate.X-departure.Stop.Station.Coordinate.X)*progress
-					}
-				}
-			}
-
-			currentStation := &models.Station{
-				ID:   departure.Stop.Station.ID,
-				Name: departure.Stop.Station.Name,
-				Coordinate: models.Coordinate{
-					X: departure.Stop.Station.Coordinate.X,
-					Y: departure.Stop.Station.Coordinate.Y,
-				},
-			}
-
-			trains = append(trains, models.Train{
-				ID:             trainID,
-				Name:           departure.Name,
-				Category:       departure.Category,
-				Number:         departure.Number,
-				Operator:       departure.Operator,
-				From:           station.Name,
-				To:             departure.To,
-				Position:       position,
-				CurrentStation: currentStation,
-				Delay:          departure.Stop.Delay,
-				Cancelled:      false,
-				Speed:          60 + time.Now().Nanosecond()%60,
-				Direction:      time.Now().Nanosecond() % 360,
-				LastUpdate:     now.Format(time.RFC3339),
-			})
-		}
-
-		// Respect rate limits
-		time.Sleep(200 * time.Millisecond)
-	}
-
-	return trains, nil
-}
@@ -0,0 +1,526 @@
+// Package services - Train Identity
+// This file merges upstream stationboard journeys into one train per
+// physical run, matched against GTFS trips where possible.
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// TrainIdentity identifies one physical train run across stationboards.
+type TrainIdentity struct {
+	ID           string    // Stable train ID (GTFS trip ID when matched)
+	TripID       string    // Matched GTFS trip, empty if unmatched
+	OperatingDay time.Time // Swiss midnight of the operating day
+}
+
+// key distinguishes runs of the same trip on different days.
+func (i TrainIdentity) key() string {
+	return i.ID + "@" + i.OperatingDay.Format("20060102")
+}
+
+// TrainIdentityResolver matches upstream category+number+operating day to
+// GTFS trips by trip_short_name, and merges all stationboards listing the
+// same run into a single train.
+type TrainIdentityResolver struct {
+	gtfsService *GTFSService
+	tolerance   time.Duration
+}
+
+// matchTolerance is the maximum difference between the upstream scheduled
+// departure and the GTFS departure at the same station.
+const matchTolerance = 3 * time.Minute
+
+// NewTrainIdentityResolver creates a resolver backed by the GTFS timetable.
+func NewTrainIdentityResolver(gtfsService *GTFSService) *TrainIdentityResolver {
+	return &TrainIdentityResolver{
+		gtfsService: gtfsService,
+		tolerance:   matchTolerance,
+	}
+}
+
+// Resolve identifies the train of an upstream stationboard journey.
+func (r *TrainIdentityResolver) Resolve(journey StationBoardJourney) TrainIdentity {
+	scheduled := parseOpenDataTime(journey.Stop.Departure)
+	if scheduled.IsZero() {
+		scheduled = parseOpenDataTime(journey.Stop.Arrival)
+	}
+
+	if r.gtfsService != nil && r.gtfsService.IsDataLoaded() && !scheduled.IsZero() {
+		tripID, day, ok := r.gtfsService.MatchTrip(
+			shortNameCandidates(journey),
+			journey.Stop.Station.ID,
+			scheduled,
+			r.tolerance,
+		)
+		if ok {
+			return TrainIdentity{ID: tripID, TripID: tripID, OperatingDay: day}
+		}
+	}
+
+	if scheduled.IsZero() {
+		scheduled = time.Now()
+	}
+	day := runOperatingDay(scheduled)
+
+	// A train number is given once per operating day; keyed by calendar
+	// date, a run past midnight would split in two
+	return TrainIdentity{
+		ID:           fmt.Sprintf("%s-%s-%s", journey.Category, journey.Number, day.Format("20060102")),
+		OperatingDay: day,
+	}
+}
+
+// operatingDayStart is when a new operating day starts (Swiss time). Runs
+// departing their origin before midnight call at their last stops on the
+// next calendar day, within the same operating day.
+const operatingDayStart = 4 * time.Hour
+
+// runOperatingDay returns the Swiss midnight of the operating day a train
+// scheduled at t runs on: the previous day until operatingDayStart.
+func runOperatingDay(t time.Time) time.Time {
+	day := swissMidnight(t)
+	if t.Before(day.Add(operatingDayStart)) {
+		day = swissMidnight(day.Add(-time.Hour))
+	}
+	return day
+}
+
+// shortNameCandidates lists the names a journey may carry as trip_short_name.
+// Feeds differ: some use the line ("IC 1"), others the train number ("715").
+func shortNameCandidates(journey StationBoardJourney) []string {
+	candidates := []string{
+		journey.Category + " " + journey.Number,
+		journey.Category + journey.Number,
+		journey.Number,
+	}
+	if journey.Name != "" {
+		candidates = append(candidates, journey.Name)
+	}
+	return candidates
+}
+
+// ============================================================================
+// MERGING
+// ============================================================================
+
+// observedStop is one stop of a run as seen on stationboards or the timetable.
+type observedStop struct {
+	station        TransportStation
+	arrival        time.Time // Scheduled
+	departure      time.Time // Scheduled
+	arrivalDelay   int       // Minutes
+	departureDelay int       // Minutes
+	platform       string
+	observed       bool      // Seen upstream (delays are real)
+	observedAt     time.Time // When the upstream board was fetched
+}
+
+// time returns the scheduled time used to order stops.
+func (o *observedStop) time() time.Time {
+	if !o.arrival.IsZero() {
+		return o.arrival
+	}
+	return o.departure
+}
+
+// actualArrival returns the arrival including delay.
+func (o *observedStop) actualArrival() time.Time {
+	if o.arrival.IsZero() {
+		return o.actualDeparture()
+	}
+	return o.arrival.Add(time.Duration(o.arrivalDelay) * time.Minute)
+}
+
+// actualDeparture returns the departure including delay.
+func (o *observedStop) actualDeparture() time.Time {
+	if o.departure.IsZero() {
+		return o.arrival.Add(time.Duration(o.arrivalDelay) * time.Minute)
+	}
+	return o.departure.Add(time.Duration(o.departureDelay) * time.Minute)
+}
+
+// trainRun collects every observation of one physical run.
+type trainRun struct {
+	identity  TrainIdentity
+	journey   StationBoardJourney // Most recently fetched journey (metadata)
+	fetchedAt time.Time
+	cancelled bool
+	stops     map[string]*observedStop // Keyed by station ID
+}
+
+// observe merges a stop observation; newer observations win.
+func (run *trainRun) observe(stop observedStop) {
+	existing := run.stops[stop.station.ID]
+	if existing == nil {
+		run.stops[stop.station.ID] = &stop
+		return
+	}
+
+	if existing.arrival.IsZero() {
+		existing.arrival = stop.arrival
+	}
+	if existing.departure.IsZero() {
+		existing.departure = stop.departure
+	}
+	if !stop.observed || (existing.observed && stop.observedAt.Before(existing.observedAt)) {
+		return
+	}
+
+	existing.arrivalDelay = stop.arrivalDelay
+	existing.departureDelay = stop.departureDelay
+	if stop.platform != "" {
+		existing.platform = stop.platform
+	}
+	existing.observed = true
+	existing.observedAt = stop.observedAt
+}
+
+// addJourney records a stationboard journey (board stop + pass list).
+func (run *trainRun) addJourney(journey StationBoardJourney, fetchedAt time.Time) {
+	if fetchedAt.After(run.fetchedAt) || run.fetchedAt.IsZero() {
+		run.journey = journey
+		run.fetchedAt = fetchedAt
+		run.cancelled = journey.Cancelled
+	}
+
+	platform := journey.Stop.Platform
+	if journey.Stop.Prognosis != nil && journey.Stop.Prognosis.Platform != "" {
+		platform = journey.Stop.Prognosis.Platform
+	}
+
+	run.observe(observedStop{
+		station:        journey.Stop.Station,
+		arrival:        parseOpenDataTime(journey.Stop.Arrival),
+		departure:      parseOpenDataTime(journey.Stop.Departure),
+		arrivalDelay:   journey.Stop.Delay,
+		departureDelay: journey.Stop.Delay,
+		platform:       platform,
+		observed:       true,
+		observedAt:     fetchedAt,
+	})
+
+	for _, pass := range journey.PassList {
+		// The board station is usually repeated as the first pass list entry
+		if pass.Station.ID == "" {
+			continue
+		}
+		run.observe(observedStop{
+			station:        pass.Station,
+			arrival:        parseOpenDataTime(pass.Arrival),
+			departure:      parseOpenDataTime(pass.Departure),
+			arrivalDelay:   pass.Delay,
+			departureDelay: pass.Delay,
+			platform:       pass.Platform,
+			observed:       true,
+			observedAt:     fetchedAt,
+		})
+	}
+}
+
+// MergeBoards merges the journeys of all boards into one train per run.
+// Trains are ordered by ID.
+func (r *TrainIdentityResolver) MergeBoards(boards []*LiveBoard, now time.Time) []models.Train {
+	return r.buildTrains(r.mergeRuns(boards), now)
+}
+
+// RunningTrains is MergeBoards limited to the runs between their first
+// departure and last arrival (delays included) at now.
+func (r *TrainIdentityResolver) RunningTrains(boards []*LiveBoard, now time.Time) []models.Train {
+	runs := r.mergeRuns(boards)
+	for key, run := range runs {
+		if !run.running(now) {
+			delete(runs, key)
+		}
+	}
+	return r.buildTrains(runs, now)
+}
+
+// mergeRuns collects the journeys of all boards by run, seeded with the
+// timetable of matched trips.
+func (r *TrainIdentityResolver) mergeRuns(boards []*LiveBoard) map[string]*trainRun {
+	runs := make(map[string]*trainRun)
+
+	for _, live := range boards {
+		if live == nil || live.Board == nil {
+			continue
+		}
+
+		for _, journey := range live.Board.Stationboard {
+			identity := r.Resolve(journey)
+
+			run := runs[identity.key()]
+			if run == nil {
+				run = &trainRun{
+					identity: identity,
+					stops:    make(map[string]*observedStop),
+				}
+				runs[identity.key()] = run
+			}
+
+			run.addJourney(journey, live.FetchedAt)
+		}
+	}
+
+	for _, run := range runs {
+		r.addScheduledStops(run)
+	}
+	return runs
+}
+
+// buildTrains builds the trains of merged runs, ordered by ID.
+func (r *TrainIdentityResolver) buildTrains(runs map[string]*trainRun, now time.Time) []models.Train {
+	trains := make([]models.Train, 0, len(runs))
+	for _, run := range runs {
+		train := buildTrain(run, now)
+		if run.identity.TripID != "" && r.gtfsService != nil {
+			if trip := r.gtfsService.GetTrip(run.identity.TripID); trip != nil {
+				train.RouteID = trip.RouteID
+			}
+		}
+		trains = append(trains, train)
+	}
+
+	sort.Slice(trains, func(i, j int) bool {
+		return trains[i].ID < trains[j].ID
+	})
+
+	return trains
+}
+
+// running reports whether the run has left its first stop and not yet
+// reached its last one at now.
+func (run *trainRun) running(now time.Time) bool {
+	var first, last *observedStop
+	for _, stop := range run.stops {
+		if stop.time().IsZero() {
+			continue
+		}
+		if first == nil || stop.time().Before(first.time()) {
+			first = stop
+		}
+		if last == nil || stop.time().After(last.time()) {
+			last = stop
+		}
+	}
+	if first == nil || first == last {
+		return false
+	}
+	return !now.Before(first.actualDeparture()) && !now.After(last.actualArrival())
+}
+
+// MergeRealtime replaces simulated trains by the upstream trains of the
+// same run and adds upstream trains without a GTFS counterpart.
+func MergeRealtime(simulated, upstream []models.Train) []models.Train {
+	if len(upstream) == 0 {
+		return simulated
+	}
+
+	byID := make(map[string]int, len(upstream))
+	for i := range upstream {
+		byID[upstream[i].ID] = i
+	}
+
+	merged := make([]models.Train, 0, len(simulated)+len(upstream))
+	for i := range simulated {
+		if _, ok := byID[simulated[i].ID]; !ok {
+			merged = append(merged, simulated[i])
+		}
+	}
+	return append(merged, upstream...)
+}
+
+// addScheduledStops seeds a matched run with the full GTFS timetable so
+// stops not listed on any watched board are still known.
+func (r *TrainIdentityResolver) addScheduledStops(run *trainRun) {
+	if run.identity.TripID == "" || r.gtfsService == nil {
+		return
+	}
+
+	for _, st := range r.gtfsService.GetTripStopTimes(run.identity.TripID) {
+		station := r.gtfsService.GetStationByID(st.StopID)
+		if station == nil {
+			continue
+		}
+
+		stop := observedStop{
+			station: TransportStation{
+				ID:         station.ID,
+				Name:       station.Name,
+				Coordinate: TransportCoordinate{X: station.Coordinate.X, Y: station.Coordinate.Y},
+			},
+		}
+		if secs := parseTimeToSeconds(st.ArrivalTime); secs >= 0 {
+			stop.arrival = run.identity.OperatingDay.Add(time.Duration(secs) * time.Second)
+		}
+		if secs := parseTimeToSeconds(st.DepartureTime); secs >= 0 {
+			stop.departure = run.identity.OperatingDay.Add(time.Duration(secs) * time.Second)
+		}
+
+		run.observe(stop)
+	}
+}
+
+// buildTrain turns a merged run into a train with timetable and position.
+func buildTrain(run *trainRun, now time.Time) models.Train {
+	stops := make([]*observedStop, 0, len(run.stops))
+	for _, stop := range run.stops {
+		if !stop.time().IsZero() {
+			stops = append(stops, stop)
+		}
+	}
+	sort.Slice(stops, func(i, j int) bool {
+		return stops[i].time().Before(stops[j].time())
+	})
+
+	// Delays carry forward to stops nobody has reported on yet
+	carried := 0
+	for _, stop := range stops {
+		if stop.observed {
+			carried = stop.departureDelay
+			continue
+		}
+		stop.arrivalDelay = carried
+		stop.departureDelay = carried
+	}
+
+	journey := run.journey
+	train := models.Train{
+		ID:         run.identity.ID,
+		TripID:     run.identity.TripID,
+		Name:       journey.Name,
+		Category:   journey.Category,
+		Number:     journey.Number,
+		Operator:   journey.Operator,
+		To:         journey.To,
+		Cancelled:  run.cancelled,
+		Realtime:   true,
+		LastUpdate: run.fetchedAt.Format(time.RFC3339),
+	}
+	if train.Name == "" {
+		train.Name = strings.TrimSpace(journey.Category + " " + journey.Number)
+	}
+	if len(stops) == 0 {
+		return train
+	}
+
+	first, last := stops[0], stops[len(stops)-1]
+	train.From = first.station.Name
+	if train.To == "" {
+		train.To = last.station.Name
+	}
+	train.DepartureTime = formatClock(first.departure)
+	train.ArrivalTime = formatClock(last.arrival)
+
+	// Timetable and current stop
+	train.Timetable = make([]models.TrainStop, len(stops))
+	current := -1
+	for i, stop := range stops {
+		passed := now.After(stop.actualDeparture()) && i < len(stops)-1
+		atStation := !now.Before(stop.actualArrival()) && !now.After(stop.actualDeparture())
+		if atStation && current < 0 {
+			current = i
+		}
+
+		train.Timetable[i] = models.TrainStop{
+			Station:          stop.station.toStation(),
+			ArrivalTime:      formatClock(stop.arrival),
+			DepartureTime:    formatClock(stop.departure),
+			ArrivalDelay:     stop.arrivalDelay,
+			DepartureDelay:   stop.departureDelay,
+			Platform:         stop.platform,
+			IsPassed:         passed && !atStation,
+			IsSkipped:        run.cancelled,
+			IsCurrentStation: false,
+		}
+	}
+	if current < 0 {
+		// Between stations: the next stop not yet passed is current
+		for i := range train.Timetable {
+			if !train.Timetable[i].IsPassed {
+				current = i
+				break
+			}
+		}
+	}
+	if current < 0 {
+		current = len(stops) - 1
+	}
+	train.Timetable[current].IsCurrentStation = true
+	train.CurrentStation = train.Timetable[current].Station
+	train.Delay = stops[current].departureDelay
+
+	// Position along the segment leading to the current stop
+	next := stops[current]
+	position := models.Position{Lat: next.station.Coordinate.Y, Lng: next.station.Coordinate.X}
+	if current > 0 && now.Before(next.actualArrival()) {
+		prev := stops[current-1]
+		from, to := prev.actualDeparture(), next.actualArrival()
+
+		progress := 0.0
+		if segment := to.Sub(from); segment > 0 {
+			progress = float64(now.Sub(from)) / float64(segment)
+		}
+		if progress < 0 {
+			progress = 0
+		}
+		if progress > 1 {
+			progress = 1
+		}
+
+		position.Lat = prev.station.Coordinate.Y + (next.station.Coordinate.Y-prev.station.Coordinate.Y)*progress
+		position.Lng = prev.station.Coordinate.X + (next.station.Coordinate.X-prev.station.Coordinate.X)*progress
+
+		train.Direction = calculateBearing(prev.station.Coordinate.Y, prev.station.Coordinate.X,
+			next.station.Coordinate.Y, next.station.Coordinate.X)
+		if hours := to.Sub(from).Hours(); hours > 0 && progress > 0 && progress < 1 {
+			distance := haversineDistance(prev.station.Coordinate.Y, prev.station.Coordinate.X,
+				next.station.Coordinate.Y, next.station.Coordinate.X)
+			train.Speed = int(distance / hours)
+		}
+	} else if current < len(stops)-1 {
+		// Standing at a station: face the next stop
+		after := stops[current+1]
+		train.Direction = calculateBearing(next.station.Coordinate.Y, next.station.Coordinate.X,
+			after.station.Coordinate.Y, after.station.Coordinate.X)
+	}
+	train.Position = &position
+
+	return train
+}
+
+// toStation converts an upstream station into the API model.
+func (st TransportStation) toStation() *models.Station {
+	return &models.Station{
+		ID:         st.ID,
+		Name:       st.Name,
+		Coordinate: models.Coordinate{X: st.Coordinate.X, Y: st.Coordinate.Y},
+	}
+}
+
+// parseOpenDataTime parses an opendata timestamp; zero time if absent.
+func parseOpenDataTime(value *string) time.Time {
+	if value == nil || *value == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(openDataTimeLayout, *value); err == nil {
+		return t
+	}
+	if t, err := time.Parse(time.RFC3339, *value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// formatClock formats a time as Swiss local HH:MM:SS, like GTFS timetables.
+func formatClock(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(swissLocation).Format("15:04:05")
+}
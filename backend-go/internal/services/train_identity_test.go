@@ -0,0 +1,151 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// identityGTFS has the daily IC 1 leaving Zürich HB at 23:30 and reaching
+// Bern at 00:40.
+func identityGTFS(t *testing.T) *GTFSService {
+	t.Helper()
+	return loadTestGTFS(t, map[string]string{
+		"calendar.txt": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+			"daily,1,1,1,1,1,1,1,20240101,20301231\n",
+		"trips.txt": "route_id,service_id,trip_id,trip_short_name,direction_id\n" +
+			"1,daily,ic1-late,IC 1,0\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"ic1-late,,23:30:00,8503000,1\nic1-late,24:40:00,,8507000,2\n",
+	})
+}
+
+var (
+	zurichHB = TransportStation{ID: "8503000", Name: "Zürich HB", Coordinate: TransportCoordinate{X: 8.540192, Y: 47.378177}}
+	bern     = TransportStation{ID: "8507000", Name: "Bern", Coordinate: TransportCoordinate{X: 7.439122, Y: 46.948825}}
+)
+
+func openDataTime(value string) *string {
+	return &value
+}
+
+// boardJourney is a journey on the board of station, departing (or, without
+// departure, arriving) at the given time.
+func boardJourney(category, number string, station TransportStation, arrival, departure string, delay int) StationBoardJourney {
+	journey := StationBoardJourney{
+		Category: category,
+		Number:   number,
+		Stop:     StationBoardStop{Station: station, Delay: delay},
+	}
+	if arrival != "" {
+		journey.Stop.Arrival = openDataTime(arrival)
+	}
+	if departure != "" {
+		journey.Stop.Departure = openDataTime(departure)
+	}
+	return journey
+}
+
+func TestResolve(t *testing.T) {
+	r := NewTrainIdentityResolver(identityGTFS(t))
+
+	for _, tc := range []struct {
+		name    string
+		journey StationBoardJourney
+		id      string
+		tripID  string
+		day     string
+	}{
+		{"matched", boardJourney("IC", "1", zurichHB, "", "2025-03-14T23:30:00+0100", 0),
+			"ic1-late", "ic1-late", "2025-03-14"},
+		{"matched past midnight", boardJourney("IC", "1", bern, "2025-03-15T00:40:00+0100", "", 3),
+			"ic1-late", "ic1-late", "2025-03-14"},
+		{"matched within tolerance", boardJourney("IC", "1", zurichHB, "", "2025-03-14T23:32:00+0100", 0),
+			"ic1-late", "ic1-late", "2025-03-14"},
+		{"beyond tolerance", boardJourney("IC", "1", zurichHB, "", "2025-03-14T23:40:00+0100", 0),
+			"IC-1-20250314", "", "2025-03-14"},
+		{"unmatched", boardJourney("IR", "2517", zurichHB, "", "2025-03-14T23:50:00+0100", 0),
+			"IR-2517-20250314", "", "2025-03-14"},
+		{"unmatched past midnight", boardJourney("IR", "2517", bern, "2025-03-15T00:20:00+0100", "2025-03-15T00:22:00+0100", 0),
+			"IR-2517-20250314", "", "2025-03-14"},
+		{"unmatched next morning", boardJourney("IR", "2517", bern, "", "2025-03-15T06:20:00+0100", 0),
+			"IR-2517-20250315", "", "2025-03-15"},
+	} {
+		identity := r.Resolve(tc.journey)
+		if identity.ID != tc.id || identity.TripID != tc.tripID || identity.OperatingDay.Format("2006-01-02") != tc.day {
+			t.Errorf("%s: identity = %+v, want %s (trip %q) on %s", tc.name, identity, tc.id, tc.tripID, tc.day)
+		}
+		if identity.OperatingDay.Hour() != 0 {
+			t.Errorf("%s: operating day %v is not a Swiss midnight", tc.name, identity.OperatingDay)
+		}
+	}
+}
+
+func TestMergeBoards(t *testing.T) {
+	r := NewTrainIdentityResolver(identityGTFS(t))
+	fetched := time.Date(2025, 3, 14, 23, 55, 0, 0, time.UTC)
+
+	// The IR leaves Zürich before midnight and is on Bern's board after
+	ir := boardJourney("IR", "2517", zurichHB, "", "2025-03-14T23:50:00+0100", 2)
+	ir.To = "Bern"
+	ir.PassList = []PassListStop{
+		{Station: zurichHB, Departure: openDataTime("2025-03-14T23:50:00+0100"), Delay: 2},
+		{Station: bern, Arrival: openDataTime("2025-03-15T00:20:00+0100"), Delay: 2},
+	}
+	irAtBern := boardJourney("IR", "2517", bern, "2025-03-15T00:20:00+0100", "2025-03-15T00:22:00+0100", 4)
+	irAtBern.To = "Genève"
+	irAtBern.Stop.Platform = "3"
+
+	// The IC 1 is on Bern's board only, cancelled
+	ic := boardJourney("IC", "1", bern, "2025-03-15T00:40:00+0100", "", 0)
+	ic.Cancelled = true
+
+	boards := []*LiveBoard{
+		{StationID: zurichHB.ID, FetchedAt: fetched, Board: &StationBoardResponse{Stationboard: []StationBoardJourney{ir}}},
+		{StationID: bern.ID, FetchedAt: fetched.Add(time.Minute), Board: &StationBoardResponse{Stationboard: []StationBoardJourney{irAtBern, ic}}},
+		{StationID: "8500010", FetchedAt: fetched, Error: "upstream down"},
+	}
+
+	now := time.Date(2025, 3, 14, 23, 10, 0, 0, time.UTC) // 00:10 in Zürich
+	trains := r.MergeBoards(boards, now)
+	if len(trains) != 2 {
+		t.Fatalf("trains = %+v, want the IR and the IC once each", trains)
+	}
+	irTrain, icTrain := trains[0], trains[1]
+
+	// One IR across both boards, its stops ordered, delays and platform from
+	// the later board
+	if irTrain.ID != "IR-2517-20250314" || irTrain.TripID != "" || irTrain.Name != "IR 2517" {
+		t.Errorf("IR = %+v", irTrain)
+	}
+	if len(irTrain.Timetable) != 2 || irTrain.Timetable[0].Station.ID != zurichHB.ID ||
+		irTrain.Timetable[1].Station.ID != bern.ID || irTrain.Timetable[1].Platform != "3" ||
+		irTrain.Timetable[1].ArrivalDelay != 4 {
+		t.Errorf("IR timetable = %+v", irTrain.Timetable)
+	}
+	if !irTrain.Timetable[0].IsPassed || !irTrain.Timetable[1].IsCurrentStation || irTrain.Delay != 4 ||
+		irTrain.From != "Zürich HB" || irTrain.To != "Genève" {
+		t.Errorf("IR = %+v, want between Zürich and Bern, 4 minutes late", irTrain)
+	}
+	if irTrain.Position == nil || irTrain.Position.Lat >= zurichHB.Coordinate.Y || irTrain.Position.Lat <= bern.Coordinate.Y {
+		t.Errorf("IR position = %+v, want between Zürich and Bern", irTrain.Position)
+	}
+
+	// The IC has its timetable from GTFS and is cancelled everywhere
+	if icTrain.ID != "ic1-late" || icTrain.RouteID != "1" || !icTrain.Cancelled || len(icTrain.Timetable) != 2 {
+		t.Fatalf("IC = %+v", icTrain)
+	}
+	if icTrain.DepartureTime != "23:30:00" || icTrain.ArrivalTime != "00:40:00" || !icTrain.Timetable[0].IsSkipped {
+		t.Errorf("IC = %+v", icTrain)
+	}
+
+	// Both run at 00:10; at 00:30 the IR has reached Bern (00:20, 4 minutes
+	// late) and only the IC is left
+	running := r.RunningTrains(boards, now)
+	if len(running) != 2 {
+		t.Errorf("running = %d trains, want both", len(running))
+	}
+	later := time.Date(2025, 3, 14, 23, 30, 0, 0, time.UTC)
+	if running := r.RunningTrains(boards, later); len(running) != 1 || running[0].ID != "ic1-late" {
+		t.Errorf("running at 00:30 = %+v, want the IC", running)
+	}
+}
@@ -0,0 +1,131 @@
+// Package services - Transport Provider
+// This file defines the upstream timetable provider abstraction.
+package services
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// Supported upstream timetable providers.
+const (
+	ProviderOpenData = "opendata" // transport.opendata.ch
+	ProviderSearchCh = "searchch" // search.ch timetable API
+)
+
+// TransportProvider is an upstream timetable API.
+// All providers map their responses onto the transport.opendata.ch shapes
+// so handlers don't need to know which API answered.
+type TransportProvider interface {
+	Name() string
+	SearchStations(query string) ([]models.Station, error)
+	GetStationBoard(stationID string, limit int) (*StationBoardResponse, error)
+	GetConnections(from, to string, limit int) (*ConnectionsResponse, error)
+	GetRateLimitStatus() map[string]interface{}
+}
+
+// FallbackTransport queries a primary provider and falls back to a
+// secondary one when the primary fails (rate limit, outage, bad response).
+type FallbackTransport struct {
+	primary   TransportProvider
+	secondary TransportProvider
+}
+
+// NewFallbackTransport creates a provider chain. secondary may be nil.
+func NewFallbackTransport(primary, secondary TransportProvider) *FallbackTransport {
+	return &FallbackTransport{
+		primary:   primary,
+		secondary: secondary,
+	}
+}
+
+// Name returns the provider chain name, e.g. "opendata+searchch".
+func (f *FallbackTransport) Name() string {
+	if f.secondary == nil {
+		return f.primary.Name()
+	}
+	return f.primary.Name() + "+" + f.secondary.Name()
+}
+
+// SearchStations searches stations on the primary provider, then the secondary.
+func (f *FallbackTransport) SearchStations(query string) ([]models.Station, error) {
+	stations, err := f.primary.SearchStations(query)
+	if err == nil || f.secondary == nil {
+		return stations, err
+	}
+	f.logFallback("search", err)
+	return f.secondary.SearchStations(query)
+}
+
+// GetStationBoard fetches a stationboard from the primary provider, then the secondary.
+func (f *FallbackTransport) GetStationBoard(stationID string, limit int) (*StationBoardResponse, error) {
+	board, err := f.primary.GetStationBoard(stationID, limit)
+	if err == nil || f.secondary == nil {
+		return board, err
+	}
+	f.logFallback("stationboard", err)
+	return f.secondary.GetStationBoard(stationID, limit)
+}
+
+// GetConnections fetches connections from the primary provider, then the secondary.
+func (f *FallbackTransport) GetConnections(from, to string, limit int) (*ConnectionsResponse, error) {
+	connections, err := f.primary.GetConnections(from, to, limit)
+	if err == nil || f.secondary == nil {
+		return connections, err
+	}
+	f.logFallback("connections", err)
+	return f.secondary.GetConnections(from, to, limit)
+}
+
+// GetRateLimitStatus returns the rate limit status of every provider in the chain.
+func (f *FallbackTransport) GetRateLimitStatus() map[string]interface{} {
+	status := map[string]interface{}{
+		f.primary.Name(): f.primary.GetRateLimitStatus(),
+	}
+	if f.secondary != nil {
+		status[f.secondary.Name()] = f.secondary.GetRateLimitStatus()
+	}
+	return status
+}
+
+func (f *FallbackTransport) logFallback(operation string, err error) {
+	log.Warn().
+		Err(err).
+		Str("operation", operation).
+		Str("primary", f.primary.Name()).
+		Str("fallback", f.secondary.Name()).
+		Msg("Primary transport provider failed, using fallback")
+}
+
+// NewTransportProvider builds the provider chain selected by configuration.
+// fallback may be empty (or "none") to disable the secondary provider.
+func NewTransportProvider(primary, fallback, openDataURL, searchChURL string) (*FallbackTransport, error) {
+	build := func(name string) (TransportProvider, error) {
+		switch name {
+		case ProviderOpenData:
+			return NewSwissTransportService(openDataURL), nil
+		case ProviderSearchCh:
+			return NewSearchChService(searchChURL), nil
+		default:
+			return nil, fmt.Errorf("unknown transport provider %q", name)
+		}
+	}
+
+	first, err := build(primary)
+	if err != nil {
+		return nil, err
+	}
+
+	if fallback == "" || fallback == "none" || fallback == primary {
+		return NewFallbackTransport(first, nil), nil
+	}
+
+	second, err := build(fallback)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFallbackTransport(first, second), nil
+}
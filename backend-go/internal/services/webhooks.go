@@ -0,0 +1,565 @@
+// Package services - Webhook Dispatcher
+// This file delivers alerts to webhooks: signed POSTs retried with
+// exponential backoff, a log of recent deliveries and a dead-letter list of
+// those that gave up.
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// Webhook request headers. The signature is "sha256=" and the hex HMAC-SHA256
+// of "<timestamp>.<body>" with the rule's secret.
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// maxWebhookBackoff caps the wait between two attempts.
+const maxWebhookBackoff = 10 * time.Minute
+
+// ErrWebhookQueueFull is recorded on deliveries dropped because the queue
+// was full.
+var ErrWebhookQueueFull = errors.New("webhook queue is full")
+
+// ErrWebhookAddress is returned for webhooks on addresses the server must
+// not reach: loopback, private, link-local, unspecified and multicast ones,
+// unless they are allowed by WebhookConfig.AllowedNetworks.
+var ErrWebhookAddress = errors.New("webhook address is not allowed")
+
+// blockedNetworks are reserved ranges the net.IP predicates don't cover.
+var blockedNetworks = mustParseNetworks("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4")
+
+// SignWebhook returns the signature header of a delivery body sent at
+// timestamp (Unix seconds). Receivers recompute it to verify deliveries.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookConfig configures a WebhookDispatcher. Zero values get defaults.
+type WebhookConfig struct {
+	Workers        int           // concurrent deliveries
+	QueueSize      int           // deliveries waiting for a worker
+	MaxAttempts    int           // attempts before a delivery is dead
+	RetryBase      time.Duration // wait before the first retry, doubled after each
+	Timeout        time.Duration // per attempt
+	LogSize        int           // deliveries kept in the log
+	DeadLetterSize int           // dead deliveries kept for redelivery
+
+	// AllowedNetworks may be reached although they are internal, e.g. a
+	// receiver on the same host during development
+	AllowedNetworks []*net.IPNet
+}
+
+// WebhookQuery selects deliveries from the log. Zero values match
+// everything but the owner, which is required.
+type WebhookQuery struct {
+	Owner  string
+	RuleID string
+	Status string
+	Limit  int // at most this many, newest first (0 for all)
+}
+
+// WebhookStats summarizes the dispatcher since startup.
+type WebhookStats struct {
+	Queued      int    `json:"queued"`
+	Logged      int    `json:"logged"`
+	DeadLetters int    `json:"deadLetters"`
+	Delivered   uint64 `json:"delivered"`
+	Retried     uint64 `json:"retried"`
+	Dead        uint64 `json:"dead"`
+}
+
+// webhookJob is a delivery with what sending it takes.
+type webhookJob struct {
+	delivery *models.WebhookDelivery
+	body     []byte
+	secret   string
+}
+
+// WebhookDispatcher sends alerts to webhooks in the background. Deliveries
+// answered with 2xx are done; network errors, timeouts, 408, 429 and 5xx
+// are retried with exponential backoff and jitter (honoring Retry-After);
+// other statuses, addresses that may not be reached and running out of
+// attempts move the delivery to the dead-letter list, from where it can be redelivered. Everything is kept in
+// memory: pending retries are lost on restart.
+type WebhookDispatcher struct {
+	cfg    WebhookConfig
+	client *http.Client
+	queue  chan *webhookJob
+
+	mu        sync.RWMutex
+	log       []*models.WebhookDelivery // oldest first
+	dead      []*webhookJob             // oldest first
+	delivered uint64
+	retried   uint64
+	deadTotal uint64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWebhookDispatcher creates a dispatcher; Run starts its workers.
+func NewWebhookDispatcher(cfg WebhookConfig) *WebhookDispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 6
+	}
+	if cfg.RetryBase <= 0 {
+		cfg.RetryBase = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.LogSize <= 0 {
+		cfg.LogSize = 1000
+	}
+	if cfg.DeadLetterSize <= 0 {
+		cfg.DeadLetterSize = 500
+	}
+
+	d := &WebhookDispatcher{
+		cfg:   cfg,
+		queue: make(chan *webhookJob, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+
+	// Every connection is checked after DNS resolution, so a host that
+	// resolves differently at delivery than when the rule was saved still
+	// can't reach internal addresses. Proxies are bypassed for the same
+	// reason.
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !d.allowed(ip) {
+				return fmt.Errorf("%w: %s", ErrWebhookAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	d.client = &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		// A redirect would resend the signed body somewhere else
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// CheckURL resolves the host of a webhook URL and fails with
+// ErrWebhookAddress if any of its addresses may not be reached.
+func (d *WebhookDispatcher) CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !d.allowed(ip) {
+			return fmt.Errorf("%w: %s", ErrWebhookAddress, host)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !d.allowed(addr.IP) {
+			return fmt.Errorf("%w: %s", ErrWebhookAddress, addr.IP)
+		}
+	}
+	return nil
+}
+
+// allowed reports whether a webhook may be delivered to ip.
+func (d *WebhookDispatcher) allowed(ip net.IP) bool {
+	for _, network := range d.cfg.AllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseNetworks parses IPs and CIDRs; a bare IP is a network of one.
+func ParseNetworks(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, entry := range list {
+		cidr := entry
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP or CIDR", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// mustParseNetworks parses CIDRs known to be valid.
+func mustParseNetworks(list ...string) []*net.IPNet {
+	networks, err := ParseNetworks(list)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
+// Run starts the workers and returns.
+func (d *WebhookDispatcher) Run() {
+	log.Info().
+		Int("workers", d.cfg.Workers).
+		Int("maxAttempts", d.cfg.MaxAttempts).
+		Msg("🔔 Webhook dispatcher started")
+
+	for i := 0; i < d.cfg.Workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for {
+				select {
+				case job := <-d.queue:
+					d.attempt(job)
+				case <-d.done:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop stops the workers after their current attempt.
+func (d *WebhookDispatcher) Stop() {
+	close(d.done)
+	d.wg.Wait()
+}
+
+// Dispatch queues the delivery of an alert to a rule's webhook and returns
+// the delivery as logged.
+func (d *WebhookDispatcher) Dispatch(rule models.AlertRule, alert models.Alert) models.WebhookDelivery {
+	delivery := &models.WebhookDelivery{
+		ID:        uuid.New().String(),
+		Owner:     rule.Owner,
+		RuleID:    rule.ID,
+		AlertID:   alert.ID,
+		Condition: alert.Condition,
+		URL:       rule.WebhookURL,
+		Status:    models.DeliveryPending,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Alert:     &alert,
+	}
+	job := &webhookJob{delivery: delivery, secret: rule.Secret}
+
+	body, err := json.Marshal(alert)
+	d.mu.Lock()
+	d.appendLog(delivery)
+	d.mu.Unlock()
+	if err != nil {
+		d.kill(job, err.Error())
+		return d.snapshot(delivery)
+	}
+	job.body = body
+	d.enqueue(job)
+	return d.snapshot(delivery)
+}
+
+// Redeliver takes a dead delivery of the owner off the dead-letter list and
+// queues it again, with all its attempts, to the rule's current webhook.
+func (d *WebhookDispatcher) Redeliver(owner, id string, rule models.AlertRule) (models.WebhookDelivery, bool) {
+	d.mu.Lock()
+	var job *webhookJob
+	for i, dead := range d.dead {
+		if dead.delivery.ID == id && dead.delivery.Owner == owner {
+			job = dead
+			d.dead = append(d.dead[:i:i], d.dead[i+1:]...)
+			break
+		}
+	}
+	if job == nil {
+		d.mu.Unlock()
+		return models.WebhookDelivery{}, false
+	}
+	job.secret = rule.Secret
+	job.delivery.URL = rule.WebhookURL
+	job.delivery.Status = models.DeliveryPending
+	job.delivery.Attempts = 0
+	job.delivery.NextAttemptAt = ""
+	d.appendLog(job.delivery)
+	d.mu.Unlock()
+
+	d.enqueue(job)
+	return d.snapshot(job.delivery), true
+}
+
+// Deliveries returns the owner's logged deliveries, newest first, and how
+// many matched before the limit was applied.
+func (d *WebhookDispatcher) Deliveries(q WebhookQuery) ([]models.WebhookDelivery, int) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	total := 0
+	for i := len(d.log) - 1; i >= 0; i-- {
+		delivery := d.log[i]
+		if delivery.Owner != q.Owner ||
+			(q.RuleID != "" && delivery.RuleID != q.RuleID) ||
+			(q.Status != "" && delivery.Status != q.Status) {
+			continue
+		}
+		total++
+		if q.Limit <= 0 || len(deliveries) < q.Limit {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries, total
+}
+
+// DeadLetters returns the owner's dead deliveries, newest first.
+func (d *WebhookDispatcher) DeadLetters(owner string) []models.WebhookDelivery {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for i := len(d.dead) - 1; i >= 0; i-- {
+		if delivery := d.dead[i].delivery; delivery.Owner == owner {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries
+}
+
+// DeadLetter returns one of the owner's dead deliveries.
+func (d *WebhookDispatcher) DeadLetter(owner, id string) (models.WebhookDelivery, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, job := range d.dead {
+		if job.delivery.ID == id && job.delivery.Owner == owner {
+			return *job.delivery, true
+		}
+	}
+	return models.WebhookDelivery{}, false
+}
+
+// Stats returns the dispatcher's queue, log and delivery counts.
+func (d *WebhookDispatcher) Stats() WebhookStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return WebhookStats{
+		Queued:      len(d.queue),
+		Logged:      len(d.log),
+		DeadLetters: len(d.dead),
+		Delivered:   d.delivered,
+		Retried:     d.retried,
+		Dead:        d.deadTotal,
+	}
+}
+
+// enqueue hands a job to the workers, or kills it if the queue is full.
+func (d *WebhookDispatcher) enqueue(job *webhookJob) {
+	select {
+	case d.queue <- job:
+	case <-d.done:
+	default:
+		d.kill(job, ErrWebhookQueueFull.Error())
+	}
+}
+
+// attempt sends a job once and records the outcome.
+func (d *WebhookDispatcher) attempt(job *webhookJob) {
+	status, retryAfter, err := d.send(job)
+
+	d.mu.Lock()
+	delivery := job.delivery
+	delivery.Attempts++
+	delivery.LastAttemptAt = time.Now().Format(time.RFC3339)
+	delivery.ResponseStatus = status
+	delivery.NextAttemptAt = ""
+	if err == nil {
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = delivery.LastAttemptAt
+		d.delivered++
+		d.mu.Unlock()
+		return
+	}
+	delivery.LastError = err.Error()
+	retryable := (status == 0 && !errors.Is(err, ErrWebhookAddress)) || status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests || status >= 500
+	if !retryable || delivery.Attempts >= d.cfg.MaxAttempts {
+		d.mu.Unlock()
+		d.kill(job, err.Error())
+		return
+	}
+
+	wait := d.backoff(delivery.Attempts)
+	if retryAfter > wait && retryAfter <= maxWebhookBackoff {
+		wait = retryAfter
+	}
+	delivery.NextAttemptAt = time.Now().Add(wait).Format(time.RFC3339)
+	d.retried++
+	d.mu.Unlock()
+
+	log.Warn().
+		Err(err).
+		Str("delivery", delivery.ID).
+		Int("attempt", delivery.Attempts).
+		Dur("retryIn", wait).
+		Msg("Webhook delivery failed")
+
+	time.AfterFunc(wait, func() { d.enqueue(job) })
+}
+
+// send POSTs a job's body, signed, and returns the response status (0 if
+// there was none) and its Retry-After.
+func (d *WebhookDispatcher) send(job *webhookJob) (int, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, job.delivery.URL, bytes.NewReader(job.body))
+	if err != nil {
+		return 0, 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SwissRailway-Webhooks/1.0")
+	req.Header.Set(WebhookIDHeader, job.delivery.ID)
+	req.Header.Set(WebhookEventHeader, job.delivery.Condition)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(job.secret, timestamp, job.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, 0, nil
+	}
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return resp.StatusCode, retryAfter, fmt.Errorf("webhook answered %d", resp.StatusCode)
+}
+
+// backoff returns the wait after the given number of attempts: the base
+// doubled per attempt, capped, with up to 20% jitter so failed receivers
+// aren't hit by every retry at once.
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.RetryBase
+	for i := 1; i < attempts && wait < maxWebhookBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxWebhookBackoff {
+		wait = maxWebhookBackoff
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/5+1))
+}
+
+// kill marks a job dead and moves it to the dead-letter list, dropping the
+// oldest beyond its capacity.
+func (d *WebhookDispatcher) kill(job *webhookJob, reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	job.delivery.Status = models.DeliveryDead
+	job.delivery.LastError = reason
+	job.delivery.NextAttemptAt = ""
+	d.deadTotal++
+	d.dead = append(d.dead, job)
+	if excess := len(d.dead) - d.cfg.DeadLetterSize; excess > 0 {
+		d.dead = append(d.dead[:0:0], d.dead[excess:]...)
+	}
+
+	log.Error().
+		Str("delivery", job.delivery.ID).
+		Str("rule", job.delivery.RuleID).
+		Int("attempts", job.delivery.Attempts).
+		Str("reason", reason).
+		Msg("Webhook delivery moved to dead letters")
+}
+
+// appendLog adds a delivery to the log, dropping the oldest beyond its
+// capacity. A redelivered delivery moves to the end. Callers must hold d.mu.
+func (d *WebhookDispatcher) appendLog(delivery *models.WebhookDelivery) {
+	for i, logged := range d.log {
+		if logged == delivery {
+			d.log = append(d.log[:i:i], d.log[i+1:]...)
+			break
+		}
+	}
+	d.log = append(d.log, delivery)
+	if excess := len(d.log) - d.cfg.LogSize; excess > 0 {
+		d.log = append(d.log[:0:0], d.log[excess:]...)
+	}
+}
+
+// snapshot copies a delivery under the lock.
+func (d *WebhookDispatcher) snapshot(delivery *models.WebhookDelivery) models.WebhookDelivery {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return *delivery
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// receiver is a local webhook endpoint answering with the statuses it is
+// given, one per request, and then 200.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header // sent with the answers
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	at     time.Time
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+	rcv := &receiver{statuses: statuses, header: make(http.Header)}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		rcv.requests = append(rcv.requests, receivedRequest{at: time.Now(), header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(rcv.statuses) > 0 {
+			status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+		}
+		for name, values := range rcv.header {
+			w.Header()[name] = values
+		}
+		rcv.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) received() []receivedRequest {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]receivedRequest(nil), rcv.requests...)
+}
+
+// newTestDispatcher returns a running dispatcher allowed to reach the
+// local receivers.
+func newTestDispatcher(t *testing.T, cfg WebhookConfig) *WebhookDispatcher {
+	t.Helper()
+	if cfg.RetryBase == 0 {
+		cfg.RetryBase = 10 * time.Millisecond
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.AllowedNetworks == nil {
+		cfg.AllowedNetworks = mustParseNetworks("127.0.0.1/32", "::1/128")
+	}
+	d := NewWebhookDispatcher(cfg)
+	d.Run()
+	t.Cleanup(d.Stop)
+	return d
+}
+
+func testRule(url string) models.AlertRule {
+	return models.AlertRule{ID: "rule-1", Owner: "user:alice", WebhookURL: url, Secret: testSecret, Enabled: true}
+}
+
+func testAlert() models.Alert {
+	return models.Alert{ID: "alert-1", RuleID: "rule-1", Condition: models.AlertCancelled, Message: "IC 1 is cancelled"}
+}
+
+// waitForStatus waits until a delivery has the status and returns it.
+func waitForStatus(t *testing.T, d *WebhookDispatcher, id, status string) models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, _ := d.Deliveries(WebhookQuery{Owner: "user:alice"})
+		for _, delivery := range deliveries {
+			if delivery.ID == id && delivery.Status == status {
+				return delivery
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery %s never became %s: %+v", id, status, deliveries)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookSignatureVerifies(t *testing.T) {
+	rcv := newReceiver(t)
+	d := newTestDispatcher(t, WebhookConfig{})
+
+	delivery := d.Dispatch(testRule(rcv.URL), testAlert())
+	waitForStatus(t, d, delivery.ID, models.DeliveryDelivered)
+
+	requests := rcv.received()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if got := req.header.Get(WebhookIDHeader); got != delivery.ID {
+		t.Errorf("%s = %q, want %q", WebhookIDHeader, got, delivery.ID)
+	}
+	if got := req.header.Get(WebhookEventHeader); got != models.AlertCancelled {
+		t.Errorf("%s = %q, want %q", WebhookEventHeader, got, models.AlertCancelled)
+	}
+
+	// What a receiver does: recompute the signature from the timestamp and body
+	timestamp, err := strconv.ParseInt(req.header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("bad timestamp header: %v", err)
+	}
+	signature := req.header.Get(WebhookSignatureHeader)
+	if !hmac.Equal([]byte(signature), []byte(SignWebhook(testSecret, timestamp, req.body))) {
+		t.Errorf("signature %q doesn't verify with the rule's secret", signature)
+	}
+	if hmac.Equal([]byte(signature), []byte(SignWebhook("another secret", timestamp, req.body))) {
+		t.Error("signature verifies with another secret")
+	}
+	if hmac.Equal([]byte(signature), []byte(SignWebhook(testSecret, timestamp+1, req.body))) {
+		t.Error("signature verifies with another timestamp")
+	}
+	if !strings.Contains(string(req.body), `"message":"IC 1 is cancelled"`) {
+		t.Errorf("body %s doesn't hold the alert", req.body)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	rcv := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	d := newTestDispatcher(t, WebhookConfig{MaxAttempts: 5})
+
+	// The 429 asks for a second, far more than the backoff
+	rcv.mu.Lock()
+	rcv.header.Set("Retry-After", "1")
+	rcv.mu.Unlock()
+
+	delivery := d.Dispatch(testRule(rcv.URL), testAlert())
+	delivered := waitForStatus(t, d, delivery.ID, models.DeliveryDelivered)
+	if delivered.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", delivered.Attempts)
+	}
+
+	requests := rcv.received()
+	if len(requests) != 3 {
+		t.Fatalf("receiver got %d requests, want 3", len(requests))
+	}
+	if wait := requests[2].at.Sub(requests[1].at); wait < time.Second {
+		t.Errorf("retried %v after a 429 with Retry-After: 1, want at least 1s", wait)
+	}
+	if stats := d.Stats(); stats.Retried != 2 || stats.Delivered != 1 {
+		t.Errorf("stats = %+v, want 2 retries and 1 delivery", stats)
+	}
+}
+
+func TestWebhookDeadAfterMaxAttempts(t *testing.T) {
+	rcv := newReceiver(t, 500, 500, 500, 500)
+	d := newTestDispatcher(t, WebhookConfig{MaxAttempts: 3})
+
+	delivery := d.Dispatch(testRule(rcv.URL), testAlert())
+	dead := waitForStatus(t, d, delivery.ID, models.DeliveryDead)
+	if dead.Attempts != 3 || dead.ResponseStatus != 500 {
+		t.Errorf("dead delivery = %+v, want 3 attempts answered 500", dead)
+	}
+	if got := len(rcv.received()); got != 3 {
+		t.Errorf("receiver got %d requests, want 3", got)
+	}
+	if _, ok := d.DeadLetter("user:alice", delivery.ID); !ok {
+		t.Error("delivery is not in the dead letters")
+	}
+}
+
+func TestWebhookDeadOnClientError(t *testing.T) {
+	rcv := newReceiver(t, http.StatusBadRequest)
+	d := newTestDispatcher(t, WebhookConfig{MaxAttempts: 5})
+
+	delivery := d.Dispatch(testRule(rcv.URL), testAlert())
+	dead := waitForStatus(t, d, delivery.ID, models.DeliveryDead)
+	if dead.Attempts != 1 || dead.ResponseStatus != http.StatusBadRequest {
+		t.Errorf("dead delivery = %+v, want 1 attempt answered 400", dead)
+	}
+	if got := len(d.DeadLetters("user:alice")); got != 1 {
+		t.Errorf("%d dead letters, want 1", got)
+	}
+}
+
+func TestWebhookRedeliver(t *testing.T) {
+	failing := newReceiver(t, http.StatusGone)
+	d := newTestDispatcher(t, WebhookConfig{})
+
+	delivery := d.Dispatch(testRule(failing.URL), testAlert())
+	waitForStatus(t, d, delivery.ID, models.DeliveryDead)
+
+	if _, ok := d.Redeliver("user:bob", delivery.ID, testRule(failing.URL)); ok {
+		t.Error("another owner redelivered the delivery")
+	}
+	if _, ok := d.Redeliver("user:alice", "unknown", testRule(failing.URL)); ok {
+		t.Error("an unknown delivery was redelivered")
+	}
+
+	// Redeliveries go to the rule's current webhook with its current secret
+	fixed := newReceiver(t)
+	rule := testRule(fixed.URL)
+	rule.Secret = "fedcba9876543210fedcba9876543210"
+	if _, ok := d.Redeliver("user:alice", delivery.ID, rule); !ok {
+		t.Fatal("dead delivery wasn't redelivered")
+	}
+	delivered := waitForStatus(t, d, delivery.ID, models.DeliveryDelivered)
+	// A redelivery gets all its attempts again
+	if delivered.Attempts != 1 || delivered.URL != fixed.URL {
+		t.Errorf("redelivered = %+v, want 1 attempt to %s", delivered, fixed.URL)
+	}
+	if got := len(d.DeadLetters("user:alice")); got != 0 {
+		t.Errorf("%d dead letters after redelivery, want 0", got)
+	}
+
+	requests := fixed.received()
+	if len(requests) != 1 {
+		t.Fatalf("fixed receiver got %d requests, want 1", len(requests))
+	}
+	timestamp, _ := strconv.ParseInt(requests[0].header.Get(WebhookTimestampHeader), 10, 64)
+	if got := requests[0].header.Get(WebhookSignatureHeader); got != SignWebhook(rule.Secret, timestamp, requests[0].body) {
+		t.Error("redelivery isn't signed with the rule's current secret")
+	}
+}
+
+func TestWebhookRedirectsNotFollowed(t *testing.T) {
+	target := newReceiver(t)
+	rcv := newReceiver(t, http.StatusFound)
+	rcv.mu.Lock()
+	rcv.header.Set("Location", target.URL)
+	rcv.mu.Unlock()
+	d := newTestDispatcher(t, WebhookConfig{})
+
+	delivery := d.Dispatch(testRule(rcv.URL), testAlert())
+	dead := waitForStatus(t, d, delivery.ID, models.DeliveryDead)
+	if dead.ResponseStatus != http.StatusFound {
+		t.Errorf("response status = %d, want 302", dead.ResponseStatus)
+	}
+	if got := len(target.received()); got != 0 {
+		t.Errorf("redirect target got %d requests, want 0", got)
+	}
+}
+
+func TestWebhookInternalAddressRefused(t *testing.T) {
+	rcv := newReceiver(t)
+	d := newTestDispatcher(t, WebhookConfig{AllowedNetworks: []*net.IPNet{}})
+
+	delivery := d.Dispatch(testRule(rcv.URL), testAlert())
+	dead := waitForStatus(t, d, delivery.ID, models.DeliveryDead)
+	if dead.Attempts != 1 || !strings.Contains(dead.LastError, ErrWebhookAddress.Error()) {
+		t.Errorf("dead delivery = %+v, want 1 attempt refused for its address", dead)
+	}
+	if got := len(rcv.received()); got != 0 {
+		t.Errorf("receiver got %d requests, want 0", got)
+	}
+}
+
+func TestWebhookCheckURL(t *testing.T) {
+	d := NewWebhookDispatcher(WebhookConfig{AllowedNetworks: mustParseNetworks("10.9.0.0/16")})
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://10.0.0.1/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://172.16.0.1/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://224.0.0.1/hook", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+		{"http://localhost/hook", false},
+		{"http://10.9.1.2/hook", true}, // allowed network
+		{"https://8.8.8.8/hook", true},
+	}
+	for _, tt := range tests {
+		err := d.CheckURL(context.Background(), tt.url)
+		if tt.allowed && err != nil {
+			t.Errorf("CheckURL(%s) = %v, want allowed", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrWebhookAddress) {
+			t.Errorf("CheckURL(%s) = %v, want ErrWebhookAddress", tt.url, err)
+		}
+	}
+}
@@ -0,0 +1,1048 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// Buckets of the favorites database. Each holds a bucket per owner: records
+// are JSON keyed by favorite, collection or rule ID, and the index buckets
+// map a station ID, train ID, journey key, collection key or rule key to
+// the owner's record ID. Idempotent responses are keyed by idempotency key
+// and audit entries by sequence number.
+var (
+	bucketMeta         = []byte("meta")
+	bucketStations     = []byte("favorite_stations")
+	bucketTrains       = []byte("favorite_trains")
+	bucketStationIndex = []byte("favorite_stations_by_station")
+	bucketTrainIndex   = []byte("favorite_trains_by_train")
+	bucketJourneys     = []byte("favorite_journeys")
+	bucketJourneyIndex = []byte("favorite_journeys_by_stations")
+	bucketRules        = []byte("alert_rules")
+	bucketRuleIndex    = []byte("alert_rules_by_condition")
+
+	bucketCollections     = []byte("favorite_collections")
+	bucketCollectionIndex = []byte("favorite_collections_by_name")
+
+	bucketResponses = []byte("idempotent_responses")
+	bucketAudit     = []byte("favorite_audit")
+	bucketAuditTime = []byte("favorite_audit_by_time")
+)
+
+// BoltFavorites keeps favorites in an embedded bbolt database file.
+type BoltFavorites struct {
+	db *bolt.DB
+}
+
+// OpenBoltFavorites opens (or creates) the database at path and migrates
+// it to the current schema. Only one process can open the file at a time.
+func OpenBoltFavorites(path string) (*BoltFavorites, error) {
+	if path == "" {
+		return nil, fmt.Errorf("favorites database path is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create favorites database directory: %w", err)
+	}
+
+	// Without a timeout, a second instance on the same file blocks forever
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open favorites database %s: %w", path, err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltFavorites{db: db}, nil
+}
+
+// ListStations returns the owner's favorite stations.
+func (b *BoltFavorites) ListStations(owner string) ([]models.FavoriteStation, error) {
+	var favorites []models.FavoriteStation
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		favorites, err = listStations(tx, owner)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortStations(favorites)
+	return favorites, nil
+}
+
+// GetStation returns one of the owner's favorite stations.
+func (b *BoltFavorites) GetStation(owner, id string) (*models.FavoriteStation, error) {
+	var favorite models.FavoriteStation
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return getOwned(tx, bucketStations, owner, id, &favorite)
+	})
+	if err != nil {
+		return nil, err
+	}
+	favorite.Owner = owner
+	return &favorite, nil
+}
+
+// CreateStation stores a new favorite station.
+func (b *BoltFavorites) CreateStation(favorite *models.FavoriteStation) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		position, err := nextPosition(tx, bucketStations, favorite.Owner)
+		if err != nil {
+			return err
+		}
+		favorite.Position = position
+		favorite.Version = 1
+		return createOwned(tx, bucketStations, bucketStationIndex, favorite.Owner, favorite.ID, favorite.StationID, favorite)
+	})
+}
+
+// UpdateStation applies update to one of the owner's favorite stations.
+// The update must not change the station ID.
+func (b *BoltFavorites) UpdateStation(owner, id string, version int, update func(*models.FavoriteStation)) (*models.FavoriteStation, error) {
+	var favorite models.FavoriteStation
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketStations, owner, id, &favorite); err != nil {
+			return err
+		}
+		if err := checkVersion(favorite.Version, version); err != nil {
+			return err
+		}
+		favorite.Owner = owner
+		update(&favorite)
+		favorite.Version++
+		return putJSON(tx.Bucket(bucketStations).Bucket([]byte(owner)), id, favorite)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &favorite, nil
+}
+
+// DeleteStation removes one of the owner's favorite stations and returns it.
+func (b *BoltFavorites) DeleteStation(owner, id string, version int) (*models.FavoriteStation, error) {
+	var favorite models.FavoriteStation
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketStations, owner, id, &favorite); err != nil {
+			return err
+		}
+		if err := checkVersion(favorite.Version, version); err != nil {
+			return err
+		}
+		return deleteOwned(tx, bucketStations, bucketStationIndex, owner, id, favorite.StationID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	favorite.Owner = owner
+	return &favorite, nil
+}
+
+// ReorderStations numbers the owner's favorite stations in the order of ids.
+func (b *BoltFavorites) ReorderStations(owner string, ids []string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return reorderOwned(tx, bucketStations, owner, ids)
+	})
+}
+
+// AllStations returns every owner's favorite stations.
+func (b *BoltFavorites) AllStations() ([]models.FavoriteStation, error) {
+	favorites := make([]models.FavoriteStation, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return forEachOwner(tx, bucketStations, func(owner string) error {
+			owned, err := listStations(tx, owner)
+			favorites = append(favorites, owned...)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortStations(favorites)
+	return favorites, nil
+}
+
+func listStations(tx *bolt.Tx, owner string) ([]models.FavoriteStation, error) {
+	favorites := make([]models.FavoriteStation, 0)
+	err := forEachOwned(tx, bucketStations, owner, func(data []byte) error {
+		var favorite models.FavoriteStation
+		if err := json.Unmarshal(data, &favorite); err != nil {
+			return err
+		}
+		favorite.Owner = owner
+		favorites = append(favorites, favorite)
+		return nil
+	})
+	return favorites, err
+}
+
+// ListTrains returns the owner's favorite trains.
+func (b *BoltFavorites) ListTrains(owner string) ([]models.FavoriteTrain, error) {
+	var favorites []models.FavoriteTrain
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		favorites, err = listTrains(tx, owner)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTrains(favorites)
+	return favorites, nil
+}
+
+// GetTrain returns one of the owner's favorite trains.
+func (b *BoltFavorites) GetTrain(owner, id string) (*models.FavoriteTrain, error) {
+	var favorite models.FavoriteTrain
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return getOwned(tx, bucketTrains, owner, id, &favorite)
+	})
+	if err != nil {
+		return nil, err
+	}
+	favorite.Owner = owner
+	return &favorite, nil
+}
+
+// CreateTrain stores a new favorite train.
+func (b *BoltFavorites) CreateTrain(favorite *models.FavoriteTrain) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		position, err := nextPosition(tx, bucketTrains, favorite.Owner)
+		if err != nil {
+			return err
+		}
+		favorite.Position = position
+		favorite.Version = 1
+		return createOwned(tx, bucketTrains, bucketTrainIndex, favorite.Owner, favorite.ID, TrainKey(*favorite), favorite)
+	})
+}
+
+// UpdateTrain applies update to one of the owner's favorite trains. The
+// update must not change its key (see TrainKey).
+func (b *BoltFavorites) UpdateTrain(owner, id string, version int, update func(*models.FavoriteTrain)) (*models.FavoriteTrain, error) {
+	var favorite models.FavoriteTrain
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketTrains, owner, id, &favorite); err != nil {
+			return err
+		}
+		if err := checkVersion(favorite.Version, version); err != nil {
+			return err
+		}
+		favorite.Owner = owner
+		update(&favorite)
+		favorite.Version++
+		return putJSON(tx.Bucket(bucketTrains).Bucket([]byte(owner)), id, favorite)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &favorite, nil
+}
+
+// DeleteTrain removes one of the owner's favorite trains and returns it.
+func (b *BoltFavorites) DeleteTrain(owner, id string, version int) (*models.FavoriteTrain, error) {
+	var favorite models.FavoriteTrain
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketTrains, owner, id, &favorite); err != nil {
+			return err
+		}
+		if err := checkVersion(favorite.Version, version); err != nil {
+			return err
+		}
+		return deleteOwned(tx, bucketTrains, bucketTrainIndex, owner, id, TrainKey(favorite))
+	})
+	if err != nil {
+		return nil, err
+	}
+	favorite.Owner = owner
+	return &favorite, nil
+}
+
+// ReorderTrains numbers the owner's favorite trains in the order of ids.
+func (b *BoltFavorites) ReorderTrains(owner string, ids []string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return reorderOwned(tx, bucketTrains, owner, ids)
+	})
+}
+
+// AllTrains returns every owner's favorite trains.
+func (b *BoltFavorites) AllTrains() ([]models.FavoriteTrain, error) {
+	favorites := make([]models.FavoriteTrain, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return forEachOwner(tx, bucketTrains, func(owner string) error {
+			owned, err := listTrains(tx, owner)
+			favorites = append(favorites, owned...)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTrains(favorites)
+	return favorites, nil
+}
+
+func listTrains(tx *bolt.Tx, owner string) ([]models.FavoriteTrain, error) {
+	favorites := make([]models.FavoriteTrain, 0)
+	err := forEachOwned(tx, bucketTrains, owner, func(data []byte) error {
+		var favorite models.FavoriteTrain
+		if err := json.Unmarshal(data, &favorite); err != nil {
+			return err
+		}
+		favorite.Owner = owner
+		favorites = append(favorites, favorite)
+		return nil
+	})
+	return favorites, err
+}
+
+// ListJourneys returns the owner's favorite journeys.
+func (b *BoltFavorites) ListJourneys(owner string) ([]models.FavoriteJourney, error) {
+	favorites := make([]models.FavoriteJourney, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return forEachOwned(tx, bucketJourneys, owner, func(data []byte) error {
+			var favorite models.FavoriteJourney
+			if err := json.Unmarshal(data, &favorite); err != nil {
+				return err
+			}
+			favorite.Owner = owner
+			favorites = append(favorites, favorite)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortJourneys(favorites)
+	return favorites, nil
+}
+
+// GetJourney returns one of the owner's favorite journeys.
+func (b *BoltFavorites) GetJourney(owner, id string) (*models.FavoriteJourney, error) {
+	var favorite models.FavoriteJourney
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return getOwned(tx, bucketJourneys, owner, id, &favorite)
+	})
+	if err != nil {
+		return nil, err
+	}
+	favorite.Owner = owner
+	return &favorite, nil
+}
+
+// CreateJourney stores a new favorite journey.
+func (b *BoltFavorites) CreateJourney(favorite *models.FavoriteJourney) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		position, err := nextPosition(tx, bucketJourneys, favorite.Owner)
+		if err != nil {
+			return err
+		}
+		favorite.Position = position
+		favorite.Version = 1
+		return createOwned(tx, bucketJourneys, bucketJourneyIndex, favorite.Owner, favorite.ID, JourneyKey(*favorite), favorite)
+	})
+}
+
+// UpdateJourney applies update to one of the owner's favorite journeys. The
+// update must not change the stations.
+func (b *BoltFavorites) UpdateJourney(owner, id string, version int, update func(*models.FavoriteJourney)) (*models.FavoriteJourney, error) {
+	var favorite models.FavoriteJourney
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketJourneys, owner, id, &favorite); err != nil {
+			return err
+		}
+		if err := checkVersion(favorite.Version, version); err != nil {
+			return err
+		}
+		favorite.Owner = owner
+		update(&favorite)
+		favorite.Version++
+		return putJSON(tx.Bucket(bucketJourneys).Bucket([]byte(owner)), id, favorite)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &favorite, nil
+}
+
+// DeleteJourney removes one of the owner's favorite journeys and returns it.
+func (b *BoltFavorites) DeleteJourney(owner, id string, version int) (*models.FavoriteJourney, error) {
+	var favorite models.FavoriteJourney
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketJourneys, owner, id, &favorite); err != nil {
+			return err
+		}
+		if err := checkVersion(favorite.Version, version); err != nil {
+			return err
+		}
+		return deleteOwned(tx, bucketJourneys, bucketJourneyIndex, owner, id, JourneyKey(favorite))
+	})
+	if err != nil {
+		return nil, err
+	}
+	favorite.Owner = owner
+	return &favorite, nil
+}
+
+// ReorderJourneys numbers the owner's favorite journeys in the order of ids.
+func (b *BoltFavorites) ReorderJourneys(owner string, ids []string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return reorderOwned(tx, bucketJourneys, owner, ids)
+	})
+}
+
+// ListCollections returns the owner's collections.
+func (b *BoltFavorites) ListCollections(owner string) ([]models.FavoriteCollection, error) {
+	collections := make([]models.FavoriteCollection, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return forEachOwned(tx, bucketCollections, owner, func(data []byte) error {
+			var collection models.FavoriteCollection
+			if err := json.Unmarshal(data, &collection); err != nil {
+				return err
+			}
+			collection.Owner = owner
+			collections = append(collections, collection)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortCollections(collections)
+	return collections, nil
+}
+
+// GetCollection returns one of the owner's collections.
+func (b *BoltFavorites) GetCollection(owner, id string) (*models.FavoriteCollection, error) {
+	var collection models.FavoriteCollection
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return getOwned(tx, bucketCollections, owner, id, &collection)
+	})
+	if err != nil {
+		return nil, err
+	}
+	collection.Owner = owner
+	return &collection, nil
+}
+
+// CreateCollection stores a new collection.
+func (b *BoltFavorites) CreateCollection(collection *models.FavoriteCollection) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		position, err := nextPosition(tx, bucketCollections, collection.Owner)
+		if err != nil {
+			return err
+		}
+		collection.Position = position
+		return createOwned(tx, bucketCollections, bucketCollectionIndex, collection.Owner, collection.ID, CollectionKey(*collection), collection)
+	})
+}
+
+// UpdateCollection applies update to one of the owner's collections and
+// moves its index entry when it is renamed.
+func (b *BoltFavorites) UpdateCollection(owner, id string, update func(*models.FavoriteCollection)) (*models.FavoriteCollection, error) {
+	var collection models.FavoriteCollection
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketCollections, owner, id, &collection); err != nil {
+			return err
+		}
+		collection.Owner = owner
+		oldKey := CollectionKey(collection)
+		update(&collection)
+
+		if newKey := CollectionKey(collection); newKey != oldKey {
+			index := tx.Bucket(bucketCollectionIndex).Bucket([]byte(owner))
+			if other := index.Get([]byte(newKey)); other != nil && string(other) != id {
+				return ErrDuplicate
+			}
+			if err := index.Delete([]byte(oldKey)); err != nil {
+				return err
+			}
+			if err := index.Put([]byte(newKey), []byte(id)); err != nil {
+				return err
+			}
+		}
+		return putJSON(tx.Bucket(bucketCollections).Bucket([]byte(owner)), id, collection)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// DeleteCollection removes one of the owner's collections and takes its
+// favorites out of it.
+func (b *BoltFavorites) DeleteCollection(owner, id string) (*models.FavoriteCollection, error) {
+	var collection models.FavoriteCollection
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketCollections, owner, id, &collection); err != nil {
+			return err
+		}
+		for _, parent := range [][]byte{bucketStations, bucketTrains, bucketJourneys} {
+			if err := clearCollection(tx, parent, owner, id); err != nil {
+				return err
+			}
+		}
+		return deleteOwned(tx, bucketCollections, bucketCollectionIndex, owner, id, CollectionKey(collection))
+	})
+	if err != nil {
+		return nil, err
+	}
+	collection.Owner = owner
+	return &collection, nil
+}
+
+// ReorderCollections numbers the owner's collections in the order of ids.
+func (b *BoltFavorites) ReorderCollections(owner string, ids []string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return reorderOwned(tx, bucketCollections, owner, ids)
+	})
+}
+
+// ListRules returns the owner's alert rules.
+func (b *BoltFavorites) ListRules(owner string) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		rules, err = listRules(tx, owner)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortRules(rules)
+	return rules, nil
+}
+
+// GetRule returns one of the owner's alert rules.
+func (b *BoltFavorites) GetRule(owner, id string) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return getOwned(tx, bucketRules, owner, id, &rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	rule.Owner = owner
+	return &rule, nil
+}
+
+// CreateRule stores a new alert rule.
+func (b *BoltFavorites) CreateRule(rule models.AlertRule) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return createOwned(tx, bucketRules, bucketRuleIndex, rule.Owner, rule.ID, RuleKey(rule), rule)
+	})
+}
+
+// UpdateRule applies update to one of the owner's alert rules. The update
+// must not change the favorite or condition.
+func (b *BoltFavorites) UpdateRule(owner, id string, update func(*models.AlertRule)) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketRules, owner, id, &rule); err != nil {
+			return err
+		}
+		rule.Owner = owner
+		update(&rule)
+		return putJSON(tx.Bucket(bucketRules).Bucket([]byte(owner)), id, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteRule removes one of the owner's alert rules and returns it.
+func (b *BoltFavorites) DeleteRule(owner, id string) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := getOwned(tx, bucketRules, owner, id, &rule); err != nil {
+			return err
+		}
+		return deleteOwned(tx, bucketRules, bucketRuleIndex, owner, id, RuleKey(rule))
+	})
+	if err != nil {
+		return nil, err
+	}
+	rule.Owner = owner
+	return &rule, nil
+}
+
+// AllRules returns every owner's alert rules.
+func (b *BoltFavorites) AllRules() ([]models.AlertRule, error) {
+	rules := make([]models.AlertRule, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return forEachOwner(tx, bucketRules, func(owner string) error {
+			owned, err := listRules(tx, owner)
+			rules = append(rules, owned...)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortRules(rules)
+	return rules, nil
+}
+
+func listRules(tx *bolt.Tx, owner string) ([]models.AlertRule, error) {
+	rules := make([]models.AlertRule, 0)
+	err := forEachOwned(tx, bucketRules, owner, func(data []byte) error {
+		var rule models.AlertRule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return err
+		}
+		rule.Owner = owner
+		rules = append(rules, rule)
+		return nil
+	})
+	return rules, err
+}
+
+// GetResponse returns the stored response to one of the owner's requests.
+func (b *BoltFavorites) GetResponse(owner, key string) (*models.IdempotentResponse, error) {
+	var response models.IdempotentResponse
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return getOwned(tx, bucketResponses, owner, key, &response)
+	})
+	if err != nil {
+		return nil, err
+	}
+	response.Owner = owner
+	return &response, nil
+}
+
+// SaveResponse stores the response to one of the owner's requests.
+func (b *BoltFavorites) SaveResponse(response models.IdempotentResponse) error {
+	if response.Owner == "" {
+		return errors.New("favorites owner is required")
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		owned, err := tx.Bucket(bucketResponses).CreateBucketIfNotExists([]byte(response.Owner))
+		if err != nil {
+			return err
+		}
+		return putJSON(owned, response.Key, response)
+	})
+}
+
+// PurgeResponses removes the responses that expired before now.
+func (b *BoltFavorites) PurgeResponses(now time.Time) (int, error) {
+	purged := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		// Keys are collected first; buckets can't change while iterated
+		expired := make(map[string][]string)
+		err := forEachOwner(tx, bucketResponses, func(owner string) error {
+			return tx.Bucket(bucketResponses).Bucket([]byte(owner)).ForEach(func(key, data []byte) error {
+				var response struct {
+					ExpiresAt time.Time `json:"expiresAt"`
+				}
+				if err := json.Unmarshal(data, &response); err != nil {
+					return err
+				}
+				if response.ExpiresAt.Before(now) {
+					expired[owner] = append(expired[owner], string(key))
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		for owner, keys := range expired {
+			owned := tx.Bucket(bucketResponses).Bucket([]byte(owner))
+			for _, key := range keys {
+				if err := owned.Delete([]byte(key)); err != nil {
+					return err
+				}
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// AppendAudit adds an entry to the audit log. Entries are keyed by a
+// sequence number, so each owner's are in the order they were appended, and
+// indexed by time (see auditTimeKey) for queries of every owner and purges.
+func (b *BoltFavorites) AppendAudit(entry models.AuditEntry) error {
+	if entry.Owner == "" {
+		return errors.New("favorites owner is required")
+	}
+	at, err := time.Parse(time.RFC3339, entry.Timestamp)
+	if err != nil {
+		return fmt.Errorf("audit entry timestamp: %w", err)
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		owned, err := tx.Bucket(bucketAudit).CreateBucketIfNotExists([]byte(entry.Owner))
+		if err != nil {
+			return err
+		}
+		seq, err := owned.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := owned.Put(key, data); err != nil {
+			return err
+		}
+		return indexAudit(tx, entry.Owner, key, at)
+	})
+}
+
+// indexAudit adds the owner's entry under key to the time index.
+func indexAudit(tx *bolt.Tx, owner string, key []byte, at time.Time) error {
+	index := tx.Bucket(bucketAuditTime)
+	seq, err := index.NextSequence()
+	if err != nil {
+		return err
+	}
+	return index.Put(auditTimeKey(at, seq), append(append([]byte(owner), 0), key...))
+}
+
+// auditTimeKey is an entry's key in the time index: the second it was
+// recorded at and a sequence number, both big-endian, so a cursor walks
+// the entries of every owner in time order. The value is the owner, a zero
+// byte and the entry's key in the owner's bucket.
+func auditTimeKey(at time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(at.Unix()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// auditIndexed returns the owner and the entry of a time index value.
+func auditIndexed(tx *bolt.Tx, value []byte) (string, []byte, error) {
+	i := bytes.IndexByte(value, 0)
+	if i < 0 {
+		return "", nil, errors.New("corrupt audit index entry")
+	}
+	owner, key := string(value[:i]), value[i+1:]
+	owned := tx.Bucket(bucketAudit).Bucket([]byte(owner))
+	if owned == nil {
+		return owner, nil, nil
+	}
+	return owner, owned.Get(key), nil
+}
+
+// ListAudit returns the audit entries the query selects, newest first. An
+// owner's entries are read from their bucket; queries of every owner walk
+// the time index back from the query's end.
+func (b *BoltFavorites) ListAudit(query AuditQuery) ([]models.AuditEntry, error) {
+	entries := make([]models.AuditEntry, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var cursor *bolt.Cursor
+		var key, data []byte
+		if query.Owner != "" {
+			owned := tx.Bucket(bucketAudit).Bucket([]byte(query.Owner))
+			if owned == nil {
+				return nil
+			}
+			cursor = owned.Cursor()
+			key, data = cursor.Last()
+		} else {
+			cursor = tx.Bucket(bucketAuditTime).Cursor()
+			if query.To.IsZero() {
+				key, data = cursor.Last()
+			} else if key, data = cursor.Seek(auditTimeKey(query.To.Add(time.Second), 0)); key != nil {
+				key, data = cursor.Prev()
+			} else {
+				key, data = cursor.Last()
+			}
+		}
+
+		for ; key != nil; key, data = cursor.Prev() {
+			if query.Owner == "" {
+				if !query.From.IsZero() && int64(binary.BigEndian.Uint64(key)) < query.From.Unix() {
+					break
+				}
+				var err error
+				if _, data, err = auditIndexed(tx, data); err != nil {
+					return err
+				}
+				if data == nil {
+					continue
+				}
+			}
+			var entry models.AuditEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return err
+			}
+			if !query.matches(entry) {
+				continue
+			}
+			entries = append(entries, entry)
+			if query.Limit > 0 && len(entries) == query.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return query.newestFirst(entries), nil
+}
+
+// PurgeAudit removes the audit entries recorded before before, oldest
+// first through the time index.
+func (b *BoltFavorites) PurgeAudit(before time.Time) (int, error) {
+	purged := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		index := tx.Bucket(bucketAuditTime)
+		end := auditTimeKey(before, 0)
+		var stale [][]byte
+		cursor := index.Cursor()
+		for key, value := cursor.First(); key != nil && bytes.Compare(key, end) < 0; key, value = cursor.Next() {
+			owner, _, err := auditIndexed(tx, value)
+			if err != nil {
+				return err
+			}
+			if owned := tx.Bucket(bucketAudit).Bucket([]byte(owner)); owned != nil {
+				if err := owned.Delete(value[len(owner)+1:]); err != nil {
+					return err
+				}
+			}
+			stale = append(stale, key)
+		}
+		// Keys are deleted after the walk; a bucket can't change while iterated
+		for _, key := range stale {
+			if err := index.Delete(key); err != nil {
+				return err
+			}
+		}
+		purged = len(stale)
+		return nil
+	})
+	return purged, err
+}
+
+// Close closes the database file.
+func (b *BoltFavorites) Close() error {
+	return b.db.Close()
+}
+
+// forEachOwner calls fn with every owner that has records in parent.
+func forEachOwner(tx *bolt.Tx, parent []byte, fn func(owner string) error) error {
+	return tx.Bucket(parent).ForEach(func(key, value []byte) error {
+		if value != nil {
+			return nil // not a bucket
+		}
+		return fn(string(key))
+	})
+}
+
+// forEachOwned calls fn with each of the owner's records in parent.
+func forEachOwned(tx *bolt.Tx, parent []byte, owner string, fn func(data []byte) error) error {
+	owned := tx.Bucket(parent).Bucket([]byte(owner))
+	if owned == nil {
+		return nil
+	}
+	return owned.ForEach(func(_, data []byte) error {
+		return fn(data)
+	})
+}
+
+// getOwned decodes one of the owner's records, or returns ErrNotFound.
+func getOwned(tx *bolt.Tx, parent []byte, owner, id string, v interface{}) error {
+	owned := tx.Bucket(parent).Bucket([]byte(owner))
+	if owned == nil {
+		return ErrNotFound
+	}
+	return getJSON(owned, id, v)
+}
+
+// createOwned stores a record of the owner unless its unique key (station
+// ID, TrainKey, ...) is already in the owner's index.
+func createOwned(tx *bolt.Tx, parent, indexParent []byte, owner, id, unique string, v interface{}) error {
+	if owner == "" {
+		return errors.New("favorites owner is required")
+	}
+	owned, err := tx.Bucket(parent).CreateBucketIfNotExists([]byte(owner))
+	if err != nil {
+		return err
+	}
+	index, err := tx.Bucket(indexParent).CreateBucketIfNotExists([]byte(owner))
+	if err != nil {
+		return err
+	}
+	if index.Get([]byte(unique)) != nil {
+		return ErrDuplicate
+	}
+	if err := putJSON(owned, id, v); err != nil {
+		return err
+	}
+	return index.Put([]byte(unique), []byte(id))
+}
+
+// deleteOwned removes one of the owner's records and its index entry. An
+// entry pointing at another record (a duplicate stored before the index
+// key changed) is kept.
+func deleteOwned(tx *bolt.Tx, parent, indexParent []byte, owner, id, unique string) error {
+	if err := tx.Bucket(parent).Bucket([]byte(owner)).Delete([]byte(id)); err != nil {
+		return err
+	}
+	index := tx.Bucket(indexParent).Bucket([]byte(owner))
+	if index == nil || string(index.Get([]byte(unique))) != id {
+		return nil
+	}
+	return index.Delete([]byte(unique))
+}
+
+// nextPosition returns the position after the last of the owner's records
+// in parent.
+func nextPosition(tx *bolt.Tx, parent []byte, owner string) (int, error) {
+	next := 0
+	err := forEachOwned(tx, parent, owner, func(data []byte) error {
+		var record struct {
+			Position int `json:"position"`
+		}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		if record.Position >= next {
+			next = record.Position + 1
+		}
+		return nil
+	})
+	return next, err
+}
+
+// reorderOwned numbers the owner's records in parent in the order of ids
+// (see reorderPositions). The records are rewritten as generic JSON, so it
+// works for every kind.
+func reorderOwned(tx *bolt.Tx, parent []byte, owner string, ids []string) error {
+	owned := tx.Bucket(parent).Bucket([]byte(owner))
+	if owned == nil {
+		return ErrNotFound
+	}
+
+	// All records are read: ids must list each of them
+	var recordIDs []string
+	records := make(map[string]map[string]json.RawMessage)
+	positions := make(map[string]*int)
+	err := owned.ForEach(func(key, data []byte) error {
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		var header struct {
+			ID       string `json:"id"`
+			Position int    `json:"position"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return err
+		}
+		recordIDs = append(recordIDs, header.ID)
+		records[header.ID] = record
+		positions[header.ID] = &header.Position
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	moved, err := reorderPositions(recordIDs, ids, func(id string) *int { return positions[id] })
+	if err != nil {
+		return err
+	}
+	for _, id := range moved {
+		record := records[id]
+		record["position"] = json.RawMessage(strconv.Itoa(*positions[id]))
+		if err := modified(record); err != nil {
+			return err
+		}
+		if err := putJSON(owned, id, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearCollection takes the owner's records in parent out of a collection.
+func clearCollection(tx *bolt.Tx, parent []byte, owner, collectionID string) error {
+	owned := tx.Bucket(parent).Bucket([]byte(owner))
+	if owned == nil {
+		return nil
+	}
+
+	// Records are collected first; buckets can't change while iterated
+	cleared := make(map[string]map[string]json.RawMessage)
+	err := owned.ForEach(func(key, data []byte) error {
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		var id string
+		if raw, ok := record["collectionId"]; ok && json.Unmarshal(raw, &id) == nil && id == collectionID {
+			delete(record, "collectionId")
+			if err := modified(record); err != nil {
+				return err
+			}
+			cleared[string(key)] = record
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for key, record := range cleared {
+		if err := putJSON(owned, key, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// modified bumps the version of a record the store rewrites itself, so
+// its ETag changes, and sets its updatedAt. Collections have no version.
+func modified(record map[string]json.RawMessage) error {
+	if raw, ok := record["version"]; ok {
+		var version int
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return err
+		}
+		record["version"] = json.RawMessage(strconv.Itoa(version + 1))
+	}
+	updatedAt, err := json.Marshal(modifiedAt())
+	if err != nil {
+		return err
+	}
+	record["updatedAt"] = updatedAt
+	return nil
+}
+
+// getJSON decodes the record stored under key, or returns ErrNotFound.
+func getJSON(bucket *bolt.Bucket, key string, v interface{}) error {
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+// putJSON stores v as JSON under key.
+func putJSON(bucket *bolt.Bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), data)
+}
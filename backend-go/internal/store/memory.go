@@ -0,0 +1,686 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// MemoryFavorites keeps favorites in memory; they are lost on restart.
+type MemoryFavorites struct {
+	mu       sync.RWMutex
+	stations map[string]map[string]*models.FavoriteStation // Key: owner, then favorite ID
+	trains   map[string]map[string]*models.FavoriteTrain   // Key: owner, then favorite ID
+	journeys map[string]map[string]*models.FavoriteJourney // Key: owner, then favorite ID
+	rules    map[string]map[string]*models.AlertRule       // Key: owner, then rule ID
+
+	collections map[string]map[string]*models.FavoriteCollection // Key: owner, then collection ID
+	responses   map[string]map[string]*models.IdempotentResponse // Key: owner, then idempotency key
+	audit       []models.AuditEntry                              // oldest first
+}
+
+// NewMemoryFavorites creates an empty in-memory repository.
+func NewMemoryFavorites() *MemoryFavorites {
+	return &MemoryFavorites{
+		stations: make(map[string]map[string]*models.FavoriteStation),
+		trains:   make(map[string]map[string]*models.FavoriteTrain),
+		journeys: make(map[string]map[string]*models.FavoriteJourney),
+		rules:    make(map[string]map[string]*models.AlertRule),
+
+		collections: make(map[string]map[string]*models.FavoriteCollection),
+		responses:   make(map[string]map[string]*models.IdempotentResponse),
+	}
+}
+
+// ListStations returns the owner's favorite stations.
+func (m *MemoryFavorites) ListStations(owner string) ([]models.FavoriteStation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	favorites := make([]models.FavoriteStation, 0, len(m.stations[owner]))
+	for _, favorite := range m.stations[owner] {
+		favorites = append(favorites, *favorite)
+	}
+	sortStations(favorites)
+	return favorites, nil
+}
+
+// GetStation returns one of the owner's favorite stations.
+func (m *MemoryFavorites) GetStation(owner, id string) (*models.FavoriteStation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	favorite, ok := m.stations[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	fav := *favorite
+	return &fav, nil
+}
+
+// CreateStation stores a new favorite station.
+func (m *MemoryFavorites) CreateStation(favorite *models.FavoriteStation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := m.stations[favorite.Owner]
+	if owned == nil {
+		owned = make(map[string]*models.FavoriteStation)
+		m.stations[favorite.Owner] = owned
+	}
+	position := 0
+	for _, existing := range owned {
+		if existing.StationID == favorite.StationID {
+			return ErrDuplicate
+		}
+		if existing.Position >= position {
+			position = existing.Position + 1
+		}
+	}
+	favorite.Position = position
+	favorite.Version = 1
+	stored := *favorite
+	owned[favorite.ID] = &stored
+	return nil
+}
+
+// UpdateStation applies update to one of the owner's favorite stations.
+func (m *MemoryFavorites) UpdateStation(owner, id string, version int, update func(*models.FavoriteStation)) (*models.FavoriteStation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	favorite, ok := m.stations[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(favorite.Version, version); err != nil {
+		return nil, err
+	}
+	update(favorite)
+	favorite.Version++
+	fav := *favorite
+	return &fav, nil
+}
+
+// DeleteStation removes one of the owner's favorite stations and returns it.
+func (m *MemoryFavorites) DeleteStation(owner, id string, version int) (*models.FavoriteStation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	favorite, ok := m.stations[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(favorite.Version, version); err != nil {
+		return nil, err
+	}
+	delete(m.stations[owner], id)
+	return favorite, nil
+}
+
+// ReorderStations numbers the owner's favorite stations in the order of ids.
+func (m *MemoryFavorites) ReorderStations(owner string, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := make([]string, 0, len(m.stations[owner]))
+	for id := range m.stations[owner] {
+		owned = append(owned, id)
+	}
+	moved, err := reorderPositions(owned, ids, func(id string) *int {
+		return &m.stations[owner][id].Position
+	})
+	for _, id := range moved {
+		favorite := m.stations[owner][id]
+		favorite.Version++
+		favorite.UpdatedAt = modifiedAt()
+	}
+	return err
+}
+
+// AllStations returns every owner's favorite stations.
+func (m *MemoryFavorites) AllStations() ([]models.FavoriteStation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	favorites := make([]models.FavoriteStation, 0)
+	for _, owned := range m.stations {
+		for _, favorite := range owned {
+			favorites = append(favorites, *favorite)
+		}
+	}
+	sortStations(favorites)
+	return favorites, nil
+}
+
+// ListTrains returns the owner's favorite trains.
+func (m *MemoryFavorites) ListTrains(owner string) ([]models.FavoriteTrain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	favorites := make([]models.FavoriteTrain, 0, len(m.trains[owner]))
+	for _, favorite := range m.trains[owner] {
+		favorites = append(favorites, *favorite)
+	}
+	sortTrains(favorites)
+	return favorites, nil
+}
+
+// GetTrain returns one of the owner's favorite trains.
+func (m *MemoryFavorites) GetTrain(owner, id string) (*models.FavoriteTrain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	favorite, ok := m.trains[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	fav := *favorite
+	return &fav, nil
+}
+
+// CreateTrain stores a new favorite train.
+func (m *MemoryFavorites) CreateTrain(favorite *models.FavoriteTrain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := m.trains[favorite.Owner]
+	if owned == nil {
+		owned = make(map[string]*models.FavoriteTrain)
+		m.trains[favorite.Owner] = owned
+	}
+	position := 0
+	for _, existing := range owned {
+		if TrainKey(*existing) == TrainKey(*favorite) {
+			return ErrDuplicate
+		}
+		if existing.Position >= position {
+			position = existing.Position + 1
+		}
+	}
+	favorite.Position = position
+	favorite.Version = 1
+	stored := *favorite
+	owned[favorite.ID] = &stored
+	return nil
+}
+
+// UpdateTrain applies update to one of the owner's favorite trains.
+func (m *MemoryFavorites) UpdateTrain(owner, id string, version int, update func(*models.FavoriteTrain)) (*models.FavoriteTrain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	favorite, ok := m.trains[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(favorite.Version, version); err != nil {
+		return nil, err
+	}
+	update(favorite)
+	favorite.Version++
+	fav := *favorite
+	return &fav, nil
+}
+
+// DeleteTrain removes one of the owner's favorite trains and returns it.
+func (m *MemoryFavorites) DeleteTrain(owner, id string, version int) (*models.FavoriteTrain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	favorite, ok := m.trains[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(favorite.Version, version); err != nil {
+		return nil, err
+	}
+	delete(m.trains[owner], id)
+	return favorite, nil
+}
+
+// ReorderTrains numbers the owner's favorite trains in the order of ids.
+func (m *MemoryFavorites) ReorderTrains(owner string, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := make([]string, 0, len(m.trains[owner]))
+	for id := range m.trains[owner] {
+		owned = append(owned, id)
+	}
+	moved, err := reorderPositions(owned, ids, func(id string) *int {
+		return &m.trains[owner][id].Position
+	})
+	for _, id := range moved {
+		favorite := m.trains[owner][id]
+		favorite.Version++
+		favorite.UpdatedAt = modifiedAt()
+	}
+	return err
+}
+
+// AllTrains returns every owner's favorite trains.
+func (m *MemoryFavorites) AllTrains() ([]models.FavoriteTrain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	favorites := make([]models.FavoriteTrain, 0)
+	for _, owned := range m.trains {
+		for _, favorite := range owned {
+			favorites = append(favorites, *favorite)
+		}
+	}
+	sortTrains(favorites)
+	return favorites, nil
+}
+
+// ListJourneys returns the owner's favorite journeys.
+func (m *MemoryFavorites) ListJourneys(owner string) ([]models.FavoriteJourney, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	favorites := make([]models.FavoriteJourney, 0, len(m.journeys[owner]))
+	for _, favorite := range m.journeys[owner] {
+		favorites = append(favorites, *favorite)
+	}
+	sortJourneys(favorites)
+	return favorites, nil
+}
+
+// GetJourney returns one of the owner's favorite journeys.
+func (m *MemoryFavorites) GetJourney(owner, id string) (*models.FavoriteJourney, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	favorite, ok := m.journeys[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	fav := *favorite
+	return &fav, nil
+}
+
+// CreateJourney stores a new favorite journey.
+func (m *MemoryFavorites) CreateJourney(favorite *models.FavoriteJourney) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := m.journeys[favorite.Owner]
+	if owned == nil {
+		owned = make(map[string]*models.FavoriteJourney)
+		m.journeys[favorite.Owner] = owned
+	}
+	position := 0
+	for _, existing := range owned {
+		if JourneyKey(*existing) == JourneyKey(*favorite) {
+			return ErrDuplicate
+		}
+		if existing.Position >= position {
+			position = existing.Position + 1
+		}
+	}
+	favorite.Position = position
+	favorite.Version = 1
+	stored := *favorite
+	owned[favorite.ID] = &stored
+	return nil
+}
+
+// UpdateJourney applies update to one of the owner's favorite journeys.
+func (m *MemoryFavorites) UpdateJourney(owner, id string, version int, update func(*models.FavoriteJourney)) (*models.FavoriteJourney, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	favorite, ok := m.journeys[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(favorite.Version, version); err != nil {
+		return nil, err
+	}
+	update(favorite)
+	favorite.Version++
+	fav := *favorite
+	return &fav, nil
+}
+
+// DeleteJourney removes one of the owner's favorite journeys and returns it.
+func (m *MemoryFavorites) DeleteJourney(owner, id string, version int) (*models.FavoriteJourney, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	favorite, ok := m.journeys[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(favorite.Version, version); err != nil {
+		return nil, err
+	}
+	delete(m.journeys[owner], id)
+	return favorite, nil
+}
+
+// ReorderJourneys numbers the owner's favorite journeys in the order of ids.
+func (m *MemoryFavorites) ReorderJourneys(owner string, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := make([]string, 0, len(m.journeys[owner]))
+	for id := range m.journeys[owner] {
+		owned = append(owned, id)
+	}
+	moved, err := reorderPositions(owned, ids, func(id string) *int {
+		return &m.journeys[owner][id].Position
+	})
+	for _, id := range moved {
+		favorite := m.journeys[owner][id]
+		favorite.Version++
+		favorite.UpdatedAt = modifiedAt()
+	}
+	return err
+}
+
+// ListCollections returns the owner's collections.
+func (m *MemoryFavorites) ListCollections(owner string) ([]models.FavoriteCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collections := make([]models.FavoriteCollection, 0, len(m.collections[owner]))
+	for _, collection := range m.collections[owner] {
+		collections = append(collections, *collection)
+	}
+	sortCollections(collections)
+	return collections, nil
+}
+
+// GetCollection returns one of the owner's collections.
+func (m *MemoryFavorites) GetCollection(owner, id string) (*models.FavoriteCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collection, ok := m.collections[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *collection
+	return &c, nil
+}
+
+// CreateCollection stores a new collection.
+func (m *MemoryFavorites) CreateCollection(collection *models.FavoriteCollection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := m.collections[collection.Owner]
+	if owned == nil {
+		owned = make(map[string]*models.FavoriteCollection)
+		m.collections[collection.Owner] = owned
+	}
+	position := 0
+	for _, existing := range owned {
+		if CollectionKey(*existing) == CollectionKey(*collection) {
+			return ErrDuplicate
+		}
+		if existing.Position >= position {
+			position = existing.Position + 1
+		}
+	}
+	collection.Position = position
+	stored := *collection
+	owned[collection.ID] = &stored
+	return nil
+}
+
+// UpdateCollection applies update to one of the owner's collections.
+func (m *MemoryFavorites) UpdateCollection(owner, id string, update func(*models.FavoriteCollection)) (*models.FavoriteCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.collections[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := *collection
+	update(&updated)
+	for otherID, other := range m.collections[owner] {
+		if otherID != id && CollectionKey(*other) == CollectionKey(updated) {
+			return nil, ErrDuplicate
+		}
+	}
+	*collection = updated
+	return &updated, nil
+}
+
+// DeleteCollection removes one of the owner's collections and takes its
+// favorites out of it.
+func (m *MemoryFavorites) DeleteCollection(owner, id string) (*models.FavoriteCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, ok := m.collections[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(m.collections[owner], id)
+
+	for _, favorite := range m.stations[owner] {
+		if favorite.CollectionID == id {
+			favorite.CollectionID = ""
+			favorite.Version++
+			favorite.UpdatedAt = modifiedAt()
+		}
+	}
+	for _, favorite := range m.trains[owner] {
+		if favorite.CollectionID == id {
+			favorite.CollectionID = ""
+			favorite.Version++
+			favorite.UpdatedAt = modifiedAt()
+		}
+	}
+	for _, favorite := range m.journeys[owner] {
+		if favorite.CollectionID == id {
+			favorite.CollectionID = ""
+			favorite.Version++
+			favorite.UpdatedAt = modifiedAt()
+		}
+	}
+	return collection, nil
+}
+
+// ReorderCollections numbers the owner's collections in the order of ids.
+func (m *MemoryFavorites) ReorderCollections(owner string, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := make([]string, 0, len(m.collections[owner]))
+	for id := range m.collections[owner] {
+		owned = append(owned, id)
+	}
+	moved, err := reorderPositions(owned, ids, func(id string) *int {
+		return &m.collections[owner][id].Position
+	})
+	for _, id := range moved {
+		m.collections[owner][id].UpdatedAt = modifiedAt()
+	}
+	return err
+}
+
+// ListRules returns the owner's alert rules.
+func (m *MemoryFavorites) ListRules(owner string) ([]models.AlertRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := make([]models.AlertRule, 0, len(m.rules[owner]))
+	for _, rule := range m.rules[owner] {
+		rules = append(rules, *rule)
+	}
+	sortRules(rules)
+	return rules, nil
+}
+
+// GetRule returns one of the owner's alert rules.
+func (m *MemoryFavorites) GetRule(owner, id string) (*models.AlertRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rule, ok := m.rules[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	r := *rule
+	return &r, nil
+}
+
+// CreateRule stores a new alert rule.
+func (m *MemoryFavorites) CreateRule(rule models.AlertRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := m.rules[rule.Owner]
+	if owned == nil {
+		owned = make(map[string]*models.AlertRule)
+		m.rules[rule.Owner] = owned
+	}
+	for _, existing := range owned {
+		if RuleKey(*existing) == RuleKey(rule) {
+			return ErrDuplicate
+		}
+	}
+	owned[rule.ID] = &rule
+	return nil
+}
+
+// UpdateRule applies update to one of the owner's alert rules.
+func (m *MemoryFavorites) UpdateRule(owner, id string, update func(*models.AlertRule)) (*models.AlertRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, ok := m.rules[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	update(rule)
+	r := *rule
+	return &r, nil
+}
+
+// DeleteRule removes one of the owner's alert rules and returns it.
+func (m *MemoryFavorites) DeleteRule(owner, id string) (*models.AlertRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, ok := m.rules[owner][id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(m.rules[owner], id)
+	return rule, nil
+}
+
+// AllRules returns every owner's alert rules.
+func (m *MemoryFavorites) AllRules() ([]models.AlertRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := make([]models.AlertRule, 0)
+	for _, owned := range m.rules {
+		for _, rule := range owned {
+			rules = append(rules, *rule)
+		}
+	}
+	sortRules(rules)
+	return rules, nil
+}
+
+// Close does nothing; memory needs no cleanup.
+func (m *MemoryFavorites) Close() error {
+	return nil
+}
+
+// GetResponse returns the stored response to one of the owner's requests.
+func (m *MemoryFavorites) GetResponse(owner, key string) (*models.IdempotentResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	response, ok := m.responses[owner][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	stored := *response
+	return &stored, nil
+}
+
+// SaveResponse stores the response to one of the owner's requests.
+func (m *MemoryFavorites) SaveResponse(response models.IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owned := m.responses[response.Owner]
+	if owned == nil {
+		owned = make(map[string]*models.IdempotentResponse)
+		m.responses[response.Owner] = owned
+	}
+	owned[response.Key] = &response
+	return nil
+}
+
+// PurgeResponses removes the responses that expired before now.
+func (m *MemoryFavorites) PurgeResponses(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := 0
+	for owner, owned := range m.responses {
+		for key, response := range owned {
+			if response.ExpiresAt.Before(now) {
+				delete(owned, key)
+				purged++
+			}
+		}
+		if len(owned) == 0 {
+			delete(m.responses, owner)
+		}
+	}
+	return purged, nil
+}
+
+// AppendAudit adds an entry to the audit log.
+func (m *MemoryFavorites) AppendAudit(entry models.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.audit = append(m.audit, entry)
+	return nil
+}
+
+// PurgeAudit removes the audit entries recorded before before.
+func (m *MemoryFavorites) PurgeAudit(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.audit[:0]
+	for _, entry := range m.audit {
+		if at, err := time.Parse(time.RFC3339, entry.Timestamp); err != nil || !at.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	purged := len(m.audit) - len(kept)
+	m.audit = kept
+	return purged, nil
+}
+
+// ListAudit returns the audit entries the query selects, newest first.
+func (m *MemoryFavorites) ListAudit(query AuditQuery) ([]models.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]models.AuditEntry, 0)
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		entry := m.audit[i]
+		if (query.Owner == "" || entry.Owner == query.Owner) && query.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return query.newestFirst(entries), nil
+}
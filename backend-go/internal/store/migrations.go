@@ -0,0 +1,399 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// keySchemaVersion holds how many migrations the database has applied.
+var keySchemaVersion = []byte("schema_version")
+
+// migration upgrades the database schema by one version.
+type migration struct {
+	name string
+	up   func(tx *bolt.Tx) error
+}
+
+// migrations are applied in order; schema version N has applied the first
+// N. Only ever append: released migrations may already have run.
+var migrations = []migration{
+	{
+		name: "create favorite stations and trains",
+		up: func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{bucketStations, bucketTrains} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Duplicate checks look up the index instead of scanning every record
+		name: "index favorites by station and train",
+		up: func(tx *bolt.Tx) error {
+			if err := buildIndex(tx, bucketStations, bucketStationIndex, func(data []byte) (string, error) {
+				var record struct {
+					StationID string `json:"stationId"`
+				}
+				err := json.Unmarshal(data, &record)
+				return record.StationID, err
+			}); err != nil {
+				return err
+			}
+			return buildIndex(tx, bucketTrains, bucketTrainIndex, func(data []byte) (string, error) {
+				var record struct {
+					TrainID string `json:"trainId"`
+				}
+				err := json.Unmarshal(data, &record)
+				return record.TrainID, err
+			})
+		},
+	},
+	{
+		// Favorites were global; they now belong to a user or device. Those
+		// stored before go to LegacyOwner
+		name: "scope favorites to owners",
+		up: func(tx *bolt.Tx) error {
+			if err := moveToOwner(tx, bucketStations, LegacyOwner); err != nil {
+				return err
+			}
+			if err := moveToOwner(tx, bucketTrains, LegacyOwner); err != nil {
+				return err
+			}
+			if err := moveToOwner(tx, bucketStationIndex, LegacyOwner); err != nil {
+				return err
+			}
+			return moveToOwner(tx, bucketTrainIndex, LegacyOwner)
+		},
+	},
+	{
+		name: "create favorite journeys",
+		up: func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{bucketJourneys, bucketJourneyIndex} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name: "create alert rules",
+		up: func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{bucketRules, bucketRuleIndex} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Favorites were listed in creation order; they keep that order as
+		// their positions
+		name: "create collections and number favorite positions",
+		up: func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{bucketCollections, bucketCollectionIndex} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			for _, name := range [][]byte{bucketStations, bucketTrains, bucketJourneys} {
+				if err := numberPositions(tx, name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Updates check and bump versions; existing favorites start at 1.
+		// Creates with an idempotency key store their response
+		name: "version favorites and store idempotent responses",
+		up: func(tx *bolt.Tx) error {
+			if _, err := tx.CreateBucketIfNotExists(bucketResponses); err != nil {
+				return err
+			}
+			for _, name := range [][]byte{bucketStations, bucketTrains, bucketJourneys} {
+				if err := setVersions(tx, name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name: "create favorites audit log",
+		up: func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(bucketAudit)
+			return err
+		},
+	},
+	{
+		// Favorite trains were unique by trip, so one service favorited
+		// through two trips was stored twice. They are now unique by
+		// pattern (TrainKey); of duplicates stored before, the index keeps
+		// the first in list order and the others stay as they are
+		name: "index favorite trains by pattern",
+		up:   reindexTrains,
+	},
+	{
+		// Queries of every owner's audit entries and retention purges
+		// seek by time instead of reading every entry
+		name: "index the audit log by time",
+		up:   indexAuditByTime,
+	},
+}
+
+// migrate brings the database to the latest schema version. Each migration
+// runs in its own transaction with the version bump, so a failed migration
+// leaves the database at the previous version.
+func migrate(db *bolt.DB) error {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("favorites database schema version %d is newer than this build supports (%d)", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		m := migrations[i]
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+			if err != nil {
+				return err
+			}
+			return meta.Put(keySchemaVersion, []byte(strconv.Itoa(i+1)))
+		})
+		if err != nil {
+			return fmt.Errorf("favorites database migration %d (%s): %w", i+1, m.name, err)
+		}
+		log.Info().Int("version", i+1).Str("migration", m.name).Msg("Applied favorites database migration")
+	}
+	return nil
+}
+
+// schemaVersion returns the database's schema version (0 when new).
+func schemaVersion(db *bolt.DB) (int, error) {
+	version := 0
+	err := db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		if meta == nil {
+			return nil
+		}
+		data := meta.Get(keySchemaVersion)
+		if data == nil {
+			return nil
+		}
+		v, err := strconv.Atoi(string(data))
+		if err != nil {
+			return fmt.Errorf("invalid favorites database schema version %q", data)
+		}
+		version = v
+		return nil
+	})
+	return version, err
+}
+
+// buildIndex creates an index bucket mapping a field of each record to the
+// record's key.
+func buildIndex(tx *bolt.Tx, records, name []byte, field func(data []byte) (string, error)) error {
+	index, err := tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(records).ForEach(func(key, data []byte) error {
+		value, err := field(data)
+		if err != nil {
+			return err
+		}
+		return index.Put([]byte(value), key)
+	})
+}
+
+// reindexTrains rebuilds every owner's favorite train index with TrainKey.
+func reindexTrains(tx *bolt.Tx) error {
+	if err := tx.DeleteBucket(bucketTrainIndex); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return err
+	}
+	indexes, err := tx.CreateBucket(bucketTrainIndex)
+	if err != nil {
+		return err
+	}
+	return forEachOwner(tx, bucketTrains, func(owner string) error {
+		favorites, err := listTrains(tx, owner)
+		if err != nil {
+			return err
+		}
+		index, err := indexes.CreateBucket([]byte(owner))
+		if err != nil {
+			return err
+		}
+		sortTrains(favorites)
+		for _, favorite := range favorites {
+			key := []byte(TrainKey(favorite))
+			if index.Get(key) == nil {
+				if err := index.Put(key, []byte(favorite.ID)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// indexAuditByTime creates the audit log's time index and adds every
+// entry stored so far.
+func indexAuditByTime(tx *bolt.Tx) error {
+	if _, err := tx.CreateBucketIfNotExists(bucketAuditTime); err != nil {
+		return err
+	}
+	return forEachOwner(tx, bucketAudit, func(owner string) error {
+		return tx.Bucket(bucketAudit).Bucket([]byte(owner)).ForEach(func(key, data []byte) error {
+			var entry struct {
+				Timestamp string `json:"timestamp"`
+			}
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return err
+			}
+			at, err := time.Parse(time.RFC3339, entry.Timestamp)
+			if err != nil {
+				return err
+			}
+			return indexAudit(tx, owner, key, at)
+		})
+	})
+}
+
+// numberPositions sets the position of each owner's records in a bucket
+// to their index in creation order.
+func numberPositions(tx *bolt.Tx, name []byte) error {
+	// Owners are collected first; writing to an owner's bucket may change
+	// the parent bucket, which can't change while iterated
+	var owners []string
+	if err := forEachOwner(tx, name, func(owner string) error {
+		owners = append(owners, owner)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	type record struct {
+		key       string
+		createdAt string
+		fields    map[string]json.RawMessage
+	}
+	for _, owner := range owners {
+		owned := tx.Bucket(name).Bucket([]byte(owner))
+		var records []record
+		err := owned.ForEach(func(key, data []byte) error {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				return err
+			}
+			var createdAt string
+			if raw, ok := fields["createdAt"]; ok {
+				if err := json.Unmarshal(raw, &createdAt); err != nil {
+					return err
+				}
+			}
+			records = append(records, record{key: string(key), createdAt: createdAt, fields: fields})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(records, func(i, j int) bool {
+			if records[i].createdAt != records[j].createdAt {
+				return records[i].createdAt < records[j].createdAt
+			}
+			return records[i].key < records[j].key
+		})
+		for i, r := range records {
+			r.fields["position"] = json.RawMessage(strconv.Itoa(i))
+			if err := putJSON(owned, r.key, r.fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setVersions sets the version of each record in a bucket to 1.
+func setVersions(tx *bolt.Tx, name []byte) error {
+	var owners []string
+	if err := forEachOwner(tx, name, func(owner string) error {
+		owners = append(owners, owner)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, owner := range owners {
+		owned := tx.Bucket(name).Bucket([]byte(owner))
+		records := make(map[string]map[string]json.RawMessage)
+		err := owned.ForEach(func(key, data []byte) error {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				return err
+			}
+			records[string(key)] = fields
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for key, fields := range records {
+			fields["version"] = json.RawMessage("1")
+			if err := putJSON(owned, key, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// moveToOwner moves the records at the top level of a bucket into the
+// owner's nested bucket.
+func moveToOwner(tx *bolt.Tx, name []byte, owner string) error {
+	parent := tx.Bucket(name)
+	owned, err := parent.CreateBucketIfNotExists([]byte(owner))
+	if err != nil {
+		return err
+	}
+
+	// Records are collected first; buckets can't change while iterated
+	type record struct{ key, value []byte }
+	var records []record
+	err = parent.ForEach(func(key, value []byte) error {
+		if value != nil { // not a nested bucket
+			records = append(records, record{key: key, value: value})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := owned.Put(r.key, r.value); err != nil {
+			return err
+		}
+		if err := parent.Delete(r.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// writeDatabase creates a database at path with the given schema version
+// and buckets of flat records.
+func writeDatabase(t *testing.T, path string, version int, buckets map[string]map[string]string) {
+	t.Helper()
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucket(bucketMeta)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(keySchemaVersion, []byte(strconv.Itoa(version))); err != nil {
+			return err
+		}
+		for name, records := range buckets {
+			bucket, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for key, value := range records {
+				if err := bucket.Put([]byte(key), []byte(value)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMigrateIndexes opens a database from before the duplicate check
+// indexes; its favorites go to LegacyOwner.
+func TestMigrateIndexes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.db")
+	writeDatabase(t, path, 1, map[string]map[string]string{
+		"favorite_stations": {
+			"s1": `{"id":"s1","stationId":"8507000","station":{"id":"8507000","name":"Bern"},"createdAt":"2024-05-01T08:00:00Z"}`,
+		},
+		"favorite_trains": {
+			"t1": `{"id":"t1","trainId":"1001","train":{"id":"1001","name":"IC 1"},"createdAt":"2024-05-01T09:00:00Z"}`,
+		},
+	})
+
+	repo, err := OpenBoltFavorites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if version, err := schemaVersion(repo.db); err != nil || version != len(migrations) {
+		t.Errorf("schema version = %d, %v; want %d", version, err, len(migrations))
+	}
+	if favorite, err := repo.GetTrain(LegacyOwner, "t1"); err != nil || favorite.Owner != LegacyOwner {
+		t.Errorf("train = %+v, %v", favorite, err)
+	}
+	if err := repo.CreateStation(station(LegacyOwner, "s2", "8507000", 0)); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate station = %v, want ErrDuplicate", err)
+	}
+	if err := repo.CreateStation(station(LegacyOwner, "s3", "8503000", 0)); err != nil {
+		t.Errorf("another station: %v", err)
+	}
+	if err := repo.CreateStation(station("alice", "a1", "8507000", 0)); err != nil {
+		t.Errorf("another owner's station: %v", err)
+	}
+}
+
+// TestMigrateUnscopedFavorites opens a database of the first release with
+// favorites, before they had owners, positions and versions.
+func TestMigrateUnscopedFavorites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.db")
+	writeDatabase(t, path, 2, map[string]map[string]string{
+		"favorite_stations": {
+			"s1": `{"id":"s1","stationId":"8507000","station":{"id":"8507000","name":"Bern"},"createdAt":"2024-05-02T08:00:00Z"}`,
+			"s2": `{"id":"s2","stationId":"8503000","station":{"id":"8503000","name":"Zürich HB"},"createdAt":"2024-05-01T08:00:00Z"}`,
+		},
+		"favorite_stations_by_station": {"8507000": "s1", "8503000": "s2"},
+		"favorite_trains": {
+			"t1": `{"id":"t1","trainId":"1001","train":{"id":"1001","name":"IC 1"},"autoFollow":true,"createdAt":"2024-05-01T09:00:00Z"}`,
+		},
+		"favorite_trains_by_train": {"1001": "t1"},
+	})
+
+	repo, err := OpenBoltFavorites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// The favorites belong to LegacyOwner, in creation order, at version 1
+	stations, err := repo.ListStations(LegacyOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 2 || stations[0].ID != "s2" || stations[1].ID != "s1" {
+		t.Fatalf("stations = %+v, want s2 and s1", stations)
+	}
+	for i, favorite := range stations {
+		if favorite.Position != i || favorite.Version != 1 {
+			t.Errorf("%s at position %d, version %d; want %d and 1", favorite.ID, favorite.Position, favorite.Version, i)
+		}
+	}
+	if favorite, err := repo.GetTrain(LegacyOwner, "t1"); err != nil || !favorite.AutoFollow || favorite.Version != 1 {
+		t.Errorf("train = %+v, %v", favorite, err)
+	}
+
+	// The indexes moved along: duplicates are still found
+	if err := repo.CreateStation(station(LegacyOwner, "s3", "8507000", 0)); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate station = %v, want ErrDuplicate", err)
+	}
+	train := &models.FavoriteTrain{ID: "t2", Owner: LegacyOwner, TrainID: "1001"}
+	if err := repo.CreateTrain(train); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate train = %v, want ErrDuplicate", err)
+	}
+	if err := repo.CreateStation(station("alice", "a1", "8507000", 0)); err != nil {
+		t.Errorf("another owner's station: %v", err)
+	}
+
+	// Updates check the versions set by the migration
+	if _, err := repo.UpdateStation(LegacyOwner, "s1", 1, func(f *models.FavoriteStation) { f.Nickname = "Office" }); err != nil {
+		t.Errorf("update: %v", err)
+	}
+	if _, err := repo.DeleteTrain(LegacyOwner, "t1", 2); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("delete of a stale version = %v, want ErrVersionConflict", err)
+	}
+	if _, err := repo.DeleteTrain(LegacyOwner, "t1", 1); err != nil {
+		t.Errorf("delete: %v", err)
+	}
+	if err := repo.CreateTrain(train); err != nil {
+		t.Errorf("train after delete: %v", err)
+	}
+}
+
+// TestMigrateAuditIndex opens a database whose audit log has no time index
+// yet.
+func TestMigrateAuditIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.db")
+	repo, err := OpenBoltFavorites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	for i, owner := range []string{"alice", "bob", "alice"} {
+		entry := models.AuditEntry{
+			ID: strconv.Itoa(i + 1), Owner: owner, Actor: owner, Action: models.AuditCreate,
+			Timestamp: day.AddDate(0, 0, i).Format(time.RFC3339),
+		}
+		if err := repo.AppendAudit(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Back to the schema before the index
+	err = repo.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketAuditTime); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte(strconv.Itoa(len(migrations)-1)))
+	})
+	repo.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repo, err = OpenBoltFavorites(path); err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	entries, err := repo.ListAudit(AuditQuery{From: day.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	if !reflect.DeepEqual(ids, []string{"3", "2"}) {
+		t.Errorf("entries = %v, want 3 and 2", ids)
+	}
+	if purged, err := repo.PurgeAudit(day.AddDate(0, 0, 1)); err != nil || purged != 1 {
+		t.Errorf("purge = %d, %v; want 1", purged, err)
+	}
+}
+
+// TestMigrateNewerSchema refuses a database written by a newer build.
+func TestMigrateNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.db")
+	writeDatabase(t, path, len(migrations)+1, nil)
+
+	if _, err := OpenBoltFavorites(path); err == nil || !strings.Contains(err.Error(), "newer than this build") {
+		t.Errorf("open = %v, want a schema version error", err)
+	}
+}
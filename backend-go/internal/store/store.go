@@ -0,0 +1,340 @@
+// Package store provides persistence for user data.
+//
+// Favorites (stations, trains and journeys), the collections grouping
+// them, the alert rules attached to them, the responses replayed to
+// retried creates and the audit log of changes live behind the
+// FavoritesRepository interface. The in-memory
+// repository keeps them for the life of the process; the bbolt repository
+// keeps them in an embedded database file, so they survive restarts and
+// deploys. The schema of the database is versioned and migrated on open.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+var (
+	// ErrNotFound is returned for a favorite ID the owner doesn't have.
+	ErrNotFound = errors.New("favorite not found")
+
+	// ErrDuplicate is returned when the station, train or journey is
+	// already one of the owner's favorites, the owner already has a
+	// collection of the name, or the favorite already has a rule for the
+	// condition.
+	ErrDuplicate = errors.New("already in favorites")
+
+	// ErrVersionConflict is returned when a favorite has changed since the
+	// version the caller expected.
+	ErrVersionConflict = errors.New("favorite has changed")
+
+	// ErrIncompleteOrder is returned by reorders whose IDs don't list each
+	// of the owner's records exactly once.
+	ErrIncompleteOrder = errors.New("reorder must list every record once")
+)
+
+// LegacyOwner owns the favorites stored before they were scoped to users.
+const LegacyOwner = "legacy"
+
+// FavoritesRepository stores favorite stations, trains and journeys per
+// owner (a user or a device). Owners only see their own favorites; the All
+// methods span every owner, with Owner set, for background work such as
+// polling favorite stations. Lists are in the owner's order (by position,
+// then creation). Create methods set the position, appending to that
+// order; Reorder methods fail with ErrNotFound if an ID isn't the owner's,
+// and with ErrIncompleteOrder unless the IDs list each of the owner's
+// records once.
+// Favorites start at version 1 and each update adds one; Update and Delete
+// methods given a version other than 0 fail with ErrVersionConflict unless
+// the favorite is at that version. Implementations are safe for concurrent
+// use.
+type FavoritesRepository interface {
+	ListStations(owner string) ([]models.FavoriteStation, error)
+	GetStation(owner, id string) (*models.FavoriteStation, error)
+	// CreateStation stores a favorite of favorite.Owner. It fails with
+	// ErrDuplicate if the owner already has the station.
+	CreateStation(favorite *models.FavoriteStation) error
+	// UpdateStation applies update to a favorite atomically and returns it.
+	UpdateStation(owner, id string, version int, update func(*models.FavoriteStation)) (*models.FavoriteStation, error)
+	DeleteStation(owner, id string, version int) (*models.FavoriteStation, error)
+	// ReorderStations numbers all of the owner's favorites from 0 in the
+	// order of ids. Favorites whose position changes get a new version.
+	ReorderStations(owner string, ids []string) error
+	AllStations() ([]models.FavoriteStation, error)
+
+	ListTrains(owner string) ([]models.FavoriteTrain, error)
+	GetTrain(owner, id string) (*models.FavoriteTrain, error)
+	// CreateTrain stores a favorite of favorite.Owner. It fails with
+	// ErrDuplicate if the owner already has the train.
+	CreateTrain(favorite *models.FavoriteTrain) error
+	// UpdateTrain applies update to a favorite atomically and returns it.
+	UpdateTrain(owner, id string, version int, update func(*models.FavoriteTrain)) (*models.FavoriteTrain, error)
+	DeleteTrain(owner, id string, version int) (*models.FavoriteTrain, error)
+	ReorderTrains(owner string, ids []string) error
+	AllTrains() ([]models.FavoriteTrain, error)
+
+	ListJourneys(owner string) ([]models.FavoriteJourney, error)
+	GetJourney(owner, id string) (*models.FavoriteJourney, error)
+	// CreateJourney stores a favorite of favorite.Owner. It fails with
+	// ErrDuplicate if the owner already has a journey between the stations.
+	CreateJourney(favorite *models.FavoriteJourney) error
+	// UpdateJourney applies update to a favorite atomically and returns it.
+	UpdateJourney(owner, id string, version int, update func(*models.FavoriteJourney)) (*models.FavoriteJourney, error)
+	DeleteJourney(owner, id string, version int) (*models.FavoriteJourney, error)
+	ReorderJourneys(owner string, ids []string) error
+
+	ListCollections(owner string) ([]models.FavoriteCollection, error)
+	GetCollection(owner, id string) (*models.FavoriteCollection, error)
+	// CreateCollection stores a collection of collection.Owner. It fails
+	// with ErrDuplicate if the owner already has one of the name.
+	CreateCollection(collection *models.FavoriteCollection) error
+	// UpdateCollection applies update to a collection atomically and
+	// returns it. It fails with ErrDuplicate if a rename takes the name of
+	// another collection.
+	UpdateCollection(owner, id string, update func(*models.FavoriteCollection)) (*models.FavoriteCollection, error)
+	// DeleteCollection removes a collection and takes the favorites in it
+	// out of it.
+	DeleteCollection(owner, id string) (*models.FavoriteCollection, error)
+	ReorderCollections(owner string, ids []string) error
+
+	ListRules(owner string) ([]models.AlertRule, error)
+	GetRule(owner, id string) (*models.AlertRule, error)
+	// CreateRule stores a rule of rule.Owner. It fails with ErrDuplicate if
+	// the favorite already has a rule for the condition.
+	CreateRule(rule models.AlertRule) error
+	// UpdateRule applies update to a rule atomically and returns it.
+	UpdateRule(owner, id string, update func(*models.AlertRule)) (*models.AlertRule, error)
+	DeleteRule(owner, id string) (*models.AlertRule, error)
+	AllRules() ([]models.AlertRule, error)
+
+	// GetResponse returns the stored response to the owner's request with
+	// the idempotency key, or ErrNotFound.
+	GetResponse(owner, key string) (*models.IdempotentResponse, error)
+	// SaveResponse stores a response of response.Owner, replacing any of
+	// its key.
+	SaveResponse(response models.IdempotentResponse) error
+	// PurgeResponses removes the responses that expired before now and
+	// returns how many there were.
+	PurgeResponses(now time.Time) (int, error)
+
+	// AppendAudit adds an entry of entry.Owner to the audit log. Entries
+	// are never changed; PurgeAudit removes them once they are past the
+	// retention period.
+	AppendAudit(entry models.AuditEntry) error
+	// ListAudit returns the audit entries the query selects, newest first.
+	ListAudit(query AuditQuery) ([]models.AuditEntry, error)
+	// PurgeAudit removes the audit entries recorded before before and
+	// returns how many there were.
+	PurgeAudit(before time.Time) (int, error)
+
+	// Close releases the repository's resources.
+	Close() error
+}
+
+// Config selects and configures a favorites repository.
+type Config struct {
+	Type string // "memory" or "bolt"
+	Path string // database file (bolt only)
+}
+
+// AuditQuery selects audit entries; zero fields select every entry.
+type AuditQuery struct {
+	Owner      string
+	FavoriteID string
+	Actor      string
+	Action     string
+	From       time.Time // entries at or after From
+	To         time.Time // entries before To
+	Limit      int       // the newest Limit entries (0 for all)
+}
+
+// matches reports whether the query selects an entry of its owner.
+func (q AuditQuery) matches(entry models.AuditEntry) bool {
+	if q.FavoriteID != "" && entry.FavoriteID != q.FavoriteID {
+		return false
+	}
+	if q.Actor != "" && entry.Actor != q.Actor {
+		return false
+	}
+	if q.Action != "" && entry.Action != q.Action {
+		return false
+	}
+	if q.From.IsZero() && q.To.IsZero() {
+		return true
+	}
+	at, err := time.Parse(time.RFC3339, entry.Timestamp)
+	if err != nil {
+		return false
+	}
+	return (q.From.IsZero() || !at.Before(q.From)) && (q.To.IsZero() || at.Before(q.To))
+}
+
+// newestFirst sorts audit entries of several owners by time, newest first,
+// and keeps the query's limit.
+func (q AuditQuery) newestFirst(entries []models.AuditEntry) []models.AuditEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, _ := time.Parse(time.RFC3339, entries[i].Timestamp)
+		b, _ := time.Parse(time.RFC3339, entries[j].Timestamp)
+		return a.After(b)
+	})
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries
+}
+
+// New opens the configured repository.
+func New(cfg Config) (FavoritesRepository, error) {
+	switch cfg.Type {
+	case "", "memory":
+		return NewMemoryFavorites(), nil
+	case "bolt":
+		return OpenBoltFavorites(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown favorites store %q (expected memory or bolt)", cfg.Type)
+	}
+}
+
+// sortStations sorts favorite stations by position, then in creation order.
+func sortStations(favorites []models.FavoriteStation) {
+	sort.SliceStable(favorites, func(i, j int) bool {
+		if favorites[i].Position != favorites[j].Position {
+			return favorites[i].Position < favorites[j].Position
+		}
+		if favorites[i].CreatedAt != favorites[j].CreatedAt {
+			return favorites[i].CreatedAt < favorites[j].CreatedAt
+		}
+		return favorites[i].ID < favorites[j].ID
+	})
+}
+
+// checkVersion fails with ErrVersionConflict unless version is 0 or the
+// favorite's current version.
+func checkVersion(current, version int) error {
+	if version != 0 && version != current {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// JourneyKey identifies a journey between two stations for duplicate checks.
+func JourneyKey(favorite models.FavoriteJourney) string {
+	return favorite.OriginID + ">" + favorite.DestinationID
+}
+
+// TrainKey identifies a favorite train for duplicate checks: by its
+// pattern (name, origin and departure time), so trips of the same service
+// on different days are one train. Favorites stored before patterns only
+// have their trip.
+func TrainKey(favorite models.FavoriteTrain) string {
+	if p := favorite.Pattern; p != nil {
+		name := strings.ToUpper(strings.Join(strings.Fields(p.Name), " "))
+		return name + "|" + p.OriginID + "|" + p.DepartureTime
+	}
+	return favorite.TrainID
+}
+
+// sortTrains sorts favorite trains by position, then in creation order.
+func sortTrains(favorites []models.FavoriteTrain) {
+	sort.SliceStable(favorites, func(i, j int) bool {
+		if favorites[i].Position != favorites[j].Position {
+			return favorites[i].Position < favorites[j].Position
+		}
+		if favorites[i].CreatedAt != favorites[j].CreatedAt {
+			return favorites[i].CreatedAt < favorites[j].CreatedAt
+		}
+		return favorites[i].ID < favorites[j].ID
+	})
+}
+
+// sortJourneys sorts favorite journeys by position, then in creation order.
+func sortJourneys(favorites []models.FavoriteJourney) {
+	sort.SliceStable(favorites, func(i, j int) bool {
+		if favorites[i].Position != favorites[j].Position {
+			return favorites[i].Position < favorites[j].Position
+		}
+		if favorites[i].CreatedAt != favorites[j].CreatedAt {
+			return favorites[i].CreatedAt < favorites[j].CreatedAt
+		}
+		return favorites[i].ID < favorites[j].ID
+	})
+}
+
+// CollectionKey identifies a collection by its name, ignoring case, for
+// duplicate checks.
+func CollectionKey(collection models.FavoriteCollection) string {
+	return strings.ToLower(strings.TrimSpace(collection.Name))
+}
+
+// sortCollections sorts collections by position, then in creation order.
+func sortCollections(collections []models.FavoriteCollection) {
+	sort.SliceStable(collections, func(i, j int) bool {
+		if collections[i].Position != collections[j].Position {
+			return collections[i].Position < collections[j].Position
+		}
+		if collections[i].CreatedAt != collections[j].CreatedAt {
+			return collections[i].CreatedAt < collections[j].CreatedAt
+		}
+		return collections[i].ID < collections[j].ID
+	})
+}
+
+// reorderPositions numbers the owner's records from 0 in the order of ids,
+// so no two share a position. owned lists all of the owner's records, and
+// ids must list each of them once; position returns a pointer to a
+// record's position. It returns the IDs of the records whose position
+// changed.
+func reorderPositions(owned, ids []string, position func(id string) *int) ([]string, error) {
+	listed := make(map[string]bool, len(owned))
+	for _, id := range owned {
+		listed[id] = false
+	}
+	for _, id := range ids {
+		seen, ok := listed[id]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		if seen {
+			return nil, ErrIncompleteOrder
+		}
+		listed[id] = true
+	}
+	if len(ids) != len(owned) {
+		return nil, ErrIncompleteOrder
+	}
+
+	var moved []string
+	for i, id := range ids {
+		if p := position(id); *p != i {
+			*p = i
+			moved = append(moved, id)
+		}
+	}
+	return moved, nil
+}
+
+// modifiedAt is the UpdatedAt of a record the store rewrites itself, as
+// reorders and collection deletes do.
+func modifiedAt() string {
+	return time.Now().Format(time.RFC3339)
+}
+
+// RuleKey identifies a favorite's rule for a condition for duplicate checks.
+func RuleKey(rule models.AlertRule) string {
+	return rule.FavoriteID + "|" + rule.Condition
+}
+
+// sortRules sorts alert rules in creation order.
+func sortRules(rules []models.AlertRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].CreatedAt != rules[j].CreatedAt {
+			return rules[i].CreatedAt < rules[j].CreatedAt
+		}
+		return rules[i].ID < rules[j].ID
+	})
+}
@@ -0,0 +1,440 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// eachRepository runs test against a MemoryFavorites and a BoltFavorites
+// on a temporary file.
+func eachRepository(t *testing.T, test func(t *testing.T, repo FavoritesRepository)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryFavorites())
+	})
+	t.Run("bolt", func(t *testing.T) {
+		repo, err := OpenBoltFavorites(filepath.Join(t.TempDir(), "favorites.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		test(t, repo)
+	})
+}
+
+// createdAt returns the creation time of the nth favorite of a test.
+func createdAt(n int) string {
+	return time.Date(2025, 3, 14, 8, n, 0, 0, time.UTC).Format(time.RFC3339)
+}
+
+func station(owner, id, stationID string, n int) *models.FavoriteStation {
+	return &models.FavoriteStation{
+		ID: id, Owner: owner, StationID: stationID,
+		Station:   models.Station{ID: stationID, Name: "Station " + stationID},
+		CreatedAt: createdAt(n),
+	}
+}
+
+// stationIDs returns the IDs of the owner's favorite stations in order.
+func stationIDs(t *testing.T, repo FavoritesRepository, owner string) []string {
+	t.Helper()
+	favorites, err := repo.ListStations(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(favorites))
+	for i, favorite := range favorites {
+		ids[i] = favorite.ID
+	}
+	return ids
+}
+
+// checkNumbered checks that the owner's favorite stations are numbered
+// from 0 in list order.
+func checkNumbered(t *testing.T, repo FavoritesRepository, owner string) {
+	t.Helper()
+	favorites, err := repo.ListStations(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, favorite := range favorites {
+		if favorite.Position != i {
+			t.Errorf("%s is at position %d, want %d", favorite.ID, favorite.Position, i)
+		}
+	}
+}
+
+func TestStationCRUD(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repo FavoritesRepository) {
+		for i, favorite := range []*models.FavoriteStation{
+			station("alice", "a1", "8503000", 1),
+			station("alice", "a2", "8507000", 2),
+			station("bob", "b1", "8503000", 3), // owners don't share favorites
+		} {
+			if err := repo.CreateStation(favorite); err != nil {
+				t.Fatalf("create %d: %v", i, err)
+			}
+		}
+		if err := repo.CreateStation(station("alice", "a3", "8503000", 3)); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("duplicate station = %v, want ErrDuplicate", err)
+		}
+		if ids := stationIDs(t, repo, "alice"); !reflect.DeepEqual(ids, []string{"a1", "a2"}) {
+			t.Errorf("alice has %v", ids)
+		}
+
+		// Owners only reach their own favorites
+		if favorite, err := repo.GetStation("alice", "a2"); err != nil || favorite.StationID != "8507000" || favorite.Version != 1 || favorite.Station.Name != "Station 8507000" {
+			t.Errorf("get = %+v, %v", favorite, err)
+		}
+		if _, err := repo.GetStation("alice", "x"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("get of an unknown ID = %v, want ErrNotFound", err)
+		}
+		if _, err := repo.GetStation("bob", "a1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("bob reading alice's favorite = %v, want ErrNotFound", err)
+		}
+		if _, err := repo.DeleteStation("bob", "a1", 0); !errors.Is(err, ErrNotFound) {
+			t.Errorf("bob deleting alice's favorite = %v, want ErrNotFound", err)
+		}
+
+		// Updates check and bump the version
+		rename := func(f *models.FavoriteStation) { f.Nickname = "Home" }
+		if favorite, err := repo.UpdateStation("alice", "a1", 0, rename); err != nil || favorite.Version != 2 || favorite.Nickname != "Home" {
+			t.Errorf("unconditional update = %+v, %v", favorite, err)
+		}
+		if _, err := repo.UpdateStation("alice", "a1", 1, rename); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("update of a stale version = %v, want ErrVersionConflict", err)
+		}
+		if favorite, err := repo.UpdateStation("alice", "a1", 2, rename); err != nil || favorite.Version != 3 {
+			t.Errorf("update of the current version = %+v, %v", favorite, err)
+		}
+		if favorite, _ := repo.GetStation("alice", "a1"); favorite.Version != 3 || favorite.Nickname != "Home" {
+			t.Errorf("stored %+v", favorite)
+		}
+		if _, err := repo.UpdateStation("bob", "a1", 0, rename); !errors.Is(err, ErrNotFound) {
+			t.Errorf("update of another owner's favorite = %v, want ErrNotFound", err)
+		}
+
+		if _, err := repo.DeleteStation("alice", "a1", 2); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("delete of a stale version = %v, want ErrVersionConflict", err)
+		}
+		if favorite, err := repo.DeleteStation("alice", "a1", 3); err != nil || favorite.ID != "a1" || favorite.Nickname != "Home" {
+			t.Errorf("delete = %+v, %v", favorite, err)
+		}
+		if _, err := repo.GetStation("alice", "a1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("get after delete = %v, want ErrNotFound", err)
+		}
+		if _, err := repo.DeleteStation("alice", "a1", 0); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second delete = %v, want ErrNotFound", err)
+		}
+
+		// The station can be a favorite again, going last
+		if err := repo.CreateStation(station("alice", "a4", "8503000", 4)); err != nil {
+			t.Fatalf("re-create: %v", err)
+		}
+		if ids := stationIDs(t, repo, "alice"); !reflect.DeepEqual(ids, []string{"a2", "a4"}) {
+			t.Errorf("alice has %v", ids)
+		}
+		if _, err := repo.GetStation("bob", "b1"); err != nil {
+			t.Errorf("bob's favorite: %v", err)
+		}
+
+		all, err := repo.AllStations()
+		if err != nil || len(all) != 3 {
+			t.Errorf("all stations = %d, %v; want 3", len(all), err)
+		}
+	})
+}
+
+func TestTrainCRUD(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repo FavoritesRepository) {
+		ic1 := &models.FavoriteTrain{ID: "t1", Owner: "alice", TrainID: "1001", Train: models.Train{ID: "1001", Name: "IC 1"}, CreatedAt: createdAt(1)}
+		if err := repo.CreateTrain(ic1); err != nil {
+			t.Fatal(err)
+		}
+		again := *ic1
+		again.ID = "t2"
+		if err := repo.CreateTrain(&again); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("same train = %v, want ErrDuplicate", err)
+		}
+		bobs := *ic1
+		bobs.Owner = "bob"
+		if err := repo.CreateTrain(&bobs); err != nil {
+			t.Errorf("another owner's train: %v", err)
+		}
+
+		follow := func(f *models.FavoriteTrain) { f.AutoFollow = true }
+		if favorite, err := repo.UpdateTrain("alice", "t1", 0, follow); err != nil || !favorite.AutoFollow {
+			t.Errorf("update = %+v, %v", favorite, err)
+		}
+		if favorites, err := repo.ListTrains("alice"); err != nil || len(favorites) != 1 || !favorites[0].AutoFollow || favorites[0].Train.Name != "IC 1" {
+			t.Errorf("list = %+v, %v", favorites, err)
+		}
+
+		if _, err := repo.DeleteTrain("alice", "t1", 0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetTrain("alice", "t1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("get after delete = %v, want ErrNotFound", err)
+		}
+		if err := repo.CreateTrain(&again); err != nil {
+			t.Errorf("train after delete: %v", err)
+		}
+		if all, err := repo.AllTrains(); err != nil || len(all) != 2 {
+			t.Errorf("all trains = %d, %v; want 2", len(all), err)
+		}
+	})
+}
+
+func TestTrainDuplicates(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repo FavoritesRepository) {
+		ic1 := func(id, tripID, name string) *models.FavoriteTrain {
+			return &models.FavoriteTrain{
+				ID: id, Owner: "alice", TrainID: tripID, CreatedAt: createdAt(1),
+				Pattern: &models.ServicePattern{Name: name, OriginID: "8506302", DepartureTime: "05:32"},
+			}
+		}
+		if err := repo.CreateTrain(ic1("t1", "1001", "IC 1")); err != nil {
+			t.Fatal(err)
+		}
+		// The same service through another day's trip, spelled differently
+		if err := repo.CreateTrain(ic1("t2", "2001", "ic  1")); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("same pattern = %v, want ErrDuplicate", err)
+		}
+		later := ic1("t3", "1002", "IC 1")
+		later.Pattern.DepartureTime = "06:32"
+		if err := repo.CreateTrain(later); err != nil {
+			t.Errorf("another departure: %v", err)
+		}
+
+		// Favorites without a pattern are unique by trip
+		legacy := &models.FavoriteTrain{ID: "t4", Owner: "alice", TrainID: "3001", CreatedAt: createdAt(2)}
+		if err := repo.CreateTrain(legacy); err != nil {
+			t.Fatal(err)
+		}
+		again := *legacy
+		again.ID = "t5"
+		if err := repo.CreateTrain(&again); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("same trip = %v, want ErrDuplicate", err)
+		}
+
+		if _, err := repo.DeleteTrain("alice", "t1", 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.CreateTrain(ic1("t2", "2001", "ic  1")); err != nil {
+			t.Errorf("pattern after delete: %v", err)
+		}
+	})
+}
+
+func TestReorder(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repo FavoritesRepository) {
+		for i, id := range []string{"a", "b", "c", "d"} {
+			if err := repo.CreateStation(station("alice", id, "850000"+id, i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repo.CreateStation(station("bob", "e", "8500001", 4)); err != nil {
+			t.Fatal(err)
+		}
+
+		// d and a swap places; b and c stay
+		if err := repo.ReorderStations("alice", []string{"d", "b", "c", "a"}); err != nil {
+			t.Fatal(err)
+		}
+		if ids := stationIDs(t, repo, "alice"); !reflect.DeepEqual(ids, []string{"d", "b", "c", "a"}) {
+			t.Errorf("order = %v", ids)
+		}
+		checkNumbered(t, repo, "alice")
+		// Moved favorites get a new version, so their ETag changes
+		for id, version := range map[string]int{"a": 2, "b": 1, "c": 1, "d": 2} {
+			if favorite, _ := repo.GetStation("alice", id); favorite.Version != version {
+				t.Errorf("%s is at version %d, want %d", id, favorite.Version, version)
+			} else if version > 1 && favorite.UpdatedAt == "" {
+				t.Errorf("%s has no updatedAt", id)
+			}
+		}
+
+		// Every favorite must be listed once, and only the owner's
+		for _, tc := range []struct {
+			name  string
+			owner string
+			ids   []string
+			err   error
+		}{
+			{"partial", "alice", []string{"a", "d"}, ErrIncompleteOrder},
+			{"duplicate", "alice", []string{"a", "b", "c", "c"}, ErrIncompleteOrder},
+			{"listed twice and complete", "alice", []string{"a", "b", "c", "d", "a"}, ErrIncompleteOrder},
+			{"unknown", "alice", []string{"a", "b", "c", "x"}, ErrNotFound},
+			{"another owner's", "alice", []string{"a", "b", "c", "d", "e"}, ErrNotFound},
+			{"none of theirs", "carol", []string{"a"}, ErrNotFound},
+		} {
+			if err := repo.ReorderStations(tc.owner, tc.ids); !errors.Is(err, tc.err) {
+				t.Errorf("%s: %v, want %v", tc.name, err, tc.err)
+			}
+		}
+		if ids := stationIDs(t, repo, "alice"); !reflect.DeepEqual(ids, []string{"d", "b", "c", "a"}) {
+			t.Errorf("order after rejected reorders = %v", ids)
+		}
+		if favorite, _ := repo.GetStation("bob", "e"); favorite.Position != 0 || favorite.Version != 1 {
+			t.Errorf("bob's favorite = %+v", favorite)
+		}
+
+		// Favorites sharing a position are all renumbered
+		for _, id := range []string{"a", "b", "c", "d"} {
+			if _, err := repo.UpdateStation("alice", id, 0, func(f *models.FavoriteStation) { f.Position = 0 }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repo.ReorderStations("alice", []string{"c", "b", "a", "d"}); err != nil {
+			t.Fatal(err)
+		}
+		if ids := stationIDs(t, repo, "alice"); !reflect.DeepEqual(ids, []string{"c", "b", "a", "d"}) {
+			t.Errorf("order after ties = %v", ids)
+		}
+		checkNumbered(t, repo, "alice")
+
+		// The other kinds are numbered alike
+		for i, name := range []string{"Commute", "Weekend"} {
+			if err := repo.CreateCollection(&models.FavoriteCollection{ID: name, Owner: "alice", Name: name, CreatedAt: createdAt(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repo.ReorderCollections("alice", []string{"Weekend"}); !errors.Is(err, ErrIncompleteOrder) {
+			t.Errorf("partial collections: %v", err)
+		}
+		if err := repo.ReorderCollections("alice", []string{"Weekend", "Commute"}); err != nil {
+			t.Fatal(err)
+		}
+		if collections, _ := repo.ListCollections("alice"); collections[0].ID != "Weekend" || collections[0].Position != 0 || collections[1].Position != 1 {
+			t.Errorf("collections = %+v", collections)
+		}
+	})
+}
+
+func TestDeleteCollection(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repo FavoritesRepository) {
+		commute := &models.FavoriteCollection{ID: "c1", Owner: "alice", Name: "Commute", CreatedAt: createdAt(0)}
+		if err := repo.CreateCollection(commute); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.CreateCollection(&models.FavoriteCollection{ID: "c2", Owner: "alice", Name: "commute"}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("duplicate name = %v, want ErrDuplicate", err)
+		}
+		in := station("alice", "a", "8503000", 1)
+		in.CollectionID = "c1"
+		out := station("alice", "b", "8507000", 2)
+		for _, favorite := range []*models.FavoriteStation{in, out} {
+			if err := repo.CreateStation(favorite); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := repo.DeleteCollection("alice", "c1"); err != nil {
+			t.Fatal(err)
+		}
+		if favorite, _ := repo.GetStation("alice", "a"); favorite.CollectionID != "" || favorite.Version != 2 {
+			t.Errorf("favorite in the collection = %+v, want it out and at version 2", favorite)
+		}
+		if favorite, _ := repo.GetStation("alice", "b"); favorite.Version != 1 {
+			t.Errorf("favorite outside the collection = %+v, want it unchanged", favorite)
+		}
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	eachRepository(t, func(t *testing.T, repo FavoritesRepository) {
+		day := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+		for i, owner := range []string{"alice", "bob", "alice", "bob"} {
+			entry := models.AuditEntry{
+				ID: string(rune('1' + i)), Owner: owner, Actor: owner, Action: models.AuditCreate,
+				FavoriteType: models.AuditFavoriteStation, FavoriteID: owner + "-favorite",
+				Timestamp: day.AddDate(0, 0, i).Format(time.RFC3339),
+			}
+			if err := repo.AppendAudit(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ids := func(query AuditQuery) []string {
+			t.Helper()
+			entries, err := repo.ListAudit(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, len(entries))
+			for i, entry := range entries {
+				ids[i] = entry.ID
+			}
+			return ids
+		}
+		for _, tc := range []struct {
+			name  string
+			query AuditQuery
+			want  []string
+		}{
+			{"everyone", AuditQuery{}, []string{"4", "3", "2", "1"}},
+			{"owner", AuditQuery{Owner: "alice"}, []string{"3", "1"}},
+			{"range", AuditQuery{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 3)}, []string{"3", "2"}},
+			{"owner in range", AuditQuery{Owner: "bob", From: day.AddDate(0, 0, 2)}, []string{"4"}},
+			{"limit", AuditQuery{Limit: 3}, []string{"4", "3", "2"}},
+			{"range and limit", AuditQuery{To: day.AddDate(0, 0, 3), Limit: 1}, []string{"3"}},
+			{"after the last", AuditQuery{From: day.AddDate(0, 0, 4)}, []string{}},
+		} {
+			if got := ids(tc.query); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s: %v, want %v", tc.name, got, tc.want)
+			}
+		}
+
+		purged, err := repo.PurgeAudit(day.AddDate(0, 0, 2))
+		if err != nil || purged != 2 {
+			t.Errorf("purge = %d, %v; want 2", purged, err)
+		}
+		if got := ids(AuditQuery{}); !reflect.DeepEqual(got, []string{"4", "3"}) {
+			t.Errorf("after the purge: %v", got)
+		}
+		if got := ids(AuditQuery{Owner: "alice"}); !reflect.DeepEqual(got, []string{"3"}) {
+			t.Errorf("alice's after the purge: %v", got)
+		}
+	})
+}
+
+// TestBoltReopen checks that favorites survive closing the database.
+func TestBoltReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.db")
+	repo, err := OpenBoltFavorites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateStation(station("alice", "a1", "8503000", 1)); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	if repo, err = OpenBoltFavorites(path); err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if ids := stationIDs(t, repo, "alice"); !reflect.DeepEqual(ids, []string{"a1"}) {
+		t.Errorf("favorites after reopening = %v", ids)
+	}
+	if err := repo.CreateStation(station("alice", "a2", "8503000", 2)); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate after reopening = %v, want ErrDuplicate", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if repo, err := New(Config{}); err != nil {
+		t.Errorf("default store: %v", err)
+	} else if _, ok := repo.(*MemoryFavorites); !ok {
+		t.Errorf("default store is a %T, want memory", repo)
+	}
+	for _, cfg := range []Config{{Type: "bolt"}, {Type: "sqlite"}} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
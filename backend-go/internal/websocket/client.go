@@ -0,0 +1,270 @@
+// Package websocket - Client
+// This file contains the per-connection read/write pumps and the handling
+// of incoming client messages.
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// Client represents a WebSocket client connection.
+type Client struct {
+	hub   *Hub
+	conn  *websocket.Conn
+	queue *outboundQueue
+
+	// websocket.TextMessage for JSON, websocket.BinaryMessage for MessagePack
+	frameType int
+
+	// Semaphore of in-flight RPC calls
+	calls chan struct{}
+
+	// Session the connection is bound to; replaced on resume
+	mu      sync.Mutex
+	session *Session
+}
+
+// sess returns the client's current session.
+func (c *Client) sess() *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+// setSession binds the client to another session.
+func (c *Client) setSession(session *Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = session
+}
+
+// reply sends a message to the client through its session.
+func (c *Client) reply(msg models.WebSocketMessage) {
+	if _, ok := c.sess().enqueue(newFrame(msg)); !ok {
+		log.Debug().Str("type", msg.Type).Msg("Client queue full, reply dropped")
+	}
+}
+
+// sendError sends an error message to the client. Message repeats the
+// error's message for clients that predate structured errors.
+func (c *Client) sendError(errType, message string) {
+	c.reply(models.WebSocketMessage{
+		Type:    "error",
+		Message: message,
+		Error: &models.RequestError{
+			Status:  http.StatusBadRequest,
+			Type:    errType,
+			Message: message,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// readPump reads messages from the WebSocket connection.
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.unregister <- c
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(512 * 1024) // 512KB max message size
+	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+
+	for {
+		messageType, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Warn().Err(err).Msg("WebSocket read error")
+			}
+			break
+		}
+
+		// MessagePack clients send binary frames
+		if messageType == websocket.BinaryMessage {
+			if message, err = msgPackToJSON(message); err != nil {
+				log.Warn().Err(err).Msg("Invalid MessagePack WebSocket message")
+				continue
+			}
+		}
+
+		// Handle incoming messages
+		c.handleMessage(message)
+	}
+}
+
+// handleMessage processes incoming WebSocket messages.
+func (c *Client) handleMessage(message []byte) {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(message, &msg); err != nil {
+		log.Warn().Err(err).Msg("Invalid WebSocket message")
+		c.sendError("Invalid Message", "Message must be a JSON object")
+		return
+	}
+
+	msgType, _ := msg["type"].(string)
+	log.Debug().Str("type", msgType).Msg("Received WebSocket message")
+
+	session := c.sess()
+
+	switch msgType {
+	case "request_live_data":
+		if c.hub.gtfsService.IsDataLoaded() {
+			c.reply(models.WebSocketMessage{
+				Type:      "live_trains",
+				Data:      c.hub.trainsFor(session),
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+		}
+
+	case "subscribe", "unsubscribe":
+		var req subscriptionRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			c.sendError("Invalid Message", "Invalid "+msgType+" message: "+err.Error())
+			return
+		}
+		if err := session.subs.Apply(&req, msgType == "subscribe"); err != nil {
+			c.sendError("Invalid Subscription", err.Error())
+			return
+		}
+		c.reply(models.WebSocketMessage{
+			Type:      "subscriptions",
+			Data:      session.subs.State(),
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		if state, changed := c.hub.followingUpdate(session, c.hub.favorites()); changed {
+			c.reply(models.WebSocketMessage{
+				Type:      "following",
+				Data:      state,
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+		}
+
+	case "request_snapshot":
+		// Sent by delta clients that detected a sequence gap; the next update
+		// of the feed (or of every feed) is a full snapshot
+		if session.deltas == nil {
+			c.sendError("Bad Request", "Snapshots are only available with ?updates=delta")
+			return
+		}
+		feed, _ := msg["feed"].(string)
+		if feed == "" {
+			session.resetDeltas()
+			return
+		}
+		encoder, ok := session.deltas[feed]
+		if !ok {
+			c.sendError("Bad Request", "Unknown feed "+feed)
+			return
+		}
+		encoder.Reset()
+
+	case "resume":
+		// Sent right after reconnecting: {"type": "resume", "sessionId", "lastSeq"}
+		var req struct {
+			SessionID string `json:"sessionId"`
+			LastSeq   uint64 `json:"lastSeq"`
+		}
+		if err := json.Unmarshal(message, &req); err != nil || req.SessionID == "" {
+			c.sendError("Invalid Message", "Invalid resume message: sessionId and lastSeq are required")
+			return
+		}
+		result, err := c.hub.resume(c, req.SessionID, req.LastSeq)
+		if err != nil {
+			c.reply(models.WebSocketMessage{
+				Type:      "resume_failed",
+				Message:   err.Error(),
+				SessionID: session.ID,
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+			return
+		}
+		c.reply(models.WebSocketMessage{
+			Type:      "resumed",
+			Data:      result,
+			SessionID: req.SessionID,
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+
+	case "request":
+		c.call(message)
+
+	case "simulation":
+		var req simulationRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			c.sendError("Invalid Message", "Invalid simulation message: "+err.Error())
+			return
+		}
+		state, err := c.hub.controlSimulation(session, &req)
+		if err != nil {
+			c.sendError("Invalid Simulation", err.Error())
+			return
+		}
+		c.reply(models.WebSocketMessage{
+			Type:      "simulation",
+			Data:      state,
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		if c.hub.gtfsService.IsDataLoaded() {
+			c.reply(models.WebSocketMessage{
+				Type:      "live_trains",
+				Data:      c.hub.trainsFor(session),
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+		}
+
+	case "ping":
+		c.reply(models.WebSocketMessage{
+			Type:      "pong",
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+
+	default:
+		c.sendError("Unknown Type", "Unknown message type \""+msgType+"\" (expected request_live_data, subscribe, unsubscribe, request_snapshot, resume, request, simulation or ping)")
+	}
+}
+
+// writePump writes messages to the WebSocket connection.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(54 * time.Second) // Ping interval
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-c.queue.ready:
+			// One message per frame
+			frames, closed := c.queue.drain()
+			for _, f := range frames {
+				c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				if err := c.conn.WriteMessage(c.frameType, f.data); err != nil {
+					return
+				}
+			}
+
+			if closed {
+				c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package websocket - Delta Encoding
+// This file contains the per-client delta encoder for train feeds.
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// Train feeds that support delta encoding.
+const (
+	feedLiveTrains     = "live_trains"
+	feedFollowedTrains = "followed_trains"
+)
+
+// TrainSnapshot is the first message of a delta-encoded feed, and the answer
+// to request_snapshot. Later deltas apply on top of it.
+type TrainSnapshot struct {
+	Seq    uint64         `json:"seq"`
+	Trains []models.Train `json:"trains"`
+}
+
+// TrainDelta carries what changed since the message with sequence Seq-1.
+// Changed trains only list top-level fields that differ; a field that
+// disappeared is sent as null.
+type TrainDelta struct {
+	Seq     uint64         `json:"seq"`
+	Added   []models.Train `json:"added,omitempty"`
+	Removed []string       `json:"removed,omitempty"`
+	Changed []TrainChange  `json:"changed,omitempty"`
+}
+
+// TrainChange lists the changed fields of one train.
+type TrainChange struct {
+	ID     string                     `json:"id"`
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+// trainFields is a train broken down into its encoded top-level fields.
+type trainFields map[string]json.RawMessage
+
+// deltaEncoder remembers what a client was last sent on one feed.
+type deltaEncoder struct {
+	mu    sync.Mutex
+	feed  string
+	seq   uint64
+	state map[string]trainFields // nil until the next snapshot
+}
+
+func newDeltaEncoder(feed string) *deltaEncoder {
+	return &deltaEncoder{feed: feed}
+}
+
+// Reset makes the next encoded message a full snapshot.
+func (e *deltaEncoder) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state = nil
+}
+
+// Encode returns the message to send for the current trains: a snapshot
+// after a reset, otherwise a delta. ok is false if nothing changed.
+func (e *deltaEncoder) Encode(trains []models.Train) (msgType string, payload interface{}, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	current := make(map[string]trainFields, len(trains))
+	for i := range trains {
+		fields, err := encodeTrainFields(&trains[i])
+		if err != nil {
+			log.Error().Err(err).Str("train", trains[i].ID).Msg("Failed to encode train for delta")
+			continue
+		}
+		current[trains[i].ID] = fields
+	}
+
+	if e.state == nil {
+		e.seq++
+		e.state = current
+		if trains == nil {
+			trains = []models.Train{}
+		}
+		return e.feed + "_snapshot", TrainSnapshot{Seq: e.seq, Trains: trains}, true
+	}
+
+	delta := TrainDelta{}
+	for i := range trains {
+		id := trains[i].ID
+		fields, known := current[id]
+		if !known {
+			continue
+		}
+
+		previous, existed := e.state[id]
+		if !existed {
+			delta.Added = append(delta.Added, trains[i])
+			continue
+		}
+
+		if changed := diffFields(previous, fields); len(changed) > 0 {
+			delta.Changed = append(delta.Changed, TrainChange{ID: id, Fields: changed})
+		}
+	}
+	for id := range e.state {
+		if _, still := current[id]; !still {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+	sort.Strings(delta.Removed)
+
+	e.state = current
+	if len(delta.Added) == 0 && len(delta.Removed) == 0 && len(delta.Changed) == 0 {
+		return "", nil, false
+	}
+
+	e.seq++
+	delta.Seq = e.seq
+	return e.feed + "_delta", delta, true
+}
+
+// encodeTrainFields splits a train into its JSON-encoded top-level fields.
+func encodeTrainFields(train *models.Train) (trainFields, error) {
+	data, err := json.Marshal(train)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields trainFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// diffFields returns the fields of next that differ from prev; fields that
+// are gone are reported as null.
+func diffFields(prev, next trainFields) map[string]json.RawMessage {
+	changed := make(map[string]json.RawMessage)
+	for name, value := range next {
+		if old, ok := prev[name]; !ok || !bytes.Equal(old, value) {
+			changed[name] = value
+		}
+	}
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			changed[name] = json.RawMessage("null")
+		}
+	}
+	return changed
+}
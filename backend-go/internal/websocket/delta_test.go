@@ -0,0 +1,191 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// deltaClient keeps a feed's trains the way a client does: it loads
+// snapshots and applies deltas to them, through JSON as sent.
+type deltaClient struct {
+	t      *testing.T
+	seq    uint64
+	trains map[string]map[string]json.RawMessage
+}
+
+// receive applies a message from the encoder.
+func (c *deltaClient) receive(msgType string, payload interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	switch msgType {
+	case feedLiveTrains + "_snapshot":
+		var snapshot struct {
+			Seq    uint64                       `json:"seq"`
+			Trains []map[string]json.RawMessage `json:"trains"`
+		}
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			c.t.Fatal(err)
+		}
+		c.seq = snapshot.Seq
+		c.trains = make(map[string]map[string]json.RawMessage)
+		for _, train := range snapshot.Trains {
+			c.trains[id(c.t, train)] = train
+		}
+
+	case feedLiveTrains + "_delta":
+		var delta struct {
+			Seq     uint64                       `json:"seq"`
+			Added   []map[string]json.RawMessage `json:"added"`
+			Removed []string                     `json:"removed"`
+			Changed []TrainChange                `json:"changed"`
+		}
+		if err := json.Unmarshal(data, &delta); err != nil {
+			c.t.Fatal(err)
+		}
+		if c.trains == nil || delta.Seq != c.seq+1 {
+			c.t.Fatalf("delta %d applied to seq %d", delta.Seq, c.seq)
+		}
+		c.seq = delta.Seq
+		for _, train := range delta.Added {
+			c.trains[id(c.t, train)] = train
+		}
+		for _, removed := range delta.Removed {
+			if c.trains[removed] == nil {
+				c.t.Errorf("delta removes unknown train %s", removed)
+			}
+			delete(c.trains, removed)
+		}
+		for _, change := range delta.Changed {
+			train := c.trains[change.ID]
+			if train == nil {
+				c.t.Fatalf("delta changes unknown train %s", change.ID)
+			}
+			for name, value := range change.Fields {
+				train[name] = value
+			}
+		}
+
+	default:
+		c.t.Fatalf("unexpected message type %s", msgType)
+	}
+}
+
+// check compares the client's trains with want.
+func (c *deltaClient) check(want []models.Train) {
+	c.t.Helper()
+	got := make(map[string]models.Train, len(c.trains))
+	for trainID, fields := range c.trains {
+		data, _ := json.Marshal(fields)
+		var train models.Train
+		if err := json.Unmarshal(data, &train); err != nil {
+			c.t.Fatal(err)
+		}
+		got[trainID] = train
+	}
+	expected := make(map[string]models.Train, len(want))
+	for _, train := range want {
+		expected[train.ID] = train
+	}
+	if !reflect.DeepEqual(got, expected) {
+		c.t.Errorf("client has\n%+v\nwant\n%+v", got, expected)
+	}
+}
+
+func id(t *testing.T, train map[string]json.RawMessage) string {
+	t.Helper()
+	var trainID string
+	if err := json.Unmarshal(train["id"], &trainID); err != nil {
+		t.Fatal(err)
+	}
+	return trainID
+}
+
+func TestDeltaEncoderRoundTrip(t *testing.T) {
+	zurich := &models.Station{ID: "8503000", Name: "Zürich HB"}
+	ic1 := models.Train{
+		ID: "1009", Name: "IC 1", Category: "IC", From: "St. Gallen", To: "Genève",
+		Position:       &models.Position{Lat: 47.37, Lng: 8.54},
+		CurrentStation: zurich,
+		Timetable:      []models.TrainStop{{Station: zurich, DepartureTime: "08:02:00", Platform: "31"}},
+	}
+	ir15 := models.Train{ID: "2015", Name: "IR 15", Category: "IR", Delay: 2}
+	s12 := models.Train{ID: "3012", Name: "S 12", Category: "S"}
+
+	moved := ic1
+	moved.Position = &models.Position{Lat: 47.39, Lng: 8.51}
+	moved.Delay = 3
+	moved.Timetable = []models.TrainStop{{Station: zurich, DepartureTime: "08:02:00", Platform: "32"}}
+
+	// A field that disappears (omitempty) must disappear on the client too
+	between := moved
+	between.CurrentStation = nil
+	between.Position = nil
+
+	steps := []struct {
+		name   string
+		trains []models.Train
+		want   string // message type, "" for nothing
+	}{
+		{"first", []models.Train{ic1, ir15}, "snapshot"},
+		{"unchanged", []models.Train{ic1, ir15}, ""},
+		{"added", []models.Train{ic1, ir15, s12}, "delta"},
+		{"changed", []models.Train{moved, ir15, s12}, "delta"},
+		{"removed", []models.Train{moved, s12}, "delta"},
+		{"fields gone", []models.Train{between, s12}, "delta"},
+		{"all at once", []models.Train{ic1, ir15}, "delta"},
+		{"empty", nil, "delta"},
+		{"back", []models.Train{ir15}, "delta"},
+	}
+
+	encoder := newDeltaEncoder(feedLiveTrains)
+	client := &deltaClient{t: t}
+	for _, step := range steps {
+		msgType, payload, ok := encoder.Encode(step.trains)
+		switch {
+		case step.want == "" && ok:
+			t.Errorf("%s: sent %s, want nothing", step.name, msgType)
+		case step.want != "" && msgType != feedLiveTrains+"_"+step.want:
+			t.Errorf("%s: sent %q, want %s", step.name, msgType, step.want)
+		}
+		if ok {
+			client.receive(msgType, payload)
+		}
+		client.check(step.trains)
+	}
+
+	// Only changed fields are sent
+	msgType, payload, _ := encoder.Encode([]models.Train{func() models.Train { late := ir15; late.Delay = 5; return late }()})
+	delta, isDelta := payload.(TrainDelta)
+	if !isDelta || len(delta.Changed) != 1 || !reflect.DeepEqual(delta.Changed[0].Fields, map[string]json.RawMessage{"delay": json.RawMessage("5")}) {
+		t.Errorf("%s %+v, want a delta of the delay only", msgType, payload)
+	}
+	client.receive(msgType, payload)
+
+	// After a reset the next message is a snapshot continuing the sequence
+	seq := client.seq
+	encoder.Reset()
+	msgType, payload, ok := encoder.Encode([]models.Train{ic1})
+	if !ok || msgType != feedLiveTrains+"_snapshot" {
+		t.Fatalf("after Reset sent %q, want a snapshot", msgType)
+	}
+	if snapshot := payload.(TrainSnapshot); snapshot.Seq != seq+1 {
+		t.Errorf("snapshot seq = %d, want %d", snapshot.Seq, seq+1)
+	}
+	client.receive(msgType, payload)
+	client.check([]models.Train{ic1})
+
+	// Deltas continue from the snapshot
+	msgType, payload, _ = encoder.Encode([]models.Train{ic1, s12})
+	if delta := payload.(TrainDelta); msgType != feedLiveTrains+"_delta" || len(delta.Added) != 1 || delta.Added[0].ID != s12.ID {
+		t.Errorf("%s %+v, want S 12 added", msgType, payload)
+	}
+	client.receive(msgType, payload)
+	client.check([]models.Train{ic1, s12})
+}
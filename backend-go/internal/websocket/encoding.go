@@ -0,0 +1,166 @@
+// Package websocket - Encodings
+// This file contains the wire encodings negotiated by subprotocol
+// (JSON text frames or MessagePack binary frames).
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Supported encodings, selected with the Sec-WebSocket-Protocol header.
+// Clients that don't ask for a subprotocol get JSON.
+const (
+	EncodingJSON    = "json"
+	EncodingMsgPack = "msgpack"
+)
+
+// subprotocols lists the offered encodings in order of preference.
+var subprotocols = []string{EncodingMsgPack, EncodingJSON}
+
+// frame is an outbound message. It is encoded lazily, at most once per
+// encoding, so a broadcast costs one encode per encoding in use rather than
+// one per client.
+type frame struct {
+	msg models.WebSocketMessage
+	key string // coalescing key; empty for control messages
+
+	mu      sync.Mutex
+	encoded map[string][]byte
+}
+
+// newFrame creates a frame for a message.
+func newFrame(msg models.WebSocketMessage) *frame {
+	return &frame{msg: msg}
+}
+
+// marshalMessage creates a data frame of the given type.
+func marshalMessage(msgType string, payload interface{}) *frame {
+	return newFrame(models.WebSocketMessage{
+		Type:      msgType,
+		Data:      payload,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// coalesce marks the frame as a replaceable update: a newer frame with the
+// same key replaces it while it is still queued.
+func (f *frame) coalesce(key string) *frame {
+	f.key = key
+	return f
+}
+
+// bytes returns the frame in the given encoding, or nil if it can't be encoded.
+func (f *frame) bytes(encoding string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if data, ok := f.encoded[encoding]; ok {
+		return data
+	}
+
+	var data []byte
+	var err error
+	switch encoding {
+	case EncodingMsgPack:
+		data, err = encodeMsgPack(f.msg)
+	default:
+		data, err = json.Marshal(f.msg)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("type", f.msg.Type).Str("encoding", encoding).Msg("Failed to encode WebSocket message")
+		data = nil
+	}
+
+	if f.encoded == nil {
+		f.encoded = make(map[string][]byte)
+	}
+	f.encoded[encoding] = data
+	return data
+}
+
+// encodeMsgPack encodes a value as MessagePack using its JSON field names,
+// so both encodings carry the same keys.
+func encodeMsgPack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// msgPackToJSON converts an incoming MessagePack message to JSON so all
+// client messages go through the same handler.
+func msgPackToJSON(data []byte) ([]byte, error) {
+	var msg map[string]interface{}
+	if err := msgpack.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return json.Marshal(msg)
+}
+
+// EncodeMsgpack sends changed fields as values rather than raw JSON bytes.
+func (c TrainChange) EncodeMsgpack(enc *msgpack.Encoder) error {
+	fields := make(map[string]interface{}, len(c.Fields))
+	for name, raw := range c.Fields {
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		fields[name] = value
+	}
+	return enc.Encode(map[string]interface{}{
+		"id":     c.ID,
+		"fields": fields,
+	})
+}
+
+// numberMessage adds a "seq" field to an encoded message object.
+func numberMessage(seq uint64, data []byte, encoding string) []byte {
+	if encoding == EncodingMsgPack {
+		return numberMsgPack(seq, data)
+	}
+	return numberJSON(seq, data)
+}
+
+// numberJSON prepends "seq" to a JSON object.
+func numberJSON(seq uint64, data []byte) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+
+	prefix := `{"seq":` + strconv.FormatUint(seq, 10)
+	numbered := make([]byte, 0, len(data)+len(prefix)+1)
+	numbered = append(numbered, prefix...)
+	if data[1] != '}' {
+		numbered = append(numbered, ',')
+	}
+	return append(numbered, data[1:]...)
+}
+
+// numberMsgPack prepends "seq" to a MessagePack fixmap (fewer than 15
+// entries, which holds for every WebSocketMessage).
+func numberMsgPack(seq uint64, data []byte) []byte {
+	if len(data) == 0 || data[0]&0xf0 != 0x80 || data[0]&0x0f >= 0x0f {
+		return data
+	}
+
+	key, _ := encodeMsgPack("seq")
+	value, _ := encodeMsgPack(seq)
+
+	numbered := make([]byte, 0, len(data)+len(key)+len(value))
+	numbered = append(numbered, data[0]+1)
+	numbered = append(numbered, key...)
+	numbered = append(numbered, value...)
+	return append(numbered, data[1:]...)
+}
@@ -0,0 +1,175 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// msgPackConn is a test client speaking the msgpack subprotocol.
+type msgPackConn struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func (c *msgPackConn) send(msg map[string]interface{}) {
+	c.t.Helper()
+	data, err := msgpack.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read decodes the next frame, which must be binary MessagePack.
+func (c *msgPackConn) read() map[string]interface{} {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	frameType, data, err := c.conn.ReadMessage()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if frameType != websocket.BinaryMessage {
+		c.t.Fatalf("got a text frame %q", data)
+	}
+	var msg map[string]interface{}
+	if err := msgpack.Unmarshal(data, &msg); err != nil {
+		c.t.Fatalf("invalid MessagePack frame: %v", err)
+	}
+	return msg
+}
+
+// expect reads frames until one of the given type.
+func (c *msgPackConn) expect(msgType string) map[string]interface{} {
+	c.t.Helper()
+	for {
+		if msg := c.read(); msg["type"] == msgType {
+			return msg
+		}
+	}
+}
+
+func TestMsgPackSubprotocol(t *testing.T) {
+	h := NewHub(allDayGTFS(t), 1, 60, 16)
+	h.RegisterMethod("echo", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var v interface{}
+		err := json.Unmarshal(params, &v)
+		return v, err
+	})
+	go h.Run()
+	defer h.Stop()
+
+	server := httptest.NewServer(http.HandlerFunc(h.HandleWebSocket))
+	defer server.Close()
+	dialer := websocket.Dialer{Subprotocols: []string{EncodingMsgPack, EncodingJSON}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?updates=delta", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != EncodingMsgPack {
+		t.Fatalf("negotiated %q, want msgpack", conn.Subprotocol())
+	}
+	c := &msgPackConn{t: t, conn: conn}
+
+	welcome := c.read()
+	if welcome["type"] != "connection" || welcome["sessionId"] == "" || welcome["gtfs_loaded"] != true {
+		t.Errorf("welcome = %v", welcome)
+	}
+
+	// Client messages are MessagePack too; replies are numbered
+	c.send(map[string]interface{}{"type": "subscribe", "topic": "train:all-day"})
+	subscriptions := c.expect("subscriptions")
+	if _, ok := subscriptions["seq"]; !ok {
+		t.Errorf("subscriptions = %v, want a seq", subscriptions)
+	}
+	following := c.expect("following")
+	trains := following["data"].(map[string]interface{})["trains"].([]interface{})
+	if len(trains) != 1 || trains[0].(map[string]interface{})["trainId"] != "all-day" {
+		t.Errorf("following = %v", following)
+	}
+
+	c.send(map[string]interface{}{"type": "request", "id": "r1", "method": "echo", "params": map[string]interface{}{"n": 3}})
+	response := c.expect("response")
+	if response["id"] != "r1" || !reflect.DeepEqual(response["result"], map[string]interface{}{"n": float64(3)}) {
+		t.Errorf("response = %v", response)
+	}
+
+	c.send(map[string]interface{}{"type": "ping"})
+	c.expect("pong")
+	c.send(map[string]interface{}{"type": "nonsense"})
+	if msg := c.expect("error"); msg["error"].(map[string]interface{})["type"] != "Unknown Type" {
+		t.Errorf("error = %v", msg)
+	}
+
+	// Feeds start with a snapshot, then send deltas with changed fields as
+	// values (the train moves every tick)
+	snapshot := c.expect(feedFollowedTrains + "_snapshot")
+	if trains := snapshot["data"].(map[string]interface{})["trains"].([]interface{}); len(trains) != 1 {
+		t.Errorf("snapshot = %v", snapshot)
+	}
+	delta := c.expect(feedFollowedTrains + "_delta")
+	changed := delta["data"].(map[string]interface{})["changed"].([]interface{})[0].(map[string]interface{})
+	if changed["id"] != "all-day" {
+		t.Errorf("delta = %v", delta)
+	}
+	if _, ok := changed["fields"].(map[string]interface{})["position"].(map[string]interface{}); !ok {
+		t.Errorf("changed fields = %v, want the position as a map", changed["fields"])
+	}
+}
+
+func TestMsgPackFrames(t *testing.T) {
+	previous := 1
+	station := &models.Station{ID: "8507000", Name: "Bern"}
+	train := models.Train{ID: "all-day", Name: "IC 1", Delay: 2, Position: &models.Position{Lat: 47.1, Lng: 7.9},
+		Timetable: []models.TrainStop{{Station: station, ArrivalTime: "10:30:00", Platform: "5"}}}
+
+	frames := []*frame{
+		newFrame(models.WebSocketMessage{Type: "connection", Message: "Connected", SessionID: "s1", GTFSReady: true}),
+		marshalMessage("live_trains_update", []models.Train{train}),
+		marshalMessage("live_trains_snapshot", map[string]interface{}{"seq": 1, "trains": []models.Train{train}}),
+		marshalMessage("live_trains_delta", TrainDelta{Seq: 2, Removed: []string{"old"}, Changed: []TrainChange{
+			{ID: "all-day", Fields: map[string]json.RawMessage{"delay": json.RawMessage("3"), "position": json.RawMessage(`{"lat":47.2,"lng":7.8}`)}},
+		}}),
+		marshalMessage("departures_update", map[string]interface{}{"stationId": "8507000", "departures": []models.Train{train}}),
+		marshalMessage("train_events", []models.TrainEvent{{ID: 7, Type: models.EventDelayChange, TrainID: "all-day", Delay: 3, PreviousDelay: &previous}}),
+		marshalMessage("favorite_train_event", FollowEvent{Type: FollowArriving, FavoriteID: "f1", TrainID: "all-day", Station: station, Minutes: 5}),
+		marshalMessage("following", FollowingState{Trains: []FollowedTrain{{TrainID: "all-day", Source: "favorite", Running: true}}}),
+		marshalMessage("station_board_update", &services.LiveBoard{StationID: "8507000", FetchedAt: time.Unix(1700000000, 0).UTC()}),
+		marshalMessage("simulation_clock", SimulationState{Running: true, Date: "2025-03-14", Time: "10:00:00", Speed: 60}),
+		newFrame(models.WebSocketMessage{Type: "response", ID: "r1", Result: map[string]int{"n": 3}}),
+		newFrame(models.WebSocketMessage{Type: "error", Message: "bad", Error: &models.RequestError{Status: 400, Type: "Bad Request", Message: "bad"}}),
+	}
+
+	// MessagePack frames carry the same keys and values as JSON frames
+	for i, f := range frames {
+		var want map[string]interface{}
+		if err := json.Unmarshal(numberMessage(uint64(i+1), f.bytes(EncodingJSON), EncodingJSON), &want); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msgPackToJSON(numberMessage(uint64(i+1), f.bytes(EncodingMsgPack), EncodingMsgPack))
+		if err != nil {
+			t.Errorf("%s: %v", f.msg.Type, err)
+			continue
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: MessagePack %v, want %v", f.msg.Type, got, want)
+		}
+	}
+}
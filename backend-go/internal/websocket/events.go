@@ -0,0 +1,41 @@
+// Package websocket - Train Events
+// This file contains the delivery of train events detected by the leader
+// to clients subscribed to the events topic.
+package websocket
+
+import (
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+// SetEventLog replaces the hub's event log, so REST handlers can query the
+// same events. Call before Run.
+func (h *Hub) SetEventLog(events *services.EventLog) {
+	h.events = events
+}
+
+// broadcastTrainEvents sends each session the events of a tick it
+// subscribed to, as one "train_events" message. Sessions running a
+// simulation don't get live events.
+func (h *Hub) broadcastTrainEvents(events []models.TrainEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	h.deliver(func(session *Session) *frame {
+		if session.simulation() != nil {
+			return nil
+		}
+
+		var matching []models.TrainEvent
+		for i := range events {
+			if session.subs.MatchesEvent(&events[i]) {
+				matching = append(matching, events[i])
+			}
+		}
+		if len(matching) == 0 {
+			return nil
+		}
+		return marshalMessage("train_events", matching)
+	})
+}
@@ -0,0 +1,249 @@
+// Package websocket - Broker Fan-Out
+// This file contains the live state shared through the broker: the leader
+// computes all running trains once per update interval, detects train
+// events against the previous tick and publishes both, and publishes the
+// running trains for followed trains every second in between; every
+// instance broadcasts what it receives to its own clients.
+package websocket
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/broker"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+// Broker topics published by the hub.
+const (
+	topicLiveTick     = "live.tick"   // all trains and events, every update interval
+	topicFollowTick   = "live.follow" // all trains, every second while followed
+	topicStationBoard = "live.stationboard"
+)
+
+// liveState is one tick: every running train at a point in time, sorted by
+// name and ID so all instances see the same order, and the events since the
+// previous tick.
+type liveState struct {
+	At     time.Time           `json:"at"`
+	Trains []models.Train      `json:"trains"`
+	Events []models.TrainEvent `json:"events,omitempty"`
+
+	index map[string]int
+}
+
+func newLiveState(at time.Time, trains []models.Train) *liveState {
+	state := &liveState{At: at, Trains: trains}
+	state.buildIndex()
+	return state
+}
+
+func (s *liveState) buildIndex() {
+	s.index = make(map[string]int, len(s.Trains))
+	for i := range s.Trains {
+		s.index[s.Trains[i].ID] = i
+	}
+}
+
+// trains returns the trains accepted by match (nil accepts all), at most
+// limit (0 for no limit).
+func (s *liveState) trains(limit int, match func(*models.Train) bool) []models.Train {
+	trains := make([]models.Train, 0)
+	if s == nil {
+		return trains
+	}
+	for i := range s.Trains {
+		if limit > 0 && len(trains) >= limit {
+			break
+		}
+		if match == nil || match(&s.Trains[i]) {
+			trains = append(trains, s.Trains[i])
+		}
+	}
+	return trains
+}
+
+// train returns a running train by ID, or nil.
+func (s *liveState) train(id string) *models.Train {
+	if s == nil {
+		return nil
+	}
+	i, ok := s.index[id]
+	if !ok {
+		return nil
+	}
+	train := s.Trains[i]
+	return &train
+}
+
+// SetBroker replaces the in-memory broker. Call before Run.
+func (h *Hub) SetBroker(b broker.Broker) {
+	h.broker = b
+}
+
+// liveState returns the latest tick, or nil before the first one.
+func (h *Hub) liveState() *liveState {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.live
+}
+
+// publishTick computes all running trains, simulated from the timetable or
+// seen upstream, and their events and publishes them (leader only). Ticks
+// run while anyone uses them: clients, replicas or readers of the event log.
+// Idle ticks are skipped, and the event detector starts over afterwards
+// rather than reporting the whole gap at once.
+func (h *Hub) publishTick() {
+	if !h.broker.Leader() || !h.gtfsService.IsDataLoaded() {
+		return
+	}
+
+	now := time.Now()
+	if !h.tickWanted(now) {
+		h.detector.Reset()
+		return
+	}
+
+	// Local subscribers get the state as is; it is only encoded for replicas
+	trains := h.liveTrains(now)
+	state := &liveState{At: now, Trains: trains, Events: h.detector.Detect(now, trains)}
+	state.buildIndex()
+	if err := h.broker.PublishValue(topicLiveTick, state); err != nil {
+		log.Debug().Err(err).Msg("Failed to publish live tick")
+	}
+}
+
+// publishFollowTick publishes all running trains without events (leader
+// only), so followed trains are updated between live ticks. Replicas may
+// have followers the leader doesn't know of, so follow ticks run whenever
+// one is linked.
+func (h *Hub) publishFollowTick() {
+	if !h.broker.Leader() || !h.gtfsService.IsDataLoaded() {
+		return
+	}
+	if h.broker.Stats().Peers == 0 && !h.hasFollowers() {
+		return
+	}
+
+	now := time.Now()
+	if err := h.broker.PublishValue(topicFollowTick, newLiveState(now, h.liveTrains(now))); err != nil {
+		log.Debug().Err(err).Msg("Failed to publish follow tick")
+	}
+}
+
+// liveTrains returns the trains running at now, sorted by name and ID.
+func (h *Hub) liveTrains(now time.Time) []models.Train {
+	// Trains on upstream stationboards carry real delays, platforms and
+	// cancellations; the timetable fills in the rest
+	trains := h.gtfsService.GetLiveTrainsMatching(1.0, 0, nil)
+	if h.poller != nil {
+		trains = services.MergeRealtime(trains, h.poller.GetRunningTrains(now))
+	}
+	sort.SliceStable(trains, func(i, j int) bool {
+		if trains[i].Name != trains[j].Name {
+			return trains[i].Name < trains[j].Name
+		}
+		return trains[i].ID < trains[j].ID
+	})
+	return trains
+}
+
+// tickWanted reports whether a tick at now has any consumer.
+func (h *Hub) tickWanted(now time.Time) bool {
+	return h.sessionCount() > 0 ||
+		h.broker.Stats().Peers > 0 ||
+		h.events.QueriedSince(now.Add(-eventReaderWindow))
+}
+
+// hasFollowers reports whether a session outside a simulation follows a
+// train.
+func (h *Hub) hasFollowers() bool {
+	favorites := h.favorites()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, session := range h.sessions {
+		if session.simulation() == nil && len(followedTrains(session, favorites)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeState returns the live state of a tick message.
+func decodeState(msg broker.Message) (*liveState, bool) {
+	if state, ok := msg.Value.(*liveState); ok {
+		return state, true
+	}
+	state := &liveState{}
+	if err := json.Unmarshal(msg.Data, state); err != nil {
+		log.Warn().Err(err).Str("topic", msg.Topic).Msg("Invalid live state from broker")
+		return nil, false
+	}
+	state.buildIndex()
+	return state, true
+}
+
+// setLiveState replaces the latest state unless a newer one arrived first.
+func (h *Hub) setLiveState(state *liveState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.live == nil || !state.At.Before(h.live.At) {
+		h.live = state
+	}
+}
+
+// handleBrokerMessage applies a message published by the leader.
+func (h *Hub) handleBrokerMessage(msg broker.Message) {
+	switch msg.Topic {
+	case topicLiveTick:
+		state, ok := decodeState(msg)
+		if !ok {
+			return
+		}
+		h.setLiveState(state)
+
+		// Events are logged and favorites tracked even without clients, so
+		// nobody connecting later gets stale events
+		h.events.Append(state.Events)
+		favorites := h.favorites()
+		followEvents := h.follow.update(favorites, state)
+
+		if h.sessionCount() == 0 {
+			return
+		}
+
+		// Followed trains come with follow ticks, the overview only here
+		h.broadcastTrainEvents(state.Events)
+		h.broadcastFollowEvents(followEvents)
+		h.broadcastFollowing(favorites)
+		h.broadcastLiveData()
+
+	case topicFollowTick:
+		state, ok := decodeState(msg)
+		if !ok {
+			return
+		}
+		h.setLiveState(state)
+
+		if h.sessionCount() > 0 {
+			h.broadcastFollowedTrains(h.favorites())
+		}
+
+	case topicStationBoard:
+		board, ok := msg.Value.(*services.LiveBoard)
+		if !ok {
+			board = &services.LiveBoard{}
+			if err := json.Unmarshal(msg.Data, board); err != nil {
+				log.Warn().Err(err).Msg("Invalid stationboard from broker")
+				return
+			}
+		}
+		if h.sessionCount() > 0 {
+			h.broadcastStationBoard(board)
+		}
+	}
+}
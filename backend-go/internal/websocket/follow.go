@@ -0,0 +1,352 @@
+// Package websocket - Favorite Trains
+// This file contains follow events for favorite trains (departed, arriving,
+// arrived, delay and platform changes, cancellations), derived from the
+// train events of each tick, and the "following" state sent to clients, so
+// the UI can show which trains it follows. Delay and platform changes and
+// cancellations come from trains seen on upstream stationboards, so the
+// next stops of running favorites are polled first.
+package websocket
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+// Follow event types.
+const (
+	FollowDeparted        = "departed"
+	FollowArriving        = "arriving"
+	FollowArrived         = "arrived"
+	FollowDelayChanged    = "delay_changed"
+	FollowPlatformChanged = "platform_changed"
+	FollowCancelled       = "cancelled"
+)
+
+// FollowEvent is the payload of "favorite_train_event" messages.
+type FollowEvent struct {
+	Owner            string          `json:"-"` // sent only to sessions of the favorite's owner
+	Type             string          `json:"type"`
+	FavoriteID       string          `json:"favoriteId"`
+	TrainID          string          `json:"trainId"`
+	Name             string          `json:"name"`
+	Nickname         string          `json:"nickname,omitempty"`
+	Station          *models.Station `json:"station,omitempty"`
+	Minutes          int             `json:"minutes,omitempty"` // arriving: minutes to the destination
+	Delay            int             `json:"delay"`
+	PreviousDelay    *int            `json:"previousDelay,omitempty"`
+	Platform         string          `json:"platform,omitempty"`
+	PreviousPlatform string          `json:"previousPlatform,omitempty"`
+	Timestamp        string          `json:"timestamp"`
+}
+
+// FollowedTrain is a train a client currently follows.
+type FollowedTrain struct {
+	TrainID    string `json:"trainId"`
+	Name       string `json:"name,omitempty"` // while running
+	Source     string `json:"source"`         // "subscription" (train:{id}) or "favorite" (autoFollow)
+	FavoriteID string `json:"favoriteId,omitempty"`
+	Nickname   string `json:"nickname,omitempty"`
+	Running    bool   `json:"running"`
+}
+
+// FollowingState is the payload of "following" messages.
+type FollowingState struct {
+	Trains []FollowedTrain `json:"trains"`
+}
+
+// followTracker turns train events into follow events of favorite trains
+// and announces favorites approaching their destination. It is only used
+// from the hub's Run loop.
+type followTracker struct {
+	arrivingWithin time.Duration
+	announced      map[string]bool // trains announced as arriving in their current run
+}
+
+func newFollowTracker(arrivingWithin time.Duration) *followTracker {
+	return &followTracker{
+		arrivingWithin: arrivingWithin,
+		announced:      make(map[string]bool),
+	}
+}
+
+// followEventTypes maps train events to follow events. Departures and
+// arrivals only count at the origin and destination.
+var followEventTypes = map[string]string{
+	models.EventDelayChange:    FollowDelayChanged,
+	models.EventPlatformChange: FollowPlatformChanged,
+	models.EventCancellation:   FollowCancelled,
+}
+
+// update returns the follow events of favorite trains in a tick, one per
+// favorite: a train favorited by several owners gets an event for each.
+func (t *followTracker) update(favorites []models.FavoriteTrain, state *liveState) []FollowEvent {
+	byTrain := make(map[string][]models.FavoriteTrain, len(favorites))
+	for _, favorite := range favorites {
+		byTrain[favorite.TrainID] = append(byTrain[favorite.TrainID], favorite)
+	}
+
+	var events []FollowEvent
+	for _, event := range state.Events {
+		favorites, ok := byTrain[event.TrainID]
+		if !ok {
+			continue
+		}
+
+		followType := followEventTypes[event.Type]
+		switch {
+		case event.Type == models.EventDeparture && event.Origin:
+			followType = FollowDeparted
+		case event.Type == models.EventArrival && event.Destination:
+			followType = FollowArrived
+		}
+		if followType == "" {
+			continue
+		}
+
+		for _, favorite := range favorites {
+			events = append(events, FollowEvent{
+				Owner:            favorite.Owner,
+				Type:             followType,
+				FavoriteID:       favorite.ID,
+				TrainID:          event.TrainID,
+				Name:             event.Name,
+				Nickname:         favorite.Nickname,
+				Station:          event.Station,
+				Delay:            event.Delay,
+				PreviousDelay:    event.PreviousDelay,
+				Platform:         event.Platform,
+				PreviousPlatform: event.PreviousPlatform,
+				Timestamp:        event.Timestamp,
+			})
+		}
+	}
+
+	// Approaching the destination is a state rather than a change, so it is
+	// announced once per run from the tick itself
+	for id, favorites := range byTrain {
+		train := state.train(id)
+		if train == nil {
+			delete(t.announced, id)
+			continue
+		}
+		if t.announced[id] || train.Cancelled || !t.arriving(train, state.At) {
+			continue
+		}
+		t.announced[id] = true
+		for _, favorite := range favorites {
+			events = append(events, FollowEvent{
+				Owner:      favorite.Owner,
+				Type:       FollowArriving,
+				FavoriteID: favorite.ID,
+				TrainID:    id,
+				Name:       train.Name,
+				Nickname:   favorite.Nickname,
+				Station:    destinationStation(train),
+				Minutes:    minutesUntilArrival(train, state.At),
+				Delay:      train.Delay,
+				Timestamp:  state.At.Format(time.RFC3339),
+			})
+		}
+	}
+
+	// Forget trains that are no longer favorites
+	for id := range t.announced {
+		if _, ok := byTrain[id]; !ok {
+			delete(t.announced, id)
+		}
+	}
+	return events
+}
+
+// arriving reports whether a train reaches its destination within the
+// announcement window.
+func (t *followTracker) arriving(train *models.Train, at time.Time) bool {
+	until, ok := untilArrival(train, at)
+	return ok && until <= t.arrivingWithin
+}
+
+// untilArrival returns how long until a train reaches its destination,
+// including its delay.
+func untilArrival(train *models.Train, at time.Time) (time.Duration, bool) {
+	until, ok := services.UntilGTFSTime(train.ArrivalTime, at)
+	if !ok {
+		return 0, false
+	}
+	return until + time.Duration(train.Delay)*time.Minute, true
+}
+
+func minutesUntilArrival(train *models.Train, at time.Time) int {
+	until, _ := untilArrival(train, at)
+	return int(math.Max(0, math.Ceil(until.Minutes())))
+}
+
+func destinationStation(train *models.Train) *models.Station {
+	if len(train.Timetable) == 0 {
+		return nil
+	}
+	return train.Timetable[len(train.Timetable)-1].Station
+}
+
+// SetFavoriteTrains sets where favorite trains come from and how many
+// minutes before arrival the "arriving" event is sent. Call before Run.
+func (h *Hub) SetFavoriteTrains(list func() []models.FavoriteTrain, arrivingMinutes int) {
+	h.favoriteTrains = list
+	h.follow = newFollowTracker(time.Duration(arrivingMinutes) * time.Minute)
+}
+
+// favorites returns everyone's current favorite trains.
+func (h *Hub) favorites() []models.FavoriteTrain {
+	if h.favoriteTrains == nil {
+		return nil
+	}
+	return h.favoriteTrains()
+}
+
+// FavoriteTrainStations returns the next stop of every running favorite
+// train. The stationboard poller polls them first: delays, platform
+// changes and cancellations of favorites are only known from upstream.
+func (h *Hub) FavoriteTrainStations() []string {
+	state := h.liveState()
+	var ids []string
+	for _, favorite := range h.favorites() {
+		train := state.train(favorite.TrainID)
+		if train == nil {
+			continue
+		}
+		for _, stop := range train.Timetable {
+			if !stop.IsPassed && stop.Station != nil {
+				if !containsString(ids, stop.Station.ID) {
+					ids = append(ids, stop.Station.ID)
+				}
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// followedTrains returns the IDs of the trains a session follows: its
+// train:{id} subscriptions and, with the favorites topic, its owner's
+// favorite trains with autoFollow.
+func followedTrains(session *Session, favorites []models.FavoriteTrain) []string {
+	ids := session.subs.FollowedTrains()
+	if !session.subs.Favorites() {
+		return ids
+	}
+	owner := session.owner()
+	for _, favorite := range favorites {
+		if owns(owner, favorite) && favorite.AutoFollow && !containsString(ids, favorite.TrainID) {
+			ids = append(ids, favorite.TrainID)
+		}
+	}
+	return ids
+}
+
+// owns reports whether a favorite belongs to owner. Sessions without an
+// identity own nothing.
+func owns(owner string, favorite models.FavoriteTrain) bool {
+	return owner != "" && favorite.Owner == owner
+}
+
+// followingState describes what a session follows on its timeline.
+func (h *Hub) followingState(session *Session, favorites []models.FavoriteTrain) FollowingState {
+	tl := h.timelineFor(session)
+	state := FollowingState{Trains: make([]FollowedTrain, 0)}
+
+	add := func(followed FollowedTrain) {
+		if train := tl.train(followed.TrainID); train != nil {
+			followed.Name = train.Name
+			followed.Running = true
+		}
+		state.Trains = append(state.Trains, followed)
+	}
+
+	ids := session.subs.FollowedTrains()
+	for _, id := range ids {
+		add(FollowedTrain{TrainID: id, Source: "subscription"})
+	}
+	if session.subs.Favorites() {
+		owner := session.owner()
+		for _, favorite := range favorites {
+			if owns(owner, favorite) && favorite.AutoFollow && !containsString(ids, favorite.TrainID) {
+				ids = append(ids, favorite.TrainID)
+				add(FollowedTrain{
+					TrainID:    favorite.TrainID,
+					Source:     "favorite",
+					FavoriteID: favorite.ID,
+					Nickname:   favorite.Nickname,
+				})
+			}
+		}
+	}
+	return state
+}
+
+// followingUpdate returns the session's following state if it changed
+// since it was last sent.
+func (h *Hub) followingUpdate(session *Session, favorites []models.FavoriteTrain) (FollowingState, bool) {
+	state := h.followingState(session, favorites)
+
+	var key strings.Builder
+	for _, train := range state.Trains {
+		key.WriteString(train.TrainID + "|" + train.Name + "|" + train.FavoriteID + "|" + train.Nickname)
+		if train.Running {
+			key.WriteString("|running")
+		}
+		key.WriteString(";")
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.following == key.String() {
+		return state, false
+	}
+	session.following = key.String()
+	return state, true
+}
+
+// broadcastFollowing sends the following state to sessions whose followed
+// trains changed: subscriptions, favorites, or trains starting and stopping.
+func (h *Hub) broadcastFollowing(favorites []models.FavoriteTrain) {
+	h.mu.RLock()
+	sessions := make([]*Session, 0, len(h.sessions))
+	for _, session := range h.sessions {
+		sessions = append(sessions, session)
+	}
+	h.mu.RUnlock()
+
+	// Computed outside the hub lock; timelines take it themselves
+	updates := make(map[*Session]*frame)
+	for _, session := range sessions {
+		if state, changed := h.followingUpdate(session, favorites); changed {
+			updates[session] = marshalMessage("following", state).coalesce("following")
+		}
+	}
+	if len(updates) == 0 {
+		return
+	}
+
+	h.deliver(func(session *Session) *frame {
+		return updates[session]
+	})
+}
+
+// broadcastFollowEvents sends favorite train events to the owner's sessions
+// subscribed to favorites. Sessions running a simulation don't get live
+// events.
+func (h *Hub) broadcastFollowEvents(events []FollowEvent) {
+	for _, event := range events {
+		event := event
+		data := marshalMessage("favorite_train_event", event)
+		h.deliver(func(session *Session) *frame {
+			if !session.subs.Favorites() || session.simulation() != nil || session.owner() != event.Owner {
+				return nil
+			}
+			return data
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+var (
+	zurich = &models.Station{ID: "8503000", Name: "Zürich HB"}
+	olten  = &models.Station{ID: "8500218", Name: "Olten"}
+	bern   = &models.Station{ID: "8507000", Name: "Bern"}
+)
+
+// followTime returns 14 March 2025 at the given Swiss time.
+func followTime(hour, min int) time.Time {
+	zone := time.FixedZone("CET", 3600)
+	return time.Date(2025, 3, 14, hour, min, 0, 0, zone)
+}
+
+// followTrain is a train from Zürich HB via Olten to Bern, arriving at
+// 10:30 with delay minutes.
+func followTrain(id string, delay int) models.Train {
+	return models.Train{
+		ID:          id,
+		Name:        "IC " + id,
+		ArrivalTime: "10:30:00",
+		Delay:       delay,
+		Timetable: []models.TrainStop{
+			{Station: zurich, DepartureTime: "09:50:00", IsPassed: true},
+			{Station: olten, ArrivalTime: "10:00:00", DepartureTime: "10:02:00"},
+			{Station: bern, ArrivalTime: "10:30:00"},
+		},
+	}
+}
+
+// followTypes lists the types and owners of follow events.
+func followTypes(events []FollowEvent) []string {
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type + ":" + event.Owner
+	}
+	return types
+}
+
+func TestFollowTrackerEvents(t *testing.T) {
+	tracker := newFollowTracker(5 * time.Minute)
+	favorites := []models.FavoriteTrain{
+		{ID: "f1", Owner: "alice", TrainID: "1", Nickname: "home"},
+		{ID: "f2", Owner: "bob", TrainID: "1"},
+		{ID: "f3", Owner: "alice", TrainID: "2"},
+	}
+	previousDelay := 1
+
+	for _, tc := range []struct {
+		name  string
+		event models.TrainEvent
+		want  []string
+	}{
+		{"departure from the origin", models.TrainEvent{Type: models.EventDeparture, TrainID: "1", Station: zurich, Origin: true},
+			[]string{"departed:alice", "departed:bob"}},
+		{"departure on the way", models.TrainEvent{Type: models.EventDeparture, TrainID: "1", Station: olten}, nil},
+		{"arrival on the way", models.TrainEvent{Type: models.EventArrival, TrainID: "2", Station: olten}, nil},
+		{"arrival at the destination", models.TrainEvent{Type: models.EventArrival, TrainID: "2", Station: bern, Destination: true},
+			[]string{"arrived:alice"}},
+		{"delay change", models.TrainEvent{Type: models.EventDelayChange, TrainID: "2", Delay: 4, PreviousDelay: &previousDelay},
+			[]string{"delay_changed:alice"}},
+		{"platform change", models.TrainEvent{Type: models.EventPlatformChange, TrainID: "1", Station: olten, Platform: "9", PreviousPlatform: "7"},
+			[]string{"platform_changed:alice", "platform_changed:bob"}},
+		{"cancellation", models.TrainEvent{Type: models.EventCancellation, TrainID: "2"},
+			[]string{"cancelled:alice"}},
+		{"pass-through", models.TrainEvent{Type: models.EventPassThrough, TrainID: "1", Station: olten}, nil},
+		{"not a favorite", models.TrainEvent{Type: models.EventCancellation, TrainID: "3"}, nil},
+	} {
+		// Far from the destination, so nothing is arriving
+		state := newLiveState(followTime(9, 55), []models.Train{followTrain("1", 0), followTrain("2", 0)})
+		state.Events = []models.TrainEvent{tc.event}
+
+		events := tracker.update(favorites, state)
+		if got := followTypes(events); !reflect.DeepEqual(got, tc.want) && !(len(got) == 0 && len(tc.want) == 0) {
+			t.Errorf("%s: events = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Events carry the favorite and the change
+	state := newLiveState(followTime(9, 55), nil)
+	state.Events = []models.TrainEvent{{Type: models.EventDelayChange, TrainID: "1", Name: "IC 1", Delay: 4, PreviousDelay: &previousDelay}}
+	event := tracker.update(favorites[:1], state)[0]
+	if event.FavoriteID != "f1" || event.Nickname != "home" || event.Name != "IC 1" || event.Delay != 4 ||
+		event.PreviousDelay == nil || *event.PreviousDelay != 1 {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestFollowTrackerArriving(t *testing.T) {
+	tracker := newFollowTracker(5 * time.Minute)
+	favorites := []models.FavoriteTrain{
+		{ID: "f1", Owner: "alice", TrainID: "1"},
+		{ID: "f2", Owner: "bob", TrainID: "1"},
+		{ID: "f3", Owner: "alice", TrainID: "2"},
+	}
+	cancelled := followTrain("2", 0)
+	cancelled.Cancelled = true
+
+	// Two minutes late, the train is 5 minutes away at 10:27
+	for _, step := range []struct {
+		at   time.Time
+		want []string
+	}{
+		{followTime(10, 20), nil},
+		{followTime(10, 26), nil},
+		{followTime(10, 27), []string{"arriving:alice", "arriving:bob"}},
+		{followTime(10, 28), nil},
+		{followTime(10, 31), nil},
+	} {
+		state := newLiveState(step.at, []models.Train{followTrain("1", 2), cancelled})
+		events := tracker.update(favorites, state)
+		if got := followTypes(events); !reflect.DeepEqual(got, step.want) && !(len(got) == 0 && len(step.want) == 0) {
+			t.Errorf("at %s: events = %v, want %v", step.at.Format("15:04"), got, step.want)
+			continue
+		}
+		for _, event := range events {
+			if event.Minutes != 5 || event.Station != bern || event.Delay != 2 {
+				t.Errorf("arriving = %+v, want Bern in 5 minutes", event)
+			}
+		}
+	}
+}
+
+func TestFollowTrackerCleanup(t *testing.T) {
+	tracker := newFollowTracker(5 * time.Minute)
+	favorites := []models.FavoriteTrain{{ID: "f1", Owner: "alice", TrainID: "1"}}
+	arriving := func() *liveState {
+		return newLiveState(followTime(10, 27), []models.Train{followTrain("1", 0)})
+	}
+
+	if got := followTypes(tracker.update(favorites, arriving())); len(got) != 1 {
+		t.Fatalf("events = %v, want the arriving announcement", got)
+	}
+
+	// Un-followed, then followed again: a fresh announcement
+	tracker.update(nil, arriving())
+	if tracker.announced["1"] {
+		t.Error("announcement kept after the train was un-followed")
+	}
+	if got := followTypes(tracker.update(favorites, arriving())); len(got) != 1 {
+		t.Errorf("events = %v, want the announcement again", got)
+	}
+
+	// The run ended: the next run is announced again
+	tracker.update(favorites, newLiveState(followTime(10, 40), nil))
+	if len(tracker.announced) != 0 {
+		t.Errorf("announced = %v after the run ended", tracker.announced)
+	}
+}
+
+func TestFavoriteTrainStations(t *testing.T) {
+	h := NewHub(nil, 5, 0, 16)
+	h.SetFavoriteTrains(func() []models.FavoriteTrain {
+		return []models.FavoriteTrain{
+			{ID: "f1", Owner: "alice", TrainID: "1"},
+			{ID: "f2", Owner: "bob", TrainID: "1"},
+			{ID: "f3", Owner: "bob", TrainID: "2"},
+			{ID: "f4", Owner: "bob", TrainID: "3"}, // not running
+		}
+	}, 5)
+	second := followTrain("2", 0)
+	second.Timetable[1].IsPassed = true
+	h.live = newLiveState(followTime(10, 5), []models.Train{followTrain("1", 0), second, followTrain("4", 0)})
+
+	if got := h.FavoriteTrainStations(); !reflect.DeepEqual(got, []string{olten.ID, bern.ID}) {
+		t.Errorf("stations = %v, want the next stops of the favorites", got)
+	}
+}
@@ -2,13 +2,14 @@
 package websocket
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/broker"
 	"github.com/swiss-railway/backend-go/internal/models"
 	"github.com/swiss-railway/backend-go/internal/services"
 )
@@ -16,19 +17,16 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// permessage-deflate is negotiated; Hub.SetCompression decides whether
+	// outgoing frames are actually compressed
+	EnableCompression: true,
+	Subprotocols:      subprotocols,
 	CheckOrigin: func(r *http.Request) bool {
 		// In production, validate the origin
 		return true
 	},
 }
 
-// Client represents a WebSocket client connection.
-type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
-}
-
 // Hub manages all WebSocket clients and broadcasts.
 type Hub struct {
 	mu sync.RWMutex
@@ -36,8 +34,13 @@ type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
+	// Sessions by ID, including detached ones within the resume window
+	sessions     map[string]*Session
+	resumeWindow time.Duration
+	replaySize   int
+
 	// Channel for broadcasting to all clients
-	broadcast chan []byte
+	broadcast chan *frame
 
 	// Register channel
 	register chan *Client
@@ -51,77 +54,135 @@ type Hub struct {
 	// Update interval
 	updateInterval time.Duration
 
+	// Compress outgoing frames when the client negotiated permessage-deflate
+	compression bool
+
+	// Fan-out between instances; the leader publishes live ticks and
+	// stationboards, every instance broadcasts what it receives
+	broker broker.Broker
+	live   *liveState // latest tick (guarded by mu)
+
+	// Upstream stationboards; running trains seen there replace the
+	// simulated ones in the live state
+	poller *services.StationBoardPoller
+
+	// Train events: detected by the leader, logged by every instance
+	detector *services.EventDetector
+	events   *services.EventLog
+
+	// Favorite trains and their follow events (tracker used by Run only)
+	favoriteTrains func() []models.FavoriteTrain
+	follow         *followTracker
+
+	// RPC methods callable by clients (guarded by mu)
+	methods map[string]RPCMethod
+
+	// Caps on per-session simulations (guarded by mu)
+	maxSimulations int
+	maxSimSpeed    float64
+
+	// Backpressure counters of disconnected clients (guarded by mu)
+	coalescedTotal uint64
+	droppedTotal   uint64
+	overflowKicks  uint64
+
 	// Shutdown channel
 	done chan struct{}
 }
 
-// NewHub creates a new WebSocket hub.
-func NewHub(gtfsService *services.GTFSService, updateIntervalSec int) *Hub {
+// followInterval is how often followed trains (train:{id}) and simulation
+// clocks are updated.
+const followInterval = time.Second
+
+// eventReaderWindow is how long after the event log was last queried ticks
+// keep running without clients or replicas, so pollers of /api/events and
+// alert rules keep getting events.
+const eventReaderWindow = 5 * time.Minute
+
+// NewHub creates a new WebSocket hub. Disconnected sessions can be resumed
+// for resumeWindowSec seconds and replay up to replaySize missed messages.
+func NewHub(gtfsService *services.GTFSService, updateIntervalSec, resumeWindowSec, replaySize int) *Hub {
 	return &Hub{
 		clients:        make(map[*Client]bool),
-		broadcast:      make(chan []byte, 256),
+		sessions:       make(map[string]*Session),
+		resumeWindow:   time.Duration(resumeWindowSec) * time.Second,
+		replaySize:     replaySize,
+		broadcast:      make(chan *frame, 256),
 		register:       make(chan *Client),
 		unregister:     make(chan *Client),
 		gtfsService:    gtfsService,
 		updateInterval: time.Duration(updateIntervalSec) * time.Second,
+		compression:    true,
+		broker:         broker.NewMemory(),
+		detector:       services.NewEventDetector(),
+		events:         services.NewEventLog(1000),
+		follow:         newFollowTracker(5 * time.Minute),
+		methods:        make(map[string]RPCMethod),
+		maxSimulations: 20,
+		maxSimSpeed:    120,
 		done:           make(chan struct{}),
 	}
 }
 
+// SetCompression enables or disables permessage-deflate for new connections.
+func (h *Hub) SetCompression(enabled bool) {
+	h.compression = enabled
+}
+
 // Run starts the hub's main loop.
 func (h *Hub) Run() {
 	ticker := time.NewTicker(h.updateInterval)
 	defer ticker.Stop()
 
+	followTicker := time.NewTicker(followInterval)
+	defer followTicker.Stop()
+
+	updates := h.broker.Subscribe(topicLiveTick, topicFollowTick, topicStationBoard)
+	defer updates.Unsubscribe()
+
 	for {
 		select {
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
+			session := client.sess()
+			h.sessions[session.ID] = session
+			clients := len(h.clients)
 			h.mu.Unlock()
-			log.Info().Int("clients", len(h.clients)).Msg("WebSocket client connected")
+			log.Info().Int("clients", clients).Msg("WebSocket client connected")
 
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
-			h.mu.Unlock()
-			log.Info().Int("clients", len(h.clients)).Msg("WebSocket client disconnected")
+			h.removeClients([]*Client{client})
+			log.Info().Int("clients", h.ClientCount()).Msg("WebSocket client disconnected")
 
 		case message := <-h.broadcast:
-			// Collect failed clients under read lock, delete under write lock
-			h.mu.RLock()
-			var failedClients []*Client
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-					// Message sent successfully
-				default:
-					// Client's send buffer is full, mark for removal
-					failedClients = append(failedClients, client)
-				}
-			}
-			h.mu.RUnlock()
-
-			// Remove failed clients under write lock
-			if len(failedClients) > 0 {
-				h.mu.Lock()
-				for _, client := range failedClients {
-					if _, ok := h.clients[client]; ok {
-						close(client.send)
-						delete(h.clients, client)
-					}
-				}
-				h.mu.Unlock()
-				log.Debug().Int("removed", len(failedClients)).Msg("Removed unresponsive clients")
+			h.deliver(func(*Session) *frame { return message })
+
+		case msg, ok := <-updates.C:
+			if !ok {
+				// Broker closed; keep serving local simulations
+				updates.C = nil
+				continue
 			}
+			h.handleBrokerMessage(msg)
 
 		case <-ticker.C:
-			// Broadcast live train data periodically
-			if h.gtfsService.IsDataLoaded() && len(h.clients) > 0 {
-				h.broadcastLiveData()
+			h.expireSessions()
+			h.publishTick()
+
+			// Live trains arrive as ticks; departures and simulations are
+			// computed by every instance
+			if h.gtfsService.IsDataLoaded() && h.sessionCount() > 0 {
+				h.broadcastDepartures()
+				h.broadcastSimulations(true)
+			}
+
+		case <-followTicker.C:
+			// Followed trains get finer-grained updates than the overview
+			h.publishFollowTick()
+
+			if h.gtfsService.IsDataLoaded() && h.sessionCount() > 0 {
+				h.broadcastSimulations(false)
 			}
 
 		case <-h.done:
@@ -135,176 +196,475 @@ func (h *Hub) Stop() {
 	close(h.done)
 }
 
-// broadcastLiveData sends live train data to all connected clients.
-func (h *Hub) broadcastLiveData() {
-	trains := h.gtfsService.GetLiveTrains()
+// deliver sends a per-session message to every session, attached or
+// detached; build returns nil to skip a session. Stale updates are coalesced
+// in each client's queue; clients whose queue is full of control messages
+// are removed (their session stays resumable).
+func (h *Hub) deliver(build func(*Session) *frame) {
+	// Collect failed clients under read lock, remove them under write lock
+	h.mu.RLock()
+	var failedClients []*Client
+	for _, session := range h.sessions {
+		message := build(session)
+		if message == nil {
+			continue
+		}
+		if client, ok := session.enqueue(message); !ok {
+			// Client's queue is full, mark for removal
+			failedClients = append(failedClients, client)
+		}
+	}
+	h.mu.RUnlock()
 
-	msg := models.WebSocketMessage{
-		Type:      "live_trains_update",
-		Data:      trains,
-		Timestamp: time.Now().Format(time.RFC3339),
+	if len(failedClients) > 0 {
+		h.removeClients(failedClients)
+
+		h.mu.Lock()
+		h.overflowKicks += uint64(len(failedClients))
+		h.mu.Unlock()
+		log.Warn().Int("removed", len(failedClients)).Msg("Removed unresponsive clients")
 	}
+}
 
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to marshal live data")
-		return
+// removeClients unregisters clients and detaches them from their sessions.
+// Without a resume window the sessions are dropped right away.
+func (h *Hub) removeClients(clients []*Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, client := range clients {
+		if _, ok := h.clients[client]; !ok {
+			continue
+		}
+		delete(h.clients, client)
+
+		_, coalesced, dropped := client.queue.stats()
+		h.coalescedTotal += coalesced
+		h.droppedTotal += dropped
+
+		// Detach first so the session never pushes to the closed queue
+		session := client.sess()
+		session.detach(client)
+		client.queue.close()
+
+		if h.resumeWindow <= 0 {
+			session.mu.Lock()
+			detached := session.client == nil
+			session.mu.Unlock()
+			if detached {
+				delete(h.sessions, session.ID)
+			}
+		}
 	}
+}
+
+// expireSessions drops sessions detached for longer than the resume window.
+func (h *Hub) expireSessions() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	h.broadcast <- data
+	now := time.Now()
+	for id, session := range h.sessions {
+		if session.expired(h.resumeWindow, now) {
+			delete(h.sessions, id)
+		}
+	}
 }
 
-// ClientCount returns the number of connected clients.
-func (h *Hub) ClientCount() int {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	return len(h.clients)
+// ResumeResult is the payload of the "resumed" message.
+type ResumeResult struct {
+	SessionID string `json:"sessionId"`
+	LastSeq   uint64 `json:"lastSeq"`
+	Replayed  int    `json:"replayed"`
+	// Complete is false if messages were lost; delta feeds restart with snapshots
+	Complete bool `json:"complete"`
 }
 
-// HandleWebSocket handles WebSocket upgrade requests.
-func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Error().Err(err).Msg("WebSocket upgrade failed")
-		return
+// resume moves a freshly connected client onto an earlier session and
+// replays the messages after lastSeq.
+func (h *Hub) resume(client *Client, sessionID string, lastSeq uint64) (*ResumeResult, error) {
+	h.mu.Lock()
+
+	session, ok := h.sessions[sessionID]
+	if !ok || session.expired(h.resumeWindow, time.Now()) {
+		h.mu.Unlock()
+		return nil, fmt.Errorf("session %s is unknown or expired", sessionID)
 	}
 
-	client := &Client{
-		hub:  h,
-		conn: conn,
-		send: make(chan []byte, 256),
+	current := client.sess()
+	if current == session {
+		h.mu.Unlock()
+		return nil, fmt.Errorf("session %s is already active on this connection", sessionID)
 	}
 
-	h.register <- client
+	// A session only resumes for whoever opened it
+	if current.owner() != session.owner() {
+		h.mu.Unlock()
+		return nil, fmt.Errorf("session %s belongs to another user", sessionID)
+	}
 
-	// Send welcome message
-	welcomeMsg := models.WebSocketMessage{
-		Type:      "connection",
-		Message:   "Connected to Swiss Railway Network WebSocket",
-		Timestamp: time.Now().Format(time.RFC3339),
-		GTFSReady: h.gtfsService.IsDataLoaded(),
+	// Replayed frames are already encoded
+	if current.encoding != session.encoding {
+		h.mu.Unlock()
+		return nil, fmt.Errorf("session %s uses encoding %s, connection uses %s", sessionID, session.encoding, current.encoding)
 	}
 
-	welcomeData, _ := json.Marshal(welcomeMsg)
-	client.send <- welcomeData
+	// The fresh session created on connect is no longer needed
+	current.detach(client)
+	delete(h.sessions, current.ID)
 
-	// Start goroutines for reading and writing
-	go client.writePump()
-	go client.readPump()
+	client.setSession(session)
+	previous, replayed, complete := session.attach(client, lastSeq)
+	h.mu.Unlock()
+
+	// The old connection may not have noticed the network switch yet;
+	// closing its queue makes its writer hang up
+	if previous != nil && previous != client {
+		previous.queue.close()
+	}
+	if !complete {
+		session.resetDeltas()
+	}
+
+	log.Info().
+		Str("session", sessionID).
+		Int("replayed", replayed).
+		Bool("complete", complete).
+		Msg("WebSocket session resumed")
+
+	return &ResumeResult{
+		SessionID: sessionID,
+		LastSeq:   lastSeq,
+		Replayed:  replayed,
+		Complete:  complete,
+	}, nil
 }
 
-// readPump reads messages from the WebSocket connection.
-func (c *Client) readPump() {
-	defer func() {
-		c.hub.unregister <- c
-		c.conn.Close()
-	}()
-
-	c.conn.SetReadLimit(512 * 1024) // 512KB max message size
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		return nil
-	})
+// broadcastLiveData sends the overview to clients without subscriptions and
+// the matching trains to clients with station, route or bbox subscriptions.
+// Clients that only follow trains are served by broadcastFollowedTrains,
+// clients running a simulation by broadcastSimulations.
+func (h *Hub) broadcastLiveData() {
+	h.mu.RLock()
+	var overview, filtered []*Session
+	for _, session := range h.sessions {
+		switch {
+		case session.simulation() != nil:
+		case session.subs.IsOverview():
+			overview = append(overview, session)
+		case session.subs.HasAreaFilter():
+			filtered = append(filtered, session)
+		}
+	}
+	state := h.live
+	h.mu.RUnlock()
+
+	var overviewTrains []models.Train
+	var overviewData *frame
+	if len(overview) > 0 {
+		overviewTrains = state.trains(services.MaxLiveTrains, nil)
+		overviewData = marshalMessage("live_trains_update", overviewTrains).coalesce(feedLiveTrains)
+	}
 
-	for {
-		_, message, err := c.conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Warn().Err(err).Msg("WebSocket read error")
+	// Filtered clients each get their own subset of the tick
+	var candidates []models.Train
+	if len(filtered) > 0 {
+		candidates = state.trains(0, func(train *models.Train) bool {
+			for _, session := range filtered {
+				if session.subs.MatchesArea(train) {
+					return true
+				}
 			}
-			break
+			return false
+		})
+	}
+
+	h.deliver(func(session *Session) *frame {
+		if session.simulation() != nil {
+			return nil
+		}
+		if session.subs.IsOverview() {
+			if session.deltas != nil {
+				return session.encodeTrains(feedLiveTrains, overviewTrains)
+			}
+			return overviewData
 		}
+		if !session.subs.HasAreaFilter() {
+			return nil
+		}
+		return session.encodeTrains(feedLiveTrains, filterTrains(candidates, session.subs))
+	})
+}
 
-		// Handle incoming messages
-		c.handleMessage(message)
+// broadcastFollowedTrains sends followed trains, including auto-followed
+// favorites, to the clients following them.
+func (h *Hub) broadcastFollowedTrains(favorites []models.FavoriteTrain) {
+	h.mu.RLock()
+	followed := make(map[string]*models.Train)
+	for _, session := range h.sessions {
+		if session.simulation() != nil {
+			continue
+		}
+		for _, id := range followedTrains(session, favorites) {
+			followed[id] = nil
+		}
 	}
-}
+	state := h.live
+	h.mu.RUnlock()
 
-// handleMessage processes incoming WebSocket messages.
-func (c *Client) handleMessage(message []byte) {
-	var msg map[string]interface{}
-	if err := json.Unmarshal(message, &msg); err != nil {
-		log.Warn().Err(err).Msg("Invalid WebSocket message")
+	if len(followed) == 0 {
 		return
 	}
 
-	msgType, _ := msg["type"].(string)
-	log.Debug().Str("type", msgType).Msg("Received WebSocket message")
+	for id := range followed {
+		followed[id] = state.train(id)
+	}
 
-	switch msgType {
-	case "request_live_data":
-		if c.hub.gtfsService.IsDataLoaded() {
-			trains := c.hub.gtfsService.GetLiveTrains()
-			response := models.WebSocketMessage{
-				Type:      "live_trains",
-				Data:      trains,
-				Timestamp: time.Now().Format(time.RFC3339),
+	h.deliver(func(session *Session) *frame {
+		ids := followedTrains(session, favorites)
+		if len(ids) == 0 || session.simulation() != nil {
+			return nil
+		}
+
+		var trains []models.Train
+		for _, id := range ids {
+			if train := followed[id]; train != nil {
+				trains = append(trains, *train)
 			}
-			data, _ := json.Marshal(response)
-			c.send <- data
 		}
+		if len(trains) == 0 && session.deltas == nil {
+			return nil
+		}
+		return session.encodeTrains(feedFollowedTrains, trains)
+	})
+}
 
-	case "ping":
-		response := models.WebSocketMessage{
-			Type:      "pong",
-			Timestamp: time.Now().Format(time.RFC3339),
+// broadcastDepartures sends the departure boards of subscribed stations
+// (departures:{id}). Each board is computed once for all its subscribers.
+func (h *Hub) broadcastDepartures() {
+	h.mu.RLock()
+	stations := make(map[string]bool)
+	for _, session := range h.sessions {
+		for _, id := range session.subs.DepartureBoards() {
+			stations[id] = true
 		}
-		data, _ := json.Marshal(response)
-		c.send <- data
-
-	default:
-		// Echo unknown messages
-		response := models.WebSocketMessage{
-			Type:      "echo",
-			Data:      msg,
-			Timestamp: time.Now().Format(time.RFC3339),
+	}
+	h.mu.RUnlock()
+
+	for id := range stations {
+		departures := h.gtfsService.GetStationDepartures(id)
+		if departures == nil {
+			continue
 		}
-		data, _ := json.Marshal(response)
-		c.send <- data
+		data := marshalMessage("departures_update", departures).coalesce(topicDepartures + ":" + id)
+
+		stationID := id
+		h.deliver(func(session *Session) *frame {
+			if !containsString(session.subs.DepartureBoards(), stationID) {
+				return nil
+			}
+			return data
+		})
 	}
 }
 
-// writePump writes messages to the WebSocket connection.
-func (c *Client) writePump() {
-	ticker := time.NewTicker(54 * time.Second) // Ping interval
-	defer func() {
-		ticker.Stop()
-		c.conn.Close()
-	}()
+// encodeTrains encodes a train feed update for a session: the full list for
+// plain sessions, a snapshot or delta for delta sessions (nil if unchanged).
+func (s *Session) encodeTrains(feed string, trains []models.Train) *frame {
+	if s.deltas == nil {
+		return marshalMessage(feed+"_update", trains).coalesce(feed)
+	}
 
-	for {
-		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				// Channel closed
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
+	// A queued frame of the feed is replaced by this one, so the client
+	// would never get the base of a delta: send a snapshot instead
+	encoder := s.deltas[feed]
+	if s.queued(feed) {
+		encoder.Reset()
+	}
+	msgType, payload, ok := encoder.Encode(trains)
+	if !ok {
+		return nil
+	}
+	return marshalMessage(msgType, payload).coalesce(feed)
+}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
+// filterTrains returns the trains matching a client's area subscriptions.
+func filterTrains(trains []models.Train, subs *Subscriptions) []models.Train {
+	matching := make([]models.Train, 0)
+	for i := range trains {
+		if subs.MatchesArea(&trains[i]) {
+			matching = append(matching, trains[i])
+		}
+	}
+	return matching
+}
 
-			// Write queued messages
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
+// trainsFor returns the current trains a session is interested in, on its
+// simulated clock if it runs a simulation.
+func (h *Hub) trainsFor(session *Session) []models.Train {
+	tl := h.timelineFor(session)
+	if session.subs.IsOverview() {
+		return tl.trains(services.MaxLiveTrains, nil)
+	}
 
-			if err := w.Close(); err != nil {
-				return
+	trains := make([]models.Train, 0)
+	seen := make(map[string]bool)
+	for _, id := range followedTrains(session, h.favorites()) {
+		if train := tl.train(id); train != nil {
+			trains = append(trains, *train)
+			seen[train.ID] = true
+		}
+	}
+	if session.subs.HasAreaFilter() {
+		for _, train := range tl.trains(0, session.subs.MatchesArea) {
+			if !seen[train.ID] {
+				trains = append(trains, train)
 			}
+		}
+	}
+	return trains
+}
 
-		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
+// AttachStationBoards publishes boards refreshed by the poller, so clients
+// on every instance receive them, and merges the trains on them into the
+// live state. Call before Run.
+func (h *Hub) AttachStationBoards(poller *services.StationBoardPoller) {
+	h.poller = poller
+	poller.OnUpdate(func(board *services.LiveBoard) {
+		if err := h.broker.PublishValue(topicStationBoard, board); err != nil {
+			// The next refresh will carry fresher data anyway
+			log.Debug().Err(err).Str("station", board.StationID).Msg("Dropped stationboard update")
+		}
+	})
+}
+
+// broadcastStationBoard sends a refreshed upstream stationboard to overview
+// clients and to clients subscribed to the station.
+func (h *Hub) broadcastStationBoard(board *services.LiveBoard) {
+	data := marshalMessage("station_board_update", board).coalesce("station_board:" + board.StationID)
+
+	h.deliver(func(session *Session) *frame {
+		if !session.subs.WantsStation(board.StationID) {
+			return nil
+		}
+		return data
+	})
+}
+
+// sessionCount returns the number of sessions, attached or detached.
+// Sessions are also removed outside Run (resume), so this takes the lock.
+func (h *Hub) sessionCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.sessions)
+}
+
+// ClientCount returns the number of connected clients.
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// HubMetrics describes connected clients and backpressure.
+type HubMetrics struct {
+	Clients          int `json:"clients"`
+	Sessions         int `json:"sessions"`
+	DetachedSessions int `json:"detachedSessions"`
+
+	// Clients with a backlog of at least slowClientBacklog frames right now
+	SlowClients int `json:"slowClients"`
+	// Clients that had updates coalesced or dropped since they connected
+	LaggingClients int `json:"laggingClients"`
+
+	QueuedFrames        int    `json:"queuedFrames"`
+	CoalescedFrames     uint64 `json:"coalescedFrames"`
+	DroppedFrames       uint64 `json:"droppedFrames"`
+	OverflowDisconnects uint64 `json:"overflowDisconnects"`
+}
+
+// Metrics returns client and backpressure metrics.
+func (h *Hub) Metrics() HubMetrics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	metrics := HubMetrics{
+		Clients:             len(h.clients),
+		Sessions:            len(h.sessions),
+		CoalescedFrames:     h.coalescedTotal,
+		DroppedFrames:       h.droppedTotal,
+		OverflowDisconnects: h.overflowKicks,
+	}
+
+	for client := range h.clients {
+		backlog, coalesced, dropped := client.queue.stats()
+		metrics.QueuedFrames += backlog
+		metrics.CoalescedFrames += coalesced
+		metrics.DroppedFrames += dropped
+		if backlog >= slowClientBacklog {
+			metrics.SlowClients++
+		}
+		if coalesced > 0 || dropped > 0 {
+			metrics.LaggingClients++
+		}
+	}
+
+	for _, session := range h.sessions {
+		session.mu.Lock()
+		if session.client == nil {
+			metrics.DetachedSessions++
 		}
+		session.mu.Unlock()
+	}
+
+	return metrics
+}
+
+// HandleWebSocket handles WebSocket upgrade requests.
+func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("WebSocket upgrade failed")
+		return
+	}
+
+	client := &Client{
+		hub:   h,
+		conn:  conn,
+		queue: newOutboundQueue(sendQueueLimit),
+		calls: make(chan struct{}, maxPendingCalls),
+	}
+
+	// Encoding negotiated by subprotocol; MessagePack goes in binary frames
+	encoding := conn.Subprotocol()
+	if encoding == "" {
+		encoding = EncodingJSON
 	}
+	if encoding == EncodingMsgPack {
+		client.frameType = websocket.BinaryMessage
+	} else {
+		client.frameType = websocket.TextMessage
+	}
+	conn.EnableWriteCompression(h.compression)
+
+	// Opt-in delta encoding: ws://host/ws?updates=delta
+	session := newSession(encoding, r.URL.Query().Get("updates") == "delta", h.replaySize)
+	session.identify(r)
+	session.attach(client, 0)
+	client.session = session
+
+	h.register <- client
+
+	// Send welcome message (carries the session ID needed to resume)
+	client.reply(models.WebSocketMessage{
+		Type:      "connection",
+		Message:   "Connected to Swiss Railway Network WebSocket",
+		SessionID: session.ID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		GTFSReady: h.gtfsService.IsDataLoaded(),
+	})
+
+	// Start goroutines for reading and writing
+	go client.writePump()
+	go client.readPump()
 }
@@ -0,0 +1,226 @@
+package websocket
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/broker"
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+// newTestClient returns a client with its own session, as HandleWebSocket
+// creates them, without a connection.
+func newTestClient(h *Hub) *Client {
+	client := &Client{
+		hub:   h,
+		queue: newOutboundQueue(sendQueueLimit),
+		calls: make(chan struct{}, maxPendingCalls),
+	}
+	session := newSession(EncodingJSON, true, h.replaySize)
+	session.attach(client, 0)
+	client.session = session
+	return client
+}
+
+// allDayGTFS loads a timetable with the IC 1 "all-day" running from Zürich
+// HB to Bern around the clock.
+func allDayGTFS(t *testing.T) *services.GTFSService {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"agency.txt":         "agency_id,agency_name,agency_url,agency_timezone\nSBB,SBB,https://www.sbb.ch,Europe/Zurich\n",
+		"stops.txt":          "stop_id,stop_name,stop_lat,stop_lon\n8503000,Zürich HB,47.378177,8.540192\n8507000,Bern,46.948825,7.439122\n",
+		"routes.txt":         "route_id,agency_id,route_short_name,route_long_name,route_type\n1,SBB,IC 1,IC 1,2\n",
+		"calendar.txt":       "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\ndaily,1,1,1,1,1,1,1,20240101,20301231\n",
+		"calendar_dates.txt": "service_id,date,exception_type\n",
+		"trips.txt":          "route_id,service_id,trip_id,trip_short_name,direction_id\n1,daily,all-day,IC 1,0\n",
+		"stop_times.txt":     "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nall-day,,00:00:00,8503000,1\nall-day,24:00:00,,8507000,2\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := services.NewGTFSService(dir)
+	if err := s.LoadData(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// countFrames drains a client's queue for d and counts the frames by type
+// prefix.
+func countFrames(client *Client, d time.Duration, prefixes ...string) map[string]int {
+	counts := make(map[string]int)
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		frames, _ := client.queue.drain()
+		for _, f := range frames {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(f.event, prefix) {
+					counts[prefix]++
+				}
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return counts
+}
+
+func TestFollowedTrainsEverySecond(t *testing.T) {
+	// The overview is only due after a minute
+	h := NewHub(allDayGTFS(t), 60, 0, 16)
+	go h.Run()
+	defer h.Stop()
+
+	follower := newTestClient(h)
+	if err := follower.sess().subs.Apply(&subscriptionRequest{Topic: "train:all-day"}, true); err != nil {
+		t.Fatal(err)
+	}
+	overview := newTestClient(h)
+	h.register <- follower
+	h.register <- overview
+
+	var followed, live map[string]int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		followed = countFrames(follower, 3500*time.Millisecond, feedFollowedTrains, feedLiveTrains)
+	}()
+	go func() {
+		defer wg.Done()
+		live = countFrames(overview, 3500*time.Millisecond, feedFollowedTrains, feedLiveTrains)
+	}()
+	wg.Wait()
+
+	if followed[feedFollowedTrains] < 3 || followed[feedLiveTrains] != 0 {
+		t.Errorf("follower got %v, want followed trains every second", followed)
+	}
+	if live[feedFollowedTrains] != 0 || live[feedLiveTrains] != 0 {
+		t.Errorf("overview client got %v, want nothing before the update interval", live)
+	}
+}
+
+func TestReplicaGetsBothTicks(t *testing.T) {
+	leaderBroker, err := broker.ListenTCP("127.0.0.1:0", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leaderBroker.Close()
+	replicaBroker, err := broker.DialTCP(leaderBroker.Addr(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replicaBroker.Close()
+
+	// The leader has no clients of its own; its replica does
+	leader := NewHub(allDayGTFS(t), 2, 0, 16)
+	leader.SetBroker(leaderBroker)
+	replica := NewHub(allDayGTFS(t), 2, 0, 16)
+	replica.SetBroker(replicaBroker)
+	go leader.Run()
+	defer leader.Stop()
+	go replica.Run()
+	defer replica.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for leaderBroker.Stats().Peers != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("replica didn't connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	follower := newTestClient(replica)
+	if err := follower.sess().subs.Apply(&subscriptionRequest{Topic: "train:all-day"}, true); err != nil {
+		t.Fatal(err)
+	}
+	overview := newTestClient(replica)
+	replica.register <- follower
+	replica.register <- overview
+
+	var followed, live map[string]int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		followed = countFrames(follower, 3500*time.Millisecond, feedFollowedTrains)
+	}()
+	go func() {
+		defer wg.Done()
+		live = countFrames(overview, 3500*time.Millisecond, feedLiveTrains)
+	}()
+	wg.Wait()
+
+	// Follow ticks every second, live ticks every 2 seconds
+	if followed[feedFollowedTrains] < 3 {
+		t.Errorf("replica's follower got %v, want followed trains every second", followed)
+	}
+	if n := live[feedLiveTrains]; n < 1 || n > 2 {
+		t.Errorf("replica's overview client got %v, want the overview every 2s", live)
+	}
+}
+
+// TestResumeWhileTicking resumes sessions from client goroutines while Run
+// applies live ticks; run with -race.
+func TestResumeWhileTicking(t *testing.T) {
+	h := NewHub(services.NewGTFSService(t.TempDir()), 1, 60, 16)
+	go h.Run()
+	defer h.Stop()
+
+	// Ticks keep coming while clients connect, drop and resume
+	done := make(chan struct{})
+	var ticks sync.WaitGroup
+	ticks.Add(1)
+	go func() {
+		defer ticks.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			train := models.Train{ID: "1009", Name: "IC 1", Delay: i % 3}
+			h.broker.PublishValue(topicLiveTick, newLiveState(time.Now(), []models.Train{train}))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var clients sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			for j := 0; j < 10; j++ {
+				first := newTestClient(h)
+				h.register <- first
+				h.unregister <- first
+
+				second := newTestClient(h)
+				h.register <- second
+				if _, err := h.resume(second, first.sess().ID, 0); err != nil {
+					t.Errorf("resume: %v", err)
+					return
+				}
+				if second.sess() != first.sess() {
+					t.Error("resumed client isn't on the earlier session")
+					return
+				}
+				h.unregister <- second
+			}
+		}()
+	}
+	clients.Wait()
+	close(done)
+	ticks.Wait()
+
+	// Every fresh session was replaced by the one it resumed
+	if sessions := h.sessionCount(); sessions != 4*10 {
+		t.Errorf("sessions = %d, want %d", sessions, 4*10)
+	}
+}
@@ -0,0 +1,149 @@
+// Package websocket - Outbound Queue
+// This file contains the per-client outbound queue that coalesces stale
+// updates instead of disconnecting slow clients.
+package websocket
+
+import "sync"
+
+const (
+	// sendQueueLimit is the number of frames a client may have queued.
+	sendQueueLimit = 256
+
+	// slowClientBacklog is the backlog from which a client counts as slow.
+	slowClientBacklog = 32
+)
+
+// queuedFrame is an encoded, numbered frame waiting to be written.
+type queuedFrame struct {
+	seq   uint64 // session sequence number
+	event string // message type (SSE event name)
+	key   string // coalescing key; empty for control messages
+	data  []byte
+}
+
+// pushResult tells the sender what happened to a pushed frame.
+type pushResult struct {
+	ok bool // false if the queue is full of control messages (or closed)
+
+	// Key of a stale frame that was replaced or dropped, if any
+	staleKey string
+	replaced bool // the stale frame was replaced by the pushed one
+}
+
+// outboundQueue holds frames for one client until writePump sends them.
+//
+// Frames with a coalescing key (position updates, stationboards) replace a
+// queued frame with the same key, so a slow client skips stale updates
+// rather than falling further behind. When the queue is full, the oldest
+// keyed frame is dropped. Control messages (no key) are never dropped; a
+// client whose queue is full of them is disconnected.
+type outboundQueue struct {
+	mu      sync.Mutex
+	entries []queuedFrame
+	limit   int
+	closed  bool
+	ready   chan struct{}
+
+	// Counters since the client connected
+	coalesced uint64
+	dropped   uint64
+}
+
+func newOutboundQueue(limit int) *outboundQueue {
+	return &outboundQueue{
+		limit: limit,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// push queues a frame without blocking.
+func (q *outboundQueue) push(qf queuedFrame) pushResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return pushResult{ok: true}
+	}
+
+	if qf.key != "" {
+		for i := range q.entries {
+			if q.entries[i].key == qf.key {
+				// Move to the back so frames stay in sequence order
+				q.entries = append(append(q.entries[:i], q.entries[i+1:]...), qf)
+				q.coalesced++
+				return pushResult{ok: true, staleKey: qf.key, replaced: true}
+			}
+		}
+	}
+
+	result := pushResult{ok: true}
+	if len(q.entries) >= q.limit {
+		oldest := -1
+		for i := range q.entries {
+			if q.entries[i].key != "" {
+				oldest = i
+				break
+			}
+		}
+		if oldest < 0 {
+			return pushResult{ok: false}
+		}
+		result.staleKey = q.entries[oldest].key
+		q.entries = append(q.entries[:oldest], q.entries[oldest+1:]...)
+		q.dropped++
+	}
+
+	q.entries = append(q.entries, qf)
+	q.signal()
+	return result
+}
+
+// has reports whether a frame with the coalescing key is queued.
+func (q *outboundQueue) has(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i := range q.entries {
+		if q.entries[i].key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// drain takes all queued frames. closed is true once close was called.
+func (q *outboundQueue) drain() (frames []queuedFrame, closed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	frames = q.entries
+	q.entries = nil
+	return frames, q.closed
+}
+
+// close stops accepting frames; writePump sends what's left and closes
+// the connection.
+func (q *outboundQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		q.signal()
+	}
+}
+
+// stats returns the current backlog and counters.
+func (q *outboundQueue) stats() (backlog int, coalesced, dropped uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries), q.coalesced, q.dropped
+}
+
+// signal wakes writePump. Callers must hold q.mu.
+func (q *outboundQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
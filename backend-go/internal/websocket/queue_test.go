@@ -0,0 +1,114 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+func TestQueueCoalescesKeyedFrames(t *testing.T) {
+	q := newOutboundQueue(3)
+	q.push(queuedFrame{seq: 1, key: "live_trains"})
+	q.push(queuedFrame{seq: 2})
+	result := q.push(queuedFrame{seq: 3, key: "live_trains"})
+	if !result.ok || result.staleKey != "live_trains" {
+		t.Errorf("replacing push = %+v, want the stale key", result)
+	}
+
+	// The newer frame moved behind the control message
+	frames, _ := q.drain()
+	if len(frames) != 2 || frames[0].seq != 2 || frames[1].seq != 3 {
+		t.Fatalf("frames = %+v, want seq 2 and 3", frames)
+	}
+
+	// A full queue drops its oldest keyed frame, never a control message
+	q.push(queuedFrame{seq: 4})
+	q.push(queuedFrame{seq: 5, key: "a"})
+	q.push(queuedFrame{seq: 6, key: "b"})
+	if result := q.push(queuedFrame{seq: 7}); !result.ok || result.staleKey != "a" {
+		t.Errorf("push into a full queue = %+v, want a dropped", result)
+	}
+	q.push(queuedFrame{seq: 8})
+	q.push(queuedFrame{seq: 9})
+	if result := q.push(queuedFrame{seq: 10}); result.ok {
+		t.Error("queue full of control messages accepted another")
+	}
+	if _, coalesced, dropped := q.stats(); coalesced != 1 || dropped != 2 {
+		t.Errorf("coalesced %d, dropped %d; want 1 and 2", coalesced, dropped)
+	}
+}
+
+// sentFrame is a queued train feed frame, decoded.
+type sentFrame struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+func drainFrames(t *testing.T, q *outboundQueue) []sentFrame {
+	t.Helper()
+	queued, _ := q.drain()
+	frames := make([]sentFrame, len(queued))
+	for i, qf := range queued {
+		if err := json.Unmarshal(qf.data, &frames[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return frames
+}
+
+// TestDeltaFeedCoalescing checks that a feed frame replacing a queued one
+// of the same feed is a snapshot, so the client never gets a delta whose
+// base it didn't receive.
+func TestDeltaFeedCoalescing(t *testing.T) {
+	session := newSession(EncodingJSON, true, 0)
+	client := &Client{queue: newOutboundQueue(sendQueueLimit)}
+	session.attach(client, 0)
+
+	send := func(trains ...models.Train) {
+		if f := session.encodeTrains(feedLiveTrains, trains); f != nil {
+			session.enqueue(f)
+		}
+	}
+	ic1 := models.Train{ID: "1009", Name: "IC 1", Delay: 0}
+	ic1Late := models.Train{ID: "1009", Name: "IC 1", Delay: 4}
+	ir15 := models.Train{ID: "2015", Name: "IR 15"}
+
+	// snapshot -> delta while the snapshot is still queued: one snapshot
+	// of the latest trains
+	send(ic1)
+	send(ic1Late, ir15)
+	frames := drainFrames(t, client.queue)
+	if len(frames) != 1 || frames[0].Type != "live_trains_snapshot" {
+		t.Fatalf("frames = %+v, want one snapshot", frames)
+	}
+	var snapshot TrainSnapshot
+	json.Unmarshal(frames[0].Data, &snapshot)
+	if len(snapshot.Trains) != 2 || snapshot.Trains[0].Delay != 4 {
+		t.Errorf("snapshot = %+v, want the latest two trains", snapshot)
+	}
+
+	// Once the snapshot was sent, changes go out as deltas again
+	send(ic1)
+	frames = drainFrames(t, client.queue)
+	if len(frames) != 1 || frames[0].Type != "live_trains_delta" {
+		t.Fatalf("frames = %+v, want one delta", frames)
+	}
+	var delta TrainDelta
+	json.Unmarshal(frames[0].Data, &delta)
+	if delta.Seq != snapshot.Seq+1 || len(delta.Removed) != 1 || len(delta.Changed) != 1 {
+		t.Errorf("delta = %+v, want seq %d removing IR 15 and changing IC 1", delta, snapshot.Seq+1)
+	}
+
+	// delta -> delta while the first is queued: again a snapshot
+	send(ic1Late)
+	send(ic1Late, ir15)
+	frames = drainFrames(t, client.queue)
+	if len(frames) != 1 || frames[0].Type != "live_trains_snapshot" {
+		t.Fatalf("frames = %+v, want one snapshot", frames)
+	}
+	json.Unmarshal(frames[0].Data, &snapshot)
+	if len(snapshot.Trains) != 2 {
+		t.Errorf("snapshot = %+v, want two trains", snapshot)
+	}
+}
@@ -0,0 +1,150 @@
+// Package websocket - RPC
+// This file contains request/response calls over the WebSocket connection,
+// so clients don't need a second HTTP connection for lookups and favorites.
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/middleware"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// maxPendingCalls is how many RPC calls a client may have in flight.
+const maxPendingCalls = 8
+
+// RPCMethod handles one RPC method. ctx carries the caller's identity from
+// the connection request; params is the raw "params" value (nil when absent). A *models.RequestError is sent to the client as is; other
+// errors are reported as internal errors.
+type RPCMethod func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// rpcRequest is a call from the client:
+//
+//	{"type": "request", "id": "42", "method": "stations.search", "params": {"query": "Zürich"}}
+//
+// The response carries the same id:
+//
+//	{"type": "response", "id": "42", "result": [...]}
+//	{"type": "response", "id": "42", "error": {"status": 404, "type": "Not Found", "message": "..."}}
+type rpcRequest struct {
+	ID     string          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// RegisterMethod makes a method callable over the WebSocket connection.
+// Methods run outside the hub's loop and may block.
+func (h *Hub) RegisterMethod(name string, method RPCMethod) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.methods[name] = method
+}
+
+// method returns a registered method.
+func (h *Hub) method(name string) (RPCMethod, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	method, ok := h.methods[name]
+	return method, ok
+}
+
+// methodNames returns the registered method names, sorted.
+func (h *Hub) methodNames() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, 0, len(h.methods))
+	for name := range h.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// call runs an RPC request and replies with its result or error. Calls run
+// concurrently, so responses may arrive out of order; clients match them by id.
+func (c *Client) call(message []byte) {
+	var req rpcRequest
+	if err := json.Unmarshal(message, &req); err != nil || req.ID == "" {
+		c.sendError("Bad Request", "Invalid request: id and method are required")
+		return
+	}
+
+	method, ok := c.hub.method(req.Method)
+	if !ok {
+		c.respond(req.ID, nil, &models.RequestError{
+			Status:  http.StatusNotFound,
+			Type:    "Unknown Method",
+			Message: "Unknown method \"" + req.Method + "\" (available: " + strings.Join(c.hub.methodNames(), ", ") + ")",
+		})
+		return
+	}
+
+	select {
+	case c.calls <- struct{}{}:
+	default:
+		c.respond(req.ID, nil, &models.RequestError{
+			Status:  http.StatusTooManyRequests,
+			Type:    "Too Many Requests",
+			Message: "Too many pending requests; wait for responses before sending more",
+		})
+		return
+	}
+
+	go func() {
+		defer func() { <-c.calls }()
+		defer func() {
+			if err := recover(); err != nil {
+				log.Error().Interface("panic", err).Str("method", req.Method).Msg("RPC method panicked")
+				c.respond(req.ID, nil, errors.New("panic"))
+			}
+		}()
+
+		// Calls are told apart in logs and audit records by session and a
+		// generated ID; the client's ID for the call is kept beside it
+		sess := c.sess()
+		ctx := middleware.WithRequestID(sess.context(), "ws:"+sess.ID+"/"+uuid.New().String())
+		ctx = middleware.WithClientRequestID(ctx, req.ID)
+		result, err := method(ctx, req.Params)
+		c.respond(req.ID, result, err)
+	}()
+}
+
+// respond sends the response to an RPC request.
+func (c *Client) respond(id string, result interface{}, err error) {
+	msg := models.WebSocketMessage{
+		Type:      "response",
+		ID:        id,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	// A nil *models.RequestError returned as error is a success
+	var reqErr *models.RequestError
+	if errors.As(err, &reqErr) && reqErr == nil {
+		err = nil
+	}
+
+	if err != nil {
+		if reqErr == nil {
+			log.Error().Err(err).Str("id", id).Msg("RPC call failed")
+			reqErr = &models.RequestError{
+				Status:  http.StatusInternalServerError,
+				Type:    "Internal Server Error",
+				Message: "The request could not be completed",
+			}
+		}
+		msg.Error = reqErr
+	} else {
+		msg.Result = result
+	}
+
+	c.reply(msg)
+}
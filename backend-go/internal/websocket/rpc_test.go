@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/middleware"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// rpcReply is a response or error message as sent to the client.
+type rpcReply struct {
+	Type   string               `json:"type"`
+	ID     string               `json:"id"`
+	Result json.RawMessage      `json:"result"`
+	Error  *models.RequestError `json:"error"`
+}
+
+// callRPC sends a request message and waits for the client's next reply.
+func callRPC(t *testing.T, client *Client, message string) rpcReply {
+	t.Helper()
+	client.handleMessage([]byte(message))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if frames, _ := client.queue.drain(); len(frames) > 0 {
+			var reply rpcReply
+			if err := json.Unmarshal(frames[0].data, &reply); err != nil {
+				t.Fatal(err)
+			}
+			return reply
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no reply to %s", message)
+	return rpcReply{}
+}
+
+func TestRPCEnvelope(t *testing.T) {
+	h := NewHub(nil, 5, 0, 16)
+	h.RegisterMethod("echo.id", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return map[string]string{
+			"clientId":  middleware.ClientRequestIDFromContext(ctx),
+			"requestId": middleware.RequestIDFromContext(ctx),
+			"params":    string(params),
+		}, nil
+	})
+	h.RegisterMethod("typed.nil", func(context.Context, json.RawMessage) (interface{}, error) {
+		var reqErr *models.RequestError
+		return "ok", reqErr
+	})
+	h.RegisterMethod("not.found", func(context.Context, json.RawMessage) (interface{}, error) {
+		return nil, &models.RequestError{Status: http.StatusNotFound, Type: "Not Found", Message: "no such trip"}
+	})
+	h.RegisterMethod("fails", func(context.Context, json.RawMessage) (interface{}, error) {
+		return nil, errors.New("database on fire")
+	})
+	h.RegisterMethod("panics", func(context.Context, json.RawMessage) (interface{}, error) {
+		panic("boom")
+	})
+	client := newTestClient(h)
+
+	// The request ID is echoed, and given to the method beside a generated one
+	reply := callRPC(t, client, `{"type": "request", "id": "call-7", "method": "echo.id", "params": {"q": 1}}`)
+	var echoed map[string]string
+	if err := json.Unmarshal(reply.Result, &echoed); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Type != "response" || reply.ID != "call-7" || reply.Error != nil {
+		t.Errorf("reply = %+v", reply)
+	}
+	if echoed["clientId"] != "call-7" || !strings.HasPrefix(echoed["requestId"], "ws:"+client.sess().ID+"/") || echoed["params"] != `{"q": 1}` {
+		t.Errorf("method saw %v", echoed)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		message string
+		typ     string // "response" or "error"
+		status  int    // 0 for a result
+		errType string
+	}{
+		{"unknown method", `{"type": "request", "id": "1", "method": "nope"}`, "response", http.StatusNotFound, "Unknown Method"},
+		{"missing id", `{"type": "request", "method": "echo.id"}`, "error", http.StatusBadRequest, "Bad Request"},
+		{"bad envelope", `{"type": "request", "id": 5, "method": "echo.id"}`, "error", http.StatusBadRequest, "Bad Request"},
+		{"typed nil error", `{"type": "request", "id": "2", "method": "typed.nil"}`, "response", 0, ""},
+		{"request error", `{"type": "request", "id": "3", "method": "not.found"}`, "response", http.StatusNotFound, "Not Found"},
+		{"other error", `{"type": "request", "id": "4", "method": "fails"}`, "response", http.StatusInternalServerError, "Internal Server Error"},
+		{"panic", `{"type": "request", "id": "5", "method": "panics"}`, "response", http.StatusInternalServerError, "Internal Server Error"},
+	} {
+		reply := callRPC(t, client, tc.message)
+		if reply.Type != tc.typ {
+			t.Errorf("%s: reply = %+v, want %s", tc.name, reply, tc.typ)
+			continue
+		}
+		if tc.status == 0 {
+			if reply.Error != nil || string(reply.Result) != `"ok"` {
+				t.Errorf("%s: reply = %+v, want the result", tc.name, reply)
+			}
+			continue
+		}
+		if reply.Error == nil || reply.Error.Status != tc.status || reply.Error.Type != tc.errType || reply.Result != nil {
+			t.Errorf("%s: reply = %+v, want a %d %s", tc.name, reply, tc.status, tc.errType)
+		}
+	}
+
+	// Unknown methods list the available ones
+	reply = callRPC(t, client, `{"type": "request", "id": "6", "method": "nope"}`)
+	if !strings.Contains(reply.Error.Message, "echo.id, fails, not.found, panics, typed.nil") {
+		t.Errorf("message = %q", reply.Error.Message)
+	}
+}
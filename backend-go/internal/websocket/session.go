@@ -0,0 +1,195 @@
+// Package websocket - Sessions
+// This file contains resumable client sessions with numbered messages and
+// a short replay buffer.
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/swiss-railway/backend-go/internal/auth"
+)
+
+// Session is the state of a client that survives reconnects: subscriptions,
+// delta encoders and the last messages sent. A client reconnecting within
+// the resume window sends {"type": "resume", "sessionId", "lastSeq"} and
+// gets the messages it missed.
+type Session struct {
+	ID       string
+	encoding string         // EncodingJSON or EncodingMsgPack
+	identity *auth.Identity // who connected; nil without credentials
+
+	subs   *Subscriptions
+	deltas map[string]*deltaEncoder // nil for clients receiving full updates
+	only   map[string]bool          // message types delivered besides control messages; nil for all
+
+	mu         sync.Mutex
+	client     *Client // nil while detached
+	detachedAt time.Time
+	seq        uint64
+	replay     []queuedFrame // ring buffer, oldest first
+	replaySize int
+	sim        *Simulation // nil on the live clock
+	following  string      // key of the last "following" state sent
+}
+
+// controlMessages are delivered to every session, whatever it receives
+// otherwise.
+var controlMessages = map[string]bool{
+	"connection":    true,
+	"resumed":       true,
+	"resume_failed": true,
+	"error":         true,
+}
+
+// newSession creates a session keeping up to replaySize messages.
+func newSession(encoding string, delta bool, replaySize int) *Session {
+	session := &Session{
+		ID:         uuid.New().String(),
+		encoding:   encoding,
+		subs:       NewSubscriptions(),
+		replaySize: replaySize,
+	}
+	if delta {
+		session.deltas = map[string]*deltaEncoder{
+			feedLiveTrains:     newDeltaEncoder(feedLiveTrains),
+			feedFollowedTrains: newDeltaEncoder(feedFollowedTrains),
+		}
+	}
+	return session
+}
+
+// identify records the identity of the request opening the session.
+func (s *Session) identify(r *http.Request) {
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		s.identity = &identity
+	}
+}
+
+// owner returns whose favorites the session sees ("" for nobody's).
+func (s *Session) owner() string {
+	if s.identity == nil {
+		return ""
+	}
+	return s.identity.Owner()
+}
+
+// context returns a context carrying the session's identity, so RPC calls
+// are scoped like the REST requests of the same caller.
+func (s *Session) context() context.Context {
+	if s.identity == nil {
+		return context.Background()
+	}
+	return auth.NewContext(context.Background(), *s.identity)
+}
+
+// enqueue numbers a message, records it for replay and queues it for the
+// attached client without blocking. Detached sessions only record it.
+// ok is false if the attached client's queue is full of control messages.
+func (s *Session) enqueue(f *frame) (client *Client, ok bool) {
+	if s.only != nil && !s.only[f.msg.Type] && !controlMessages[f.msg.Type] {
+		return nil, true
+	}
+
+	data := f.bytes(s.encoding)
+	if data == nil {
+		return nil, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	numbered := numberMessage(s.seq, data, s.encoding)
+
+	if s.replaySize > 0 {
+		if len(s.replay) >= s.replaySize {
+			s.replay = s.replay[1:]
+		}
+		s.replay = append(s.replay, queuedFrame{seq: s.seq, event: f.msg.Type, key: f.key, data: numbered})
+	}
+
+	if s.client == nil {
+		return nil, true
+	}
+
+	return s.client, s.push(s.client, queuedFrame{seq: s.seq, event: f.msg.Type, key: f.key, data: numbered})
+}
+
+// queued reports whether the attached client has a frame with the
+// coalescing key waiting to be sent.
+func (s *Session) queued(key string) bool {
+	s.mu.Lock()
+	client := s.client
+	s.mu.Unlock()
+	return client != nil && client.queue.has(key)
+}
+
+// push queues a frame for a client. A skipped delta frame breaks the feed's
+// chain, so the feed restarts with a snapshot; a snapshot replacing the
+// skipped frame is a base of its own. encodeTrains already sends snapshots
+// in place of queued frames; this covers frames replayed meanwhile and
+// frames dropped from a full queue. Callers must hold s.mu.
+func (s *Session) push(client *Client, qf queuedFrame) bool {
+	result := client.queue.push(qf)
+	if encoder, ok := s.deltas[result.staleKey]; ok && !(result.replaced && strings.HasSuffix(qf.event, "_snapshot")) {
+		encoder.Reset()
+	}
+	return result.ok
+}
+
+// attach binds a client to the session and replays messages after lastSeq.
+// It returns the previously attached client (if any), the number of
+// replayed messages, and whether the replay covered everything missed.
+func (s *Session) attach(client *Client, lastSeq uint64) (previous *Client, replayed int, complete bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous = s.client
+	s.client = client
+	s.detachedAt = time.Time{}
+
+	complete = lastSeq >= s.seq || (len(s.replay) > 0 && s.replay[0].seq <= lastSeq+1)
+	for _, msg := range s.replay {
+		if msg.seq <= lastSeq {
+			continue
+		}
+		if !s.push(client, msg) {
+			complete = false
+			break
+		}
+		replayed++
+	}
+
+	return previous, replayed, complete
+}
+
+// detach unbinds a disconnected client; later messages are only recorded.
+// The client's queue may be closed once detach returns.
+func (s *Session) detach(client *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == client {
+		s.client = nil
+		s.detachedAt = time.Now()
+	}
+}
+
+// expired reports whether the session has been detached longer than window.
+func (s *Session) expired(window time.Duration, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client == nil && now.Sub(s.detachedAt) > window
+}
+
+// resetDeltas makes every delta feed start over with a snapshot.
+func (s *Session) resetDeltas() {
+	for _, encoder := range s.deltas {
+		encoder.Reset()
+	}
+}
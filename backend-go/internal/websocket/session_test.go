@@ -0,0 +1,164 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+// sessionMessage is the part of a numbered JSON message the tests look at.
+type sessionMessage struct {
+	Type      string          `json:"type"`
+	Seq       uint64          `json:"seq"`
+	SessionID string          `json:"sessionId"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// sessionConn is a test client speaking JSON.
+type sessionConn struct {
+	t       *testing.T
+	conn    *websocket.Conn
+	pending [][]byte // rest of a frame batching several messages
+}
+
+func dialSession(t *testing.T, url string) *sessionConn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sessionConn{t: t, conn: conn}
+}
+
+func (c *sessionConn) send(msg string) {
+	c.t.Helper()
+	if err := c.conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads messages until one of the given type.
+func (c *sessionConn) expect(msgType string) sessionMessage {
+	c.t.Helper()
+	for {
+		if len(c.pending) == 0 {
+			c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, frame, err := c.conn.ReadMessage()
+			if err != nil {
+				c.t.Fatalf("waiting for %s: %v", msgType, err)
+			}
+			c.pending = bytes.Split(frame, []byte("\n"))
+		}
+		data := c.pending[0]
+		c.pending = c.pending[1:]
+
+		var msg sessionMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.t.Fatalf("invalid message %s: %v", data, err)
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+// disconnect closes the connection and waits for the hub to detach it.
+func (c *sessionConn) disconnect(h *Hub) {
+	c.t.Helper()
+	c.conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); h.ClientCount() > 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			c.t.Fatal("client still registered")
+		}
+	}
+}
+
+func TestSessionResume(t *testing.T) {
+	h := NewHub(services.NewGTFSService(t.TempDir()), 1, 60, 16)
+	go h.Run()
+	defer h.Stop()
+	server := httptest.NewServer(http.HandlerFunc(h.HandleWebSocket))
+	defer server.Close()
+
+	first := dialSession(t, server.URL)
+	welcome := first.expect("connection")
+	if welcome.Seq != 1 || welcome.SessionID == "" {
+		t.Fatalf("welcome = %+v", welcome)
+	}
+	for i := 0; i < 3; i++ {
+		first.send(`{"type": "ping"}`)
+		if pong := first.expect("pong"); pong.Seq != uint64(i+2) {
+			t.Errorf("pong %d has seq %d", i, pong.Seq)
+		}
+	}
+	first.disconnect(h)
+
+	// The new connection gets the pongs after seq 2 again, then continues
+	// the numbering of the earlier session
+	second := dialSession(t, server.URL)
+	defer second.conn.Close()
+	if fresh := second.expect("connection"); fresh.SessionID == welcome.SessionID {
+		t.Fatal("second connection reuses the session before resuming")
+	}
+	second.send(`{"type": "resume", "sessionId": "` + welcome.SessionID + `", "lastSeq": 2}`)
+	for _, seq := range []uint64{3, 4} {
+		if pong := second.expect("pong"); pong.Seq != seq {
+			t.Errorf("replayed seq %d, want %d", pong.Seq, seq)
+		}
+	}
+	resumed := second.expect("resumed")
+	var result ResumeResult
+	if err := json.Unmarshal(resumed.Data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Seq != 5 || result.SessionID != welcome.SessionID || result.Replayed != 2 || !result.Complete {
+		t.Errorf("resumed = %+v with %+v", resumed, result)
+	}
+
+	// A session can't be resumed twice on one connection, nor an unknown one
+	for _, sessionID := range []string{welcome.SessionID, "unknown"} {
+		second.send(`{"type": "resume", "sessionId": "` + sessionID + `", "lastSeq": 0}`)
+		if failed := second.expect("resume_failed"); failed.SessionID != welcome.SessionID {
+			t.Errorf("resume of %s: %+v", sessionID, failed)
+		}
+	}
+}
+
+func TestSessionResumeIncomplete(t *testing.T) {
+	h := NewHub(services.NewGTFSService(t.TempDir()), 1, 60, 4)
+	go h.Run()
+	defer h.Stop()
+	server := httptest.NewServer(http.HandlerFunc(h.HandleWebSocket))
+	defer server.Close()
+
+	first := dialSession(t, server.URL)
+	welcome := first.expect("connection")
+	for i := 0; i < 6; i++ {
+		first.send(`{"type": "ping"}`)
+		first.expect("pong")
+	}
+	first.disconnect(h)
+
+	// Only the last 4 of the 7 messages are kept
+	second := dialSession(t, server.URL)
+	defer second.conn.Close()
+	second.expect("connection")
+	second.send(`{"type": "resume", "sessionId": "` + welcome.SessionID + `", "lastSeq": 1}`)
+	if pong := second.expect("pong"); pong.Seq != 4 {
+		t.Errorf("first replayed seq %d, want 4", pong.Seq)
+	}
+	var result ResumeResult
+	if err := json.Unmarshal(second.expect("resumed").Data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Replayed != 4 || result.Complete {
+		t.Errorf("result = %+v, want 4 replayed and incomplete", result)
+	}
+}
@@ -0,0 +1,348 @@
+// Package websocket - Simulations
+// This file contains per-session simulated timelines, so clients can replay
+// a day at their own time and speed while others watch live trains.
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+	"github.com/swiss-railway/backend-go/internal/services"
+)
+
+// Simulation is a session's own clock. Simulated time runs at speed times
+// wall-clock time from an anchor and stands still while paused.
+type Simulation struct {
+	mu     sync.Mutex
+	start  time.Time // simulated time at anchor
+	anchor time.Time // wall-clock time when start was set
+	speed  float64
+	paused bool
+}
+
+// SimulationState is the payload of "simulation" and "simulation_clock"
+// messages.
+type SimulationState struct {
+	Running bool    `json:"running"`
+	Date    string  `json:"date,omitempty"` // YYYY-MM-DD
+	Time    string  `json:"time,omitempty"` // HH:MM:SS
+	Speed   float64 `json:"speed,omitempty"`
+	Paused  bool    `json:"paused,omitempty"`
+}
+
+// simulationRequest is the payload of "simulation" messages:
+//
+//	{"type": "simulation", "action": "start", "date": "2025-03-14", "time": "07:30", "speed": 10}
+//	{"type": "simulation", "action": "pause"}  // also "resume" and "stop"
+//	{"type": "simulation", "action": "set", "time": "17:00", "speed": 2}
+//
+// start defaults to today, the current time of day and speed 1.
+type simulationRequest struct {
+	Action string   `json:"action"`
+	Date   string   `json:"date"`
+	Time   string   `json:"time"`
+	Speed  *float64 `json:"speed"`
+	Paused *bool    `json:"paused"`
+}
+
+// now returns the simulated time at wall-clock time wall.
+func (s *Simulation) now(wall time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nowLocked(wall)
+}
+
+func (s *Simulation) nowLocked(wall time.Time) time.Time {
+	if s.paused {
+		return s.start
+	}
+	elapsed := float64(wall.Sub(s.anchor)) * s.speed
+	return s.start.Add(time.Duration(elapsed))
+}
+
+// state describes the simulation at wall-clock time wall.
+func (s *Simulation) state(wall time.Time) SimulationState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at := s.nowLocked(wall)
+	return SimulationState{
+		Running: true,
+		Date:    at.Format("2006-01-02"),
+		Time:    at.Format("15:04:05"),
+		Speed:   s.speed,
+		Paused:  s.paused,
+	}
+}
+
+// apply changes the simulation. A new date or time makes the timeline jump.
+func (s *Simulation) apply(req *simulationRequest, wall time.Time, maxSpeed float64) (jumped bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.nowLocked(wall)
+	start, err := parseSimulatedTime(req.Date, req.Time, current)
+	if err != nil {
+		return false, err
+	}
+
+	speed := s.speed
+	if req.Speed != nil {
+		speed = *req.Speed
+	}
+	if speed <= 0 || speed > maxSpeed {
+		return false, fmt.Errorf("speed must be greater than 0 and at most %g", maxSpeed)
+	}
+
+	// Re-anchor so the change applies from now on
+	jumped = !start.Equal(current)
+	s.start = start
+	s.anchor = wall
+	s.speed = speed
+	if req.Paused != nil {
+		s.paused = *req.Paused
+	}
+	return jumped, nil
+}
+
+// setPaused pauses or resumes the simulated clock.
+func (s *Simulation) setPaused(paused bool, wall time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.paused == paused {
+		return
+	}
+	s.start = s.nowLocked(wall)
+	s.anchor = wall
+	s.paused = paused
+}
+
+// parseSimulatedTime combines a date (YYYY-MM-DD) and time of day (HH:MM or
+// HH:MM:SS) in Swiss local time; missing parts are taken from current.
+func parseSimulatedTime(date, clock string, current time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		loc = time.UTC
+	}
+	current = current.In(loc)
+
+	day := current
+	if date != "" {
+		if day, err = time.ParseInLocation("2006-01-02", date, loc); err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", date)
+		}
+	}
+
+	hour, minute, second := current.Clock()
+	if clock != "" {
+		layout := "15:04"
+		if strings.Count(clock, ":") == 2 {
+			layout = "15:04:05"
+		}
+		t, err := time.Parse(layout, clock)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q (expected HH:MM or HH:MM:SS)", clock)
+		}
+		hour, minute, second = t.Clock()
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, 0, loc), nil
+}
+
+// simulation returns the session's simulation, or nil on the live clock.
+func (s *Session) simulation() *Simulation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sim
+}
+
+// SetSimulationLimits caps concurrent simulations (0 disables them) and
+// their speed.
+func (h *Hub) SetSimulationLimits(maxSimulations int, maxSpeed float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxSimulations = maxSimulations
+	h.maxSimSpeed = maxSpeed
+}
+
+// controlSimulation handles a "simulation" message and returns the new state.
+func (h *Hub) controlSimulation(session *Session, req *simulationRequest) (SimulationState, error) {
+	wall := time.Now()
+
+	switch req.Action {
+	case "start":
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		sim := &Simulation{start: wall, anchor: wall, speed: 1}
+		if _, err := sim.apply(req, wall, h.maxSimSpeed); err != nil {
+			return SimulationState{}, err
+		}
+
+		// Counted under the hub lock so concurrent starts can't exceed the cap
+		running := 0
+		for _, other := range h.sessions {
+			if other != session && other.simulation() != nil {
+				running++
+			}
+		}
+		if running >= h.maxSimulations {
+			return SimulationState{}, fmt.Errorf("simulation limit reached (%d running); try again later", h.maxSimulations)
+		}
+
+		session.mu.Lock()
+		session.sim = sim
+		session.mu.Unlock()
+		session.resetDeltas()
+		return sim.state(wall), nil
+
+	case "stop":
+		session.mu.Lock()
+		stopped := session.sim != nil
+		session.sim = nil
+		session.mu.Unlock()
+		if stopped {
+			session.resetDeltas()
+		}
+		return SimulationState{Running: false}, nil
+	}
+
+	sim := session.simulation()
+	if sim == nil {
+		return SimulationState{}, fmt.Errorf("no simulation running; start one with action \"start\"")
+	}
+
+	switch req.Action {
+	case "pause", "resume":
+		sim.setPaused(req.Action == "pause", wall)
+
+	case "set":
+		h.mu.RLock()
+		maxSpeed := h.maxSimSpeed
+		h.mu.RUnlock()
+
+		jumped, err := sim.apply(req, wall, maxSpeed)
+		if err != nil {
+			return SimulationState{}, err
+		}
+		if jumped {
+			session.resetDeltas()
+		}
+
+	default:
+		return SimulationState{}, fmt.Errorf("unknown simulation action %q (expected start, pause, resume, set or stop)", req.Action)
+	}
+
+	return sim.state(wall), nil
+}
+
+// timeline computes trains on the live clock, or at a simulated time. The
+// live clock reads the latest broker tick and falls back to the timetable
+// until the first one arrives.
+type timeline struct {
+	gtfs *services.GTFSService
+	live *liveState
+	at   *time.Time // nil for the live clock
+}
+
+// timelineFor returns the timeline a session is watching.
+func (h *Hub) timelineFor(session *Session) timeline {
+	if sim := session.simulation(); sim != nil {
+		at := sim.now(time.Now())
+		return timeline{gtfs: h.gtfsService, at: &at}
+	}
+	return timeline{gtfs: h.gtfsService, live: h.liveState()}
+}
+
+// trains returns the running trains accepted by match, at most limit (0 for
+// no limit).
+func (t timeline) trains(limit int, match func(*models.Train) bool) []models.Train {
+	if t.at == nil {
+		if t.live != nil {
+			return t.live.trains(limit, match)
+		}
+		return t.gtfs.GetLiveTrainsMatching(1.0, limit, match)
+	}
+	return t.gtfs.GetTrainsAt(*t.at, limit, match)
+}
+
+// train returns the state of one trip, or nil if it isn't running.
+func (t timeline) train(tripID string) *models.Train {
+	if t.at == nil {
+		if t.live != nil {
+			return t.live.train(tripID)
+		}
+		return t.gtfs.GetLiveTrain(tripID)
+	}
+	return t.gtfs.GetTrainAt(tripID, *t.at)
+}
+
+// broadcastSimulations pushes the simulated clock and trains to sessions
+// running a simulation. Followed trains are sent on every call; the
+// overview and area-filtered trains only with withOverview.
+func (h *Hub) broadcastSimulations(withOverview bool) {
+	h.mu.RLock()
+	var simulated []*Session
+	for _, session := range h.sessions {
+		if session.simulation() != nil {
+			simulated = append(simulated, session)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(simulated) == 0 {
+		return
+	}
+
+	// Compute each session's frames outside the hub lock, then deliver them
+	// in order (clock first)
+	wall := time.Now()
+	favorites := h.favorites()
+	frames := make(map[*Session][]*frame, len(simulated))
+	for _, session := range simulated {
+		sim := session.simulation()
+		if sim == nil {
+			continue
+		}
+
+		at := sim.now(wall)
+		tl := timeline{gtfs: h.gtfsService, at: &at}
+		list := []*frame{marshalMessage("simulation_clock", sim.state(wall)).coalesce("simulation_clock")}
+
+		if withOverview {
+			switch {
+			case session.subs.IsOverview():
+				list = append(list, session.encodeTrains(feedLiveTrains, tl.trains(services.MaxLiveTrains, nil)))
+			case session.subs.HasAreaFilter():
+				list = append(list, session.encodeTrains(feedLiveTrains, tl.trains(0, session.subs.MatchesArea)))
+			}
+		}
+
+		if ids := followedTrains(session, favorites); len(ids) > 0 {
+			var trains []models.Train
+			for _, id := range ids {
+				if train := tl.train(id); train != nil {
+					trains = append(trains, *train)
+				}
+			}
+			if len(trains) > 0 || session.deltas != nil {
+				list = append(list, session.encodeTrains(feedFollowedTrains, trains))
+			}
+		}
+
+		frames[session] = list
+	}
+
+	for i := 0; i < 3; i++ {
+		h.deliver(func(session *Session) *frame {
+			if list := frames[session]; i < len(list) {
+				return list[i]
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// registerClient registers a client with a running hub and waits for its
+// session to be known.
+func registerClient(t *testing.T, h *Hub, client *Client) {
+	t.Helper()
+	h.register <- client
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h.mu.RLock()
+		_, ok := h.sessions[client.sess().ID]
+		h.mu.RUnlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client not registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func speed(v float64) *float64 {
+	return &v
+}
+
+func TestSimulationClock(t *testing.T) {
+	wall := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+	sim := &Simulation{start: wall, anchor: wall, speed: 1}
+	if _, err := sim.apply(&simulationRequest{Date: "2025-03-14", Time: "07:30", Speed: speed(10)}, wall, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := func(after time.Duration) string {
+		return sim.state(wall.Add(after)).Time
+	}
+	if got := clock(6 * time.Second); got != "07:31:00" {
+		t.Errorf("after 6s at speed 10: %s", got)
+	}
+
+	// Paused, the clock stands still; resumed, it goes on from there
+	sim.setPaused(true, wall.Add(6*time.Second))
+	if got := clock(time.Minute); got != "07:31:00" || !sim.state(wall).Paused {
+		t.Errorf("paused: %s", got)
+	}
+	sim.setPaused(false, wall.Add(time.Minute))
+	if got := clock(66 * time.Second); got != "07:32:00" {
+		t.Errorf("resumed: %s", got)
+	}
+
+	// A new speed applies from now on, without a jump
+	jumped, err := sim.apply(&simulationRequest{Speed: speed(2)}, wall.Add(66*time.Second), 100)
+	if err != nil || jumped {
+		t.Fatalf("set speed: jumped %v, %v", jumped, err)
+	}
+	if got := clock(96 * time.Second); got != "07:33:00" {
+		t.Errorf("after 30s at speed 2: %s", got)
+	}
+
+	// A new time jumps; invalid changes leave the clock alone
+	if jumped, err := sim.apply(&simulationRequest{Time: "17:00:30"}, wall.Add(96*time.Second), 100); err != nil || !jumped {
+		t.Errorf("set time: jumped %v, %v", jumped, err)
+	}
+	for _, req := range []simulationRequest{
+		{Speed: speed(101)},
+		{Speed: speed(0)},
+		{Date: "14.03.2025"},
+		{Time: "25:00"},
+	} {
+		req := req
+		if _, err := sim.apply(&req, wall.Add(96*time.Second), 100); err == nil {
+			t.Errorf("%+v accepted", req)
+		}
+	}
+	if state := sim.state(wall.Add(96 * time.Second)); state.Date != "2025-03-14" || state.Time != "17:00:30" || state.Speed != 2 {
+		t.Errorf("state = %+v", state)
+	}
+}
+
+func TestSimulationLimits(t *testing.T) {
+	h := NewHub(allDayGTFS(t), 60, 0, 16)
+	h.SetSimulationLimits(1, 100)
+	go h.Run()
+	defer h.Stop()
+
+	a, b := newTestClient(h), newTestClient(h)
+	registerClient(t, h, a)
+	registerClient(t, h, b)
+
+	start := func(client *Client, speed float64) error {
+		_, err := h.controlSimulation(client.sess(), &simulationRequest{Action: "start", Speed: &speed})
+		return err
+	}
+
+	if err := start(a, 101); err == nil || !strings.Contains(err.Error(), "at most 100") {
+		t.Errorf("speed 101: %v", err)
+	}
+	if err := start(a, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := start(b, 1); err == nil || !strings.Contains(err.Error(), "limit reached") {
+		t.Errorf("second simulation: %v", err)
+	}
+
+	// A session may restart its own, and stopping frees the slot
+	if err := start(a, 50); err != nil {
+		t.Errorf("restart: %v", err)
+	}
+	if state, err := h.controlSimulation(a.sess(), &simulationRequest{Action: "set", Speed: speed(200)}); err == nil {
+		t.Errorf("set speed 200: %+v", state)
+	}
+	if a.sess().simulation().state(time.Now()).Speed != 50 {
+		t.Error("rejected speed change applied")
+	}
+	if _, err := h.controlSimulation(a.sess(), &simulationRequest{Action: "stop"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := start(b, 1); err != nil {
+		t.Errorf("after stop: %v", err)
+	}
+
+	// Without a simulation only start and stop work
+	if _, err := h.controlSimulation(a.sess(), &simulationRequest{Action: "pause"}); err == nil {
+		t.Error("paused without a simulation")
+	}
+	if _, err := h.controlSimulation(b.sess(), &simulationRequest{Action: "rewind"}); err == nil {
+		t.Error("unknown action accepted")
+	}
+
+	// Simulations can be disabled
+	h.SetSimulationLimits(0, 100)
+	if err := start(a, 1); err == nil {
+		t.Error("started with simulations disabled")
+	}
+}
+
+func TestSimulationProgress(t *testing.T) {
+	// The overview is only due after a minute
+	h := NewHub(allDayGTFS(t), 60, 0, 16)
+	h.SetSimulationLimits(2, 100)
+	go h.Run()
+	defer h.Stop()
+
+	follower, overview := newTestClient(h), newTestClient(h)
+	if err := follower.sess().subs.Apply(&subscriptionRequest{Topic: "train:all-day"}, true); err != nil {
+		t.Fatal(err)
+	}
+	registerClient(t, h, follower)
+	registerClient(t, h, overview)
+	for _, client := range []*Client{follower, overview} {
+		if _, err := h.controlSimulation(client.sess(), &simulationRequest{Action: "start", Date: "2025-03-14", Time: "10:00", Speed: speed(60)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every second: the clock, a simulated minute on, and followed trains
+	var clocks []string
+	followed, live := 0, 0
+	deadline := time.Now().Add(3500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		for _, client := range []*Client{follower, overview} {
+			frames, _ := client.queue.drain()
+			for _, f := range frames {
+				switch {
+				case f.event == "simulation_clock" && client == follower:
+					var msg struct {
+						Data SimulationState `json:"data"`
+					}
+					if err := json.Unmarshal(f.data, &msg); err != nil {
+						t.Fatal(err)
+					}
+					clocks = append(clocks, msg.Data.Time)
+				case strings.HasPrefix(f.event, feedFollowedTrains):
+					followed++
+				case strings.HasPrefix(f.event, feedLiveTrains):
+					live++
+				}
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if len(clocks) < 3 || followed < 3 {
+		t.Fatalf("clocks %v and %d followed train updates, want one a second", clocks, followed)
+	}
+	for i := 1; i < len(clocks); i++ {
+		if clocks[i] <= clocks[i-1] || clocks[i] < "10:00:50" || clocks[i] > "10:04:00" {
+			t.Errorf("clock went %s -> %s", clocks[i-1], clocks[i])
+		}
+	}
+	if live != 0 {
+		t.Errorf("%d overview updates, want none before the update interval", live)
+	}
+}
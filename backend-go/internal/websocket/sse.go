@@ -0,0 +1,302 @@
+// Package websocket - Server-Sent Events
+// This file contains the SSE streams, which are fed by the same hub,
+// sessions and outbound queues as WebSocket clients.
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+const (
+	// sseHeartbeat is how often an idle stream gets a comment line, so
+	// proxies don't close it.
+	sseHeartbeat = 15 * time.Second
+
+	// sseRetry is the reconnection delay suggested to EventSource clients.
+	sseRetry = 3 * time.Second
+)
+
+// HandleTrainStream streams live trains as Server-Sent Events.
+//
+//	GET /api/stream/trains                         overview
+//	GET /api/stream/trains?train=1001,1002         followed trains (every second)
+//	GET /api/stream/trains?station=8503000         trains serving a station
+//	GET /api/stream/trains?route=91-1-A
+//	GET /api/stream/trains?bbox=47.3,8.4,47.5,8.7  minLat,minLng,maxLat,maxLng
+//	GET /api/stream/trains?updates=delta           snapshots and deltas
+//
+// Events carry the same payloads as the WebSocket messages; the event name
+// is the message type.
+func (h *Hub) HandleTrainStream(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	req, err := streamFilters(query)
+	if err != nil {
+		sendStreamError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.serveStream(w, r, req, query.Get("updates") == "delta", nil, func(session *Session) *frame {
+		if !h.gtfsService.IsDataLoaded() {
+			return nil
+		}
+		return marshalMessage("live_trains", h.trainsFor(session))
+	})
+}
+
+// HandleEventStream streams train events (arrivals, departures, ...) as
+// Server-Sent Events, one train_events event per tick with events:
+//
+//	GET /api/stream/events                          all trains
+//	GET /api/stream/events?station=8503000          events at a station
+//	GET /api/stream/events?train=1001&route=91-1-A
+//
+// Filters work as for /api/stream/trains; recent events can be fetched
+// from /api/events first.
+func (h *Hub) HandleEventStream(w http.ResponseWriter, r *http.Request) {
+	req, err := streamFilters(r.URL.Query())
+	if err != nil {
+		sendStreamError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Topics = append(req.Topics, topicEvents)
+
+	h.serveStream(w, r, req, false, []string{"train_events"}, func(*Session) *frame {
+		return nil
+	})
+}
+
+// HandleDepartureStream streams the departures of a station as Server-Sent
+// Events (departures_update), refreshed at the hub's update interval.
+func (h *Hub) HandleDepartureStream(w http.ResponseWriter, r *http.Request) {
+	stationID := mux.Vars(r)["id"]
+
+	if !h.gtfsService.IsDataLoaded() {
+		sendStreamError(w, http.StatusServiceUnavailable, "GTFS data is still loading. Please try again later.")
+		return
+	}
+	if h.gtfsService.GetStationByID(stationID) == nil {
+		sendStreamError(w, http.StatusNotFound, "Station not found: "+stationID)
+		return
+	}
+
+	req := subscriptionRequest{Topics: []string{topicDepartures + ":" + stationID}}
+	h.serveStream(w, r, &req, false, nil, func(*Session) *frame {
+		departures := h.gtfsService.GetStationDepartures(stationID)
+		if departures == nil {
+			return nil
+		}
+		return marshalMessage("departures_update", departures).coalesce(topicDepartures + ":" + stationID)
+	})
+}
+
+// serveStream registers an SSE client with the hub and writes its queue as
+// events until the request ends. With only, the stream carries just those
+// message types. A Last-Event-ID header ("sessionId:seq") resumes the
+// earlier session, replaying what was missed.
+func (h *Hub) serveStream(w http.ResponseWriter, r *http.Request, req *subscriptionRequest, delta bool, only []string, initial func(*Session) *frame) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendStreamError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	session := newSession(EncodingJSON, delta, h.replaySize)
+	session.identify(r)
+	if err := session.subs.Apply(req, true); err != nil {
+		sendStreamError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if only != nil {
+		session.only = make(map[string]bool, len(only))
+		for _, msgType := range only {
+			session.only[msgType] = true
+		}
+	}
+
+	client := &Client{
+		hub:       h,
+		queue:     newOutboundQueue(sendQueueLimit),
+		frameType: websocket.TextMessage,
+	}
+	session.attach(client, 0)
+	client.session = session
+
+	select {
+	case h.register <- client:
+	case <-h.done:
+		sendStreamError(w, http.StatusServiceUnavailable, "Server is shutting down")
+		return
+	}
+	defer func() {
+		select {
+		case h.unregister <- client:
+		case <-h.done:
+		}
+	}()
+
+	// Streams outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Debug().Err(err).Msg("Could not clear SSE write deadline")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+
+	resumed := false
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		resumed = h.resumeStream(client, lastEventID)
+	}
+
+	if !resumed {
+		client.reply(models.WebSocketMessage{
+			Type:      "connection",
+			Message:   "Connected to Swiss Railway Network event stream",
+			SessionID: session.ID,
+			Timestamp: time.Now().Format(time.RFC3339),
+			GTFSReady: h.gtfsService.IsDataLoaded(),
+		})
+		if f := initial(session); f != nil {
+			client.sess().enqueue(f)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-client.queue.ready:
+			frames, closed := client.queue.drain()
+			sessionID := client.sess().ID
+			for _, f := range frames {
+				if _, err := fmt.Fprintf(w, "id: %s:%d\nevent: %s\ndata: %s\n\n", sessionID, f.seq, f.event, f.data); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+
+			// Queue closed by the hub: overflow, or the session moved elsewhere
+			if closed {
+				return
+			}
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// resumeStream resumes the session named by a Last-Event-ID and reports
+// whether it succeeded. On failure the client keeps its fresh session.
+func (h *Hub) resumeStream(client *Client, lastEventID string) bool {
+	sessionID, seqText, _ := strings.Cut(lastEventID, ":")
+	lastSeq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil || sessionID == "" {
+		client.reply(models.WebSocketMessage{
+			Type:      "resume_failed",
+			Message:   "Invalid Last-Event-ID " + strconv.Quote(lastEventID) + " (expected sessionId:seq)",
+			SessionID: client.sess().ID,
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return false
+	}
+
+	result, err := h.resume(client, sessionID, lastSeq)
+	if err != nil {
+		client.reply(models.WebSocketMessage{
+			Type:      "resume_failed",
+			Message:   err.Error(),
+			SessionID: client.sess().ID,
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+		return false
+	}
+
+	client.reply(models.WebSocketMessage{
+		Type:      "resumed",
+		Data:      result,
+		SessionID: sessionID,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+	return true
+}
+
+// streamFilters turns train, station and route (comma-separated or
+// repeated) and bbox query parameters into a subscription request.
+func streamFilters(query url.Values) (*subscriptionRequest, error) {
+	var req subscriptionRequest
+	for _, kind := range []string{topicTrain, topicStation, topicRoute} {
+		for _, value := range query[kind] {
+			for _, id := range strings.Split(value, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					req.Topics = append(req.Topics, kind+":"+id)
+				}
+			}
+		}
+	}
+	if value := query.Get(topicBBox); value != "" {
+		box, err := parseBoundingBox(value)
+		if err != nil {
+			return nil, err
+		}
+		req.BBox = box
+	}
+	return &req, nil
+}
+
+// parseBoundingBox parses "minLat,minLng,maxLat,maxLng".
+func parseBoundingBox(value string) (*BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox must be minLat,minLng,maxLat,maxLng")
+	}
+
+	var coords [4]float64
+	for i, part := range parts {
+		coord, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox coordinate %q", part)
+		}
+		coords[i] = coord
+	}
+
+	box := &BoundingBox{MinLat: coords[0], MinLng: coords[1], MaxLat: coords[2], MaxLng: coords[3]}
+	if !box.valid() {
+		return nil, fmt.Errorf("bbox %q is not a valid area", value)
+	}
+	return box, nil
+}
+
+// sendStreamError sends a JSON error before a stream has started.
+func sendStreamError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.APIError{
+		Error:     http.StatusText(status),
+		Message:   message,
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
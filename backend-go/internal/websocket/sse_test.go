@@ -0,0 +1,188 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is an event read from a stream.
+type sseEvent struct {
+	id, event, data string
+}
+
+// openStream requests a train stream, resuming with lastEventID if set,
+// and returns its events until the returned cancel is called.
+func openStream(t *testing.T, url, lastEventID string) (<-chan sseEvent, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+
+	events := make(chan sseEvent, 64)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		var event sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event.event != "":
+				events <- event
+				event = sseEvent{}
+			}
+		}
+	}()
+	return events, cancel
+}
+
+// nextEvent returns the next event of a stream.
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event within 5s")
+	}
+	return sseEvent{}
+}
+
+func TestStreamResume(t *testing.T) {
+	h := NewHub(allDayGTFS(t), 1, 60, 16)
+	go h.Run()
+	defer h.Stop()
+	server := httptest.NewServer(http.HandlerFunc(h.HandleTrainStream))
+	defer server.Close()
+	url := server.URL + "?train=all-day"
+
+	// Followed trains arrive every second
+	events, cancel := openStream(t, url, "")
+	if event := nextEvent(t, events); event.event != "connection" {
+		t.Fatalf("first event = %+v", event)
+	}
+	var seen []sseEvent
+	for len(seen) < 3 {
+		if event := nextEvent(t, events); event.event == feedFollowedTrains+"_update" {
+			seen = append(seen, event)
+		}
+	}
+	cancel()
+
+	// Updates queue up while disconnected
+	time.Sleep(2500 * time.Millisecond)
+
+	var sessionID string
+	var lastSeq uint64
+	if _, err := fmt.Sscanf(strings.Replace(seen[1].id, ":", " ", 1), "%s %d", &sessionID, &lastSeq); err != nil {
+		t.Fatalf("event ID %q: %v", seen[1].id, err)
+	}
+
+	// Resuming after the second update replays everything since
+	events, cancel = openStream(t, url, seen[1].id)
+	defer cancel()
+	var replayed []sseEvent
+	var resumed sseEvent
+	for resumed.event == "" {
+		switch event := nextEvent(t, events); event.event {
+		case "resumed":
+			resumed = event
+		case "connection", "resume_failed":
+			t.Fatalf("resume failed: %+v", event)
+		default:
+			replayed = append(replayed, event)
+		}
+	}
+
+	var msg struct {
+		Data ResumeResult `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(resumed.data), &msg); err != nil {
+		t.Fatal(err)
+	}
+	result := msg.Data
+	if result.SessionID != sessionID || result.LastSeq != lastSeq || !result.Complete || result.Replayed < 3 {
+		t.Errorf("resumed = %+v, want the third update and at least one missed", result)
+	}
+
+	// The replayed updates of the feed replace one another: the newest is sent
+	if len(replayed) != 1 || replayed[0].event != feedFollowedTrains+"_update" ||
+		replayed[0].id != fmt.Sprintf("%s:%d", sessionID, lastSeq+uint64(result.Replayed)) {
+		t.Errorf("replayed %+v, want the newest update", replayed)
+	}
+
+	// Live updates continue on the same session
+	if event := nextEvent(t, events); !strings.HasPrefix(event.id, sessionID+":") {
+		t.Errorf("event after resuming = %+v, want session %s", event, sessionID)
+	}
+}
+
+func TestStreamResumeExpired(t *testing.T) {
+	h := NewHub(allDayGTFS(t), 1, 1, 16)
+	go h.Run()
+	defer h.Stop()
+	server := httptest.NewServer(http.HandlerFunc(h.HandleTrainStream))
+	defer server.Close()
+	url := server.URL + "?train=all-day"
+
+	events, cancel := openStream(t, url, "")
+	var last sseEvent
+	for last.event != feedFollowedTrains+"_update" {
+		last = nextEvent(t, events)
+	}
+	cancel()
+
+	// Past the resume window of a second
+	time.Sleep(2500 * time.Millisecond)
+
+	for _, lastEventID := range []string{last.id, "not-an-id"} {
+		events, cancel := openStream(t, url, lastEventID)
+		failed := nextEvent(t, events)
+		connected := nextEvent(t, events)
+		cancel()
+
+		if failed.event != "resume_failed" || connected.event != "connection" {
+			t.Errorf("Last-Event-ID %q: events %s, %s; want resume_failed, connection", lastEventID, failed.event, connected.event)
+			continue
+		}
+		// The stream goes on with a fresh session
+		var msg struct {
+			SessionID string `json:"sessionId"`
+		}
+		if err := json.Unmarshal([]byte(connected.data), &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.SessionID == "" || strings.HasPrefix(last.id, msg.SessionID+":") || !strings.HasPrefix(connected.id, msg.SessionID+":") {
+			t.Errorf("Last-Event-ID %q: connected as %s (event %s), want a new session", lastEventID, msg.SessionID, connected.id)
+		}
+	}
+}
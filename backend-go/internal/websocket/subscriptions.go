@@ -0,0 +1,354 @@
+// Package websocket - Subscriptions
+// This file contains the per-client subscription state used to filter
+// live updates.
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/swiss-railway/backend-go/internal/models"
+)
+
+// Subscription topic prefixes.
+const (
+	topicTrain   = "train"
+	topicStation = "station"
+	topicRoute   = "route"
+	topicBBox    = "bbox"
+
+	// Departure board of a station (GTFS departures and upstream board)
+	topicDepartures = "departures"
+
+	// Favorite train events, and auto-follow of favorites with autoFollow
+	topicFavorites = "favorites"
+
+	// Train events (arrivals, departures, ...) of the subscribed trains,
+	// stations, routes and area, or of all trains
+	topicEvents = "events"
+)
+
+// BoundingBox is a geographic area in WGS84 coordinates.
+type BoundingBox struct {
+	MinLat float64 `json:"minLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLat float64 `json:"maxLat"`
+	MaxLng float64 `json:"maxLng"`
+}
+
+// valid reports whether the box is well-formed.
+func (b *BoundingBox) valid() bool {
+	return b.MinLat <= b.MaxLat && b.MinLng <= b.MaxLng &&
+		b.MinLat >= -90 && b.MaxLat <= 90 &&
+		b.MinLng >= -180 && b.MaxLng <= 180
+}
+
+// contains reports whether a position lies inside the box.
+func (b *BoundingBox) contains(p *models.Position) bool {
+	if p == nil {
+		return false
+	}
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat &&
+		p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+}
+
+// subscriptionRequest is the payload of subscribe/unsubscribe messages.
+//
+//	{"type": "subscribe", "topics": ["train:1001", "station:8503000"]}
+//	{"type": "subscribe", "topic": "bbox", "bbox": {"minLat": 47.3, ...}}
+//	{"type": "subscribe", "topic": "favorites"}
+//	{"type": "subscribe", "topics": ["events", "station:8503000"]}
+//	{"type": "unsubscribe", "topics": ["route:91-1-A"]}
+//	{"type": "unsubscribe"} // clears all subscriptions
+type subscriptionRequest struct {
+	Topic  string       `json:"topic"`
+	Topics []string     `json:"topics"`
+	BBox   *BoundingBox `json:"bbox"`
+}
+
+// topics returns all topics of the request.
+func (r *subscriptionRequest) topics() []string {
+	topics := r.Topics
+	if r.Topic != "" {
+		topics = append(topics, r.Topic)
+	}
+	if r.BBox != nil && !containsString(topics, topicBBox) {
+		topics = append(topics, topicBBox)
+	}
+	return topics
+}
+
+// SubscriptionState describes a client's subscriptions.
+type SubscriptionState struct {
+	Trains     []string     `json:"trains"`
+	Stations   []string     `json:"stations"`
+	Routes     []string     `json:"routes"`
+	Departures []string     `json:"departures"`
+	Favorites  bool         `json:"favorites"`
+	Events     bool         `json:"events"`
+	BBox       *BoundingBox `json:"bbox,omitempty"`
+}
+
+// Subscriptions holds what a client wants to receive.
+//
+// A client without subscriptions gets the overview (all trains at the hub's
+// update interval). Followed trains (train:{id}) are sent every second;
+// station, route and bbox subscriptions narrow the overview to matching trains.
+// Departure boards (departures:{id}) are sent at the overview interval.
+// The favorites topic adds favorite train events and follows favorites
+// with autoFollow; the events topic adds train events.
+type Subscriptions struct {
+	mu         sync.RWMutex
+	trains     map[string]bool
+	stations   map[string]bool
+	routes     map[string]bool
+	departures map[string]bool
+	favorites  bool
+	events     bool
+	bbox       *BoundingBox
+}
+
+// NewSubscriptions creates an empty subscription set.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{
+		trains:     make(map[string]bool),
+		stations:   make(map[string]bool),
+		routes:     make(map[string]bool),
+		departures: make(map[string]bool),
+	}
+}
+
+// Apply subscribes to (or unsubscribes from) the given topics.
+// The request is validated as a whole before anything changes.
+func (s *Subscriptions) Apply(req *subscriptionRequest, subscribe bool) error {
+	topics := req.topics()
+
+	type change struct {
+		set map[string]bool
+		id  string
+	}
+	var changes []change
+	bboxChanged := false
+	favoritesChanged := false
+	eventsChanged := false
+
+	for _, topic := range topics {
+		kind, id, _ := strings.Cut(topic, ":")
+		id = strings.TrimSpace(id)
+
+		switch kind {
+		case topicTrain, topicStation, topicRoute, topicDepartures:
+			if id == "" {
+				return fmt.Errorf("topic %q needs an ID, e.g. %s:123", topic, kind)
+			}
+			set := map[string]map[string]bool{
+				topicTrain:      s.trains,
+				topicStation:    s.stations,
+				topicRoute:      s.routes,
+				topicDepartures: s.departures,
+			}[kind]
+			changes = append(changes, change{set: set, id: id})
+
+		case topicBBox:
+			if subscribe && (req.BBox == nil || !req.BBox.valid()) {
+				return fmt.Errorf("topic bbox needs a valid bbox {minLat, minLng, maxLat, maxLng}")
+			}
+			bboxChanged = true
+
+		case topicFavorites:
+			if id != "" {
+				return fmt.Errorf("topic favorites takes no ID")
+			}
+			favoritesChanged = true
+
+		case topicEvents:
+			if id != "" {
+				return fmt.Errorf("topic events takes no ID; combine it with train:, station:, route: or bbox to filter")
+			}
+			eventsChanged = true
+
+		default:
+			return fmt.Errorf("unknown topic %q (expected train:, station:, route:, departures:, bbox, favorites or events)", topic)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Unsubscribing without topics clears everything
+	if !subscribe && len(topics) == 0 {
+		s.trains = make(map[string]bool)
+		s.stations = make(map[string]bool)
+		s.routes = make(map[string]bool)
+		s.departures = make(map[string]bool)
+		s.favorites = false
+		s.events = false
+		s.bbox = nil
+		return nil
+	}
+
+	for _, c := range changes {
+		if subscribe {
+			c.set[c.id] = true
+		} else {
+			delete(c.set, c.id)
+		}
+	}
+	if favoritesChanged {
+		s.favorites = subscribe
+	}
+	if eventsChanged {
+		s.events = subscribe
+	}
+	if bboxChanged {
+		if subscribe {
+			box := *req.BBox
+			s.bbox = &box
+		} else {
+			s.bbox = nil
+		}
+	}
+
+	return nil
+}
+
+// IsOverview reports whether the client has no subscriptions at all.
+func (s *Subscriptions) IsOverview() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.trains) == 0 && len(s.departures) == 0 && !s.favorites && !s.events && !s.hasAreaFilterLocked()
+}
+
+// Favorites reports whether the client subscribed to favorite trains.
+func (s *Subscriptions) Favorites() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.favorites
+}
+
+// HasAreaFilter reports whether the client narrowed the overview by
+// station, route or bounding box.
+func (s *Subscriptions) HasAreaFilter() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hasAreaFilterLocked()
+}
+
+func (s *Subscriptions) hasAreaFilterLocked() bool {
+	return len(s.stations) > 0 || len(s.routes) > 0 || s.bbox != nil
+}
+
+// DepartureBoards returns the IDs of stations whose departures are wanted, sorted.
+func (s *Subscriptions) DepartureBoards() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedKeys(s.departures)
+}
+
+// FollowedTrains returns the IDs of followed trains, sorted.
+func (s *Subscriptions) FollowedTrains() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedKeys(s.trains)
+}
+
+// MatchesArea reports whether a train matches a station, route or bbox subscription.
+func (s *Subscriptions) MatchesArea(train *models.Train) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.routes[train.RouteID] {
+		return true
+	}
+	if s.bbox != nil && s.bbox.contains(train.Position) {
+		return true
+	}
+	if len(s.stations) > 0 {
+		if train.CurrentStation != nil && s.stations[train.CurrentStation.ID] {
+			return true
+		}
+		for _, stop := range train.Timetable {
+			if stop.Station != nil && s.stations[stop.Station.ID] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchesEvent reports whether a train event should be sent: the client
+// subscribed to events, and the event concerns a followed train or a
+// subscribed station, route or area (or the client has no such filters).
+func (s *Subscriptions) MatchesEvent(event *models.TrainEvent) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.events {
+		return false
+	}
+	if len(s.trains) == 0 && !s.hasAreaFilterLocked() {
+		return true
+	}
+	if s.trains[event.TrainID] || s.routes[event.RouteID] {
+		return true
+	}
+	if station := event.Station; station != nil {
+		if s.stations[station.ID] {
+			return true
+		}
+		if s.bbox != nil && s.bbox.contains(&models.Position{Lat: station.Coordinate.Y, Lng: station.Coordinate.X}) {
+			return true
+		}
+	}
+	return false
+}
+
+// WantsStation reports whether updates about a station should be sent.
+func (s *Subscriptions) WantsStation(stationID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.trains) == 0 && len(s.departures) == 0 && !s.favorites && !s.events && !s.hasAreaFilterLocked() {
+		return true
+	}
+	return s.stations[stationID] || s.departures[stationID]
+}
+
+// State returns a copy of the subscriptions for acknowledgements.
+func (s *Subscriptions) State() SubscriptionState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := SubscriptionState{
+		Trains:     sortedKeys(s.trains),
+		Stations:   sortedKeys(s.stations),
+		Routes:     sortedKeys(s.routes),
+		Departures: sortedKeys(s.departures),
+		Favorites:  s.favorites,
+		Events:     s.events,
+	}
+	if s.bbox != nil {
+		box := *s.bbox
+		state.BBox = &box
+	}
+	return state
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}